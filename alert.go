@@ -0,0 +1,57 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// ServerErrorEvent describes a request that was answered with a server error (5xx) status
+type ServerErrorEvent struct {
+	// The HTTP method of the request
+	Method string
+	// The path of the route as it was registered, including any parameters
+	Route string
+	// The URL of the request
+	URL string
+	// The HTTP status code of the response
+	Status int
+	// The reason for the error, such as the message of the error returned by an API handle or the value of a recovered
+	// panic. May be empty if the handle wrote the status itself.
+	Error string
+	// The amount of time spent handling the request
+	Elapsed time.Duration
+	// The value of the X-Request-ID header of the request, or a randomly generated ID if the header was not present
+	RequestID string
+}
+
+func (s *Server) reportServerError(route string, r *http.Request, w *responseWriter, elapsed time.Duration) {
+	if s.Options.OnServerError == nil {
+		return
+	}
+
+	requestID := r.Header.Get("X-Request-ID")
+	if requestID == "" {
+		requestID = newRandomID()
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			log.PError("Recovered from panic during server error hook", map[string]interface{}{
+				"error": fmt.Sprintf("%v", p),
+				"route": route,
+				"stack": string(debug.Stack()),
+			})
+		}
+	}()
+	s.Options.OnServerError(ServerErrorEvent{
+		Method:    r.Method,
+		Route:     route,
+		URL:       r.URL.String(),
+		Status:    w.statusCode(),
+		Error:     w.err,
+		Elapsed:   elapsed,
+		RequestID: requestID,
+	})
+}
@@ -0,0 +1,81 @@
+package web_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestOnServerError(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+	events := make(chan web.ServerErrorEvent, 4)
+	server.Options.OnServerError = func(event web.ServerErrorEvent) {
+		events <- event
+	}
+
+	errorPath := randomString(5)
+	panicPath := randomString(5)
+	okPath := randomString(5)
+	server.API.GET("/"+errorPath+"/:id", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return nil, nil, web.ValidationError("database unavailable")
+	}, web.HandleOptions{})
+	server.API.GET("/"+okPath, func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}, web.HandleOptions{})
+	server.HTTP.GET("/"+panicPath, func(w http.ResponseWriter, request web.Request) {
+		panic("oh no")
+	}, web.HandleOptions{})
+
+	// Client errors and successful requests should not fire the hook
+	http.Get(fmt.Sprintf("http://localhost:%d/%s/1", server.ListenPort, errorPath))
+	http.Get(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, okPath))
+
+	server.API.GET("/"+errorPath+"/:id/fail", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return nil, nil, web.CommonErrors.ServiceUnavailable
+	}, web.HandleOptions{})
+	req, _ := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d/%s/1/fail", server.ListenPort, errorPath), nil)
+	req.Header.Set("X-Request-ID", "example")
+	if _, err := http.DefaultClient.Do(req); err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+
+	event := <-events
+	if event.Route != "/"+errorPath+"/:id/fail" {
+		t.Errorf("Unexpected route. Expected '%s' got '%s'", "/"+errorPath+"/:id/fail", event.Route)
+	}
+	if event.Status != 503 {
+		t.Errorf("Unexpected status. Expected %d got %d", 503, event.Status)
+	}
+	if event.Error != web.CommonErrors.ServiceUnavailable.Message {
+		t.Errorf("Unexpected error. Expected '%s' got '%s'", web.CommonErrors.ServiceUnavailable.Message, event.Error)
+	}
+	if event.RequestID != "example" {
+		t.Errorf("Unexpected request ID. Expected '%s' got '%s'", "example", event.RequestID)
+	}
+	if event.Elapsed <= 0 {
+		t.Errorf("Elapsed time not recorded")
+	}
+
+	if _, err := http.Get(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, panicPath)); err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	event = <-events
+	if event.Status != 500 {
+		t.Errorf("Unexpected status. Expected %d got %d", 500, event.Status)
+	}
+	if event.Error != "oh no" {
+		t.Errorf("Unexpected error. Expected '%s' got '%s'", "oh no", event.Error)
+	}
+	if event.RequestID == "" {
+		t.Errorf("No request ID generated")
+	}
+
+	select {
+	case event := <-events:
+		t.Errorf("Unexpected server error event for %s", event.URL)
+	default:
+	}
+}
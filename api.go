@@ -1,12 +1,11 @@
 package web
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"net/http"
 	"runtime/debug"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/ecnepsnai/web/router"
@@ -53,12 +52,20 @@ func (a API) DELETE(path string, handle APIHandle, options HandleOptions) {
 	a.registerAPIEndpoint("DELETE", path, handle, options)
 }
 
+// Unregister removes the handle, and any variants of it, registered for the method and path, even while the server is
+// serving requests. Requests already being handled are not affected. Parameter names are not considered, so a path
+// registered as "/users/:id" can be removed with "/users/:user_id". Returns false if no handle is registered for the
+// method and path.
+func (a API) Unregister(method, path string) bool {
+	return a.server.unregisterRoute(method, path)
+}
+
 func (a API) registerAPIEndpoint(method string, path string, handle APIHandle, options HandleOptions) {
 	log.PDebug("Register API endpoint", map[string]interface{}{
 		"method": method,
 		"path":   path,
 	})
-	a.server.router.Handle(method, path, a.apiPreHandle(handle, options))
+	a.server.registerRoute(method, path, a.server.measure(method, path, options, a.server.dispatch(a.apiPreHandle(handle, options))), options)
 }
 
 func (a API) apiPreHandle(endpointHandle APIHandle, options HandleOptions) router.Handle {
@@ -69,7 +76,15 @@ func (a API) apiPreHandle(endpointHandle APIHandle, options HandleOptions) route
 			}
 		}
 
-		if a.server.isRateLimited(w, request.HTTP) {
+		if a.server.isUnavailable(w, request.HTTP, options) {
+			return
+		}
+
+		if a.server.isBanned(w, request.HTTP) {
+			return
+		}
+
+		if a.server.isRateLimited(w, request.HTTP, options) {
 			return
 		}
 
@@ -83,88 +98,194 @@ func (a API) apiPreHandle(endpointHandle APIHandle, options HandleOptions) route
 					"body_length": length,
 					"max_length":  options.MaxBodyLength,
 				})
-				w.WriteHeader(413)
+				a.server.reportRejected(request.HTTP, RejectedPayloadTooLarge, 413)
+				a.server.writeError(w, CommonErrors.PayloadTooLarge, "")
 				return
 			}
+
+			// The Content-Length header may be missing, such as with chunked requests, so limit what is actually read
+			limitRequestBody(w, request.HTTP, options.MaxBodyLength)
 		}
 
 		if options.AuthenticateMethod != nil {
-			userData := options.AuthenticateMethod(request.HTTP)
+			userData := a.server.authenticate(w, request.HTTP, options)
+			if a.server.isUserRateLimited(w, request.HTTP, userData, options) {
+				return
+			}
 			if isUserdataNil(userData) {
+				a.server.reportRejected(request.HTTP, RejectedUnauthenticated, 401)
 				if options.UnauthorizedMethod == nil {
 					log.PWarn("Rejected request to authenticated API endpoint", map[string]interface{}{
 						"url":         request.HTTP.URL,
 						"method":      request.HTTP.Method,
-						"remote_addr": RealRemoteAddr(request.HTTP),
+						"remote_addr": a.server.realRemoteAddr(request.HTTP),
 					})
 					w.Header().Set("Content-Type", "application/json")
 					w.WriteHeader(http.StatusUnauthorized)
-					json.NewEncoder(w).Encode(Error{401, "Unauthorized"})
+					a.server.jsonEncoder().NewEncoder(w).Encode(a.server.serializeError(&Error{Code: 401, Message: "Unauthorized"}, Error{Code: 401, Message: "Unauthorized"}))
 					return
 				}
 
 				options.UnauthorizedMethod(w, request.HTTP)
 			} else {
-				a.apiPostHandle(endpointHandle, userData, options)(w, request)
+				spanUser(request.HTTP, userData)
+				if !a.server.isForbidden(w, request.HTTP, userData, options) && !selfTestHandled(w, request.HTTP) {
+					a.server.withCache(a.withPayloadEncryption(a.server.withDeduplication(a.apiPostHandle(endpointHandle, userData, options), options), options), userData, options)(w, request)
+				}
 			}
 			return
 		}
-		a.apiPostHandle(endpointHandle, nil, options)(w, request)
+		if a.server.isForbidden(w, request.HTTP, nil, options) || selfTestHandled(w, request.HTTP) {
+			return
+		}
+		a.server.withCache(a.withPayloadEncryption(a.server.withDeduplication(a.apiPostHandle(endpointHandle, nil, options), options), options), nil, options)(w, request)
 	}
 }
 
+type apiResult struct {
+	data interface{}
+	resp *APIResponse
+	err  *Error
+}
+
 func (a API) apiPostHandle(endpointHandle APIHandle, userData interface{}, options HandleOptions) router.Handle {
 	return func(w http.ResponseWriter, r router.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
 		response := JSONResponse{}
+		start := time.Now()
 		request := Request{
 			HTTP:       r.HTTP,
 			Parameters: r.Parameters,
 			UserData:   userData,
+			server:     a.server,
+			options:    options,
+			start:      start,
+			decoder:    a.server.jsonDecoder(),
+			traced:     isTraced(w),
+			writer:     w,
 		}
 
-		start := time.Now()
 		defer func() {
 			if p := recover(); p != nil {
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				stack := debug.Stack()
 				log.PError("Recovered from panic during API handle", map[string]interface{}{
 					"error":  fmt.Sprintf("%v", p),
 					"route":  r.HTTP.URL.Path,
 					"method": r.HTTP.Method,
-					"stack":  string(debug.Stack()),
+					"stack":  string(stack),
 				})
+				a.server.reportPanic(p, stack, request, options)
+				setResponseError(w, fmt.Sprintf("%v", p))
 				w.WriteHeader(500)
-				json.NewEncoder(w).Encode(JSONResponse{Error: CommonErrors.ServerError})
+				a.server.jsonEncoder().NewEncoder(w).Encode(a.server.serializeError(CommonErrors.ServerError, JSONResponse{Error: CommonErrors.ServerError}))
 			}
 		}()
 
-		data, resp, err := endpointHandle(request)
+		traceMark(w, "prehandle")
+		var data interface{}
+		var resp *APIResponse
+		var err *Error
+		if timeout := a.server.handleTimeout(options); timeout > 0 {
+			var cancel context.CancelFunc
+			request.HTTP, cancel = withTimeout(r.HTTP, timeout)
+			defer cancel()
+			result, ok := callWithTimeout(request.HTTP.Context(), func() interface{} {
+				data, resp, err := endpointHandle(request)
+				return apiResult{data, resp, err}
+			}, a.server.abandonHandle(r.HTTP, start, func(value interface{}) {
+				// Drain any stream so that the producer isn't blocked forever
+				if stream, ok := value.(apiResult).data.(JSONStream); ok && stream.Items != nil {
+					for range stream.Items {
+					}
+				}
+			}))
+			if ok {
+				data, resp, err = result.(apiResult).data, result.(apiResult).resp, result.(apiResult).err
+			} else {
+				a.server.logTimeout(w, r.HTTP, timeout)
+				err = a.server.timeoutError()
+			}
+		} else {
+			data, resp, err = endpointHandle(request)
+		}
+		traceMark(w, "handle")
 		if resp != nil {
 			for key, value := range resp.Headers {
 				w.Header().Set(key, value)
 			}
-			for _, cookie := range resp.Cookies {
-				http.SetCookie(w, &cookie)
-			}
+			a.server.setCookies(w, resp.Cookies)
 		}
 
 		elapsed := time.Since(start)
+		stream, isStream := data.(JSONStream)
+		_, noContent := data.(NoContent)
+		noContent = err == nil && (noContent || (resp != nil && resp.Status == http.StatusNoContent))
+		var codec Codec
+		if noContent {
+			w.Header().Del("Content-Type")
+		} else if !isStream || err != nil {
+			var mediaType string
+			if codec, mediaType = a.server.negotiateCodec(r.HTTP); codec != nil {
+				w.Header().Set("Content-Type", mediaType)
+			}
+		}
+		if len(a.server.Options.Codecs) > 0 {
+			w.Header().Add("Vary", "Accept")
+		}
 		if err != nil {
+			setResponseError(w, err.Error())
 			w.WriteHeader(err.Code)
 			response.Error = err
 		} else {
+			if isStream && stream.Format == StreamNDJSON {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+			}
+			if noContent {
+				w.WriteHeader(http.StatusNoContent)
+			} else if resp != nil && resp.Status != 0 {
+				w.WriteHeader(resp.Status)
+			}
 			response.Data = data
+			response.Truncated = request.DeadlineExceeded()
 		}
 		if !options.DontLogRequests {
-			log.PWrite(a.server.Options.RequestLogLevel, "API Request", map[string]interface{}{
-				"remote_addr": RealRemoteAddr(r.HTTP),
-				"method":      r.HTTP.Method,
-				"url":         r.HTTP.URL,
-				"elapsed":     elapsed.String(),
-			})
+			// Logged once the response has been written so the number of bytes written is known
+			defer func() {
+				a.server.logRequest(w, r.HTTP, "API Request", responseStatus(w), elapsed, map[string]interface{}{
+					"remote_addr":    a.server.realRemoteAddr(r.HTTP),
+					"method":         r.HTTP.Method,
+					"url":            r.HTTP.URL,
+					"elapsed":        elapsed.String(),
+					"status":         responseStatus(w),
+					"request_bytes":  requestBytes(r.HTTP),
+					"response_bytes": responseBytes(w),
+				})
+			}()
+		}
+		if noContent {
+			return
+		}
+		if isStream && err == nil {
+			a.writeJSONStream(w, r.HTTP, stream)
+			return
+		}
+		var body interface{} = response
+		var encoder Encoder = a.server.jsonEncoder().NewEncoder(w)
+		if codec != nil {
+			body = data
+			if err != nil {
+				body = a.server.serializeError(err, err)
+			}
+			encoder = codec.NewEncoder(w)
+		} else if err != nil {
+			body = a.server.serializeError(err, response)
 		}
-		if err := json.NewEncoder(w).Encode(response); err != nil {
-			if strings.Contains(err.Error(), "write: broken pipe") {
+		if err := encoder.Encode(body); err != nil {
+			if isClientAbort(err) {
 				return
 			}
 
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"regexp"
@@ -298,6 +299,35 @@ func TestAPILargeBody(t *testing.T) {
 	}
 }
 
+func TestAPILargeChunkedBody(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		params := map[string]string{}
+		if err := request.DecodeJSON(&params); err != nil {
+			return nil, nil, err
+		}
+		return true, nil, nil
+	}
+	options := web.HandleOptions{
+		MaxBodyLength: 10,
+	}
+
+	path := randomString(5)
+	server.API.POST("/"+path, handle, options)
+
+	// Without a known length the request is sent chunked, with no Content-Length header
+	body := io.MultiReader(bytes.NewReader([]byte(`{"key":"` + randomString(50) + `"}`)))
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path), "application/json", body)
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 413 {
+		t.Fatalf("Unexpected HTTP status code. Expected %d got %d", 413, resp.StatusCode)
+	}
+}
+
 func TestAPIValidJSON(t *testing.T) {
 	t.Parallel()
 	server := newServer()
@@ -423,6 +453,184 @@ func TestAPIRateLimit(t *testing.T) {
 	doTest(429)
 }
 
+func TestAPIRateLimitExempt(t *testing.T) {
+	t.Parallel()
+
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}
+
+	doTest := func(server *web.Server, path string, header map[string]string, expectedStatus int) {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path), nil)
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Network error: %s", err.Error())
+		}
+		resp.Body.Close()
+		if resp.StatusCode != expectedStatus {
+			t.Errorf("Unexpected HTTP status code. Expected %d got %d for %s", expectedStatus, resp.StatusCode, path)
+		}
+	}
+
+	// Exempt route
+	server := newServer()
+	server.Options.MaxRequestsPerSecond = 1
+	server.API.GET("/health", handle, web.HandleOptions{DisableRateLimit: true})
+	server.API.GET("/limited", handle, web.HandleOptions{})
+	for i := 0; i < 3; i++ {
+		doTest(server, "health", nil, 200)
+	}
+	doTest(server, "limited", nil, 200)
+	doTest(server, "limited", nil, 429)
+
+	// Exempt network
+	server = newServer()
+	server.Options.MaxRequestsPerSecond = 1
+	server.Options.RateLimitExemptNetworks = []string{"127.0.0.0/8", "::1"}
+	server.API.GET("/limited", handle, web.HandleOptions{})
+	for i := 0; i < 3; i++ {
+		doTest(server, "limited", nil, 200)
+	}
+
+	// Exempt method
+	server = newServer()
+	server.Options.MaxRequestsPerSecond = 1
+	server.Options.RateLimitExempt = func(r *http.Request) bool {
+		return r.Header.Get("X-Admin") == "1"
+	}
+	server.API.GET("/limited", handle, web.HandleOptions{})
+	// Exempt requests must not use up the limit of the client
+	for i := 0; i < 3; i++ {
+		doTest(server, "limited", map[string]string{"X-Admin": "1"}, 200)
+	}
+	doTest(server, "limited", nil, 200)
+	doTest(server, "limited", map[string]string{"X-Admin": "1"}, 200)
+	doTest(server, "limited", nil, 429)
+}
+
+func TestAPIRateLimitExemptConcurrent(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+	server.Options.MaxRequestsPerSecond = 1
+
+	checking := make(chan bool)
+	release := make(chan bool)
+	server.Options.RateLimitExempt = func(r *http.Request) bool {
+		if r.Header.Get("X-Slow") == "" {
+			return false
+		}
+		checking <- true
+		<-release
+		return true
+	}
+	server.API.GET("/limited", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}, web.HandleOptions{})
+
+	request := func(remoteAddr string, slow bool) int {
+		req := httptest.NewRequest("GET", "/limited", nil)
+		req.RemoteAddr = remoteAddr
+		if slow {
+			req.Header.Set("X-Slow", "1")
+		}
+		return server.Do(req).Status
+	}
+
+	slow := make(chan int)
+	go func() {
+		slow <- request("192.168.1.1:1234", true)
+	}()
+	<-checking
+
+	// Other clients must not wait for the RateLimitExempt method of another client
+	other := make(chan int)
+	go func() {
+		other <- request("192.168.1.2:1234", false)
+	}()
+	select {
+	case status := <-other:
+		if status != 200 {
+			t.Errorf("Unexpected HTTP status code. Expected %d got %d", 200, status)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Request blocked by RateLimitExempt method of another client")
+	}
+
+	release <- true
+	if status := <-slow; status != 200 {
+		t.Errorf("Unexpected HTTP status code. Expected %d got %d", 200, status)
+	}
+}
+
+func TestAPIRateLimitCost(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+	server.Options.MaxRequestsPerSecond = 10
+
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}
+	server.API.GET("/search", handle, web.HandleOptions{RateLimitCost: 4})
+	server.API.GET("/ping", handle, web.HandleOptions{})
+
+	// 4 + 4 leaves 2 of the 10 requests in the budget, which is not enough for another search but is for a ping
+	for i, expected := range []int{200, 200, 429} {
+		if response := server.Request("GET", "/search", nil); response.Status != expected {
+			t.Errorf("Unexpected status code for search %d. Expected %d got %d", i+1, expected, response.Status)
+		}
+	}
+	if response := server.Request("GET", "/ping", nil); response.Status != 200 {
+		t.Errorf("Unexpected status code for ping. Expected %d got %d", 200, response.Status)
+	}
+}
+
+func TestAPIRateLimitKey(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+	server.Options.MaxRequestsPerSecond = 2
+	server.Options.RateLimitKey = func(request *http.Request, userData interface{}) (string, int) {
+		if userData == nil {
+			return "", 0
+		}
+		if userData.(string) == "premium" {
+			return "premium", 5
+		}
+		return userData.(string), 0
+	}
+
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}
+	server.API.GET("/", handle, web.HandleOptions{
+		AuthenticateMethod: func(request *http.Request) interface{} {
+			if user := request.Header.Get("X-User"); user != "" {
+				return user
+			}
+			return nil
+		},
+	})
+
+	// All requests come from the same address, but each user has their own limit
+	check := func(user string, expected []int) {
+		for i, expectedStatus := range expected {
+			req := httptest.NewRequest("GET", "/", nil)
+			if user != "" {
+				req.Header.Set("X-User", user)
+			}
+			if response := server.Do(req); response.Status != expectedStatus {
+				t.Errorf("Unexpected status code for '%s' request %d. Expected %d got %d", user, i+1, expectedStatus, response.Status)
+			}
+		}
+	}
+	check("alice", []int{200, 200, 429})
+	check("bob", []int{200, 200, 429})
+	check("premium", []int{200, 200, 200, 200, 200, 429})
+	check("", []int{401, 401, 429})
+}
+
 func TestAPIResponse(t *testing.T) {
 	t.Parallel()
 	server := newServer()
@@ -475,6 +683,43 @@ func TestAPIResponse(t *testing.T) {
 	}
 }
 
+func TestAPINoContent(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	headerName := randomString(6)
+	headerValue := randomString(6)
+	sentinelPath := randomString(5)
+	server.API.DELETE("/"+sentinelPath, func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return web.NoContent{}, &web.APIResponse{Headers: map[string]string{headerName: headerValue}}, nil
+	}, web.HandleOptions{})
+	statusPath := randomString(5)
+	server.API.DELETE("/"+statusPath, func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, &web.APIResponse{Status: 204}, nil
+	}, web.HandleOptions{})
+
+	for _, path := range []string{sentinelPath, statusPath} {
+		req, _ := http.NewRequest("DELETE", fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path), nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Network error: %s", err.Error())
+		}
+		if resp.StatusCode != 204 {
+			t.Errorf("Unexpected HTTP status code. Expected %d got %d", 204, resp.StatusCode)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if len(body) > 0 {
+			t.Errorf("Unexpected body for no content response: %s", body)
+		}
+		if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+			t.Errorf("Unexpected content type for no content response: %s", contentType)
+		}
+		if path == sentinelPath && resp.Header.Get(headerName) != headerValue {
+			t.Errorf("Unexpected HTTP header. Expected %s got %s", headerValue, resp.Header.Get(headerName))
+		}
+	}
+}
+
 func TestAPILogLevel(t *testing.T) {
 	logtic.Log.Reset()
 	logFilePath := path.Join(t.TempDir(), "web.log")
@@ -504,8 +749,8 @@ func TestAPILogLevel(t *testing.T) {
 	http.Get(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path))
 
 	logtic.Log.Close()
-	debugPattern := regexp.MustCompile(`[0-9\-:TZ]+ \[DEBUG\]\[HTTP\] API Request: elapsed='[^']+' method='GET' remote_addr='[^']+' url='[^']+'`)
-	infoPattern := regexp.MustCompile(`[0-9\-:TZ]+ \[INFO\]\[HTTP\] API Request: elapsed='[^']+' method='GET' remote_addr='[^']+' url='[^']+'`)
+	debugPattern := regexp.MustCompile(`[0-9\-:TZ]+ \[DEBUG\]\[HTTP\] API Request: elapsed='[^']+' method='GET' remote_addr='[^']+' request_bytes=[0-9]+ response_bytes=[0-9]+ status=200 url='[^']+'`)
+	infoPattern := regexp.MustCompile(`[0-9\-:TZ]+ \[INFO\]\[HTTP\] API Request: elapsed='[^']+' method='GET' remote_addr='[^']+' request_bytes=[0-9]+ response_bytes=[0-9]+ status=200 url='[^']+'`)
 	f, err := os.OpenFile(logFilePath, os.O_RDONLY, 0644)
 	if err != nil {
 		panic(err)
@@ -566,8 +811,8 @@ func TestAPIHandleNoLog(t *testing.T) {
 	http.Get(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path2))
 
 	logtic.Log.Close()
-	path1Pattern := regexp.MustCompile(`[0-9\-:TZ]+ \[DEBUG\]\[HTTP\] API Request: elapsed='[^']+' method='GET' remote_addr='[^']+' url='/` + path1 + `'`)
-	path2Pattern := regexp.MustCompile(`[0-9\-:TZ]+ \[DEBUG\]\[HTTP\] API Request: elapsed='[^']+' method='GET' remote_addr='[^']+' url='/` + path2 + `'`)
+	path1Pattern := regexp.MustCompile(`[0-9\-:TZ]+ \[DEBUG\]\[HTTP\] API Request: elapsed='[^']+' method='GET' remote_addr='[^']+' request_bytes=[0-9]+ response_bytes=[0-9]+ status=200 url='/` + path1 + `'`)
+	path2Pattern := regexp.MustCompile(`[0-9\-:TZ]+ \[DEBUG\]\[HTTP\] API Request: elapsed='[^']+' method='GET' remote_addr='[^']+' request_bytes=[0-9]+ response_bytes=[0-9]+ status=200 url='/` + path2 + `'`)
 	f, err := os.OpenFile(logFilePath, os.O_RDONLY, 0644)
 	if err != nil {
 		panic(err)
@@ -644,3 +889,99 @@ func TestAPIPreHandle(t *testing.T) {
 		t.Fatalf("Unexpected HTTP status code. Expected %d got %d", 200, resp.StatusCode)
 	}
 }
+
+func TestAPIUnregister(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return request.Parameters["id"], nil, nil
+	}
+	server.API.GET("/plugins/:id", handle, web.HandleOptions{})
+	server.API.GET("/plugins/:id", handle, web.HandleOptions{MatchHeaders: map[string]string{"X-Beta": "1"}})
+
+	if response := server.Request("GET", "/plugins/1", nil); response.Status != 200 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 200, response.Status)
+	}
+
+	if !server.API.Unregister("GET", "/plugins/:plugin_id") {
+		t.Errorf("Route was not unregistered")
+	}
+	if server.API.Unregister("GET", "/plugins/:id") {
+		t.Errorf("Route was unregistered twice")
+	}
+	if response := server.Request("GET", "/plugins/1", nil); response.Status != 404 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 404, response.Status)
+	}
+
+	// Routes can be registered again, and registered while requests are being served
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			server.Request("GET", "/plugins/1", nil)
+		}
+	}()
+	server.API.GET("/plugins/:id", handle, web.HandleOptions{})
+	<-done
+	if response := server.Request("GET", "/plugins/1", nil); response.Status != 200 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 200, response.Status)
+	}
+}
+
+func TestAPIUnregisterDuringRequest(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	started := make(chan bool)
+	release := make(chan bool)
+	server.API.GET("/slow", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		started <- true
+		<-release
+		return true, nil, nil
+	}, web.HandleOptions{})
+	server.API.GET("/fast", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}, web.HandleOptions{})
+	server.API.GET("/other", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}, web.HandleOptions{})
+
+	slow := make(chan int)
+	go func() {
+		slow <- server.Request("GET", "/slow", nil).Status
+	}()
+	<-started
+
+	// Neither unregistering a route nor requests to other routes should wait for the slow request
+	unregistered := make(chan bool)
+	go func() {
+		unregistered <- server.API.Unregister("GET", "/other")
+	}()
+	select {
+	case ok := <-unregistered:
+		if !ok {
+			t.Errorf("Route was not unregistered")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Unregister blocked by request in flight")
+	}
+
+	fast := make(chan int)
+	go func() {
+		fast <- server.Request("GET", "/fast", nil).Status
+	}()
+	select {
+	case status := <-fast:
+		if status != 200 {
+			t.Errorf("Unexpected status code. Expected %d got %d", 200, status)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Request blocked by request in flight")
+	}
+
+	release <- true
+	if status := <-slow; status != 200 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 200, status)
+	}
+}
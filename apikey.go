@@ -0,0 +1,135 @@
+package web
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIKey describes an API key, as returned by the Lookup method of [web.APIKeyOptions]. When a request is
+// authenticated with [web.APIKeyAuth], the user data of the request is the *APIKey.
+type APIKey struct {
+	// The ID of the key, which is the part of the key before the first '.'. The ID is not secret and may be logged.
+	ID string
+	// The hex-encoded SHA-256 hash of the secret of the key, which is the part of the key after the first '.', as
+	// returned by [web.NewAPIKey] or [web.HashAPIKeySecret]. The hash of the secret from the request is compared with
+	// this value in constant time. Only the hash is stored so that the keys can't be recovered from a copy of the store.
+	SecretHash string
+	// The maximum number of requests per second for the key, used by [web.APIKeyRateLimit]. The default value of 0
+	// uses the MaxRequestsPerSecond server option.
+	RateLimit int
+	// Optional time after which the key is no longer valid
+	Expires time.Time
+	// Optional data for the handle, such as the account that owns the key
+	UserData interface{}
+}
+
+// APIKeyOptions describes options for [web.APIKeyAuth]
+type APIKeyOptions struct {
+	// Method that returns the key with the given ID, or nil if there is no such key or it has been revoked. Required.
+	Lookup func(id string) *APIKey
+	// Optional name of the header containing the key, such as "X-API-Key". Defaults to a bearer token in the
+	// 'Authorization' header.
+	Header string
+	// Optional method called each time a request is authenticated with a key, such as to record when the key was last
+	// used. It is called before the handle, so it should return quickly, for example by only saving the time when it
+	// has changed by more than a minute.
+	OnUse func(key *APIKey, request *http.Request)
+}
+
+// APIKeyAuth returns an AuthenticateMethod for API keys in the form "<id>.<secret>", such as those made by
+// [web.NewAPIKey]. The Lookup method is called with the ID from the key, and the hash of the secret is compared with
+// the SecretHash of the returned key in constant time. The user data for authenticated requests is the *APIKey.
+// Requests without a key, or with an unknown, incorrect, or expired key, are not authenticated.
+//
+// To limit each key to its own RateLimit, set the RateLimitKey server option to [web.APIKeyRateLimit].
+//
+// For example:
+//
+//	options := web.HandleOptions{
+//	    AuthenticateMethod: web.APIKeyAuth(web.APIKeyOptions{
+//	        Lookup: func(id string) *web.APIKey {
+//	            return apiKeys.Get(id)
+//	        },
+//	        Header: "X-API-Key",
+//	    }),
+//	}
+func APIKeyAuth(options APIKeyOptions) func(request *http.Request) interface{} {
+	return func(request *http.Request) interface{} {
+		value := ""
+		if options.Header != "" {
+			value = strings.TrimSpace(request.Header.Get(options.Header))
+		} else {
+			scheme, token, ok := strings.Cut(request.Header.Get("Authorization"), " ")
+			if !ok || !strings.EqualFold(scheme, "Bearer") {
+				setAuthChallenge(request, "Bearer")
+				return nil
+			}
+			setAuthChallenge(request, `Bearer error="invalid_token"`)
+			value = strings.TrimSpace(token)
+		}
+
+		id, secret, ok := strings.Cut(value, ".")
+		if !ok || id == "" || secret == "" {
+			return nil
+		}
+		key := options.Lookup(id)
+		if key == nil || !secretMatchesHash(secret, key.SecretHash) {
+			log.PWarn("Rejected invalid API key", map[string]interface{}{
+				"key_id":      id,
+				"url":         request.URL,
+				"remote_addr": authRemoteAddr(request),
+			})
+			return nil
+		}
+		if !key.Expires.IsZero() && time.Now().After(key.Expires) {
+			log.PWarn("Rejected expired API key", map[string]interface{}{
+				"key_id":      id,
+				"url":         request.URL,
+				"remote_addr": authRemoteAddr(request),
+			})
+			return nil
+		}
+
+		if options.OnUse != nil {
+			options.OnUse(key, request)
+		}
+		return key
+	}
+}
+
+// APIKeyRateLimit is a RateLimitKey server option that limits requests authenticated with [web.APIKeyAuth] by the ID
+// of their key, using the RateLimit of the key. All other requests are limited by their IP address.
+func APIKeyRateLimit(request *http.Request, userData interface{}) (string, int) {
+	key, ok := userData.(*APIKey)
+	if !ok || key == nil {
+		return "", 0
+	}
+	return "apikey:" + key.ID, key.RateLimit
+}
+
+// NewAPIKey returns a new random API key with the given ID, and the hash of its secret. The key is given to the client,
+// and the hash is saved for the SecretHash of the [web.APIKey]. The ID must not contain a '.'.
+func NewAPIKey(id string) (key string, secretHash string) {
+	b := make([]byte, 32)
+	rand.Read(b)
+	secret := hex.EncodeToString(b)
+	return id + "." + secret, HashAPIKeySecret(secret)
+}
+
+// HashAPIKeySecret returns the hash of the secret of an API key for the SecretHash of a [web.APIKey], such as to store
+// keys that were not made by [web.NewAPIKey]. The secret is the part of the key after the first '.'.
+func HashAPIKeySecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// secretMatchesHash compares the hash of the secret with the expected hash in constant time
+func secretMatchesHash(secret, expectedHash string) bool {
+	hash := HashAPIKeySecret(secret)
+	return expectedHash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(strings.ToLower(expectedHash))) == 1
+}
@@ -0,0 +1,149 @@
+package web_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/logtic"
+	"github.com/ecnepsnai/web"
+)
+
+func TestAPIKeyAuth(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+	server.Options.RateLimitKey = web.APIKeyRateLimit
+
+	validKey, validHash := web.NewAPIKey("valid")
+	limitedKey, limitedHash := web.NewAPIKey("limited")
+	expiredKey, expiredHash := web.NewAPIKey("expired")
+	keys := map[string]*web.APIKey{
+		"valid":   {ID: "valid", SecretHash: validHash, UserData: "alice"},
+		"limited": {ID: "limited", SecretHash: limitedHash, RateLimit: 1},
+		"expired": {ID: "expired", SecretHash: expiredHash, Expires: time.Now().Add(-time.Minute)},
+	}
+	_, validSecret, _ := strings.Cut(validKey, ".")
+	if strings.Contains(validHash, validSecret) {
+		t.Errorf("Secret of key is not hashed")
+	}
+	used := map[string]int{}
+
+	server.API.GET("/", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return request.UserData.(*web.APIKey).UserData, nil, nil
+	}, web.HandleOptions{
+		AuthenticateMethod: web.APIKeyAuth(web.APIKeyOptions{
+			Lookup: func(id string) *web.APIKey {
+				return keys[id]
+			},
+			OnUse: func(key *web.APIKey, request *http.Request) {
+				used[key.ID]++
+			},
+		}),
+	})
+	server.API.GET("/header", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return request.UserData.(*web.APIKey).ID, nil, nil
+	}, web.HandleOptions{
+		AuthenticateMethod: web.APIKeyAuth(web.APIKeyOptions{
+			Lookup: func(id string) *web.APIKey {
+				return keys[id]
+			},
+			Header: "X-API-Key",
+		}),
+	})
+
+	get := func(key string) web.MockResponse {
+		req := httptest.NewRequest("GET", "/", nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		return server.Do(req)
+	}
+
+	if response := get(validKey); response.Status != 200 || !strings.Contains(string(response.Body), "alice") {
+		t.Errorf("Unexpected response for valid key. Status %d: %s", response.Status, response.Body)
+	}
+	if used["valid"] != 1 {
+		t.Errorf("Unexpected number of uses. Expected %d got %d", 1, used["valid"])
+	}
+	for _, key := range []string{"", "valid", "valid." + validSecret[1:], "unknown." + validSecret, expiredKey} {
+		if response := get(key); response.Status != 401 {
+			t.Errorf("Unexpected status code for key '%s'. Expected %d got %d", key, 401, response.Status)
+		}
+	}
+	if response := get(""); response.Header.Get("WWW-Authenticate") != "Bearer" {
+		t.Errorf("Unexpected challenge '%s'", response.Header.Get("WWW-Authenticate"))
+	}
+
+	// Each key is rate limited separately
+	if response := get(limitedKey); response.Status != 200 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 200, response.Status)
+	}
+	if response := get(limitedKey); response.Status != 429 {
+		t.Errorf("Unexpected status code for rate limited key. Expected %d got %d", 429, response.Status)
+	}
+	if response := get(validKey); response.Status != 200 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 200, response.Status)
+	}
+
+	req := httptest.NewRequest("GET", "/header", nil)
+	req.Header.Set("X-API-Key", validKey)
+	if response := server.Do(req); response.Status != 200 || !strings.Contains(string(response.Body), "valid") {
+		t.Errorf("Unexpected response for key in header. Status %d: %s", response.Status, response.Body)
+	}
+}
+
+func TestAPIKeyAuthRejectionLog(t *testing.T) {
+	logtic.Log.Reset()
+	logFilePath := path.Join(t.TempDir(), "web.log")
+	logtic.Log.FilePath = logFilePath
+
+	stdout := &bytes.Buffer{}
+	logtic.Log.Stdout = stdout
+	logtic.Log.Stderr = stdout
+
+	logtic.Log.Level = logtic.LevelDebug
+	logtic.Log.Open()
+	defer logtic.Log.Close()
+
+	server := web.NewMockServer()
+	server.Options.TrustedProxies = []string{"192.0.2.0/24"}
+	server.API.GET("/", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}, web.HandleOptions{
+		AuthenticateMethod: web.APIKeyAuth(web.APIKeyOptions{
+			Lookup: func(id string) *web.APIKey {
+				return nil
+			},
+		}),
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Real-IP", "203.0.113.5")
+	req.Header.Set("Authorization", "Bearer unknown.secret")
+	if response := server.Do(req); response.Status != 401 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 401, response.Status)
+	}
+
+	logtic.Log.Close()
+	logFileData, err := os.ReadFile(logFilePath)
+	if err != nil {
+		panic(err)
+	}
+	if !strings.Contains(string(logFileData), "remote_addr='203.0.113.5'") {
+		t.Errorf("Rejection not logged with real client address\n----\n%s\n----", logFileData)
+	}
+
+	logtic.Log.Reset()
+	for _, arg := range os.Args {
+		if arg == "-test.v=true" {
+			logtic.Log.Level = logtic.LevelDebug
+			logtic.Log.Open()
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package web
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type authChallengeKey struct{}
+
+type authServerKey struct{}
+
+// authenticate calls the AuthenticateMethod of the handle. If the request is not authenticated and the method provided
+// a challenge, such as those from [web.BasicAuth] and [web.BearerAuth], then the 'WWW-Authenticate' header is set on
+// the response before the unauthorized response is written.
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request, options HandleOptions) interface{} {
+	challenge := new(string)
+	ctx := context.WithValue(r.Context(), authChallengeKey{}, challenge)
+	ctx = context.WithValue(ctx, authServerKey{}, s)
+	userData := options.AuthenticateMethod(r.WithContext(ctx))
+	if isUserdataNil(userData) && *challenge != "" {
+		w.Header().Set("WWW-Authenticate", *challenge)
+	}
+	return userData
+}
+
+// authRemoteAddr returns the address of the client of the request, honoring the TrustedProxies option of the server if
+// the request is being authenticated by a handle
+func authRemoteAddr(r *http.Request) net.IP {
+	if s, ok := r.Context().Value(authServerKey{}).(*Server); ok {
+		return s.realRemoteAddr(r)
+	}
+	return RealRemoteAddr(r)
+}
+
+// setAuthChallenge records the challenge for the unauthorized response of the request, if the request is being
+// authenticated by a handle
+func setAuthChallenge(r *http.Request, challenge string) {
+	if value, ok := r.Context().Value(authChallengeKey{}).(*string); ok {
+		*value = challenge
+	}
+}
+
+// BasicAuth returns an AuthenticateMethod for HTTP basic authentication. Validate is called with the username and
+// password from the 'Authorization' header of the request, and returns the user data for the request or nil if the
+// credentials are not valid. Requests without credentials are not passed to validate. Unauthorized responses include a
+// 'WWW-Authenticate' challenge so that browsers prompt for credentials.
+//
+// For example:
+//
+//	options := web.HandleOptions{
+//	    AuthenticateMethod: web.BasicAuth(func(username, password string) interface{} {
+//	        return users.Login(username, password)
+//	    }),
+//	}
+func BasicAuth(validate func(username, password string) interface{}) func(request *http.Request) interface{} {
+	return func(request *http.Request) interface{} {
+		setAuthChallenge(request, `Basic realm="Restricted", charset="UTF-8"`)
+		username, password, ok := request.BasicAuth()
+		if !ok {
+			return nil
+		}
+		return validate(username, password)
+	}
+}
+
+// BearerAuth returns an AuthenticateMethod for bearer token authentication, such as with API keys or OAuth access
+// tokens. Validate is called with the token from the 'Authorization' header of the request, and returns the user data
+// for the request or nil if the token is not valid. Requests without a token are not passed to validate. Unauthorized
+// responses include a 'WWW-Authenticate' challenge, which indicates if the token was invalid.
+//
+// For example:
+//
+//	options := web.HandleOptions{
+//	    AuthenticateMethod: web.BearerAuth(func(token string) interface{} {
+//	        return apiKeys.Lookup(token)
+//	    }),
+//	}
+func BearerAuth(validate func(token string) interface{}) func(request *http.Request) interface{} {
+	return func(request *http.Request) interface{} {
+		scheme, token, ok := strings.Cut(request.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+			setAuthChallenge(request, "Bearer")
+			return nil
+		}
+		setAuthChallenge(request, `Bearer error="invalid_token"`)
+		return validate(strings.TrimSpace(token))
+	}
+}
+
+// ClientCertificateAuth returns an AuthenticateMethod for TLS client certificate (mutual TLS) authentication. Identify
+// is called with the verified certificate of the client, and returns the user data for the request or nil if the
+// client is not permitted. Requests without a verified certificate are not passed to identify. The server must have
+// the ClientCAs option set.
+//
+// For example:
+//
+//	options := web.HandleOptions{
+//	    AuthenticateMethod: web.ClientCertificateAuth(func(certificate *x509.Certificate) interface{} {
+//	        return devices.Lookup(certificate.Subject.CommonName)
+//	    }),
+//	}
+func ClientCertificateAuth(identify func(certificate *x509.Certificate) interface{}) func(request *http.Request) interface{} {
+	return func(request *http.Request) interface{} {
+		certificate := ClientCertificate(request)
+		if certificate == nil {
+			return nil
+		}
+		return identify(certificate)
+	}
+}
+
+// ClientCertificate returns the certificate the client presented over TLS, if it was verified by the ClientCAs of the
+// server, or nil
+func ClientCertificate(request *http.Request) *x509.Certificate {
+	chain := ClientCertificateChain(request)
+	if len(chain) == 0 {
+		return nil
+	}
+	return chain[0]
+}
+
+// ClientCertificateChain returns the verified chain of the certificate the client presented over TLS, starting with
+// the certificate of the client and ending with the certificate authority, or nil if there is no verified certificate
+func ClientCertificateChain(request *http.Request) []*x509.Certificate {
+	if request == nil || request.TLS == nil || len(request.TLS.VerifiedChains) == 0 {
+		return nil
+	}
+	return request.TLS.VerifiedChains[0]
+}
@@ -0,0 +1,82 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestBasicAuth(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	server.API.GET("/", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return request.UserData, nil, nil
+	}, web.HandleOptions{
+		AuthenticateMethod: web.BasicAuth(func(username, password string) interface{} {
+			if username == "admin" && password == "hunter2" {
+				return username
+			}
+			return nil
+		}),
+	})
+
+	check := func(username, password string, expectedStatus int) {
+		req := httptest.NewRequest("GET", "/", nil)
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+		response := server.Do(req)
+		if response.Status != expectedStatus {
+			t.Errorf("Unexpected status code. Expected %d got %d", expectedStatus, response.Status)
+		}
+		challenge := response.Header.Get("WWW-Authenticate")
+		if expectedStatus == 401 && challenge != `Basic realm="Restricted", charset="UTF-8"` {
+			t.Errorf("Unexpected challenge '%s'", challenge)
+		}
+		if expectedStatus == 200 && challenge != "" {
+			t.Errorf("Unexpected challenge '%s' for authenticated request", challenge)
+		}
+	}
+
+	check("", "", 401)
+	check("admin", "wrong", 401)
+	check("admin", "hunter2", 200)
+}
+
+func TestBearerAuth(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	server.HTTP.GET("/", func(w http.ResponseWriter, r web.Request) {
+		w.Write([]byte(r.UserData.(string)))
+	}, web.HandleOptions{
+		AuthenticateMethod: web.BearerAuth(func(token string) interface{} {
+			if token == "secret" {
+				return "service"
+			}
+			return nil
+		}),
+	})
+
+	check := func(authorization string, expectedStatus int, expectedChallenge string) {
+		req := httptest.NewRequest("GET", "/", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		response := server.Do(req)
+		if response.Status != expectedStatus {
+			t.Errorf("Unexpected status code for '%s'. Expected %d got %d", authorization, expectedStatus, response.Status)
+		}
+		if challenge := response.Header.Get("WWW-Authenticate"); challenge != expectedChallenge {
+			t.Errorf("Unexpected challenge for '%s'. Expected '%s' got '%s'", authorization, expectedChallenge, challenge)
+		}
+	}
+
+	check("", 401, "Bearer")
+	check("Basic abc", 401, "Bearer")
+	check("Bearer wrong", 401, `Bearer error="invalid_token"`)
+	check("bearer secret", 200, "")
+}
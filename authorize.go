@@ -0,0 +1,47 @@
+package web
+
+import (
+	"net/http"
+)
+
+// authorize returns the error from the AuthorizeMethod of the handle, or nil if the request is permitted or there is no
+// AuthorizeMethod. The returned error always has a 403 code.
+func authorize(userData interface{}, r *http.Request, options HandleOptions) *Error {
+	if options.AuthorizeMethod == nil {
+		return nil
+	}
+	return forbiddenError(options.AuthorizeMethod(userData, r))
+}
+
+// forbiddenError returns err with a 403 code, using the default message if err has none, or nil if err is nil
+func forbiddenError(err *Error) *Error {
+	if err == nil {
+		return nil
+	}
+	message := err.Message
+	if message == "" {
+		message = CommonErrors.Forbidden.Message
+	}
+	return &Error{Code: 403, Message: message}
+}
+
+// isForbidden returns true if the request was denied by the AuthorizeMethod of the handle, in which case a
+// "403 Forbidden" JSON error has been written to w.
+func (s *Server) isForbidden(w http.ResponseWriter, r *http.Request, userData interface{}, options HandleOptions) bool {
+	err := authorize(userData, r, options)
+	if err == nil {
+		return false
+	}
+
+	log.PWarn("Rejected unauthorized request", map[string]interface{}{
+		"url":         r.URL,
+		"method":      r.Method,
+		"remote_addr": s.realRemoteAddr(r),
+		"message":     err.Message,
+	})
+	s.reportRejected(r, RejectedForbidden, err.Code)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.Code)
+	s.jsonEncoder().NewEncoder(w).Encode(s.serializeError(err, err))
+	return true
+}
@@ -0,0 +1,74 @@
+package web_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestAuthorizeMethod(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	options := web.HandleOptions{
+		AuthenticateMethod: func(request *http.Request) interface{} {
+			return request.Header.Get("X-Role")
+		},
+		AuthorizeMethod: func(userData interface{}, request *http.Request) *web.Error {
+			if userData.(string) != "admin" {
+				return &web.Error{Message: "Administrators only"}
+			}
+			return nil
+		},
+	}
+
+	server.API.GET("/api/settings", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}, options)
+	server.HTTP.GET("/settings", func(w http.ResponseWriter, r web.Request) {
+		w.Write([]byte("settings"))
+	}, options)
+
+	for _, path := range []string{"/api/settings", "/settings"} {
+		request, _ := http.NewRequest("GET", path, nil)
+		request.Header.Set("X-Role", "user")
+		response := server.Do(request)
+		if response.Status != 403 {
+			t.Errorf("Unexpected status code for '%s'. Expected %d got %d", path, 403, response.Status)
+		}
+		apiErr := web.Error{}
+		if err := response.DecodeJSON(&apiErr); err != nil {
+			t.Fatalf("Error decoding response: %s", err.Error())
+		}
+		if apiErr.Code != 403 || apiErr.Message != "Administrators only" {
+			t.Errorf("Unexpected error. Expected %d '%s' got %d '%s'", 403, "Administrators only", apiErr.Code, apiErr.Message)
+		}
+
+		request, _ = http.NewRequest("GET", path, nil)
+		request.Header.Set("X-Role", "admin")
+		if response := server.Do(request); response.Status != 200 {
+			t.Errorf("Unexpected status code for '%s'. Expected %d got %d", path, 200, response.Status)
+		}
+	}
+}
+
+func TestAuthorizeMethodWithoutAuthentication(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	server.API.GET("/", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}, web.HandleOptions{
+		AuthorizeMethod: func(userData interface{}, request *http.Request) *web.Error {
+			if userData != nil {
+				t.Errorf("Unexpected user data %v", userData)
+			}
+			return web.CommonErrors.Forbidden
+		},
+	})
+
+	if response := server.Request("GET", "/", nil); response.Status != 403 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 403, response.Status)
+	}
+}
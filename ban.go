@@ -0,0 +1,120 @@
+package web
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ecnepsnai/logtic"
+	"github.com/ecnepsnai/web/router"
+)
+
+const defaultHoneypotBanDuration = 24 * time.Hour
+
+var honeypotLog = logtic.Log.Connect("HTTP Honeypot")
+
+// Ban blocks all requests from the given IP address to any registered handle for duration. Requests from banned
+// addresses receive a "403 Forbidden" response. Banning an address that is already banned replaces its duration.
+// Expired bans are removed whenever an address is banned.
+func (s *Server) Ban(ip net.IP, duration time.Duration) {
+	log.PWarn("Banning address", map[string]interface{}{
+		"remote_addr": ip,
+		"duration":    duration.String(),
+	})
+	s.banLock.Lock()
+	defer s.banLock.Unlock()
+	s.pruneBans()
+	s.bans[ip.String()] = time.Now().Add(duration)
+}
+
+// BannedAddresses returns the expiry of the ban of each address that is currently banned
+func (s *Server) BannedAddresses() map[string]time.Time {
+	s.banLock.Lock()
+	defer s.banLock.Unlock()
+	s.pruneBans()
+	bans := make(map[string]time.Time, len(s.bans))
+	for address, expires := range s.bans {
+		bans[address] = expires
+	}
+	return bans
+}
+
+// pruneBans removes bans that have expired, so that bans of addresses that are never seen again don't accumulate. The
+// lock must be held.
+func (s *Server) pruneBans() {
+	now := time.Now()
+	for address, expires := range s.bans {
+		if now.After(expires) {
+			delete(s.bans, address)
+		}
+	}
+}
+
+// Unban removes the ban for the given IP address. Does nothing if the address is not banned.
+func (s *Server) Unban(ip net.IP) {
+	s.banLock.Lock()
+	defer s.banLock.Unlock()
+	delete(s.bans, ip.String())
+}
+
+// IsBanned returns true if the given IP address is currently banned.
+func (s *Server) IsBanned(ip net.IP) bool {
+	s.banLock.Lock()
+	defer s.banLock.Unlock()
+	expires, ok := s.bans[ip.String()]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expires) {
+		delete(s.bans, ip.String())
+		return false
+	}
+	return true
+}
+
+// isBanned writes a 403 response and returns true if the client of the request is banned
+func (s *Server) isBanned(w http.ResponseWriter, r *http.Request) bool {
+	if isSynthetic(r) || !s.IsBanned(s.realRemoteAddr(r)) {
+		return false
+	}
+	log.PWrite(s.Options.RequestLogLevel, "HTTP Request", map[string]interface{}{
+		"remote_addr": s.realRemoteAddr(r),
+		"method":      r.Method,
+		"url":         r.URL,
+		"elapsed":     time.Duration(0).String(),
+		"status":      403,
+	})
+	s.reportRejected(r, RejectedBanned, 403)
+	s.writeError(w, CommonErrors.Forbidden, "Forbidden")
+	return true
+}
+
+// Honeypot registers decoy handles for all methods at each of the given paths, such as "/wp-login.php", that no real
+// client of the application would request. Requests to a honeypot receive a "404 Not Found" response, are logged to a
+// separate "HTTP Honeypot" log source, and the client is banned for the HoneypotBanDuration of the server. Clients in
+// the RateLimitExemptNetworks of the server are logged but not banned.
+func (s *Server) Honeypot(paths ...string) {
+	for _, path := range paths {
+		for _, method := range []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"} {
+			s.router.Handle(method, path, s.honeypotHandle)
+		}
+	}
+}
+
+func (s *Server) honeypotHandle(w http.ResponseWriter, r router.Request) {
+	ip := s.realRemoteAddr(r.HTTP)
+	honeypotLog.PWarn("Honeypot request", map[string]interface{}{
+		"remote_addr": ip,
+		"method":      r.HTTP.Method,
+		"url":         r.HTTP.URL,
+		"user_agent":  r.HTTP.UserAgent(),
+	})
+	if !networksContain(s.exemptNetworks.get(s.runtimeConfig().RateLimitExemptNetworks), ip) {
+		duration := s.Options.HoneypotBanDuration
+		if duration <= 0 {
+			duration = defaultHoneypotBanDuration
+		}
+		s.Ban(ip, duration)
+	}
+	s.writeError(w, CommonErrors.NotFound, "Not found")
+}
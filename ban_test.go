@@ -0,0 +1,82 @@
+package web_test
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestHoneypot(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+	server.Honeypot("/wp-login.php", "/.env")
+	server.API.GET("/data", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}, web.HandleOptions{})
+
+	doTest := func(method, path string, expectedStatus int) {
+		req, _ := http.NewRequest(method, fmt.Sprintf("http://127.0.0.1:%d/%s", server.ListenPort, path), nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Network error: %s", err.Error())
+		}
+		resp.Body.Close()
+		if resp.StatusCode != expectedStatus {
+			t.Errorf("Unexpected status code for %s %s. Expected %d got %d", method, path, expectedStatus, resp.StatusCode)
+		}
+	}
+
+	doTest("GET", "data", 200)
+	doTest("POST", "wp-login.php", 404)
+	if !server.IsBanned(net.ParseIP("127.0.0.1")) {
+		t.Errorf("Client was not banned after requesting honeypot")
+	}
+	doTest("GET", "data", 403)
+
+	server.Unban(net.ParseIP("127.0.0.1"))
+	doTest("GET", "data", 200)
+}
+
+func TestBanExpires(t *testing.T) {
+	t.Parallel()
+	server := web.New("127.0.0.1:0")
+
+	ip := net.ParseIP("192.0.2.1")
+	server.Ban(ip, 20*time.Millisecond)
+	if !server.IsBanned(ip) {
+		t.Errorf("Address was not banned")
+	}
+	if server.IsBanned(net.ParseIP("192.0.2.2")) {
+		t.Errorf("Unexpected address was banned")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if server.IsBanned(ip) {
+		t.Errorf("Ban did not expire")
+	}
+}
+
+func TestBanPrune(t *testing.T) {
+	t.Parallel()
+	server := web.New("127.0.0.1:0")
+
+	for i := 1; i <= 10; i++ {
+		server.Ban(net.ParseIP(fmt.Sprintf("192.0.2.%d", i)), 10*time.Millisecond)
+	}
+	if bans := server.BannedAddresses(); len(bans) != 10 {
+		t.Fatalf("Unexpected number of bans. Expected %d got %d", 10, len(bans))
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	server.Ban(net.ParseIP("198.51.100.1"), time.Hour)
+	bans := server.BannedAddresses()
+	if len(bans) != 1 {
+		t.Fatalf("Unexpected number of bans. Expected %d got %d", 1, len(bans))
+	}
+	if _, ok := bans["198.51.100.1"]; !ok {
+		t.Errorf("Active ban was removed")
+	}
+}
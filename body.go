@@ -0,0 +1,51 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// ErrBodyReadTimeout is returned when reading from the reader returned by [web.Request.ReadBodyWithin] after its
+// deadline has passed
+var ErrBodyReadTimeout = errors.New("timed out reading request body")
+
+// ReadBodyWithin returns a reader for the body of the request that must be read within d, independent of any handle
+// or server timeout. Once d has passed, reads return [web.ErrBodyReadTimeout], including reads that are blocked waiting
+// for a client that has stopped sending data. Handles parsing streamed uploads may use this to bound how long a stalled
+// client can hold the request.
+//
+// The deadline is applied to the connection of the request, and is cleared before the next request on the connection.
+// When the connection does not support deadlines, such as with mock requests, the deadline is only checked before each
+// read.
+//
+// A body decoded with [web.Request.Decode] from this reader returns a 408 error once the deadline has passed.
+func (r Request) ReadBodyWithin(d time.Duration) io.Reader {
+	deadline := time.Now().Add(d)
+	if r.writer != nil {
+		if err := http.NewResponseController(r.writer).SetReadDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			log.PWarn("Error setting read deadline for request body", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}
+	return &deadlineReader{body: r.HTTP.Body, deadline: deadline}
+}
+
+type deadlineReader struct {
+	body     io.Reader
+	deadline time.Time
+}
+
+func (r *deadlineReader) Read(p []byte) (int, error) {
+	if !time.Now().Before(r.deadline) {
+		return 0, ErrBodyReadTimeout
+	}
+	n, err := r.body.Read(p)
+	if err != nil && errors.Is(err, os.ErrDeadlineExceeded) {
+		err = ErrBodyReadTimeout
+	}
+	return n, err
+}
@@ -0,0 +1,153 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+const defaultResponseBufferThreshold = 1 << 20
+
+// ResponseBuffer buffers the body of a response in memory, up to a threshold, after which the buffer is moved to a
+// temporary file. This bounds the memory used by handles that must buffer their entire response before it is sent,
+// such as to set the ETag and Content-Length headers of a large export.
+//
+// Data is appended with Write, and read with Read and Seek from the start of the buffer. A ResponseBuffer implements
+// [io.ReadSeekCloser], so it can be passed to [web.AttachmentResponse] or used as the Reader of a [web.HTTPResponse],
+// which closes it once the response has been written. Closing the buffer removes any temporary file.
+type ResponseBuffer struct {
+	// The maximum number of bytes kept in memory before the buffer is moved to a temporary file. Defaults to 1MiB.
+	Threshold int64
+	// The directory for the temporary file. Defaults to the default directory for temporary files.
+	Dir string
+
+	memory []byte
+	file   *os.File
+	size   int64
+	offset int64
+	closed bool
+}
+
+// NewResponseBuffer returns a new, empty, response buffer that keeps at most threshold bytes in memory. A threshold of
+// 0 uses the default of 1MiB.
+func NewResponseBuffer(threshold int64) *ResponseBuffer {
+	return &ResponseBuffer{Threshold: threshold}
+}
+
+// Write appends p to the end of the buffer, moving the buffer to a temporary file if it would exceed the threshold
+func (b *ResponseBuffer) Write(p []byte) (int, error) {
+	if b.closed {
+		return 0, os.ErrClosed
+	}
+
+	if b.file == nil {
+		threshold := b.Threshold
+		if threshold <= 0 {
+			threshold = defaultResponseBufferThreshold
+		}
+		if b.size+int64(len(p)) <= threshold {
+			b.memory = append(b.memory, p...)
+			b.size += int64(len(p))
+			return len(p), nil
+		}
+		if err := b.spill(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := b.file.WriteAt(p, b.size)
+	b.size += int64(n)
+	return n, err
+}
+
+// spill moves the data buffered in memory to a new temporary file
+func (b *ResponseBuffer) spill() error {
+	file, err := os.CreateTemp(b.Dir, "response-*")
+	if err != nil {
+		log.PError("Error creating temporary file for response buffer", map[string]interface{}{
+			"dir":   b.Dir,
+			"error": err.Error(),
+		})
+		return err
+	}
+	if _, err := file.Write(b.memory); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	log.PDebug("Response buffer moved to temporary file", map[string]interface{}{
+		"path": file.Name(),
+		"size": b.size,
+	})
+	b.file = file
+	b.memory = nil
+	return nil
+}
+
+// Read reads from the current position of the buffer
+func (b *ResponseBuffer) Read(p []byte) (int, error) {
+	if b.closed {
+		return 0, os.ErrClosed
+	}
+	if b.offset >= b.size {
+		return 0, io.EOF
+	}
+
+	var n int
+	var err error
+	if b.file == nil {
+		n = copy(p, b.memory[b.offset:])
+	} else {
+		if remaining := b.size - b.offset; int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+		n, err = b.file.ReadAt(p, b.offset)
+		if err == io.EOF && n > 0 {
+			err = nil
+		}
+	}
+	b.offset += int64(n)
+	return n, err
+}
+
+// Seek sets the position for the next Read, see [io.Seeker]. Seeking does not affect where data is written.
+func (b *ResponseBuffer) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += b.offset
+	case io.SeekEnd:
+		offset += b.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	b.offset = offset
+	return offset, nil
+}
+
+// Len returns the number of bytes written to the buffer
+func (b *ResponseBuffer) Len() int64 {
+	return b.size
+}
+
+// OnDisk returns true if the buffer has been moved to a temporary file
+func (b *ResponseBuffer) OnDisk() bool {
+	return b.file != nil
+}
+
+// Close discards the data of the buffer and removes any temporary file
+func (b *ResponseBuffer) Close() error {
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	b.memory = nil
+	if b.file == nil {
+		return nil
+	}
+	b.file.Close()
+	return os.Remove(b.file.Name())
+}
@@ -0,0 +1,85 @@
+package web_test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestResponseBuffer(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	buffer := web.NewResponseBuffer(10)
+	buffer.Dir = dir
+
+	buffer.Write([]byte("0123456789"))
+	if buffer.OnDisk() {
+		t.Errorf("Buffer moved to disk before exceeding threshold")
+	}
+	buffer.Write([]byte("abcdef"))
+	if !buffer.OnDisk() {
+		t.Fatalf("Buffer not moved to disk after exceeding threshold")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Unexpected number of temporary files. Expected %d got %d", 1, len(entries))
+	}
+	if buffer.Len() != 16 {
+		t.Errorf("Unexpected length. Expected %d got %d", 16, buffer.Len())
+	}
+
+	data, err := io.ReadAll(buffer)
+	if err != nil {
+		t.Fatalf("Error reading buffer: %s", err.Error())
+	}
+	if string(data) != "0123456789abcdef" {
+		t.Errorf("Unexpected data. Expected '%s' got '%s'", "0123456789abcdef", data)
+	}
+	buffer.Seek(-4, io.SeekEnd)
+	if data, _ := io.ReadAll(buffer); string(data) != "cdef" {
+		t.Errorf("Unexpected data after seek. Expected '%s' got '%s'", "cdef", data)
+	}
+
+	if err := buffer.Close(); err != nil {
+		t.Fatalf("Error closing buffer: %s", err.Error())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Temporary file not removed after closing buffer")
+	}
+}
+
+func TestResponseBufferAttachment(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	dir := t.TempDir()
+	server.HTTPEasy.GET("/export", func(request web.Request) web.HTTPResponse {
+		buffer := web.NewResponseBuffer(64)
+		buffer.Dir = dir
+		for i := 0; i < 100; i++ {
+			fmt.Fprintf(buffer, "%d,row\n", i)
+		}
+		return web.AttachmentResponse(buffer, "export.csv")
+	}, web.HandleOptions{})
+
+	response := server.Request("GET", "/export", nil)
+	if response.Status != 200 {
+		t.Fatalf("Unexpected status code. Expected %d got %d", 200, response.Status)
+	}
+	if lines := strings.Count(string(response.Body), "\n"); lines != 100 {
+		t.Errorf("Unexpected number of lines. Expected %d got %d", 100, lines)
+	}
+	if response.Header.Get("ETag") == "" {
+		t.Errorf("Missing ETag header")
+	}
+	if length := response.Header.Get("Content-Length"); length != fmt.Sprintf("%d", len(response.Body)) {
+		t.Errorf("Unexpected Content-Length header. Expected '%d' got '%s'", len(response.Body), length)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Temporary file not removed after response was written")
+	}
+}
@@ -0,0 +1,379 @@
+package web
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ecnepsnai/web/router"
+)
+
+const defaultCacheMaxBodySize = 1 << 20
+
+// CacheOptions describes options for caching the responses of a handle
+type CacheOptions struct {
+	// The amount of time to cache responses for. Required.
+	TTL time.Duration
+	// Optional method that returns the key identifying the user for a request, used to cache responses separately for
+	// each user. Defaults to formatting the user data from the AuthenticateMethod with fmt, so user data that is a
+	// pointer should provide this method.
+	UserKey func(userData interface{}) string
+	// The maximum size in bytes of a response body that will be cached. Defaults to 1MiB.
+	MaxBodySize int
+}
+
+// CachedResponse describes a response saved in a [web.CacheStore]
+type CachedResponse struct {
+	Status  int
+	Header  http.Header
+	Body    []byte
+	Stored  time.Time
+	Expires time.Time
+}
+
+// CacheStore describes an interface for storing cached responses. Keys begin with the path of the request.
+type CacheStore interface {
+	// GetResponse will return the response with the given key, or nil if no response exists or it has expired.
+	GetResponse(key string) (*CachedResponse, error)
+	// SetResponse will save the response, replacing any existing response with the same key.
+	SetResponse(key string, response CachedResponse) error
+	// PurgeResponses will remove all responses with keys that begin with prefix.
+	PurgeResponses(prefix string) error
+}
+
+// MemoryCacheStore is a [web.CacheStore] that keeps responses in memory. Once full, the least recently used response
+// is removed.
+type MemoryCacheStore struct {
+	// The maximum number of responses to keep. Defaults to 1000.
+	MaxEntries int
+
+	entries map[string]*list.Element
+	order   *list.List
+	lock    *sync.Mutex
+}
+
+type memoryCacheEntry struct {
+	key      string
+	response CachedResponse
+}
+
+// NewMemoryCacheStore will return a new, empty, in-memory cache store
+func NewMemoryCacheStore() *MemoryCacheStore {
+	return &MemoryCacheStore{
+		MaxEntries: 1000,
+		entries:    map[string]*list.Element{},
+		order:      list.New(),
+		lock:       &sync.Mutex{},
+	}
+}
+
+// GetResponse will return the response with the given key, or nil if no response exists or it has expired.
+func (s *MemoryCacheStore) GetResponse(key string) (*CachedResponse, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	entry := element.Value.(*memoryCacheEntry)
+	if time.Now().After(entry.response.Expires) {
+		s.order.Remove(element)
+		delete(s.entries, key)
+		return nil, nil
+	}
+	s.order.MoveToFront(element)
+	response := entry.response
+	return &response, nil
+}
+
+// SetResponse will save the response, replacing any existing response with the same key.
+func (s *MemoryCacheStore) SetResponse(key string, response CachedResponse) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		element.Value.(*memoryCacheEntry).response = response
+		s.order.MoveToFront(element)
+		return nil
+	}
+	s.entries[key] = s.order.PushFront(&memoryCacheEntry{key, response})
+	for s.MaxEntries > 0 && s.order.Len() > s.MaxEntries {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryCacheEntry).key)
+	}
+	return nil
+}
+
+// PurgeResponses will remove all responses with keys that begin with prefix.
+func (s *MemoryCacheStore) PurgeResponses(prefix string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for key, element := range s.entries {
+		if strings.HasPrefix(key, prefix) {
+			s.order.Remove(element)
+			delete(s.entries, key)
+		}
+	}
+	return nil
+}
+
+func (s *Server) cacheStore() CacheStore {
+	if s.Options.CacheStore != nil {
+		return s.Options.CacheStore
+	}
+	return s.cache
+}
+
+// InvalidateCache removes all cached responses for the given request path, such as "/users/1", for every method, query
+// and user.
+func (s *Server) InvalidateCache(path string) error {
+	return s.cacheStore().PurgeResponses(path + "\x00")
+}
+
+// ClearCache removes all cached responses.
+func (s *Server) ClearCache() error {
+	return s.cacheStore().PurgeResponses("")
+}
+
+// WarmSpec describes a request made by [web.Server.WarmCache] to prime the response cache
+type WarmSpec struct {
+	// The path of the request, which may include a query, such as "/users?page=1". Required.
+	Path string
+	// The HTTP method of the request, either GET or HEAD. Defaults to GET.
+	Method string
+	// Optional headers to include in the request, such as to authenticate as a user or to match a variant of the route.
+	Headers map[string]string
+}
+
+type cacheWarmKey struct{}
+
+// cacheWarm records if the response to a request made by WarmCache was saved in the cache
+type cacheWarm struct {
+	stored bool
+}
+
+// cacheWarming returns the state of the request if it was made by [web.Server.WarmCache], otherwise nil
+func cacheWarming(r *http.Request) *cacheWarm {
+	warm, _ := r.Context().Value(cacheWarmKey{}).(*cacheWarm)
+	return warm
+}
+
+// WarmCache primes the response cache by sending a synthetic request for each spec through the router and the
+// pipeline of the route, such as after the server starts to avoid slow responses while the cache is empty. Any cached
+// response for a spec is replaced. Requests are not rate limited and are not included in the metrics of the server.
+//
+// Returns an error describing every spec that was not cached, such as if the route has no cache options or the handle
+// did not respond with a 200 status, or nil.
+func (s *Server) WarmCache(specs []WarmSpec) error {
+	failures := []string{}
+	for _, spec := range specs {
+		method := spec.Method
+		if method == "" {
+			method = "GET"
+		}
+		if err := s.warmCacheSpec(method, spec); err != nil {
+			log.PError("Error warming cache", map[string]interface{}{
+				"method": method,
+				"path":   spec.Path,
+				"error":  err.Error(),
+			})
+			failures = append(failures, fmt.Sprintf("%s %s: %s", method, spec.Path, err.Error()))
+			continue
+		}
+		log.PDebug("Warmed cache", map[string]interface{}{
+			"method": method,
+			"path":   spec.Path,
+		})
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%d of %d requests were not cached: %s", len(failures), len(specs), strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// WarmCacheEvery calls [web.Server.WarmCache] with the specs immediately and then at every interval, such as to keep
+// responses cached when the interval is shorter than their TTL. Errors are logged. Call the returned method to stop.
+func (s *Server) WarmCacheEvery(specs []WarmSpec, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			s.WarmCache(specs)
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	once := &sync.Once{}
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+func (s *Server) warmCacheSpec(method string, spec WarmSpec) error {
+	if method != "GET" && method != "HEAD" {
+		return fmt.Errorf("only GET and HEAD requests are cached")
+	}
+	if !strings.HasPrefix(spec.Path, "/") {
+		return fmt.Errorf("path must begin with /")
+	}
+
+	warm := &cacheWarm{}
+	r := httptest.NewRequest(method, spec.Path, nil)
+	r = r.WithContext(context.WithValue(r.Context(), cacheWarmKey{}, warm))
+	for name, value := range spec.Headers {
+		r.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, r)
+
+	if !warm.stored {
+		if w.Code != http.StatusOK {
+			return fmt.Errorf("response was not cached (status %d)", w.Code)
+		}
+		return fmt.Errorf("response was not cached")
+	}
+	return nil
+}
+
+func cacheKey(r *http.Request, userData interface{}, options *CacheOptions) string {
+	user := ""
+	if userData != nil {
+		if options.UserKey != nil {
+			user = options.UserKey(userData)
+		} else {
+			user = fmt.Sprintf("%v", userData)
+		}
+	}
+	// htmx requests are often answered with a fragment of the page, so they are cached separately
+	return strings.Join([]string{r.URL.Path, r.Method, r.URL.Query().Encode(), user, r.Header.Get("HX-Request")}, "\x00")
+}
+
+// responseCapture records the body of a response to be cached
+type responseCapture struct {
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+	// The amount of time to cache the response for, which is also advertised to the client
+	maxAge time.Duration
+	// If the 'Cache-Control' header should not be added to the response
+	hideMaxAge bool
+	// If the response must not be cached
+	skip bool
+}
+
+func (c *responseCapture) write(p []byte) {
+	if c.overflow {
+		return
+	}
+	if c.body.Len()+len(p) > c.limit {
+		c.overflow = true
+		c.body.Reset()
+		return
+	}
+	c.body.Write(p)
+}
+
+// withCache wraps the handle to serve cached responses for GET and HEAD requests, if the handle has cache options
+func (s *Server) withCache(handle router.Handle, userData interface{}, options HandleOptions) router.Handle {
+	if options.Cache == nil || options.Cache.TTL <= 0 {
+		return handle
+	}
+
+	return func(w http.ResponseWriter, r router.Request) {
+		writer, ok := w.(*responseWriter)
+		if !ok || (r.HTTP.Method != "GET" && r.HTTP.Method != "HEAD") || r.HTTP.Header.Get("Range") != "" {
+			handle(w, r)
+			return
+		}
+
+		key := cacheKey(r.HTTP, userData, options.Cache)
+		if len(options.MatchHeaders) > 0 || options.Variant != "" {
+			// Variants of a route are cached separately from each other
+			key += "\x00" + matchHeadersKey(options.MatchHeaders) + "\x00" + options.Variant
+		}
+		if _, mediaType := s.negotiateCodec(r.HTTP); mediaType != "" {
+			// Responses encoded with a codec are cached separately from JSON responses
+			key += "\x00" + mediaType
+		}
+		warm := cacheWarming(r.HTTP)
+		if warm == nil {
+			cached, err := s.cacheStore().GetResponse(key)
+			if err != nil {
+				log.PError("Error reading cached response", map[string]interface{}{
+					"url":   r.HTTP.URL,
+					"error": err.Error(),
+				})
+			}
+			if cached != nil {
+				s.writeCachedResponse(writer, r.HTTP, cached, options)
+				return
+			}
+		}
+
+		maxBodySize := options.Cache.MaxBodySize
+		if maxBodySize <= 0 {
+			maxBodySize = defaultCacheMaxBodySize
+		}
+		writer.capture = &responseCapture{limit: maxBodySize, maxAge: options.Cache.TTL}
+		handle(writer, r)
+		capture := writer.capture
+		writer.capture = nil
+		if writer.status != http.StatusOK || capture.overflow || capture.skip || writer.Header().Get("Set-Cookie") != "" {
+			return
+		}
+
+		now := time.Now()
+		response := CachedResponse{
+			Status:  writer.status,
+			Header:  writer.Header().Clone(),
+			Body:    capture.body.Bytes(),
+			Stored:  now,
+			Expires: now.Add(capture.maxAge),
+		}
+		response.Header.Del("Server-Timing")
+		if err := s.cacheStore().SetResponse(key, response); err != nil {
+			log.PError("Error saving cached response", map[string]interface{}{
+				"url":   r.HTTP.URL,
+				"error": err.Error(),
+			})
+		} else if warm != nil {
+			warm.stored = true
+		}
+	}
+}
+
+func (s *Server) writeCachedResponse(w http.ResponseWriter, r *http.Request, cached *CachedResponse, options HandleOptions) {
+	for key, values := range cached.Header {
+		w.Header()[key] = values
+	}
+	age := time.Since(cached.Stored)
+	w.Header().Set("Age", fmt.Sprintf("%d", int(age.Seconds())))
+	if strings.HasPrefix(w.Header().Get("Cache-Control"), "max-age=") {
+		w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(time.Until(cached.Expires).Seconds())))
+	}
+	w.WriteHeader(cached.Status)
+	w.Write(cached.Body)
+
+	if !options.DontLogRequests {
+		s.logRequest(w, r, "Cached Request", cached.Status, 0, map[string]interface{}{
+			"remote_addr": s.realRemoteAddr(r),
+			"method":      r.Method,
+			"url":         r.URL,
+			"age":         age.String(),
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package web_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestCacheAPI(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	var calls int32
+	path := randomString(5)
+	server.API.GET("/"+path, func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return atomic.AddInt32(&calls, 1), nil, nil
+	}, web.HandleOptions{
+		Cache: &web.CacheOptions{TTL: time.Minute},
+	})
+
+	get := func(query string) (string, *http.Response) {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/%s%s", server.ListenPort, path, query))
+		if err != nil {
+			t.Fatalf("Network error: %s", err.Error())
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return strings.TrimSpace(string(body)), resp
+	}
+
+	first, resp := get("")
+	if first != `{"data":1}` {
+		t.Errorf("Unexpected body. Expected '%s' got '%s'", `{"data":1}`, first)
+	}
+	if cacheControl := resp.Header.Get("Cache-Control"); cacheControl != "max-age=60" {
+		t.Errorf("Unexpected Cache-Control header. Expected '%s' got '%s'", "max-age=60", cacheControl)
+	}
+
+	second, resp := get("")
+	if second != first {
+		t.Errorf("Response was not cached. Expected '%s' got '%s'", first, second)
+	}
+	if resp.Header.Get("Age") == "" {
+		t.Errorf("Cached response missing Age header")
+	}
+	if resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected content type for cached response: '%s'", resp.Header.Get("Content-Type"))
+	}
+
+	// Different query is cached separately
+	if body, _ := get("?page=2"); body != `{"data":2}` {
+		t.Errorf("Unexpected body. Expected '%s' got '%s'", `{"data":2}`, body)
+	}
+
+	if err := server.InvalidateCache("/" + path); err != nil {
+		t.Fatalf("Error invalidating cache: %s", err.Error())
+	}
+	if body, _ := get(""); body != `{"data":3}` {
+		t.Errorf("Unexpected body after invalidation. Expected '%s' got '%s'", `{"data":3}`, body)
+	}
+}
+
+func TestCacheHTTPEasyUser(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	var calls int32
+	path := randomString(5)
+	server.HTTPEasy.GET("/"+path, func(request web.Request) web.HTTPResponse {
+		body := fmt.Sprintf("%s-%d", request.UserData, atomic.AddInt32(&calls, 1))
+		return web.HTTPResponse{
+			Reader:      io.NopCloser(strings.NewReader(body)),
+			ContentType: "text/plain",
+		}
+	}, web.HandleOptions{
+		AuthenticateMethod: func(request *http.Request) interface{} {
+			return request.Header.Get("X-User")
+		},
+		Cache: &web.CacheOptions{TTL: time.Minute},
+	})
+
+	get := func(user string) string {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path), nil)
+		req.Header.Set("X-User", user)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Network error: %s", err.Error())
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return string(body)
+	}
+
+	if body := get("alice"); body != "alice-1" {
+		t.Errorf("Unexpected body. Expected '%s' got '%s'", "alice-1", body)
+	}
+	if body := get("bob"); body != "bob-2" {
+		t.Errorf("Unexpected body. Expected '%s' got '%s'", "bob-2", body)
+	}
+	if body := get("alice"); body != "alice-1" {
+		t.Errorf("Unexpected body. Expected '%s' got '%s'", "alice-1", body)
+	}
+
+	server.ClearCache()
+	if body := get("alice"); body != "alice-3" {
+		t.Errorf("Unexpected body. Expected '%s' got '%s'", "alice-3", body)
+	}
+}
+
+func TestMemoryCacheStore(t *testing.T) {
+	t.Parallel()
+
+	store := web.NewMemoryCacheStore()
+	store.MaxEntries = 2
+	now := time.Now()
+	store.SetResponse("a", web.CachedResponse{Status: 200, Expires: now.Add(time.Minute)})
+	store.SetResponse("b", web.CachedResponse{Status: 200, Expires: now.Add(time.Minute)})
+	store.GetResponse("a")
+	store.SetResponse("c", web.CachedResponse{Status: 200, Expires: now.Add(time.Minute)})
+	store.SetResponse("d", web.CachedResponse{Status: 200, Expires: now.Add(-time.Minute)})
+
+	if response, _ := store.GetResponse("b"); response != nil {
+		t.Errorf("Least recently used response was not evicted")
+	}
+	if response, _ := store.GetResponse("d"); response != nil {
+		t.Errorf("Expired response was returned")
+	}
+	if response, _ := store.GetResponse("c"); response == nil {
+		t.Errorf("Recent response was evicted")
+	}
+}
+
+func TestWarmCache(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	var calls int32
+	server.API.GET("/products", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return atomic.AddInt32(&calls, 1), nil, nil
+	}, web.HandleOptions{
+		Cache: &web.CacheOptions{TTL: time.Minute},
+	})
+	server.API.GET("/orders", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return nil, nil, nil
+	}, web.HandleOptions{})
+
+	if err := server.WarmCache([]web.WarmSpec{{Path: "/products?page=1"}}); err != nil {
+		t.Fatalf("Unexpected error warming cache: %s", err.Error())
+	}
+	if calls != 1 {
+		t.Fatalf("Unexpected number of calls. Expected %d got %d", 1, calls)
+	}
+
+	// Warmed responses are served from the cache
+	response := server.Request("GET", "/products?page=1", nil)
+	if body := strings.TrimSpace(string(response.Body)); body != `{"data":1}` {
+		t.Errorf("Unexpected body. Expected '%s' got '%s'", `{"data":1}`, body)
+	}
+	if response.Header.Get("Age") == "" {
+		t.Errorf("Warmed response was not served from the cache")
+	}
+
+	// Warming replaces the cached response
+	if err := server.WarmCache([]web.WarmSpec{{Path: "/products?page=1"}}); err != nil {
+		t.Fatalf("Unexpected error warming cache: %s", err.Error())
+	}
+	if body := strings.TrimSpace(string(server.Request("GET", "/products?page=1", nil).Body)); body != `{"data":2}` {
+		t.Errorf("Unexpected body. Expected '%s' got '%s'", `{"data":2}`, body)
+	}
+
+	// Routes without cache options, unknown routes, and other methods are not cached
+	err := server.WarmCache([]web.WarmSpec{{Path: "/orders"}, {Path: "/unknown"}, {Path: "/products", Method: "POST"}})
+	if err == nil {
+		t.Fatalf("No error seen when warming routes that can't be cached")
+	}
+	if !strings.HasPrefix(err.Error(), "3 of 3 requests were not cached") || !strings.Contains(err.Error(), "status 404") {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+	if server.RejectedRequests().Total != 0 {
+		t.Errorf("Warm requests were counted as rejected")
+	}
+}
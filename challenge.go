@@ -0,0 +1,25 @@
+package web
+
+import "net/http"
+
+// Challenger describes an interface for challenging clients that have been rate limited, such as with a CAPTCHA or a
+// proof-of-work puzzle. Clients that solve the challenge may continue making requests beyond the rate limit.
+type Challenger interface {
+	// Challenge returns the payload describing a new challenge for the client, which is sent as the data of a
+	// [web.JSONResponse] with a "429 Too Many Requests" status.
+	Challenge(r *http.Request) interface{}
+	// Verify returns true if the request includes a valid solution to a challenge, such as in a header. Requests with
+	// a valid solution are not rate limited and do not count towards the limit. Verify is called for every request
+	// subject to rate limiting, so it should return quickly for requests without a solution.
+	Verify(r *http.Request) bool
+}
+
+// writeChallenge writes a 429 response with a new challenge from the Challenger of the server
+func (s *Server) writeChallenge(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(CommonErrors.TooManyRequests.Code)
+	s.jsonEncoder().NewEncoder(w).Encode(JSONResponse{
+		Data:  s.Options.Challenger.Challenge(r),
+		Error: CommonErrors.TooManyRequests,
+	})
+}
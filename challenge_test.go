@@ -0,0 +1,66 @@
+package web_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+type testChallenger struct {
+	answer string
+}
+
+func (c testChallenger) Challenge(r *http.Request) interface{} {
+	return map[string]string{"question": "answer?"}
+}
+
+func (c testChallenger) Verify(r *http.Request) bool {
+	return r.Header.Get("X-Challenge-Answer") == c.answer
+}
+
+func TestRateLimitChallenge(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+	server.Options.MaxRequestsPerSecond = 1
+	server.Options.Challenger = testChallenger{answer: randomString(6)}
+
+	path := randomString(5)
+	server.API.GET("/"+path, func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}, web.HandleOptions{})
+
+	doTest := func(answer string, expectedStatus int) *http.Response {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path), nil)
+		if answer != "" {
+			req.Header.Set("X-Challenge-Answer", answer)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Network error: %s", err.Error())
+		}
+		if resp.StatusCode != expectedStatus {
+			t.Errorf("Unexpected status code. Expected %d got %d", expectedStatus, resp.StatusCode)
+		}
+		return resp
+	}
+
+	doTest("", 200).Body.Close()
+	resp := doTest("", 429)
+	response := struct {
+		Data  map[string]string `json:"data"`
+		Error *web.Error        `json:"error"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %s", err.Error())
+	}
+	resp.Body.Close()
+	if response.Data["question"] != "answer?" || response.Error == nil || response.Error.Code != 429 {
+		t.Errorf("Unexpected challenge response: %+v", response)
+	}
+
+	doTest("wrong", 429).Body.Close()
+	doTest(server.Options.Challenger.(testChallenger).answer, 200).Body.Close()
+}
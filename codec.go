@@ -0,0 +1,92 @@
+package web
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Codec describes an interface for creating encoders for a media type other than JSON, such as "application/xml".
+// Codecs are registered with the Codecs server option, and are used to encode the data of API responses for clients
+// that prefer the media type in their 'Accept' header. Implement this interface to support other formats, such as
+// "application/msgpack" with a MessagePack library.
+type Codec interface {
+	// NewEncoder will return a new encoder that writes to w
+	NewEncoder(w io.Writer) Encoder
+}
+
+// StandardXML is a [web.Codec] for "application/xml" that uses encoding/xml. The data of the response must be
+// supported by encoding/xml, which does not support maps.
+var StandardXML = standardXML{}
+
+type standardXML struct{}
+
+func (standardXML) NewEncoder(w io.Writer) Encoder {
+	return xml.NewEncoder(w)
+}
+
+// StandardCSV is a [web.Codec] for "text/csv" that uses encoding/csv. The data of the response must be a [][]string,
+// where each element is a row. Errors are written as a single row with the code and message of the error.
+var StandardCSV = standardCSV{}
+
+type standardCSV struct{}
+
+func (standardCSV) NewEncoder(w io.Writer) Encoder {
+	return csvEncoder{w}
+}
+
+type csvEncoder struct {
+	w io.Writer
+}
+
+func (e csvEncoder) Encode(v any) error {
+	writer := csv.NewWriter(e.w)
+	switch value := v.(type) {
+	case [][]string:
+		writer.WriteAll(value)
+	case *Error:
+		writer.Write([]string{strconv.Itoa(value.Code), value.Message})
+	default:
+		return fmt.Errorf("unsupported type for csv: %T", v)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// negotiateCodec returns the codec and media type preferred by the 'Accept' header of the request, or nil if the
+// response should be JSON
+func (s *Server) negotiateCodec(r *http.Request) (Codec, string) {
+	if len(s.Options.Codecs) == 0 {
+		return nil, ""
+	}
+
+	var best Codec
+	bestType := ""
+	bestQuality := 0.0
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if quality <= bestQuality {
+			continue
+		}
+		if mediaType == "application/json" || mediaType == "*/*" {
+			best, bestType, bestQuality = nil, "", quality
+		} else if codec, ok := s.Options.Codecs[mediaType]; ok {
+			best, bestType, bestQuality = codec, mediaType, quality
+		}
+	}
+	return best, bestType
+}
@@ -0,0 +1,78 @@
+package web_test
+
+import (
+	"encoding/xml"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+type codecTestUser struct {
+	XMLName xml.Name `xml:"user" json:"-"`
+	Name    string   `xml:"name" json:"name"`
+}
+
+func TestAPICodecs(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+	server.Options.Codecs = map[string]web.Codec{
+		"application/xml": web.StandardXML,
+		"text/csv":        web.StandardCSV,
+	}
+
+	server.API.GET("/user", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return codecTestUser{Name: "alice"}, nil, nil
+	}, web.HandleOptions{})
+	server.API.GET("/report", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return [][]string{{"name", "count"}, {"alice", "2"}}, nil, nil
+	}, web.HandleOptions{})
+	server.API.GET("/error", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return nil, nil, web.ValidationError("bad input")
+	}, web.HandleOptions{})
+
+	get := func(path, accept string) web.MockResponse {
+		req := httptest.NewRequest("GET", path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		return server.Do(req)
+	}
+
+	response := get("/user", "application/xml")
+	if contentType := response.Header.Get("Content-Type"); contentType != "application/xml" {
+		t.Errorf("Unexpected content type. Expected '%s' got '%s'", "application/xml", contentType)
+	}
+	if expected := "<user><name>alice</name></user>"; string(response.Body) != expected {
+		t.Errorf("Unexpected body. Expected '%s' got '%s'", expected, response.Body)
+	}
+	if vary := response.Header.Get("Vary"); vary != "Accept" {
+		t.Errorf("Unexpected vary header. Expected '%s' got '%s'", "Accept", vary)
+	}
+
+	response = get("/report", "text/csv")
+	if expected := "name,count\nalice,2\n"; string(response.Body) != expected {
+		t.Errorf("Unexpected body. Expected '%s' got '%s'", expected, response.Body)
+	}
+
+	response = get("/error", "text/csv")
+	if response.Status != 400 || string(response.Body) != "400,bad input\n" {
+		t.Errorf("Unexpected response. Expected %d '%s' got %d '%s'", 400, "400,bad input\n", response.Status, response.Body)
+	}
+
+	for _, accept := range []string{"", "application/json", "*/*", "application/msgpack", "application/xml;q=0.5, application/json"} {
+		response = get("/user", accept)
+		if contentType := response.Header.Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("Unexpected content type for '%s'. Expected '%s' got '%s'", accept, "application/json", contentType)
+		}
+		user := codecTestUser{}
+		if err := response.DecodeJSON(&web.JSONResponse{Data: &user}); err != nil || user.Name != "alice" {
+			t.Errorf("Unexpected JSON response for '%s': %s", accept, response.Body)
+		}
+	}
+
+	response = get("/user", "application/json;q=0.2, application/xml;q=0.8")
+	if contentType := response.Header.Get("Content-Type"); contentType != "application/xml" {
+		t.Errorf("Unexpected content type. Expected '%s' got '%s'", "application/xml", contentType)
+	}
+}
@@ -2,12 +2,19 @@ package web
 
 // CommonErrors are common errors types suitable for API endpoints
 var CommonErrors = struct {
-	NotFound        *Error
-	BadRequest      *Error
-	Unauthorized    *Error
-	Forbidden       *Error
-	ServerError     *Error
-	TooManyRequests *Error
+	NotFound           *Error
+	BadRequest         *Error
+	Unauthorized       *Error
+	Forbidden          *Error
+	MethodNotAllowed   *Error
+	ServerError        *Error
+	TooManyRequests    *Error
+	PayloadTooLarge    *Error
+	MisdirectedRequest *Error
+	BadGateway         *Error
+	ServiceUnavailable *Error
+	GatewayTimeout     *Error
+	RequestTimeout     *Error
 }{
 	NotFound: &Error{
 		Code:    404,
@@ -25,6 +32,10 @@ var CommonErrors = struct {
 		Code:    403,
 		Message: "Forbidden",
 	},
+	MethodNotAllowed: &Error{
+		Code:    405,
+		Message: "Method Not Allowed",
+	},
 	ServerError: &Error{
 		Code:    500,
 		Message: "Server Error",
@@ -33,4 +44,28 @@ var CommonErrors = struct {
 		Code:    429,
 		Message: "Too Many Requests",
 	},
+	PayloadTooLarge: &Error{
+		Code:    413,
+		Message: "Payload Too Large",
+	},
+	MisdirectedRequest: &Error{
+		Code:    421,
+		Message: "Misdirected Request",
+	},
+	BadGateway: &Error{
+		Code:    502,
+		Message: "Bad Gateway",
+	},
+	ServiceUnavailable: &Error{
+		Code:    503,
+		Message: "Service Unavailable",
+	},
+	GatewayTimeout: &Error{
+		Code:    504,
+		Message: "Gateway Timeout",
+	},
+	RequestTimeout: &Error{
+		Code:    408,
+		Message: "Request Timeout",
+	},
 }
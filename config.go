@@ -0,0 +1,243 @@
+package web
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RuntimeConfig describes the options of the server that can be changed while it is running with
+// [web.Server.ApplyConfig]. The JSON of a [web.ServerConfig] can be decoded as a RuntimeConfig, such as to edit and
+// apply an exported configuration.
+type RuntimeConfig struct {
+	// The MaxRequestsPerSecond server option
+	MaxRequestsPerSecond int `json:"max_requests_per_second"`
+	// The RateLimitExemptNetworks server option
+	RateLimitExemptNetworks []string `json:"rate_limit_exempt_networks"`
+	// The MaxHTTPRanges server option
+	MaxHTTPRanges int `json:"max_http_ranges"`
+	// The MaxHTTPRangeBytes server option
+	MaxHTTPRangeBytes uint64 `json:"max_http_range_bytes"`
+	// The DefaultTimeout server option
+	DefaultTimeout time.Duration `json:"default_timeout"`
+	// The Maintenance server option
+	Maintenance bool `json:"maintenance"`
+	// The AllowedHosts server option
+	AllowedHosts []string `json:"allowed_hosts"`
+}
+
+// ServerConfig describes the effective configuration of a server, as returned by [web.Server.Config]. Properties match
+// the server option of the same name. Options that can't be represented as JSON, such as methods, are omitted.
+type ServerConfig struct {
+	RuntimeConfig
+	BindAddress              string            `json:"bind_address,omitempty"`
+	ListenPort               uint16            `json:"listen_port,omitempty"`
+	ListenNetwork            string            `json:"listen_network,omitempty"`
+	TLS                      bool              `json:"tls"`
+	RequireClientCertificate bool              `json:"require_client_certificate"`
+	DisableHTTP2             bool              `json:"disable_http2"`
+	EnableH2C                bool              `json:"enable_h2c"`
+	MaxConnections           int               `json:"max_connections"`
+	TrustedProxies           []string          `json:"trusted_proxies"`
+	TraceHeader              string            `json:"trace_header,omitempty"`
+	TraceSources             []string          `json:"trace_sources"`
+	RequestLogLevel          int               `json:"request_log_level"`
+	TimeoutStatus            int               `json:"timeout_status,omitempty"`
+	HealthCheckTimeout       time.Duration     `json:"health_check_timeout,omitempty"`
+	ShutdownDelay            time.Duration     `json:"shutdown_delay,omitempty"`
+	HoneypotBanDuration      time.Duration     `json:"honeypot_ban_duration,omitempty"`
+	DefaultHeaders           map[string]string `json:"default_headers,omitempty"`
+	// The API, HTTP, HTTPEasy, and Socket routes registered on the server, sorted by path and method
+	Routes []RouteConfig `json:"routes"`
+}
+
+// RouteConfig describes a route registered on the server
+type RouteConfig struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// The number of variants of the route registered with the MatchHeaders or Weight handle options
+	Variants int `json:"variants,omitempty"`
+	// If any handle for the route has an AuthenticateMethod
+	Authenticated bool `json:"authenticated"`
+	// The Description and Annotations handle options of the route
+	Description string            `json:"description,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// runtimeConfig returns the options of the server that may be changed by ApplyConfig while the server is running
+func (s *Server) runtimeConfig() RuntimeConfig {
+	s.configLock.RLock()
+	defer s.configLock.RUnlock()
+	return RuntimeConfig{
+		MaxRequestsPerSecond:    s.Options.MaxRequestsPerSecond,
+		RateLimitExemptNetworks: s.Options.RateLimitExemptNetworks,
+		MaxHTTPRanges:           s.Options.MaxHTTPRanges,
+		MaxHTTPRangeBytes:       s.Options.MaxHTTPRangeBytes,
+		DefaultTimeout:          s.Options.DefaultTimeout,
+		Maintenance:             s.Options.Maintenance,
+		AllowedHosts:            s.Options.AllowedHosts,
+	}
+}
+
+// Config returns the effective configuration of the server, including its options, limits, and registered routes,
+// such as to expose it as JSON on an administrative handle.
+func (s *Server) Config() ServerConfig {
+	config := ServerConfig{
+		RuntimeConfig:            s.runtimeConfig(),
+		BindAddress:              s.BindAddress,
+		ListenPort:               s.ListenPort,
+		ListenNetwork:            s.Options.ListenNetwork,
+		TLS:                      s.Options.TLSConfig != nil,
+		RequireClientCertificate: s.Options.RequireClientCertificate,
+		DisableHTTP2:             s.Options.DisableHTTP2,
+		EnableH2C:                s.Options.EnableH2C,
+		MaxConnections:           s.Options.MaxConnections,
+		TrustedProxies:           s.Options.TrustedProxies,
+		TraceHeader:              s.Options.TraceHeader,
+		TraceSources:             s.Options.TraceSources,
+		RequestLogLevel:          int(s.Options.RequestLogLevel),
+		TimeoutStatus:            s.Options.TimeoutStatus,
+		HealthCheckTimeout:       s.Options.HealthCheckTimeout,
+		ShutdownDelay:            s.Options.ShutdownDelay,
+		HoneypotBanDuration:      s.Options.HoneypotBanDuration,
+		DefaultHeaders:           s.Options.DefaultHeaders,
+	}
+
+	s.variants.lock.RLock()
+	config.Routes = make([]RouteConfig, 0, len(s.variants.routes))
+	for _, route := range s.variants.routes {
+		config.Routes = append(config.Routes, RouteConfig{
+			Method:        route.method,
+			Path:          route.path,
+			Variants:      len(route.variants),
+			Authenticated: route.authenticated,
+			Description:   route.description,
+			Annotations:   route.annotations,
+		})
+	}
+	s.variants.lock.RUnlock()
+	sort.Slice(config.Routes, func(i, j int) bool {
+		if config.Routes[i].Path == config.Routes[j].Path {
+			return config.Routes[i].Method < config.Routes[j].Method
+		}
+		return config.Routes[i].Path < config.Routes[j].Path
+	})
+	return config
+}
+
+// Validate returns an error if any of the options in the configuration are not valid
+func (c RuntimeConfig) Validate() error {
+	if c.MaxRequestsPerSecond < 0 {
+		return fmt.Errorf("max_requests_per_second must not be negative")
+	}
+	if c.MaxHTTPRanges < 0 {
+		return fmt.Errorf("max_http_ranges must not be negative")
+	}
+	if c.DefaultTimeout < 0 {
+		return fmt.Errorf("default_timeout must not be negative")
+	}
+	for _, network := range c.RateLimitExemptNetworks {
+		if len(parseNetworks([]string{network})) == 0 {
+			return fmt.Errorf("invalid rate limit exempt address %s", network)
+		}
+	}
+	for _, host := range c.AllowedHosts {
+		if strings.TrimPrefix(host, "*.") == "" || strings.ContainsAny(host, "/: ") {
+			return fmt.Errorf("invalid allowed host %s", host)
+		}
+	}
+	return nil
+}
+
+// ApplyConfig validates and applies the configuration to the server while it is running, without a restart. All
+// options in the configuration replace the current value of the matching server option, including options that are
+// empty. If the configuration is not valid then an error is returned and no options are changed.
+//
+// Options of the server that are included in the RuntimeConfig must only be changed with this method once the server
+// has started.
+func (s *Server) ApplyConfig(config RuntimeConfig) error {
+	if err := config.Validate(); err != nil {
+		log.PError("Invalid server configuration", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	s.configLock.Lock()
+	s.Options.MaxRequestsPerSecond = config.MaxRequestsPerSecond
+	s.Options.RateLimitExemptNetworks = config.RateLimitExemptNetworks
+	s.Options.MaxHTTPRanges = config.MaxHTTPRanges
+	s.Options.MaxHTTPRangeBytes = config.MaxHTTPRangeBytes
+	s.Options.DefaultTimeout = config.DefaultTimeout
+	s.Options.Maintenance = config.Maintenance
+	s.Options.AllowedHosts = config.AllowedHosts
+	s.configLock.Unlock()
+	// Parse the networks now rather than during the next request
+	s.exemptNetworks.get(config.RateLimitExemptNetworks)
+
+	log.PWarn("Applied server configuration", map[string]interface{}{
+		"max_requests_per_second":    config.MaxRequestsPerSecond,
+		"rate_limit_exempt_networks": config.RateLimitExemptNetworks,
+		"max_http_ranges":            config.MaxHTTPRanges,
+		"max_http_range_bytes":       config.MaxHTTPRangeBytes,
+		"default_timeout":            config.DefaultTimeout.String(),
+		"maintenance":                config.Maintenance,
+		"allowed_hosts":              config.AllowedHosts,
+	})
+	return nil
+}
+
+// hostAllowed returns true if the host, without any port, matches one of the allowed hosts
+func hostAllowed(host string, allowedHosts []string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, allowed := range allowedHosts {
+		allowed = strings.ToLower(allowed)
+		if allowed == host {
+			return true
+		}
+		if suffix, wildcard := strings.CutPrefix(allowed, "*"); wildcard && strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isUnavailable writes an error response and returns true if the host of the request is not one of the AllowedHosts
+// of the server, or if the server is in maintenance mode and the handle is not available during maintenance
+func (s *Server) isUnavailable(w http.ResponseWriter, r *http.Request, options HandleOptions) bool {
+	if isSynthetic(r) {
+		return false
+	}
+	config := s.runtimeConfig()
+
+	reason := RejectionReason("")
+	err := CommonErrors.MisdirectedRequest
+	text := "Misdirected request"
+	if len(config.AllowedHosts) > 0 && !hostAllowed(r.Host, config.AllowedHosts) {
+		reason = RejectedMisdirected
+	} else if config.Maintenance && !options.AllowDuringMaintenance {
+		reason = RejectedMaintenance
+		err = CommonErrors.ServiceUnavailable
+		text = "Service unavailable"
+	} else {
+		return false
+	}
+
+	log.PWrite(s.Options.RequestLogLevel, "HTTP Request", map[string]interface{}{
+		"remote_addr": s.realRemoteAddr(r),
+		"method":      r.Method,
+		"url":         r.URL,
+		"host":        r.Host,
+		"elapsed":     time.Duration(0).String(),
+		"status":      err.Code,
+	})
+	s.reportRejected(r, reason, err.Code)
+	s.writeError(w, err, text)
+	return true
+}
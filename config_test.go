@@ -0,0 +1,86 @@
+package web_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestServerConfig(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+	server.Options.MaxRequestsPerSecond = 5
+	server.Health.Register()
+
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}
+	server.API.GET("/users/:id", handle, web.HandleOptions{})
+	server.API.POST("/users", handle, web.HandleOptions{})
+
+	config := server.Config()
+	if config.MaxRequestsPerSecond != 5 {
+		t.Errorf("Unexpected max requests per second. Expected %d got %d", 5, config.MaxRequestsPerSecond)
+	}
+	if len(config.Routes) != 6 || config.Routes[4].Path != "/users" || config.Routes[5].Path != "/users/:id" {
+		t.Errorf("Unexpected routes %+v", config.Routes)
+	}
+
+	// Exported configurations can be edited and applied
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("Error encoding config: %s", err.Error())
+	}
+	runtimeConfig := web.RuntimeConfig{}
+	if err := json.Unmarshal(data, &runtimeConfig); err != nil {
+		t.Fatalf("Error decoding config: %s", err.Error())
+	}
+	if runtimeConfig.MaxRequestsPerSecond != 5 {
+		t.Errorf("Unexpected max requests per second. Expected %d got %d", 5, runtimeConfig.MaxRequestsPerSecond)
+	}
+
+	// Invalid configurations are not applied
+	runtimeConfig.Maintenance = true
+	runtimeConfig.RateLimitExemptNetworks = []string{"not an address"}
+	if err := server.ApplyConfig(runtimeConfig); err == nil {
+		t.Errorf("No error seen for invalid configuration")
+	}
+	if server.Config().Maintenance {
+		t.Errorf("Invalid configuration was applied")
+	}
+
+	runtimeConfig.RateLimitExemptNetworks = []string{"10.0.0.0/8"}
+	if err := server.ApplyConfig(runtimeConfig); err != nil {
+		t.Fatalf("Error applying configuration: %s", err.Error())
+	}
+	if response := server.Request("POST", "/users", nil); response.Status != 503 {
+		t.Errorf("Unexpected status code during maintenance. Expected %d got %d", 503, response.Status)
+	}
+	if response := server.Request("GET", "/healthz", nil); response.Status != 200 {
+		t.Errorf("Unexpected status code for health check during maintenance. Expected %d got %d", 200, response.Status)
+	}
+
+	runtimeConfig.Maintenance = false
+	runtimeConfig.AllowedHosts = []string{"example.com", "*.example.org"}
+	if err := server.ApplyConfig(runtimeConfig); err != nil {
+		t.Fatalf("Error applying configuration: %s", err.Error())
+	}
+	for host, status := range map[string]int{
+		"example.com":         200,
+		"EXAMPLE.COM:8443":    200,
+		"api.example.org":     200,
+		"example.org":         421,
+		"example.com.evil.io": 421,
+	} {
+		req := httptest.NewRequest("POST", "/users", nil)
+		req.Host = host
+		if response := server.Do(req); response.Status != status {
+			t.Errorf("Unexpected status code for host '%s'. Expected %d got %d", host, status, response.Status)
+		}
+	}
+	if stats := server.RejectedRequests(); stats.ByReason[web.RejectedMaintenance] != 1 || stats.ByReason[web.RejectedMisdirected] != 2 {
+		t.Errorf("Unexpected rejected requests %+v", stats.ByReason)
+	}
+}
@@ -0,0 +1,53 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+)
+
+// CookiePolicy describes the attributes applied to cookies set with the Cookies property of a [web.APIResponse] or
+// [web.HTTPResponse]. Attributes are only ever added to a cookie, so a cookie that sets Secure or HttpOnly itself keeps
+// it, and the SameSite policy is only used for cookies that do not specify one.
+type CookiePolicy struct {
+	// Set the Secure attribute on cookies so that they are only sent over HTTPS.
+	Secure bool
+	// Set the HttpOnly attribute on cookies so that they are not available to JavaScript.
+	HttpOnly bool
+	// The SameSite attribute to use for cookies that do not specify one.
+	SameSite http.SameSite
+}
+
+// applyCookiePolicy applies the cookie policy of the server to the cookie. Cookies with the "__Host-" or "__Secure-"
+// name prefix, or with SameSite=None, are always given the attributes that browsers require for them to be accepted.
+func (s *Server) applyCookiePolicy(cookie *http.Cookie) {
+	policy := s.Options.CookieDefaults
+	if override, ok := s.Options.CookieOverrides[cookie.Name]; ok {
+		policy = &override
+	}
+	if policy != nil {
+		cookie.Secure = cookie.Secure || policy.Secure
+		cookie.HttpOnly = cookie.HttpOnly || policy.HttpOnly
+		if cookie.SameSite == 0 {
+			cookie.SameSite = policy.SameSite
+		}
+	}
+
+	if strings.HasPrefix(cookie.Name, "__Host-") {
+		cookie.Secure = true
+		cookie.Path = "/"
+		cookie.Domain = ""
+	} else if strings.HasPrefix(cookie.Name, "__Secure-") {
+		cookie.Secure = true
+	}
+	if cookie.SameSite == http.SameSiteNoneMode {
+		cookie.Secure = true
+	}
+}
+
+// setCookies applies the cookie policy of the server to each cookie and adds it to the response
+func (s *Server) setCookies(w http.ResponseWriter, cookies []http.Cookie) {
+	for _, cookie := range cookies {
+		s.applyCookiePolicy(&cookie)
+		http.SetCookie(w, &cookie)
+	}
+}
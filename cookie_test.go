@@ -0,0 +1,68 @@
+package web_test
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestCookiePolicy(t *testing.T) {
+	t.Parallel()
+
+	server := web.New("127.0.0.1:0")
+	server.Options.CookieDefaults = &web.CookiePolicy{
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	}
+	server.Options.CookieOverrides = map[string]web.CookiePolicy{
+		"theme": {SameSite: http.SameSiteLaxMode},
+	}
+	listening := make(chan net.Addr, 1)
+	server.Options.OnListen = func(address net.Addr) {
+		listening <- address
+	}
+	server.API.GET("/cookies", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, &web.APIResponse{
+			Cookies: []http.Cookie{
+				{Name: "session", Value: "1"},
+				{Name: "theme", Value: "dark"},
+				{Name: "tracking", Value: "1", SameSite: http.SameSiteNoneMode},
+				{Name: "__Host-id", Value: "1", Path: "/app", Domain: "example.com"},
+			},
+		}, nil
+	}, web.HandleOptions{})
+	go server.Start()
+	defer server.Stop()
+	address := <-listening
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/cookies", address.String()))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	resp.Body.Close()
+
+	cookies := map[string]string{}
+	for _, cookie := range resp.Header.Values("Set-Cookie") {
+		parsed, err := http.ParseSetCookie(cookie)
+		if err != nil {
+			t.Fatalf("Invalid cookie '%s': %s", cookie, err.Error())
+		}
+		cookies[parsed.Name] = cookie
+	}
+
+	expected := map[string]string{
+		"session":   "session=1; HttpOnly; Secure; SameSite=Strict",
+		"theme":     "theme=dark; SameSite=Lax",
+		"tracking":  "tracking=1; HttpOnly; Secure; SameSite=None",
+		"__Host-id": "__Host-id=1; Path=/; HttpOnly; Secure; SameSite=Strict",
+	}
+	for name, value := range expected {
+		if cookies[name] != value {
+			t.Errorf("Unexpected cookie. Expected '%s' got '%s'", value, cookies[name])
+		}
+	}
+}
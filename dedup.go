@@ -0,0 +1,174 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ecnepsnai/web/router"
+)
+
+// DeduplicateOptions describes options for dropping duplicate requests to a handle
+type DeduplicateOptions struct {
+	// The amount of time after a request is received that exact duplicates of it are answered with its response.
+	// Required.
+	Window time.Duration
+	// The maximum size in bytes of a response body that will be replayed to duplicate requests. Duplicates of requests
+	// with larger responses are passed to the handle. Defaults to 1MiB.
+	MaxBodySize int
+}
+
+// dedupEntry describes the original request for a deduplication key. Done is closed once the response is known.
+type dedupEntry struct {
+	done     chan struct{}
+	response *CachedResponse
+}
+
+type dedupRegistry struct {
+	entries map[string]*dedupEntry
+	lock    *sync.Mutex
+}
+
+func newDedupRegistry() *dedupRegistry {
+	return &dedupRegistry{
+		entries: map[string]*dedupEntry{},
+		lock:    &sync.Mutex{},
+	}
+}
+
+// remove removes the entry for the key, unless it has since been replaced by another entry
+func (d *dedupRegistry) remove(key string, entry *dedupEntry) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.entries[key] == entry {
+		delete(d.entries, key)
+	}
+}
+
+// dedupKey returns a key identifying the client, method, path, query, and body of the request. The client is
+// identified by its address and any credentials in the 'Authorization' or 'Cookie' headers.
+func (s *Server) dedupKey(r *http.Request, body []byte, options HandleOptions) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Header.Get("Authorization")))
+	hash.Write([]byte{0})
+	hash.Write([]byte(strings.Join(r.Header.Values("Cookie"), "; ")))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return strings.Join([]string{s.realRemoteAddr(r).String(), r.Method, r.URL.Path, r.URL.RawQuery, options.Variant, hex.EncodeToString(hash.Sum(nil))}, "\x00")
+}
+
+// withDeduplication wraps the handle to answer exact duplicates of a request received within the window with the
+// response to the original request, if the handle has deduplicate options. Duplicates received while the original
+// request is still being handled wait for its response.
+func (s *Server) withDeduplication(handle router.Handle, options HandleOptions) router.Handle {
+	if options.Deduplicate == nil || options.Deduplicate.Window <= 0 {
+		return handle
+	}
+
+	return func(w http.ResponseWriter, r router.Request) {
+		writer, ok := w.(*responseWriter)
+		if !ok || writer.capture != nil {
+			// The response is already being captured for the cache
+			handle(w, r)
+			return
+		}
+
+		start := time.Now()
+		body, err := io.ReadAll(r.HTTP.Body)
+		r.HTTP.Body.Close()
+		if err != nil {
+			// Let the handle see the same error when it reads the body, such as from MaxBodyLength
+			r.HTTP.Body = &countingReader{ReadCloser: io.NopCloser(io.MultiReader(bytes.NewReader(body), errorReader{err}))}
+			handle(w, r)
+			return
+		}
+		r.HTTP.Body = &countingReader{ReadCloser: io.NopCloser(bytes.NewReader(body))}
+
+		key := s.dedupKey(r.HTTP, body, options)
+		s.dedup.lock.Lock()
+		entry, duplicate := s.dedup.entries[key]
+		if !duplicate {
+			entry = &dedupEntry{done: make(chan struct{})}
+			s.dedup.entries[key] = entry
+		}
+		s.dedup.lock.Unlock()
+
+		if duplicate {
+			select {
+			case <-entry.done:
+			case <-r.HTTP.Context().Done():
+				return
+			}
+			if entry.response != nil {
+				s.writeDeduplicatedResponse(writer, r.HTTP, entry.response, options)
+				return
+			}
+			// The response to the original request could not be replayed
+			handle(w, r)
+			return
+		}
+
+		defer func() {
+			close(entry.done)
+			if remaining := time.Until(start.Add(options.Deduplicate.Window)); remaining > 0 {
+				time.AfterFunc(remaining, func() {
+					s.dedup.remove(key, entry)
+				})
+			} else {
+				s.dedup.remove(key, entry)
+			}
+		}()
+
+		maxBodySize := options.Deduplicate.MaxBodySize
+		if maxBodySize <= 0 {
+			maxBodySize = defaultCacheMaxBodySize
+		}
+		writer.capture = &responseCapture{limit: maxBodySize, hideMaxAge: true}
+		handle(writer, r)
+		capture := writer.capture
+		writer.capture = nil
+		if capture.overflow || writer.hijacked || writer.status == 0 {
+			return
+		}
+
+		response := &CachedResponse{
+			Status: writer.status,
+			Header: writer.Header().Clone(),
+			Body:   capture.body.Bytes(),
+			Stored: start,
+		}
+		response.Header.Del("Server-Timing")
+		entry.response = response
+	}
+}
+
+func (s *Server) writeDeduplicatedResponse(w http.ResponseWriter, r *http.Request, response *CachedResponse, options HandleOptions) {
+	for key, values := range response.Header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(response.Status)
+	w.Write(response.Body)
+
+	if !options.DontLogRequests {
+		s.logRequest(w, r, "Deduplicated Request", response.Status, 0, map[string]interface{}{
+			"remote_addr": s.realRemoteAddr(r),
+			"method":      r.Method,
+			"url":         r.URL,
+			"age":         time.Since(response.Stored).String(),
+		})
+	}
+}
+
+// errorReader is a reader that always returns an error
+type errorReader struct {
+	err error
+}
+
+func (r errorReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
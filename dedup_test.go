@@ -0,0 +1,61 @@
+package web_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestAPIDeduplicate(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	var calls int32
+	server.API.POST("/orders", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		time.Sleep(50 * time.Millisecond)
+		return atomic.AddInt32(&calls, 1), &web.APIResponse{Status: 201}, nil
+	}, web.HandleOptions{
+		Deduplicate: &web.DeduplicateOptions{Window: 200 * time.Millisecond},
+	})
+
+	post := func(body string) (int, string) {
+		response := server.Do(httptest.NewRequest("POST", "/orders", strings.NewReader(body)))
+		return response.Status, strings.TrimSpace(string(response.Body))
+	}
+
+	// Duplicates received while the original is being handled wait for its response
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, body := post(`{"item":1}`)
+			if status != 201 {
+				t.Errorf("Unexpected status code. Expected %d got %d", 201, status)
+			}
+			if body != `{"data":1}` {
+				t.Errorf("Unexpected body. Expected '%s' got '%s'", `{"data":1}`, body)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("Unexpected number of handle calls. Expected 1 got %d", calls)
+	}
+
+	// Requests with a different body are not duplicates
+	if _, body := post(`{"item":2}`); body != `{"data":2}` {
+		t.Errorf("Unexpected body. Expected '%s' got '%s'", `{"data":2}`, body)
+	}
+
+	// Once the window has passed the handle is called again
+	time.Sleep(250 * time.Millisecond)
+	if _, body := post(`{"item":1}`); body != `{"data":3}` {
+		t.Errorf("Unexpected body. Expected '%s' got '%s'", `{"data":3}`, body)
+	}
+}
@@ -0,0 +1,184 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ecnepsnai/web/router"
+)
+
+// FileResponse returns a response for a HTTPEasy handle that downloads the file at path. The Content-Disposition,
+// Content-Type, Content-Length, Last-Modified, and ETag headers are set from the name and information of the file, and
+// HTTP range and conditional requests are supported. If the file can't be opened, or is a directory, then the response
+// has a 404 or 500 status.
+//
+// The file is opened when this method is called and is closed once the response has been written.
+func FileResponse(path string) HTTPResponse {
+	f, err := os.Open(path)
+	if err != nil {
+		return fileErrorResponse(path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fileErrorResponse(path, err)
+	}
+	if info.IsDir() {
+		f.Close()
+		return fileErrorResponse(path, os.ErrNotExist)
+	}
+
+	response := AttachmentResponse(nil, info.Name())
+	response.Reader = f
+	response.ContentLength = uint64(info.Size())
+	response.Headers["ETag"] = fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+	response.Headers["Last-Modified"] = info.ModTime().UTC().Format(http.TimeFormat)
+	return response
+}
+
+// AttachmentResponse returns a response for a HTTPEasy handle that downloads the data from reader as a file with the
+// given name. The Content-Disposition and Content-Type headers are set from the name of the file. If reader implements
+// [io.Seeker] then the Content-Length and ETag headers are also set, and HTTP range and conditional requests are
+// supported. The ETag is a hash of the data, so the entire reader is read once before the response is sent. Data
+// generated by the handle can be written to a [web.ResponseBuffer] to bound the memory used while it is buffered.
+//
+// If reader implements [io.Closer] then it is closed once the response has been written.
+func AttachmentResponse(reader io.Reader, filename string) HTTPResponse {
+	response := HTTPResponse{
+		ContentType: router.MimeGetter.GetMime(filename),
+		Headers: map[string]string{
+			"Content-Disposition": contentDisposition(filename),
+		},
+	}
+
+	switch r := reader.(type) {
+	case nil:
+		return response
+	case io.ReadSeekCloser:
+		response.Reader = r
+	case io.ReadSeeker:
+		response.Reader = nopSeekCloser{r}
+	case io.ReadCloser:
+		response.Reader = r
+	default:
+		response.Reader = io.NopCloser(r)
+	}
+
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		if etag, length, err := seekerETag(seeker); err == nil {
+			response.Headers["ETag"] = etag
+			response.ContentLength = length
+		} else {
+			log.PWarn("Error determining ETag of attachment", map[string]interface{}{
+				"filename": filename,
+				"error":    err.Error(),
+			})
+		}
+	}
+	return response
+}
+
+// contentDisposition returns the value of a Content-Disposition header for an attachment with the given file name.
+// Names with characters outside of ASCII are encoded as described in RFC 2231.
+func contentDisposition(filename string) string {
+	filename = filepath.Base(strings.ReplaceAll(filename, "\\", "/"))
+	if disposition := mime.FormatMediaType("attachment", map[string]string{"filename": filename}); disposition != "" {
+		return disposition
+	}
+	return "attachment"
+}
+
+// seekerETag returns a strong ETag and the length of the data from the current position of the seeker, and returns the
+// seeker to that position
+func seekerETag(seeker io.ReadSeeker) (string, uint64, error) {
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", 0, err
+	}
+	hash := sha256.New()
+	length, err := io.Copy(hash, seeker)
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+		return "", 0, err
+	}
+	return `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`, uint64(length), nil
+}
+
+func fileErrorResponse(path string, err error) HTTPResponse {
+	status := 500
+	if os.IsNotExist(err) {
+		status = 404
+	} else {
+		log.PError("Error opening file for download", map[string]interface{}{
+			"path":  path,
+			"error": err.Error(),
+		})
+	}
+	return HTTPResponse{
+		Status: status,
+	}
+}
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error {
+	return nil
+}
+
+// etagMatches returns true if the value of an If-None-Match or If-Range request header matches the ETag of the
+// response, using the weak comparison described in RFC 9110
+func etagMatches(header string, etag string) bool {
+	if header == "" || etag == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// responseHeader returns the value of the header with the given name from the headers of a response, regardless of the
+// case of the name
+func responseHeader(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// notModifiedSince returns true if the value of an If-Modified-Since request header is not before the Last-Modified
+// time of the response
+func notModifiedSince(header string, lastModified string) bool {
+	if header == "" || lastModified == "" {
+		return false
+	}
+	since, err := http.ParseTime(header)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
@@ -0,0 +1,121 @@
+package web_test
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestFileResponse(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "report.csv")
+	if err := os.WriteFile(filePath, []byte("id,name\n1,example\n"), 0644); err != nil {
+		t.Fatalf("Error writing file: %s", err.Error())
+	}
+	server.HTTPEasy.GET("/report", func(request web.Request) web.HTTPResponse {
+		return web.FileResponse(filePath)
+	}, web.HandleOptions{})
+	server.HTTPEasy.GET("/missing", func(request web.Request) web.HTTPResponse {
+		return web.FileResponse(filepath.Join(dir, "missing.csv"))
+	}, web.HandleOptions{})
+
+	response := server.Request("GET", "/report", nil)
+	if response.Status != 200 {
+		t.Fatalf("Unexpected status code. Expected %d got %d", 200, response.Status)
+	}
+	if string(response.Body) != "id,name\n1,example\n" {
+		t.Errorf("Unexpected body '%s'", response.Body)
+	}
+	for header, expected := range map[string]string{
+		"Content-Disposition": `attachment; filename=report.csv`,
+		"Content-Type":        "text/csv",
+		"Content-Length":      "18",
+		"Accept-Ranges":       "bytes",
+	} {
+		if actual := response.Header.Get(header); actual != expected {
+			t.Errorf("Unexpected %s header. Expected '%s' got '%s'", header, expected, actual)
+		}
+	}
+	etag := response.Header.Get("ETag")
+	lastModified := response.Header.Get("Last-Modified")
+	if etag == "" || lastModified == "" {
+		t.Fatalf("Missing ETag or Last-Modified header")
+	}
+
+	req := httptest.NewRequest("GET", "/report", nil)
+	req.Header.Set("If-None-Match", etag)
+	if response := server.Do(req); response.Status != 304 || len(response.Body) != 0 {
+		t.Errorf("Unexpected status code for conditional request. Expected %d got %d", 304, response.Status)
+	}
+
+	req = httptest.NewRequest("GET", "/report", nil)
+	req.Header.Set("Range", "bytes=0-1")
+	req.Header.Set("If-Range", etag)
+	if response := server.Do(req); response.Status != 206 || string(response.Body) != "id" {
+		t.Errorf("Unexpected range response. Expected %d got %d: %s", 206, response.Status, response.Body)
+	} else {
+		// Clients need these headers to resume the download with another range request
+		for header, expected := range map[string]string{
+			"Content-Disposition": `attachment; filename=report.csv`,
+			"ETag":                etag,
+			"Last-Modified":       lastModified,
+		} {
+			if actual := response.Header.Get(header); actual != expected {
+				t.Errorf("Unexpected %s header for range response. Expected '%s' got '%s'", header, expected, actual)
+			}
+		}
+	}
+	req.Header.Set("If-Range", `"outdated"`)
+	if response := server.Do(req); response.Status != 200 {
+		t.Errorf("Unexpected status code for outdated range request. Expected %d got %d", 200, response.Status)
+	}
+
+	if response := server.Request("GET", "/missing", nil); response.Status != 404 {
+		t.Errorf("Unexpected status code for missing file. Expected %d got %d", 404, response.Status)
+	}
+}
+
+func TestAttachmentResponse(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	server.HTTPEasy.GET("/export", func(request web.Request) web.HTTPResponse {
+		return web.AttachmentResponse(bytes.NewReader([]byte(`{"id":1}`)), "données.json")
+	}, web.HandleOptions{})
+	server.HTTPEasy.GET("/stream", func(request web.Request) web.HTTPResponse {
+		return web.AttachmentResponse(strings.NewReader("hello"), "hello.txt")
+	}, web.HandleOptions{})
+
+	response := server.Request("GET", "/export", nil)
+	if string(response.Body) != `{"id":1}` {
+		t.Errorf("Unexpected body '%s'", response.Body)
+	}
+	if disposition := response.Header.Get("Content-Disposition"); disposition != `attachment; filename*=utf-8''donn%C3%A9es.json` {
+		t.Errorf("Unexpected Content-Disposition header '%s'", disposition)
+	}
+	if contentType := response.Header.Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Unexpected Content-Type header '%s'", contentType)
+	}
+	etag := response.Header.Get("ETag")
+	if etag == "" {
+		t.Fatalf("Missing ETag header")
+	}
+	req := httptest.NewRequest("GET", "/export", nil)
+	req.Header.Set("If-None-Match", `"other", `+etag)
+	if response := server.Do(req); response.Status != 304 {
+		t.Errorf("Unexpected status code for conditional request. Expected %d got %d", 304, response.Status)
+	}
+
+	response = server.Request("GET", "/stream", nil)
+	if string(response.Body) != "hello" || response.Header.Get("Content-Type") != "text/plain" {
+		t.Errorf("Unexpected response '%s' with content type '%s'", response.Body, response.Header.Get("Content-Type"))
+	}
+}
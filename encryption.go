@@ -0,0 +1,306 @@
+package web
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/ecnepsnai/web/router"
+)
+
+// PayloadEncryption describes options for encrypting the request and response bodies of an API handle as JSON web
+// encryption (JWE) compact serialized tokens, for data that requires protection beyond TLS.
+//
+// Payloads are encrypted with a shared AES key using direct encryption ("alg": "dir") and AES-GCM, where the size of
+// the key selects A128GCM, A192GCM, or A256GCM. Encrypted request bodies must have a Content-Type of
+// "application/jose", and the 'kid' of the token identifies the key. The 'cty' of the token is used as the content
+// type of the decrypted body, defaulting to "application/json".
+//
+// Responses are encrypted with the key of the request, and have a Content-Type of "application/jose". Encrypted
+// responses are never cached, and streamed responses are buffered in full before they are encrypted.
+type PayloadEncryption struct {
+	// Method returning the 16, 24, or 32 byte AES key with the given key ID for the request. Returning an error rejects
+	// the request with a "400 Bad Request" response. The error is logged but is not sent to the client. Required.
+	Key func(keyID string, request *http.Request) ([]byte, error)
+	// Optional method returning the ID of the key used to encrypt the response to requests that do not have an
+	// encrypted body, such as GET requests. If omitted, or if it returns an empty string, these responses are not
+	// encrypted.
+	ResponseKeyID func(request *http.Request) string
+	// If true then requests with a body that is not encrypted are rejected with a "400 Bad Request" response.
+	Required bool
+}
+
+const joseContentType = "application/jose"
+
+type jweHeader struct {
+	Algorithm   string `json:"alg"`
+	Encryption  string `json:"enc"`
+	KeyID       string `json:"kid,omitempty"`
+	ContentType string `json:"cty,omitempty"`
+}
+
+// jweEncryption returns the name of the content encryption algorithm for an AES key
+func jweEncryption(key []byte) (string, error) {
+	switch len(key) {
+	case 16:
+		return "A128GCM", nil
+	case 24:
+		return "A192GCM", nil
+	case 32:
+		return "A256GCM", nil
+	}
+	return "", fmt.Errorf("invalid key length %d", len(key))
+}
+
+// encryptJWE returns the plaintext encrypted with the key as a compact serialized JWE token
+func encryptJWE(plaintext, key []byte, keyID, contentType string) ([]byte, error) {
+	encryption, err := jweEncryption(key)
+	if err != nil {
+		return nil, err
+	}
+	headerData, err := json.Marshal(jweHeader{Algorithm: "dir", Encryption: encryption, KeyID: keyID, ContentType: contentType})
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	header := base64.RawURLEncoding.EncodeToString(headerData)
+	sealed := aead.Seal(nil, iv, plaintext, []byte(header))
+	ciphertext, tag := sealed[:len(sealed)-aead.Overhead()], sealed[len(sealed)-aead.Overhead():]
+	return []byte(strings.Join([]string{
+		header,
+		"",
+		base64.RawURLEncoding.EncodeToString(iv),
+		base64.RawURLEncoding.EncodeToString(ciphertext),
+		base64.RawURLEncoding.EncodeToString(tag),
+	}, ".")), nil
+}
+
+// parseJWE returns the header of a compact serialized JWE token
+func parseJWE(token string) (jweHeader, []string, error) {
+	header := jweHeader{}
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 5 {
+		return header, nil, fmt.Errorf("malformed token")
+	}
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return header, nil, fmt.Errorf("invalid header: %s", err.Error())
+	}
+	if header.Algorithm != "dir" {
+		return header, nil, fmt.Errorf("unsupported algorithm %s", header.Algorithm)
+	}
+	if parts[1] != "" {
+		return header, nil, fmt.Errorf("unexpected encrypted key")
+	}
+	return header, parts, nil
+}
+
+// decryptJWE returns the plaintext of a compact serialized JWE token parsed with parseJWE
+func decryptJWE(header jweHeader, parts []string, key []byte) ([]byte, error) {
+	encryption, err := jweEncryption(key)
+	if err != nil {
+		return nil, err
+	}
+	if header.Encryption != encryption {
+		return nil, fmt.Errorf("key not valid for encryption %s", header.Encryption)
+	}
+	iv, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid iv encoding")
+	}
+	ciphertext, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext encoding")
+	}
+	tag, err := base64.RawURLEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag encoding")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != aead.NonceSize() || len(tag) != aead.Overhead() {
+		return nil, fmt.Errorf("invalid iv or tag length")
+	}
+	plaintext, err := aead.Open(nil, iv, append(ciphertext, tag...), []byte(parts[0]))
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed")
+	}
+	return plaintext, nil
+}
+
+// encryptingWriter buffers the response so that it can be encrypted once the handle has finished. The headers of the
+// response are not buffered.
+type encryptingWriter struct {
+	http.ResponseWriter
+	body   bytes.Buffer
+	status int
+}
+
+func (w *encryptingWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+func (w *encryptingWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(p)
+}
+
+// Flush does nothing, as the response is only written once it has been encrypted
+func (w *encryptingWriter) Flush() {}
+
+// withPayloadEncryption wraps the handle to decrypt the body of requests and encrypt the body of responses, if the
+// handle has payload encryption options
+func (a API) withPayloadEncryption(handle router.Handle, options HandleOptions) router.Handle {
+	encryption := options.PayloadEncryption
+	if encryption == nil || encryption.Key == nil {
+		return handle
+	}
+
+	// reject logs the message and responds with the error
+	reject := func(w http.ResponseWriter, r *http.Request, message string, err *Error) {
+		log.PWarn("Rejected API request with invalid encrypted payload", map[string]interface{}{
+			"url":         r.URL,
+			"method":      r.Method,
+			"remote_addr": a.server.realRemoteAddr(r),
+			"error":       message,
+		})
+		setResponseError(w, err.Message)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(err.Code)
+		a.server.jsonEncoder().NewEncoder(w).Encode(a.server.serializeError(err, JSONResponse{Error: err}))
+	}
+
+	return func(w http.ResponseWriter, r router.Request) {
+		var key []byte
+		keyID := ""
+		mediaType, _, _ := mime.ParseMediaType(r.HTTP.Header.Get("Content-Type"))
+		if mediaType == joseContentType {
+			token, err := io.ReadAll(r.HTTP.Body)
+			if err != nil {
+				maxBytesError := &http.MaxBytesError{}
+				if errors.As(err, &maxBytesError) {
+					a.server.writeError(w, CommonErrors.PayloadTooLarge, "")
+					return
+				}
+				reject(w, r.HTTP, "error reading request body", ValidationError("error reading request body"))
+				return
+			}
+			header, parts, err := parseJWE(string(token))
+			if err != nil {
+				reject(w, r.HTTP, err.Error(), ValidationError("%s", err.Error()))
+				return
+			}
+			if key, err = encryption.Key(header.KeyID, r.HTTP); err != nil {
+				reject(w, r.HTTP, err.Error(), CommonErrors.BadRequest)
+				return
+			}
+			plaintext, err := decryptJWE(header, parts, key)
+			if err != nil {
+				reject(w, r.HTTP, err.Error(), ValidationError("%s", err.Error()))
+				return
+			}
+			keyID = header.KeyID
+			contentType := header.ContentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			r.HTTP.Header.Set("Content-Type", contentType)
+			r.HTTP.Body = &countingReader{ReadCloser: io.NopCloser(bytes.NewReader(plaintext))}
+			r.HTTP.ContentLength = int64(len(plaintext))
+		} else if encryption.Required && r.HTTP.ContentLength != 0 && r.HTTP.Body != nil && r.HTTP.Body != http.NoBody {
+			reject(w, r.HTTP, "request body must be encrypted", ValidationError("request body must be encrypted"))
+			return
+		} else if encryption.ResponseKeyID != nil {
+			if keyID = encryption.ResponseKeyID(r.HTTP); keyID != "" {
+				var err error
+				if key, err = encryption.Key(keyID, r.HTTP); err != nil {
+					reject(w, r.HTTP, err.Error(), CommonErrors.BadRequest)
+					return
+				}
+			}
+		}
+
+		if key == nil {
+			handle(w, r)
+			return
+		}
+
+		writer, ok := w.(*responseWriter)
+		if !ok {
+			writer = &responseWriter{ResponseWriter: w}
+		}
+		if writer.capture != nil {
+			// Encrypted responses are never cached
+			writer.capture.skip = true
+		}
+
+		// Buffer the response underneath the response writer so that the status and errors of the response are still
+		// recorded
+		original := writer.ResponseWriter
+		buffer := &encryptingWriter{ResponseWriter: original}
+		writer.ResponseWriter = buffer
+		handle(writer, r)
+		writer.ResponseWriter = original
+		status := buffer.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if status == http.StatusNoContent {
+			// There is no body to encrypt
+			original.WriteHeader(status)
+			return
+		}
+
+		body, err := encryptJWE(buffer.body.Bytes(), key, keyID, original.Header().Get("Content-Type"))
+		if err != nil {
+			log.PError("Error encrypting API response", map[string]interface{}{
+				"url":    r.HTTP.URL,
+				"method": r.HTTP.Method,
+				"error":  err.Error(),
+			})
+			setResponseError(writer, err.Error())
+			writer.status = 500
+			status = 500
+			errorBody := &bytes.Buffer{}
+			a.server.jsonEncoder().NewEncoder(errorBody).Encode(a.server.serializeError(CommonErrors.ServerError, JSONResponse{Error: CommonErrors.ServerError}))
+			body = errorBody.Bytes()
+			original.Header().Set("Content-Type", "application/json")
+		} else {
+			original.Header().Set("Content-Type", joseContentType)
+		}
+		original.Header().Del("Content-Length")
+		original.WriteHeader(status)
+		written, _ := original.Write(body)
+		writer.written = uint64(written)
+	}
+}
@@ -0,0 +1,136 @@
+package web_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func testEncryptJWE(plaintext, key []byte, keyID string) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"alg":"dir","enc":"A256GCM","kid":"%s"}`, keyID)))
+	block, _ := aes.NewCipher(key)
+	aead, _ := cipher.NewGCM(block)
+	iv := make([]byte, aead.NonceSize())
+	rand.Read(iv)
+	sealed := aead.Seal(nil, iv, plaintext, []byte(header))
+	ciphertext, tag := sealed[:len(sealed)-aead.Overhead()], sealed[len(sealed)-aead.Overhead():]
+	return strings.Join([]string{header, "", base64.RawURLEncoding.EncodeToString(iv), base64.RawURLEncoding.EncodeToString(ciphertext), base64.RawURLEncoding.EncodeToString(tag)}, ".")
+}
+
+func testDecryptJWE(t *testing.T, token string, key []byte) ([]byte, map[string]interface{}) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		t.Fatalf("Malformed token '%s'", token)
+	}
+	headerData, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	header := map[string]interface{}{}
+	json.Unmarshal(headerData, &header)
+	iv, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	ciphertext, _ := base64.RawURLEncoding.DecodeString(parts[3])
+	tag, _ := base64.RawURLEncoding.DecodeString(parts[4])
+	block, _ := aes.NewCipher(key)
+	aead, _ := cipher.NewGCM(block)
+	plaintext, err := aead.Open(nil, iv, append(ciphertext, tag...), []byte(parts[0]))
+	if err != nil {
+		t.Fatalf("Error decrypting response: %s", err.Error())
+	}
+	return plaintext, header
+}
+
+func TestAPIPayloadEncryption(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	key := make([]byte, 32)
+	rand.Read(key)
+	options := web.HandleOptions{
+		PayloadEncryption: &web.PayloadEncryption{
+			Key: func(keyID string, request *http.Request) ([]byte, error) {
+				if keyID != "client-1" {
+					return nil, fmt.Errorf("unknown key %s", keyID)
+				}
+				return key, nil
+			},
+			ResponseKeyID: func(request *http.Request) string {
+				return request.Header.Get("X-Key-ID")
+			},
+			Required: true,
+		},
+	}
+
+	type account struct {
+		Number string `json:"number"`
+	}
+	server.API.POST("/accounts", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		params := account{}
+		if err := request.DecodeJSON(&params); err != nil {
+			return nil, nil, err
+		}
+		return params.Number, nil, nil
+	}, options)
+	server.API.GET("/accounts", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return "1234", nil, nil
+	}, options)
+
+	post := func(body string, contentType string) web.MockResponse {
+		req := httptest.NewRequest("POST", "/accounts", bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", contentType)
+		return server.Do(req)
+	}
+
+	// Encrypted requests are decrypted for the handle, and the response is encrypted with the same key
+	response := post(testEncryptJWE([]byte(`{"number":"5678"}`), key, "client-1"), "application/jose")
+	if response.Status != 200 {
+		t.Fatalf("Unexpected status code. Expected %d got %d: %s", 200, response.Status, response.Body)
+	}
+	if contentType := response.Header.Get("Content-Type"); contentType != "application/jose" {
+		t.Errorf("Unexpected content type. Expected '%s' got '%s'", "application/jose", contentType)
+	}
+	plaintext, header := testDecryptJWE(t, string(response.Body), key)
+	if strings.TrimSpace(string(plaintext)) != `{"data":"5678"}` {
+		t.Errorf("Unexpected response. Expected '%s' got '%s'", `{"data":"5678"}`, plaintext)
+	}
+	if header["kid"] != "client-1" || header["cty"] != "application/json" {
+		t.Errorf("Unexpected response header %v", header)
+	}
+
+	// Unencrypted bodies are rejected
+	if response := post(`{"number":"5678"}`, "application/json"); response.Status != 400 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 400, response.Status)
+	}
+
+	// Unknown keys and tampered tokens are rejected
+	if response := post(testEncryptJWE([]byte(`{}`), key, "client-2"), "application/jose"); response.Status != 400 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 400, response.Status)
+	} else if strings.Contains(string(response.Body), "unknown key") {
+		t.Errorf("Error from key method sent to client: %s", response.Body)
+	}
+	token := testEncryptJWE([]byte(`{"number":"5678"}`), key, "client-1")
+	if response := post(token[:len(token)-4]+"AAAA", "application/jose"); response.Status != 400 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 400, response.Status)
+	}
+
+	// Requests without a body use the key from ResponseKeyID
+	req := httptest.NewRequest("GET", "/accounts", nil)
+	req.Header.Set("X-Key-ID", "client-1")
+	response = server.Do(req)
+	if contentType := response.Header.Get("Content-Type"); contentType != "application/jose" {
+		t.Fatalf("Unexpected content type. Expected '%s' got '%s'", "application/jose", contentType)
+	}
+	if plaintext, _ := testDecryptJWE(t, string(response.Body), key); strings.TrimSpace(string(plaintext)) != `{"data":"1234"}` {
+		t.Errorf("Unexpected response. Expected '%s' got '%s'", `{"data":"1234"}`, plaintext)
+	}
+	if response := server.Do(httptest.NewRequest("GET", "/accounts", nil)); response.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected content type. Expected '%s' got '%s'", "application/json", response.Header.Get("Content-Type"))
+	}
+}
@@ -1,11 +1,67 @@
 package web
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+)
 
 // Error describes an API error object
 type Error struct {
 	Code    int    `json:"code,omitempty"`
 	Message string `json:"message,omitempty"`
+	// Optional application-specific code for the error, such as "insufficient_funds", allowing clients to handle
+	// specific errors without parsing the message.
+	Reason string `json:"reason,omitempty"`
+	// Optional details about the error, such as the fields of a request that were not valid.
+	Details map[string]interface{} `json:"details,omitempty"`
+	// Optional underlying error that caused this error. The cause is logged but is never sent to the client.
+	Cause error `json:"-"`
+}
+
+// Error returns the message of the error, followed by the message of its cause if there is one
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return e.Message + ": " + e.Cause.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the cause of the error, for use with [errors.Is] and [errors.As]
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// WithReason returns a copy of the error with the given application-specific code. For example:
+//
+//	return nil, nil, web.CommonErrors.BadRequest.WithReason("insufficient_funds")
+func (e *Error) WithReason(reason string) *Error {
+	err := e.copy()
+	err.Reason = reason
+	return err
+}
+
+// WithDetail returns a copy of the error with the given detail added to its details
+func (e *Error) WithDetail(key string, value interface{}) *Error {
+	err := e.copy()
+	err.Details[key] = value
+	return err
+}
+
+// WithCause returns a copy of the error with the given underlying cause
+func (e *Error) WithCause(cause error) *Error {
+	err := e.copy()
+	err.Cause = cause
+	return err
+}
+
+// copy returns a copy of the error with its own details, so that errors such as [web.CommonErrors] are never modified
+func (e *Error) copy() *Error {
+	err := *e
+	err.Details = make(map[string]interface{}, len(e.Details)+1)
+	for key, value := range e.Details {
+		err.Details[key] = value
+	}
+	return &err
 }
 
 // ValidationError convenience method to make a error object for validation errors
@@ -15,3 +71,27 @@ func ValidationError(format string, v ...interface{}) *Error {
 		Message: fmt.Sprintf(format, v...),
 	}
 }
+
+// serializeError returns the value to encode as the body of a JSON error response, using the ErrorSerializer of the
+// server if set, or otherwise defaultBody
+func (s *Server) serializeError(err *Error, defaultBody interface{}) interface{} {
+	if s.Options.ErrorSerializer != nil {
+		return s.Options.ErrorSerializer(err)
+	}
+	return defaultBody
+}
+
+// writeError writes the response for err. If the server has an ErrorSerializer then the response is JSON, otherwise
+// the status is written with text as the body.
+func (s *Server) writeError(w http.ResponseWriter, err *Error, text string) {
+	if s.Options.ErrorSerializer != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(err.Code)
+		s.jsonEncoder().NewEncoder(w).Encode(s.Options.ErrorSerializer(err))
+		return
+	}
+	w.WriteHeader(err.Code)
+	if text != "" {
+		w.Write([]byte(text))
+	}
+}
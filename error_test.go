@@ -0,0 +1,114 @@
+package web_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestErrorSerializer(t *testing.T) {
+	t.Parallel()
+
+	type errorEnvelope struct {
+		Status int    `json:"status"`
+		Reason string `json:"reason"`
+	}
+
+	server := web.New("127.0.0.1:0")
+	server.Options.ErrorSerializer = func(err *web.Error) interface{} {
+		return errorEnvelope{Status: err.Code, Reason: err.Message}
+	}
+	listening := make(chan net.Addr, 1)
+	server.Options.OnListen = func(address net.Addr) {
+		listening <- address
+	}
+	server.API.GET("/error", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return nil, nil, web.ValidationError("bad value")
+	}, web.HandleOptions{})
+	server.API.GET("/unauthorized", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}, web.HandleOptions{
+		AuthenticateMethod: func(request *http.Request) interface{} {
+			return nil
+		},
+	})
+	server.API.POST("/limited", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}, web.HandleOptions{MaxBodyLength: 1})
+	go server.Start()
+	defer server.Stop()
+	address := <-listening
+
+	doTest := func(method, path string, expected errorEnvelope) {
+		req, _ := http.NewRequest(method, fmt.Sprintf("http://%s/%s", address.String(), path), bytes.NewReader([]byte("{}")))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Network error: %s", err.Error())
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != expected.Status {
+			t.Errorf("Unexpected status code for %s. Expected %d got %d", path, expected.Status, resp.StatusCode)
+		}
+		body := errorEnvelope{}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("Error decoding response for %s: %s", path, err.Error())
+		}
+		if body != expected {
+			t.Errorf("Unexpected response for %s. Expected %+v got %+v", path, expected, body)
+		}
+	}
+
+	doTest("GET", "error", errorEnvelope{400, "bad value"})
+	doTest("GET", "unauthorized", errorEnvelope{401, "Unauthorized"})
+	doTest("POST", "limited", errorEnvelope{413, "Payload Too Large"})
+	doTest("GET", "missing", errorEnvelope{404, "Not Found"})
+	doTest("DELETE", "error", errorEnvelope{405, "Method Not Allowed"})
+}
+
+func TestErrorDetails(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	cause := fmt.Errorf("balance too low")
+	server.API.POST("/transfer", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return nil, nil, web.CommonErrors.BadRequest.WithReason("insufficient_funds").WithDetail("balance", 10).WithCause(cause)
+	}, web.HandleOptions{})
+
+	response := server.Request("POST", "/transfer", nil)
+	if response.Status != 400 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 400, response.Status)
+	}
+	body := struct {
+		Error map[string]interface{} `json:"error"`
+	}{}
+	if err := json.Unmarshal(response.Body, &body); err != nil {
+		t.Fatalf("Error decoding response: %s", err.Error())
+	}
+	if body.Error["reason"] != "insufficient_funds" {
+		t.Errorf("Unexpected reason. Expected '%s' got '%v'", "insufficient_funds", body.Error["reason"])
+	}
+	if details, _ := body.Error["details"].(map[string]interface{}); details["balance"] != float64(10) {
+		t.Errorf("Unexpected details. Expected balance of 10 got %v", body.Error["details"])
+	}
+	if _, present := body.Error["cause"]; present {
+		t.Errorf("Cause should not be sent to the client")
+	}
+
+	if web.CommonErrors.BadRequest.Reason != "" || len(web.CommonErrors.BadRequest.Details) > 0 {
+		t.Errorf("Common error was modified")
+	}
+
+	var err error = web.CommonErrors.ServerError.WithCause(cause)
+	if !errors.Is(err, cause) {
+		t.Errorf("Error does not unwrap to its cause")
+	}
+	if err.Error() != "Server Error: balance too low" {
+		t.Errorf("Unexpected error message '%s'", err.Error())
+	}
+}
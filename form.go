@@ -0,0 +1,160 @@
+package web
+
+import (
+	"encoding"
+	"fmt"
+	"mime"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DecodeQuery populates the fields of the struct pointed to by v from the URL query parameters of the request.
+//
+// Fields are matched to parameters using the 'form' struct tag, or the name of the field if there is no tag. Fields
+// tagged with "-" are ignored. Adding ",required" to the tag, such as `form:"page,required"`, rejects requests without
+// the parameter. Parameters are converted to the type of the field, which may be a string, bool, integer, float,
+// [time.Duration], any type implementing [encoding.TextUnmarshaler], or a slice or pointer of these. Slice fields are
+// populated with every value of a repeated parameter.
+//
+// Returns a 400 error if a required parameter is missing or a value can not be converted to the type of its field.
+func (r Request) DecodeQuery(v any) *Error {
+	return decodeValues(r.HTTP.URL.Query(), v)
+}
+
+// DecodeForm populates the fields of the struct pointed to by v from the application/x-www-form-urlencoded or
+// multipart/form-data body of the request, the same as [web.Request.DecodeQuery]. URL query parameters are not
+// included.
+//
+// Returns a 400 error if the request does not have a form body, a required field is missing, or a value can not be
+// converted to the type of its field.
+func (r Request) DecodeForm(v any) *Error {
+	mediaType, _, _ := mime.ParseMediaType(r.HTTP.Header.Get("Content-Type"))
+	switch mediaType {
+	case "multipart/form-data":
+		if err := r.parseMultipartForm(); err != nil {
+			return err
+		}
+		return decodeValues(r.HTTP.MultipartForm.Value, v)
+	case "application/x-www-form-urlencoded":
+		if err := r.HTTP.ParseForm(); err != nil {
+			log.PError("Invalid form request", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return CommonErrors.BadRequest
+		}
+		return decodeValues(r.HTTP.PostForm, v)
+	}
+	return ValidationError("expected form body")
+}
+
+func decodeValues(values url.Values, v any) *Error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Ptr || target.Elem().Kind() != reflect.Struct {
+		log.PError("Invalid decode target", map[string]interface{}{
+			"type": fmt.Sprintf("%T", v),
+		})
+		return CommonErrors.ServerError
+	}
+	target = target.Elem()
+	t := target.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("form"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		fieldValues, present := values[name]
+		if !present || len(fieldValues) == 0 {
+			if options == "required" {
+				return ValidationError("%s: required", name)
+			}
+			continue
+		}
+
+		if err := setFieldValues(target.Field(i), fieldValues); err != nil {
+			return ValidationError("%s: %s", name, err.Error())
+		}
+	}
+	return nil
+}
+
+func setFieldValues(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Slice && !field.Addr().Type().Implements(textUnmarshalerType) {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setFieldValue(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+	return setFieldValue(field, values[0])
+}
+
+func setFieldValue(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Ptr {
+		ptr := reflect.New(field.Type().Elem())
+		if err := setFieldValue(ptr.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+
+	if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(value))
+	}
+
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("expected duration")
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("expected boolean")
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected integer")
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected unsigned integer")
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected number")
+		}
+		field.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package web_test
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/web"
+)
+
+type formParameters struct {
+	Name    string        `form:"name,required"`
+	Page    int           `form:"page"`
+	Ratio   *float64      `form:"ratio"`
+	Enabled bool          `form:"enabled"`
+	Tags    []string      `form:"tag"`
+	IDs     []uint16      `form:"id"`
+	Wait    time.Duration `form:"wait"`
+	Addr    net.IP        `form:"addr"`
+	Ignored string        `form:"-"`
+}
+
+func TestRequestDecodeQuery(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	path := randomString(5)
+	var params formParameters
+	server.API.GET("/"+path, func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		params = formParameters{}
+		if err := request.DecodeQuery(&params); err != nil {
+			return nil, nil, err
+		}
+		return true, nil, nil
+	}, web.HandleOptions{})
+
+	doTest := func(query string, expectedStatus int) {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/%s?%s", server.ListenPort, path, query))
+		if err != nil {
+			t.Fatalf("Network error: %s", err.Error())
+		}
+		resp.Body.Close()
+		if resp.StatusCode != expectedStatus {
+			t.Errorf("Unexpected status code for query '%s'. Expected %d got %d", query, expectedStatus, resp.StatusCode)
+		}
+	}
+
+	doTest("name=foo&page=2&ratio=0.5&enabled=true&tag=a&tag=b&id=1&id=2&wait=1s&addr=10.0.0.1&Ignored=x", 200)
+	if params.Name != "foo" || params.Page != 2 || params.Ratio == nil || *params.Ratio != 0.5 || !params.Enabled ||
+		strings.Join(params.Tags, ",") != "a,b" || len(params.IDs) != 2 || params.IDs[1] != 2 ||
+		params.Wait != time.Second || params.Addr.String() != "10.0.0.1" || params.Ignored != "" {
+		t.Errorf("Unexpected decoded parameters: %+v", params)
+	}
+
+	doTest("page=2", 400)
+	doTest("name=foo&page=two", 400)
+	doTest("name=foo&id=70000", 400)
+	doTest("name=foo&addr=nope", 400)
+}
+
+func TestRequestDecodeForm(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	path := randomString(5)
+	var params formParameters
+	server.API.POST("/"+path, func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		params = formParameters{}
+		if err := request.DecodeForm(&params); err != nil {
+			return nil, nil, err
+		}
+		return true, nil, nil
+	}, web.HandleOptions{})
+
+	resp, err := http.PostForm(fmt.Sprintf("http://localhost:%d/%s?page=9", server.ListenPort, path), url.Values{
+		"name": {"foo"},
+		"tag":  {"a", "b"},
+	})
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 200, resp.StatusCode)
+	}
+	if params.Name != "foo" || params.Page != 0 || len(params.Tags) != 2 {
+		t.Errorf("Unexpected decoded parameters: %+v", params)
+	}
+
+	resp, err = http.Post(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path), "application/json", strings.NewReader(`{"name":"foo"}`))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 400 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 400, resp.StatusCode)
+	}
+}
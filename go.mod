@@ -1,9 +1,18 @@
 module github.com/ecnepsnai/web
 
-go 1.19
+go 1.24
 
 require (
 	github.com/ecnepsnai/logtic v1.9.5
 	github.com/gorilla/websocket v1.5.3
+	github.com/quic-go/quic-go v0.59.0
 	golang.org/x/time v0.8.0
 )
+
+require (
+	github.com/quic-go/qpack v0.6.0 // indirect
+	golang.org/x/crypto v0.41.0 // indirect
+	golang.org/x/net v0.43.0 // indirect
+	golang.org/x/sys v0.35.0 // indirect
+	golang.org/x/text v0.28.0 // indirect
+)
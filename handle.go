@@ -3,6 +3,7 @@ package web
 import (
 	"net/http"
 	"reflect"
+	"time"
 )
 
 // APIHandle describes a method signature for handling an API request
@@ -25,6 +26,11 @@ type HandleOptions struct {
 	// the UnauthorizedMethod (if provided) or a default handle. If the AuthenticateMethod is not provided, then the
 	// UserData field is nil.
 	AuthenticateMethod func(request *http.Request) interface{}
+	// AuthorizeMethod method called after the request has been authenticated to determine if the user is permitted to
+	// access the handle, such as by checking their role. The userData is the value returned by the AuthenticateMethod,
+	// or nil if there is no AuthenticateMethod. Returning an error rejects the request with a "403 Forbidden" JSON
+	// response using the message of the error. If omitted, all authenticated requests are permitted.
+	AuthorizeMethod func(userData interface{}, request *http.Request) *Error
 	// PreHandle is an optional method that is called immediately upon receiving the HTTP request, before authentication
 	// and before rate limit checks. This method allows servers to provide early handling of a request before any
 	// processing happens.
@@ -41,11 +47,145 @@ type HandleOptions struct {
 	UnauthorizedMethod func(w http.ResponseWriter, request *http.Request)
 	// MaxBodyLength defines the maximum length accepted for any HTTP request body. Requests that exceed this limit will
 	// receive a "413 Payload Too Large" response. The default value of 0 will not reject requests with large bodies.
+	//
+	// Requests with a larger Content-Length are rejected before the handle is called. The body of other requests, such
+	// as chunked uploads, is limited to this length, and reading past it returns an [http.MaxBytesError]. Errors from
+	// [web.Request.DecodeJSON], [web.Request.File], and reverse proxy handles are translated to a 413 response.
 	MaxBodyLength uint64
+	// MultipartMemoryLimit defines the maximum number of bytes of a multipart/form-data request body that will be held
+	// in memory when using [web.Request.File] or [web.Request.Files], with the remainder stored in temporary files on
+	// disk. Defaults to 32MiB or MaxBodyLength, whichever is smaller. The total size of the upload is limited by
+	// MaxBodyLength.
+	MultipartMemoryLimit int64
+	// SoftDeadline defines the amount of time a handle should aim to complete within. The deadline is not enforced,
+	// instead handles can use [web.Request.Deadline] to determine how much time remains and return partial results.
+	// API responses returned after the soft deadline has passed will have the truncated property set.
+	SoftDeadline time.Duration
+	// PageSize defines the number of items returned by [web.Request.PageParams] when the request does not specify a
+	// limit. Defaults to 25.
+	PageSize int
+	// MaxPageSize defines the largest limit returned by [web.Request.PageParams]. Larger limits requested by the client
+	// are reduced to this size. Defaults to 100.
+	MaxPageSize int
+	// Timeout defines the maximum amount of time the handle has to complete. Once the timeout elapses, the context of
+	// the request is cancelled and a "503 Service Unavailable" JSON error is sent to the client, unless a HTTP handle has
+	// already started writing its response. Any response from the handle after the timeout is discarded. The default
+	// value of 0 uses the DefaultTimeout of the server, and a negative value disables the timeout for this handle. Not
+	// used for websocket handles.
+	Timeout time.Duration
+	// SocketSchema optionally describes the JSON messages accepted by a websocket handle. Only used for websocket
+	// handles.
+	SocketSchema *SocketSchema
+	// WebSocketReauthInterval defines how often the AuthenticateMethod is called again for an open websocket connection.
+	// If it returns nil, or the AuthorizeMethod denies the refreshed user data, then the connection is closed with a
+	// "policy violation" close code. Use
+	// [web.WSConn.OnReauthenticate] to receive the refreshed user data. The default value of 0 only authenticates the
+	// initial upgrade request. Only used for websocket handles.
+	WebSocketReauthInterval time.Duration
+	// WebSocketMaxMessageSize defines the maximum size in bytes of a message from the client. Connections that exceed
+	// this limit are closed with a "message too big" close code. The default value of 0 has no limit. Only used for
+	// websocket handles.
+	WebSocketMaxMessageSize int64
+	// WebSocketReadTimeout defines the maximum amount of time to wait for the next message from the client when
+	// reading from the [web.WSConn]. Each pong received from the client also extends the deadline, so idle clients that
+	// respond to the WebSocketPingInterval stay connected. Connections that exceed this timeout are closed. The default
+	// value of 0 has no timeout. Only used for websocket handles.
+	WebSocketReadTimeout time.Duration
+	// WebSocketWriteTimeout defines the maximum amount of time a write to the client may take. The default value of 0
+	// has no timeout. Only used for websocket handles.
+	WebSocketWriteTimeout time.Duration
+	// WebSocketPingInterval defines how often a ping is sent to the client to keep the connection alive. Pongs are only
+	// processed while the handle is reading from the connection. Connections where the ping can not be sent are closed.
+	// To close connections to unresponsive clients, set a WebSocketReadTimeout greater than this interval. The default
+	// value of 0 does not send pings. Only used for websocket handles.
+	WebSocketPingInterval time.Duration
+	// WebSocketSubprotocols defines the subprotocols supported by the handle, in order of preference. The first
+	// subprotocol from this list that the client requested in its 'Sec-WebSocket-Protocol' header is selected, and is
+	// available from [web.WSConn.Subprotocol]. Clients that do not request a supported subprotocol are still accepted
+	// without one, handles that require a subprotocol should close these connections. Only used for websocket handles.
+	WebSocketSubprotocols []string
+	// WebSocketCompression if true then permessage-deflate compression is negotiated with clients that support it.
+	// Only used for websocket handles.
+	WebSocketCompression bool
+	// WebSocketCompressionLevel defines the flate compression level used for messages when compression was negotiated,
+	// from -2 to 9. The default value of 0 uses the default compression level. Only used for websocket handles.
+	WebSocketCompressionLevel int
+	// MatchHeaders optionally registers the handle as a variant of the method and path that only serves requests with
+	// matching headers, such as {"X-Api-Channel": "beta"} for a canary release. Any number of variants may be
+	// registered for the same method and path, along with one handle without MatchHeaders or Weight that serves all
+	// other requests. Requests are served by the first variant, in order of registration, where every header matches.
+	//
+	// A header matches if any of its comma-separated values, ignoring parameters and case, is equal to the value, so
+	// {"Accept": "application/vnd.example.v2+json"} matches a request that accepts that media type. A value of "*"
+	// matches any request with the header. Responses from variant routes include the headers in the 'Vary' header.
+	MatchHeaders map[string]string
+	// Weight optionally registers the handle as a variant of the method and path that serves this percentage of
+	// requests, from 1 to 100, such as 5 to send 5% of requests to a new implementation of a handle. Requests that are
+	// not selected for any weighted variant are served by the handle without MatchHeaders or Weight. If MatchHeaders is
+	// also set then the percentage applies only to requests with matching headers. The total weight of all variants of
+	// a method and path may not exceed 100. Weighted variants must have a Variant name.
+	Weight int
+	// WeightKey optionally returns a key identifying the client of a request, such as the ID of the user or a session
+	// cookie, so that the same client is always served by the same weighted variant. If omitted or if the key is empty,
+	// variants are selected at random for each request. Only used when Weight is set.
+	WeightKey func(request *http.Request) string
+	// Variant optionally names the variant of the route, such as "canary". Each variant of a route has its own metrics
+	// from [web.Server.RouteMetrics], and its name is included in the [web.RequestEvent] of each request.
+	Variant string
+	// Cache optionally enables caching of the responses of an API or HTTPEasy handle. Successful responses to GET and
+	// HEAD requests are saved in the CacheStore of the server, keyed by the method, path, query, and user of the
+	// request, and served without calling the handle until they expire. Responses that set cookies are never cached.
+	// Use [web.Server.InvalidateCache] to remove cached responses once the underlying data changes.
+	Cache *CacheOptions
+	// Deduplicate optionally answers exact duplicates of a request to an API or HTTPEasy handle with the response to the
+	// original request, without calling the handle again, to absorb double submissions and retries of non-idempotent
+	// requests. Requests are duplicates if they are from the same client address with the same credentials, method,
+	// path, query, and body, and are received within the window of the original request. Duplicates received while the
+	// original request is still being handled wait for its response. Not used for requests that are cached.
+	Deduplicate *DeduplicateOptions
+	// SLO optionally defines service level objectives for the error rate and latency of the handle, which are tracked
+	// over a rolling window. The status of the objectives is included in [web.Server.RouteMetrics], and the OnSLOAlert
+	// server option is called when the route starts or stops using its error budget too quickly.
+	SLO *SLO
+	// PayloadEncryption optionally encrypts the request and response bodies of an API handle, for data that requires
+	// protection beyond TLS. See [web.PayloadEncryption]. Only used for API handles.
+	PayloadEncryption *PayloadEncryption
+	// BodyMigrations optionally transforms JSON request bodies from older versions of an API into the current shape
+	// before they are decoded by [web.Request.DecodeJSON], ordered from the oldest version to the newest. The version
+	// of a request is read from the header named by BodyVersionHeader, and every migration from the one for that
+	// version onwards is applied in order. Requests without a version, or with a version that has no migration, are
+	// decoded unchanged.
+	BodyMigrations []BodyMigration
+	// BodyVersionHeader defines the name of the request header with the version of the body, used by BodyMigrations.
+	// Defaults to "API-Version".
+	BodyVersionHeader string
+	// PanicHandler optionally replaces the PanicHandler server option for this handle, called with the value and stack
+	// trace of any panic recovered from the handle.
+	PanicHandler func(recovered interface{}, stack []byte, request Request)
+	// DisableRateLimit if true then requests to this handle are never rate limited, such as for health checks.
+	DisableRateLimit bool
+	// AllowDuringMaintenance if true then this handle continues to serve requests while the Maintenance server option is
+	// enabled, such as for health checks.
+	AllowDuringMaintenance bool
+	// RateLimitCost defines how many requests from the rate limit budget of the client each request to this handle
+	// consumes, such as 10 for an expensive search and 1 for a ping. Costs greater than the MaxRequestsPerSecond of the
+	// server are reduced to it. Defaults to 1.
+	RateLimitCost int
 	// DontLogRequests if true then requests to this handle are not logged
 	DontLogRequests bool
+	// Description optionally describes the purpose of the handle, such as "Returns the user with the given ID". The
+	// description is included in the route reference served by [web.Server.RouteReference] and in the routes of
+	// [web.Server.Config].
+	Description string
+	// Annotations optionally describe additional properties of the handle for the route reference, such as
+	// "Permission": "admin" or "Deprecated": "Use /v2/users instead".
+	Annotations map[string]string
 }
 
 func isUserdataNil(userData interface{}) bool {
 	return userData == nil || (reflect.ValueOf(userData).Kind() == reflect.Ptr && reflect.ValueOf(userData).IsNil())
 }
+
+// SocketMessageHandle describes a method signature for handling a single JSON message on a websocket registered with
+// [web.SocketT]. The returned value or error is sent to the client as a [web.JSONResponse].
+type SocketMessageHandle[In, Out any] func(request Request, message In) (Out, *Error)
@@ -0,0 +1,86 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// HandoffEnv is the name of the environment variable used to pass the file descriptor of the listening socket to a
+// child process started with [web.Server.Handoff].
+const HandoffEnv = "WEB_LISTEN_FD"
+
+// inheritedListener returns the listener passed to this process by [web.Server.Handoff], if there is one. The
+// environment variable is cleared so that the listener is only used once.
+func inheritedListener() (net.Listener, bool, error) {
+	value := os.Getenv(HandoffEnv)
+	if value == "" {
+		return nil, false, nil
+	}
+	os.Unsetenv(HandoffEnv)
+
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid inherited listener file descriptor %s", value)
+	}
+	file := os.NewFile(uintptr(fd), "listener")
+	defer file.Close()
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, true, err
+	}
+	return listener, true, nil
+}
+
+// Handoff restarts the application without dropping connections. The listening socket of the server is passed to cmd,
+// which is started as a child process, and then the server is gracefully shut down the same as Shutdown, finishing
+// active requests while the child accepts new connections. If cmd is nil then the same executable is started again
+// with the same arguments, environment, and standard output and error.
+//
+// The child process uses the socket automatically when its server is started with Start(), provided that it was
+// created with web.New(). Handoff is not supported on Windows or for listeners that are not backed by a file, such as
+// those created with web.NewListener() from a custom listener.
+//
+// The child process is returned once it has been started, even if the shutdown of the server returned an error.
+func (s *Server) Handoff(ctx context.Context, cmd *exec.Cmd) (*os.Process, error) {
+	filer, ok := s.baseListener.(interface{ File() (*os.File, error) })
+	if !ok {
+		return nil, fmt.Errorf("listener does not support handoff")
+	}
+	file, err := filer.File()
+	if err != nil {
+		log.PError("Error getting file for listener", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	defer file.Close()
+
+	if cmd == nil {
+		cmd = exec.Command(os.Args[0], os.Args[1:]...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+	}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	// Extra files start after standard input, output, and error
+	cmd.ExtraFiles = append(cmd.ExtraFiles, file)
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%d", HandoffEnv, 2+len(cmd.ExtraFiles)))
+	if err := cmd.Start(); err != nil {
+		log.PError("Error starting process for handoff", map[string]interface{}{
+			"path":  cmd.Path,
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	log.PWarn("Handed off listener to new process", map[string]interface{}{
+		"path": cmd.Path,
+		"pid":  cmd.Process.Pid,
+	})
+	return cmd.Process, s.Shutdown(ctx)
+}
@@ -0,0 +1,92 @@
+package web_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/web"
+)
+
+// TestHandoffChild is run in a child process by TestHandoff
+func TestHandoffChild(t *testing.T) {
+	if os.Getenv("WEB_TEST_HANDOFF_CHILD") == "" {
+		t.Skip("Only run as a child of TestHandoff")
+	}
+
+	server := web.New("127.0.0.1:0")
+	server.HTTP.GET("/", func(w http.ResponseWriter, r web.Request) {
+		w.Write([]byte("child"))
+	}, web.HandleOptions{})
+	go func() {
+		time.Sleep(2 * time.Second)
+		server.Stop()
+	}()
+	if err := server.Start(); err != nil {
+		t.Fatalf("Error starting server: %s", err.Error())
+	}
+}
+
+func TestHandoff(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Handoff is not supported on Windows")
+	}
+
+	server := web.New("127.0.0.1:0")
+	listening := make(chan net.Addr, 1)
+	server.Options.OnListen = func(address net.Addr) {
+		listening <- address
+	}
+	server.HTTP.GET("/", func(w http.ResponseWriter, r web.Request) {
+		w.Write([]byte("parent"))
+	}, web.HandleOptions{})
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- server.Start()
+	}()
+	address := (<-listening).String()
+
+	get := func() string {
+		resp, err := (&http.Client{Transport: &http.Transport{DisableKeepAlives: true}}).Get(fmt.Sprintf("http://%s/", address))
+		if err != nil {
+			t.Fatalf("Network error: %s", err.Error())
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return string(body)
+	}
+	if body := get(); body != "parent" {
+		t.Fatalf("Unexpected response. Expected '%s' got '%s'", "parent", body)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHandoffChild$")
+	cmd.Env = append(os.Environ(), "WEB_TEST_HANDOFF_CHILD=1")
+	process, err := server.Handoff(context.Background(), cmd)
+	if err != nil {
+		t.Fatalf("Error handing off listener: %s", err.Error())
+	}
+	defer process.Wait()
+
+	if err := <-stopped; err != nil {
+		t.Fatalf("Unexpected error from stopped server: %s", err.Error())
+	}
+	if body := get(); body != "child" {
+		t.Errorf("Unexpected response. Expected '%s' got '%s'", "child", body)
+	}
+}
+
+func TestHandoffUnsupportedListener(t *testing.T) {
+	t.Parallel()
+
+	server := web.NewMockServer()
+	if _, err := server.Handoff(context.Background(), nil); err == nil {
+		t.Errorf("No error seen for handoff without a listener")
+	}
+}
@@ -0,0 +1,50 @@
+package web_test
+
+import (
+	"net/http"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestDefaultHeaders(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+	server.Options.DefaultHeaders = web.SecurityHeaders("")
+
+	server.API.GET("/api", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}, web.HandleOptions{})
+	server.HTTP.GET("/embed", func(w http.ResponseWriter, r web.Request) {
+		w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+		w.Write([]byte("embed"))
+	}, web.HandleOptions{})
+	server.HTTPEasy.StaticFS("/static/", fstest.MapFS{
+		"app.js": &fstest.MapFile{Data: []byte("app")},
+	}, web.StaticOptions{})
+	server.Socket("/socket", func(request web.Request, conn *web.WSConn) {}, web.HandleOptions{})
+
+	for _, path := range []string{"/api", "/embed", "/static/app.js", "/socket", "/missing"} {
+		response := server.Request("GET", path, nil)
+		for header, expected := range map[string]string{
+			"Content-Security-Policy": web.DefaultContentSecurityPolicy,
+			"X-Content-Type-Options":  "nosniff",
+			"Referrer-Policy":         "strict-origin-when-cross-origin",
+		} {
+			if actual := response.Header.Get(header); actual != expected {
+				t.Errorf("Unexpected %s header for '%s'. Expected '%s' got '%s'", header, path, expected, actual)
+			}
+		}
+	}
+
+	if frameOptions := server.Request("GET", "/embed", nil).Header.Get("X-Frame-Options"); frameOptions != "SAMEORIGIN" {
+		t.Errorf("Default header not replaced by handle. Expected '%s' got '%s'", "SAMEORIGIN", frameOptions)
+	}
+	if frameOptions := server.Request("GET", "/api", nil).Header.Get("X-Frame-Options"); frameOptions != "DENY" {
+		t.Errorf("Unexpected X-Frame-Options header. Expected '%s' got '%s'", "DENY", frameOptions)
+	}
+	if csp := web.SecurityHeaders("default-src 'none'")["Content-Security-Policy"]; csp != "default-src 'none'" {
+		t.Errorf("Unexpected Content-Security-Policy. Expected '%s' got '%s'", "default-src 'none'", csp)
+	}
+}
@@ -0,0 +1,176 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultHealthCheckTimeout = 5 * time.Second
+
+// HealthCheck describes a method that checks the health of a dependency of the application, such as a database. The
+// check should return promptly once ctx is done.
+type HealthCheck func(ctx context.Context) error
+
+// Health describes the health checks of a server. Register checks with AddCheck, then call Register to add the
+// '/healthz' and '/readyz' handles.
+type Health struct {
+	server *Server
+}
+
+// HealthReport describes the result of running all health checks of a server
+type HealthReport struct {
+	// The overall status. One of "ok", "failing", "warming up", or "lame duck".
+	Status string `json:"status"`
+	// The result of each health check, keyed by the name of the check
+	Checks map[string]HealthCheckResult `json:"checks"`
+}
+
+// HealthCheckResult describes the result of a single health check
+type HealthCheckResult struct {
+	// Either "ok" or "failing"
+	Status string `json:"status"`
+	// The error returned by the check, if it failed
+	Error string `json:"error,omitempty"`
+	// The time taken to run the check
+	Elapsed string `json:"elapsed"`
+}
+
+type healthRegistry struct {
+	checks map[string]HealthCheck
+	lock   *sync.RWMutex
+}
+
+func newHealthRegistry() *healthRegistry {
+	return &healthRegistry{
+		checks: map[string]HealthCheck{},
+		lock:   &sync.RWMutex{},
+	}
+}
+
+// AddCheck registers a health check with the given name, replacing any existing check with the same name.
+func (h Health) AddCheck(name string, check HealthCheck) {
+	h.server.health.lock.Lock()
+	defer h.server.health.lock.Unlock()
+	h.server.health.checks[name] = check
+}
+
+// RemoveCheck removes the health check with the given name. Does nothing if no check exists.
+func (h Health) RemoveCheck(name string) {
+	h.server.health.lock.Lock()
+	defer h.server.health.lock.Unlock()
+	delete(h.server.health.checks, name)
+}
+
+// Check runs all health checks concurrently and returns the report. Each check is given up to the HealthCheckTimeout
+// of the server to complete. The status of the report is "ok" if every check passed, regardless of the readiness of
+// the server.
+func (h Health) Check(ctx context.Context) HealthReport {
+	h.server.health.lock.RLock()
+	checks := make(map[string]HealthCheck, len(h.server.health.checks))
+	for name, check := range h.server.health.checks {
+		checks[name] = check
+	}
+	h.server.health.lock.RUnlock()
+
+	timeout := h.server.Options.HealthCheckTimeout
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	report := HealthReport{
+		Status: "ok",
+		Checks: make(map[string]HealthCheckResult, len(checks)),
+	}
+	lock := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check HealthCheck) {
+			defer wg.Done()
+			start := time.Now()
+			result := HealthCheckResult{Status: "ok"}
+			if err := check(ctx); err != nil {
+				result.Status = "failing"
+				result.Error = err.Error()
+			}
+			result.Elapsed = time.Since(start).String()
+
+			lock.Lock()
+			defer lock.Unlock()
+			report.Checks[name] = result
+			if result.Status != "ok" {
+				report.Status = "failing"
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	if report.Status != "ok" {
+		names := make([]string, 0, len(report.Checks))
+		for name, result := range report.Checks {
+			if result.Status != "ok" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		log.PWarn("Health check failing", map[string]interface{}{
+			"checks": names,
+		})
+	}
+	return report
+}
+
+// Register adds GET and HEAD handles for '/healthz' and '/readyz' that respond with the [web.HealthReport] as JSON.
+// '/healthz' responds with "200 OK" if all checks pass, otherwise "503 Service Unavailable". '/readyz' also responds
+// with "503 Service Unavailable" while the server is warming up, in lame duck mode, or shutting down. Requests to these
+// handles are not logged or rate limited, and continue to be served in maintenance mode.
+func (h Health) Register() {
+	options := HandleOptions{
+		DontLogRequests:        true,
+		DisableRateLimit:       true,
+		AllowDuringMaintenance: true,
+	}
+	h.server.HTTPEasy.GET("/healthz", h.healthzHandle, options)
+	h.server.HTTPEasy.HEAD("/healthz", h.healthzHandle, options)
+	h.server.HTTPEasy.GET("/readyz", h.readyzHandle, options)
+	h.server.HTTPEasy.HEAD("/readyz", h.readyzHandle, options)
+}
+
+func (h Health) healthzHandle(request Request) HTTPResponse {
+	return h.reportResponse(h.Check(request.HTTP.Context()))
+}
+
+func (h Health) readyzHandle(request Request) HTTPResponse {
+	report := h.Check(request.HTTP.Context())
+	if report.Status == "ok" && !h.server.IsReady() {
+		report.Status = "warming up"
+		if h.server.isLameDuck() {
+			report.Status = "lame duck"
+		}
+	}
+	return h.reportResponse(report)
+}
+
+func (h Health) reportResponse(report HealthReport) HTTPResponse {
+	buf := &bytes.Buffer{}
+	h.server.jsonEncoder().NewEncoder(buf).Encode(report)
+	status := 200
+	if report.Status != "ok" {
+		status = 503
+	}
+	return HTTPResponse{
+		Reader:        io.NopCloser(buf),
+		Status:        status,
+		ContentType:   "application/json",
+		ContentLength: uint64(buf.Len()),
+		Headers: map[string]string{
+			"Cache-Control": "no-store",
+		},
+	}
+}
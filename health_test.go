@@ -0,0 +1,74 @@
+package web_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestHealth(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	var failing int32
+	server.Health.AddCheck("database", func(ctx context.Context) error {
+		if atomic.LoadInt32(&failing) == 1 {
+			return fmt.Errorf("connection refused")
+		}
+		return nil
+	})
+	server.Health.AddCheck("cache", func(ctx context.Context) error {
+		return nil
+	})
+	server.Health.Register()
+
+	doTest := func(path string, expectedStatus int, expectedReport string) web.HealthReport {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path))
+		if err != nil {
+			t.Fatalf("Network error: %s", err.Error())
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != expectedStatus {
+			t.Errorf("Unexpected status code for %s. Expected %d got %d", path, expectedStatus, resp.StatusCode)
+		}
+		report := web.HealthReport{}
+		if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+			t.Fatalf("Error decoding health report: %s", err.Error())
+		}
+		if report.Status != expectedReport {
+			t.Errorf("Unexpected status for %s. Expected '%s' got '%s'", path, expectedReport, report.Status)
+		}
+		return report
+	}
+
+	report := doTest("healthz", 200, "ok")
+	if len(report.Checks) != 2 || report.Checks["database"].Status != "ok" {
+		t.Errorf("Unexpected checks in report: %+v", report.Checks)
+	}
+	doTest("readyz", 200, "ok")
+
+	atomic.StoreInt32(&failing, 1)
+	report = doTest("healthz", 503, "failing")
+	if report.Checks["database"].Error != "connection refused" || report.Checks["cache"].Status != "ok" {
+		t.Errorf("Unexpected checks in report: %+v", report.Checks)
+	}
+	atomic.StoreInt32(&failing, 0)
+
+	server.Warmup()
+	doTest("healthz", 200, "ok")
+	doTest("readyz", 503, "warming up")
+	server.LameDuck()
+	doTest("readyz", 503, "lame duck")
+	server.Ready()
+	doTest("readyz", 200, "ok")
+
+	server.Health.RemoveCheck("database")
+	if report := server.Health.Check(context.Background()); len(report.Checks) != 1 {
+		t.Errorf("Unexpected number of checks. Expected %d got %d", 1, len(report.Checks))
+	}
+}
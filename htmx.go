@@ -0,0 +1,99 @@
+package web
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// HTMXRequest describes the headers sent by htmx with a request
+type HTMXRequest struct {
+	// If the request was made by an element using hx-boost
+	Boosted bool
+	// The URL of the page in the browser
+	CurrentURL string
+	// If the request is to restore history after a miss in the local history cache
+	HistoryRestore bool
+	// The response of the user to an hx-prompt
+	Prompt string
+	// The id of the target element, if it has one
+	Target string
+	// The id of the element that triggered the request, if it has one
+	Trigger string
+	// The name of the element that triggered the request, if it has one
+	TriggerName string
+}
+
+// HTMX returns the details of the request and true if it was made by htmx, or false if it is a regular request. Handles
+// that return a fragment for htmx requests and a full page otherwise should include "HX-Request" in the 'Vary' header
+// of their response so that browsers cache each separately. Responses cached with the Cache handle option are already
+// cached separately.
+func (r Request) HTMX() (HTMXRequest, bool) {
+	if r.HTTP == nil || r.HTTP.Header.Get("HX-Request") != "true" {
+		return HTMXRequest{}, false
+	}
+	return HTMXRequest{
+		Boosted:        r.HTTP.Header.Get("HX-Boosted") == "true",
+		CurrentURL:     r.HTTP.Header.Get("HX-Current-URL"),
+		HistoryRestore: r.HTTP.Header.Get("HX-History-Restore-Request") == "true",
+		Prompt:         r.HTTP.Header.Get("HX-Prompt"),
+		Target:         r.HTTP.Header.Get("HX-Target"),
+		Trigger:        r.HTTP.Header.Get("HX-Trigger"),
+		TriggerName:    r.HTTP.Header.Get("HX-Trigger-Name"),
+	}, true
+}
+
+// HTMXRedirect returns a HTTPResponse that has htmx perform a full page load of location. Unlike [web.Redirect] the
+// response status is "200 OK", as htmx follows regular redirects itself and would swap the new page into the target.
+//
+// For example:
+//
+//	server.HTTPEasy.POST("/login", func(request web.Request) web.HTTPResponse {
+//	    return web.HTMXRedirect("/dashboard")
+//	}, web.HandleOptions{})
+func HTMXRedirect(location string) HTTPResponse {
+	return HTTPResponse{
+		Headers: map[string]string{"HX-Redirect": location},
+	}
+}
+
+// HTMXRefresh returns a HTTPResponse that has htmx perform a full refresh of the page.
+func HTMXRefresh() HTTPResponse {
+	return HTTPResponse{
+		Headers: map[string]string{"HX-Refresh": "true"},
+	}
+}
+
+// HTMXTrigger adds an event that htmx triggers on the client once the response is received, using the 'HX-Trigger'
+// header. Detail is encoded as JSON and is available to event listeners as event.detail, and may be nil. Multiple
+// events can be added to the same response.
+//
+// For example:
+//
+//	response := web.HTTPResponse{Template: "row.html", Data: user}
+//	response.HTMXTrigger("showMessage", "User saved")
+//	return response
+func (r *HTTPResponse) HTMXTrigger(event string, detail interface{}) {
+	if r.Headers == nil {
+		r.Headers = map[string]string{}
+	}
+	events := map[string]interface{}{}
+	if existing := r.Headers["HX-Trigger"]; existing != "" {
+		if err := json.Unmarshal([]byte(existing), &events); err != nil {
+			// The existing value is a comma separated list of event names
+			events = map[string]interface{}{}
+			for _, name := range strings.Split(existing, ",") {
+				events[strings.TrimSpace(name)] = nil
+			}
+		}
+	}
+	events[event] = detail
+	value, err := json.Marshal(events)
+	if err != nil {
+		log.PError("Error encoding htmx trigger", map[string]interface{}{
+			"event": event,
+			"error": err.Error(),
+		})
+		return
+	}
+	r.Headers["HX-Trigger"] = string(value)
+}
@@ -0,0 +1,77 @@
+package web_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestHTMXRequest(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	server.HTTPEasy.GET("/users", func(request web.Request) web.HTTPResponse {
+		htmx, ok := request.HTMX()
+		if !ok {
+			return web.HTTPResponse{Reader: io.NopCloser(strings.NewReader("page"))}
+		}
+		return web.HTTPResponse{Reader: io.NopCloser(strings.NewReader("fragment:" + htmx.Target + ":" + htmx.Trigger))}
+	}, web.HandleOptions{})
+
+	if response := server.Request("GET", "/users", nil); string(response.Body) != "page" {
+		t.Errorf("Unexpected response. Expected '%s' got '%s'", "page", response.Body)
+	}
+
+	req := httptest.NewRequest("GET", "/users", nil)
+	req.Header.Set("HX-Request", "true")
+	req.Header.Set("HX-Target", "list")
+	req.Header.Set("HX-Trigger", "search")
+	if response := server.Do(req); string(response.Body) != "fragment:list:search" {
+		t.Errorf("Unexpected response. Expected '%s' got '%s'", "fragment:list:search", response.Body)
+	}
+}
+
+func TestHTMXResponse(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	server.HTTPEasy.POST("/login", func(request web.Request) web.HTTPResponse {
+		return web.HTMXRedirect("/dashboard")
+	}, web.HandleOptions{})
+	server.HTTPEasy.POST("/users", func(request web.Request) web.HTTPResponse {
+		response := web.HTTPResponse{Reader: io.NopCloser(strings.NewReader("<tr></tr>"))}
+		response.HTMXTrigger("userCreated", nil)
+		response.HTMXTrigger("showMessage", "User saved")
+		return response
+	}, web.HandleOptions{})
+
+	response := server.Request("POST", "/login", nil)
+	if response.Status != 200 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 200, response.Status)
+	}
+	if location := response.Header.Get("HX-Redirect"); location != "/dashboard" {
+		t.Errorf("Unexpected redirect. Expected '%s' got '%s'", "/dashboard", location)
+	}
+
+	response = server.Request("POST", "/users", nil)
+	events := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(response.Header.Get("HX-Trigger")), &events); err != nil {
+		t.Fatalf("Error decoding trigger header: %s", err.Error())
+	}
+	if detail, ok := events["userCreated"]; !ok || detail != nil {
+		t.Errorf("Unexpected userCreated event: %v", events)
+	}
+	if detail := events["showMessage"]; detail != "User saved" {
+		t.Errorf("Unexpected showMessage event detail. Expected '%s' got '%v'", "User saved", detail)
+	}
+
+	listed := web.HTTPResponse{Headers: map[string]string{"HX-Trigger": "one, two"}}
+	listed.HTMXTrigger("three", 3)
+	if value := listed.Headers["HX-Trigger"]; value != `{"one":null,"three":3,"two":null}` {
+		t.Errorf("Unexpected trigger header '%s'", value)
+	}
+}
@@ -1,6 +1,7 @@
 package web
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"runtime/debug"
@@ -50,12 +51,18 @@ func (h HTTP) DELETE(path string, handle HTTPHandle, options HandleOptions) {
 	h.registerHTTPEndpoint("DELETE", path, handle, options)
 }
 
+// Unregister removes the handle, and any variants of it, registered for the method and path, even while the server is
+// serving requests. See [web.API.Unregister].
+func (h HTTP) Unregister(method, path string) bool {
+	return h.server.unregisterRoute(method, path)
+}
+
 func (h HTTP) registerHTTPEndpoint(method string, path string, handle HTTPHandle, options HandleOptions) {
 	log.PDebug("Register HTTP endpoint", map[string]interface{}{
 		"method": method,
 		"path":   path,
 	})
-	h.server.router.Handle(method, path, h.httpPreHandle(handle, options))
+	h.server.registerRoute(method, path, h.server.measure(method, path, options, h.server.dispatch(h.httpPreHandle(handle, options))), options)
 }
 
 func (h HTTP) httpPreHandle(endpointHandle HTTPHandle, options HandleOptions) router.Handle {
@@ -66,7 +73,15 @@ func (h HTTP) httpPreHandle(endpointHandle HTTPHandle, options HandleOptions) ro
 			}
 		}
 
-		if h.server.isRateLimited(w, request.HTTP) {
+		if h.server.isUnavailable(w, request.HTTP, options) {
+			return
+		}
+
+		if h.server.isBanned(w, request.HTTP) {
+			return
+		}
+
+		if h.server.isRateLimited(w, request.HTTP, options) {
 			return
 		}
 
@@ -80,20 +95,28 @@ func (h HTTP) httpPreHandle(endpointHandle HTTPHandle, options HandleOptions) ro
 					"body_length": length,
 					"max_length":  options.MaxBodyLength,
 				})
-				w.WriteHeader(413)
+				h.server.reportRejected(request.HTTP, RejectedPayloadTooLarge, 413)
+				h.server.writeError(w, CommonErrors.PayloadTooLarge, "")
 				return
 			}
+
+			// The Content-Length header may be missing, such as with chunked requests, so limit what is actually read
+			limitRequestBody(w, request.HTTP, options.MaxBodyLength)
 		}
 
 		var userData interface{}
 		if options.AuthenticateMethod != nil {
-			userData = options.AuthenticateMethod(request.HTTP)
+			userData = h.server.authenticate(w, request.HTTP, options)
+			if h.server.isUserRateLimited(w, request.HTTP, userData, options) {
+				return
+			}
 			if isUserdataNil(userData) {
+				h.server.reportRejected(request.HTTP, RejectedUnauthenticated, 401)
 				if options.UnauthorizedMethod == nil {
 					log.PWarn("Rejected request to authenticated HTTP endpoint", map[string]interface{}{
 						"url":         request.HTTP.URL,
 						"method":      request.HTTP.Method,
-						"remote_addr": RealRemoteAddr(request.HTTP),
+						"remote_addr": h.server.realRemoteAddr(request.HTTP),
 					})
 					w.Header().Set("Content-Type", "text/html")
 					w.WriteHeader(http.StatusUnauthorized)
@@ -105,32 +128,70 @@ func (h HTTP) httpPreHandle(endpointHandle HTTPHandle, options HandleOptions) ro
 				return
 			}
 		}
+		spanUser(request.HTTP, userData)
+		if h.server.isForbidden(w, request.HTTP, userData, options) || selfTestHandled(w, request.HTTP) {
+			return
+		}
 		start := time.Now()
+		handleRequest := Request{
+			HTTP:       request.HTTP,
+			Parameters: request.Parameters,
+			UserData:   userData,
+			server:     h.server,
+			options:    options,
+			start:      start,
+			decoder:    h.server.jsonDecoder(),
+			traced:     isTraced(w),
+			writer:     w,
+		}
+		if !options.DontLogRequests {
+			// Logged once the handle has returned, or its panic has been recovered, so that the status and number of
+			// bytes written are known
+			defer func() {
+				elapsed := time.Since(start)
+				h.server.logRequest(w, request.HTTP, "HTTP Request", responseStatus(w), elapsed, map[string]interface{}{
+					"remote_addr":    h.server.realRemoteAddr(request.HTTP),
+					"method":         request.HTTP.Method,
+					"url":            request.HTTP.URL,
+					"elapsed":        elapsed.String(),
+					"status":         responseStatus(w),
+					"request_bytes":  requestBytes(request.HTTP),
+					"response_bytes": responseBytes(w),
+				})
+			}()
+		}
 		defer func() {
 			if p := recover(); p != nil {
+				stack := debug.Stack()
 				log.PError("Recovered from panic during HTTP handle", map[string]interface{}{
 					"error":  fmt.Sprintf("%v", p),
 					"route":  request.HTTP.URL.Path,
 					"method": request.HTTP.Method,
-					"stack":  string(debug.Stack()),
+					"stack":  string(stack),
 				})
+				h.server.reportPanic(p, stack, handleRequest, options)
+				setResponseError(w, fmt.Sprintf("%v", p))
 				w.WriteHeader(500)
 			}
 		}()
 
-		endpointHandle(w, Request{
-			HTTP:       request.HTTP,
-			Parameters: request.Parameters,
-			UserData:   userData,
-		})
-		elapsed := time.Since(start)
-		if !options.DontLogRequests {
-			log.PWrite(h.server.Options.RequestLogLevel, "HTTP Request", map[string]interface{}{
-				"remote_addr": RealRemoteAddr(request.HTTP),
-				"method":      request.HTTP.Method,
-				"url":         request.HTTP.URL,
-				"elapsed":     elapsed.String(),
-			})
+		traceMark(w, "prehandle")
+		if timeout := h.server.handleTimeout(options); timeout > 0 {
+			var cancel context.CancelFunc
+			handleRequest.HTTP, cancel = withTimeout(request.HTTP, timeout)
+			defer cancel()
+			writer := newTimeoutWriter(handleRequest.HTTP.Context(), w)
+			if _, ok := callWithTimeout(handleRequest.HTTP.Context(), func() interface{} {
+				endpointHandle(writer, handleRequest)
+				return nil
+			}, h.server.abandonHandle(request.HTTP, start, nil)); !ok {
+				h.server.logTimeout(w, request.HTTP, timeout)
+				if writer.timeout() {
+					h.server.writeTimeout(w)
+				}
+			}
+		} else {
+			endpointHandle(w, handleRequest)
 		}
 	}
 }
@@ -0,0 +1,112 @@
+// Package http3 serves the routes of a [web.Server] over HTTP/3 (QUIC), such as for mobile clients on networks with
+// high latency or packet loss. It is a separate package so that applications that don't use HTTP/3 do not build the
+// QUIC implementation.
+//
+// The HTTP/3 server listens on UDP alongside the TCP listener of the web server, and serves the same API, HTTP,
+// HTTPEasy, and static routes. Clients discover it from the 'Alt-Svc' header added to responses sent over TCP.
+// Websockets are not supported over HTTP/3.
+//
+//	server := web.New("0.0.0.0:443")
+//	server.Options.TLSConfig = tlsConfig
+//	quicServer := http3.New(server, "0.0.0.0:443")
+//	go quicServer.Start()
+//	server.Start()
+package http3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/ecnepsnai/logtic"
+	"github.com/ecnepsnai/web"
+	"github.com/quic-go/quic-go/http3"
+)
+
+var log = logtic.Log.Connect("HTTP3")
+
+// Server describes a HTTP/3 server for the routes of a web server
+type Server struct {
+	// The UDP address that the server listens on, such as "0.0.0.0:443"
+	BindAddress string
+
+	server   *web.Server
+	h3Server *http3.Server
+}
+
+// New creates a new HTTP/3 server that will serve the routes of server on the UDP bind address. The TLSConfig option of
+// server must be set, as HTTP/3 always uses TLS. Does not accept incoming connections until the server is started.
+//
+// Unless the AltSvc option of server is already set, it is set to advertise the port of the bind address to clients
+// connecting over TCP. When the port is not the one clients should use, such as behind a load balancer, set the AltSvc
+// option after calling this method.
+func New(server *web.Server, bindAddress string) *Server {
+	if server.Options.AltSvc == "" {
+		if _, port, err := net.SplitHostPort(bindAddress); err == nil && port != "0" {
+			server.Options.AltSvc = fmt.Sprintf(`%s=":%s"; ma=2592000`, http3.NextProtoH3, port)
+		}
+	}
+	return &Server{
+		BindAddress: bindAddress,
+		server:      server,
+		h3Server: &http3.Server{
+			Addr: bindAddress,
+		},
+	}
+}
+
+// Start will start the HTTP/3 server and listen on the UDP bind address. This method blocks.
+// If the server is stopped using the Stop() or Shutdown() methods, this returns no error.
+func (s *Server) Start() error {
+	udpAddress, err := net.ResolveUDPAddr("udp", s.BindAddress)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenUDP("udp", udpAddress)
+	if err != nil {
+		log.PError("Error listening on address", map[string]interface{}{
+			"listen_address": s.BindAddress,
+			"error":          err.Error(),
+		})
+		return err
+	}
+	defer conn.Close()
+	log.PInfo("HTTP/3 server listen", map[string]interface{}{
+		"listen_address": conn.LocalAddr().String(),
+	})
+	return s.Serve(conn)
+}
+
+// Serve will start the HTTP/3 server and accept incoming connections on the given UDP connection. This method blocks.
+// The connection is not closed when the server is stopped.
+// If the server is stopped using the Stop() or Shutdown() methods, this returns no error.
+func (s *Server) Serve(conn net.PacketConn) error {
+	tlsConfig := s.server.TLSConfig()
+	if tlsConfig == nil {
+		return fmt.Errorf("the TLSConfig option of the web server must be set to use HTTP/3")
+	}
+	s.h3Server.TLSConfig = tlsConfig
+	s.h3Server.Handler = s.server.Handler()
+
+	if err := s.h3Server.Serve(conn); err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, net.ErrClosed) {
+		return err
+	}
+	log.Info("HTTP/3 server stopped")
+	return nil
+}
+
+// Shutdown will gracefully stop the server, waiting for active requests to complete or for ctx to be done, in which
+// case the error of ctx is returned. The server can not be started again after it has been shut down.
+func (s *Server) Shutdown(ctx context.Context) error {
+	log.Warn("Shutting down HTTP/3 server")
+	return s.h3Server.Shutdown(ctx)
+}
+
+// Stop will immediately stop the server, closing all active connections. The Start() method will return without an
+// error after stopping.
+func (s *Server) Stop() error {
+	log.Warn("Stopping HTTP/3 server")
+	return s.h3Server.Close()
+}
@@ -0,0 +1,104 @@
+package http3_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/web"
+	"github.com/ecnepsnai/web/http3"
+	quichttp3 "github.com/quic-go/quic-go/http3"
+)
+
+func selfSignedCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err.Error())
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error generating certificate: %s", err.Error())
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{certificate},
+		PrivateKey:  key,
+	}
+}
+
+func TestHTTP3(t *testing.T) {
+	t.Parallel()
+
+	server := web.NewMockServer()
+	server.Options.TLSConfig = &tls.Config{Certificates: []tls.Certificate{selfSignedCertificate(t)}}
+	server.API.GET("/users/:id", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return fmt.Sprintf("%s %s", request.HTTP.Proto, request.Parameters["id"]), nil, nil
+	}, web.HandleOptions{})
+
+	quicServer := http3.New(server.Server, "0.0.0.0:8443")
+	if server.Options.AltSvc != `h3=":8443"; ma=2592000` {
+		t.Errorf("Unexpected Alt-Svc option '%s'", server.Options.AltSvc)
+	}
+
+	// Responses over TCP advertise the HTTP/3 server
+	if altSvc := server.Request("GET", "/users/1", nil).Header.Get("Alt-Svc"); altSvc != server.Options.AltSvc {
+		t.Errorf("Unexpected Alt-Svc header. Expected '%s' got '%s'", server.Options.AltSvc, altSvc)
+	}
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatalf("Error listening: %s", err.Error())
+	}
+	defer conn.Close()
+	go quicServer.Serve(conn)
+	defer quicServer.Stop()
+
+	transport := &quichttp3.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	defer transport.Close()
+	client := &http.Client{Transport: transport, Timeout: 5 * time.Second}
+
+	resp, err := client.Get(fmt.Sprintf("https://%s/users/1", conn.LocalAddr().String()))
+	if err != nil {
+		t.Fatalf("Error making HTTP/3 request: %s", err.Error())
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 200 || string(body) != "{\"data\":\"HTTP/3.0 1\"}\n" {
+		t.Errorf("Unexpected response %d: %s", resp.StatusCode, body)
+	}
+	if resp.Header.Get("Alt-Svc") != "" {
+		t.Errorf("Unexpected Alt-Svc header in HTTP/3 response")
+	}
+}
+
+func TestHTTP3WithoutTLS(t *testing.T) {
+	t.Parallel()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatalf("Error listening: %s", err.Error())
+	}
+	defer conn.Close()
+	if err := http3.New(web.NewMockServer().Server, "127.0.0.1:0").Serve(conn); err == nil {
+		t.Errorf("No error seen for web server without TLS")
+	}
+}
@@ -1,12 +1,14 @@
 package web
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"runtime/debug"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/ecnepsnai/web/router"
@@ -48,6 +50,84 @@ func (h HTTPEasy) Static(path string, directory string) {
 	h.server.router.ServeFiles(directory, path)
 }
 
+// StaticFS registers a GET and HEAD handle for all requests under path to serve any files from the filesystem fsys,
+// such as files embedded with go:embed, the same as StaticWithOptions. HTTP range requests are supported if the files
+// of the filesystem implement [io.Seeker], which files from [embed.FS] do.
+//
+// For example:
+//
+//	//go:embed static
+//	var staticFiles embed.FS
+//
+//	assets, _ := fs.Sub(staticFiles, "static")
+//	server.HTTPEasy.StaticFS("/static/", assets, web.StaticOptions{})
+//
+//	Request for '/static/image.jpg' would read the embedded file 'static/image.jpg'
+func (h HTTPEasy) StaticFS(path string, fsys fs.FS, options StaticOptions) {
+	log.PDebug("Serving files from filesystem", map[string]interface{}{
+		"path": path,
+	})
+	h.server.router.ServeFS(fsys, path, options.routerOptions())
+}
+
+// StaticOptions describes options for serving static files
+type StaticOptions struct {
+	// If true then requests for paths that do not match a file are answered with the index.html file from the root of
+	// the directory, allowing a single-page application to handle routing on the client.
+	IndexFallback bool
+	// Optional Cache-Control header values by file extension, such as ".html": "no-cache". Extensions include the
+	// leading dot and are not case sensitive. Files with any other extension use the same caching as Static.
+	CacheControl map[string]string
+	// If true then a directory listing is generated for directories that do not have an index.html file, otherwise
+	// they are not found.
+	DirectoryListing bool
+	// If true then requests for any file or directory with a name beginning with '.', such as '.git' or '.env', are
+	// not found.
+	DenyDotfiles bool
+	// The maximum number of ranges permitted in a single HTTP range request. Requests with more ranges receive a
+	// "416 Range Not Satisfiable" response. The default value of 0 has no limit.
+	MaxRanges int
+	// The maximum total number of bytes, across all ranges, permitted in a single HTTP range request. Overlapping
+	// ranges are counted each time they are requested. The default value of 0 has no limit.
+	MaxRangeBytes uint64
+	// If true then a precompressed sibling of the requested file, such as 'app.js.br', 'app.js.zst', or 'app.js.gz', is
+	// served in its place with the matching Content-Encoding header if the client accepts that encoding, such as to
+	// serve large JavaScript bundles that were compressed when they were built.
+	Precompressed bool
+}
+
+// StaticWithOptions registers a GET and HEAD handle for all requests under path to serve any files matching the
+// directory, the same as Static, using the given options.
+//
+// For example, to serve a single-page application where the index should always be revalidated by the browser:
+//
+//	server.HTTPEasy.StaticWithOptions("/", "/usr/share/www/", web.StaticOptions{
+//		IndexFallback: true,
+//		CacheControl: map[string]string{
+//			".html": "no-cache",
+//		},
+//		DenyDotfiles: true,
+//	})
+func (h HTTPEasy) StaticWithOptions(path string, directory string, options StaticOptions) {
+	log.PDebug("Serving files from directory", map[string]interface{}{
+		"directory": directory,
+		"path":      path,
+	})
+	h.server.router.ServeFilesWithOptions(directory, path, options.routerOptions())
+}
+
+func (o StaticOptions) routerOptions() router.ServeFilesOptions {
+	return router.ServeFilesOptions{
+		IndexFallback:    o.IndexFallback,
+		CacheControl:     o.CacheControl,
+		DirectoryListing: o.DirectoryListing,
+		DenyDotfiles:     o.DenyDotfiles,
+		MaxRanges:        o.MaxRanges,
+		MaxRangeBytes:    o.MaxRangeBytes,
+		Precompressed:    o.Precompressed,
+	}
+}
+
 // GET register a new HTTP GET request handle
 func (h HTTPEasy) GET(path string, handle HTTPEasyHandle, options HandleOptions) {
 	h.registerHTTPEasyEndpoint("GET", path, handle, options)
@@ -91,12 +171,18 @@ func (h HTTPEasy) DELETE(path string, handle HTTPEasyHandle, options HandleOptio
 	h.registerHTTPEasyEndpoint("DELETE", path, handle, options)
 }
 
+// Unregister removes the handle, and any variants of it, registered for the method and path, even while the server is
+// serving requests. See [web.API.Unregister].
+func (h HTTPEasy) Unregister(method, path string) bool {
+	return h.server.unregisterRoute(method, path)
+}
+
 func (h HTTPEasy) registerHTTPEasyEndpoint(method string, path string, handle HTTPEasyHandle, options HandleOptions) {
 	log.PDebug("Register HTTP endpoint", map[string]interface{}{
 		"method": method,
 		"path":   path,
 	})
-	h.server.router.Handle(method, path, h.httpPreHandle(handle, options))
+	h.server.registerRoute(method, path, h.server.measure(method, path, options, h.server.dispatch(h.httpPreHandle(handle, options))), options)
 }
 
 func (h HTTPEasy) httpPreHandle(endpointHandle HTTPEasyHandle, options HandleOptions) router.Handle {
@@ -107,7 +193,15 @@ func (h HTTPEasy) httpPreHandle(endpointHandle HTTPEasyHandle, options HandleOpt
 			}
 		}
 
-		if h.server.isRateLimited(w, request.HTTP) {
+		if h.server.isUnavailable(w, request.HTTP, options) {
+			return
+		}
+
+		if h.server.isBanned(w, request.HTTP) {
+			return
+		}
+
+		if h.server.isRateLimited(w, request.HTTP, options) {
 			return
 		}
 
@@ -121,19 +215,27 @@ func (h HTTPEasy) httpPreHandle(endpointHandle HTTPEasyHandle, options HandleOpt
 					"body_length": length,
 					"max_length":  options.MaxBodyLength,
 				})
-				w.WriteHeader(413)
+				h.server.reportRejected(request.HTTP, RejectedPayloadTooLarge, 413)
+				h.server.writeError(w, CommonErrors.PayloadTooLarge, "")
 				return
 			}
+
+			// The Content-Length header may be missing, such as with chunked requests, so limit what is actually read
+			limitRequestBody(w, request.HTTP, options.MaxBodyLength)
 		}
 
 		if options.AuthenticateMethod != nil {
-			userData := options.AuthenticateMethod(request.HTTP)
+			userData := h.server.authenticate(w, request.HTTP, options)
+			if h.server.isUserRateLimited(w, request.HTTP, userData, options) {
+				return
+			}
 			if isUserdataNil(userData) {
+				h.server.reportRejected(request.HTTP, RejectedUnauthenticated, 401)
 				if options.UnauthorizedMethod == nil {
 					log.PWarn("Rejected request to authenticated HTTP endpoint", map[string]interface{}{
 						"url":         request.HTTP.URL,
 						"method":      request.HTTP.Method,
-						"remote_addr": RealRemoteAddr(request.HTTP),
+						"remote_addr": h.server.realRemoteAddr(request.HTTP),
 					})
 					w.Header().Set("Content-Type", "text/html")
 					w.WriteHeader(http.StatusUnauthorized)
@@ -143,63 +245,177 @@ func (h HTTPEasy) httpPreHandle(endpointHandle HTTPEasyHandle, options HandleOpt
 
 				options.UnauthorizedMethod(w, request.HTTP)
 			} else {
-				h.httpPostHandle(endpointHandle, userData, options)(w, request)
+				spanUser(request.HTTP, userData)
+				if !h.server.isForbidden(w, request.HTTP, userData, options) && !selfTestHandled(w, request.HTTP) {
+					h.server.withCache(h.server.withDeduplication(h.httpPostHandle(endpointHandle, userData, options), options), userData, options)(w, request)
+				}
 			}
 			return
 		}
-		h.httpPostHandle(endpointHandle, nil, options)(w, request)
+		if h.server.isForbidden(w, request.HTTP, nil, options) || selfTestHandled(w, request.HTTP) {
+			return
+		}
+		h.server.withCache(h.server.withDeduplication(h.httpPostHandle(endpointHandle, nil, options), options), nil, options)(w, request)
 	}
 }
 
 func (h HTTPEasy) httpPostHandle(endpointHandle HTTPEasyHandle, userData interface{}, options HandleOptions) router.Handle {
 	return func(w http.ResponseWriter, r router.Request) {
+		start := time.Now()
 		request := Request{
 			HTTP:       r.HTTP,
 			Parameters: r.Parameters,
 			UserData:   userData,
+			server:     h.server,
+			options:    options,
+			start:      start,
+			decoder:    h.server.jsonDecoder(),
+			traced:     isTraced(w),
+			writer:     w,
 		}
-		start := time.Now()
 		defer func() {
 			if p := recover(); p != nil {
+				stack := debug.Stack()
 				log.PError("Recovered from panic during HTTPEasy handle", map[string]interface{}{
 					"error":  fmt.Sprintf("%v", p),
 					"route":  request.HTTP.URL.Path,
 					"method": request.HTTP.Method,
-					"stack":  string(debug.Stack()),
+					"stack":  string(stack),
 				})
+				h.server.reportPanic(p, stack, request, options)
+				setResponseError(w, fmt.Sprintf("%v", p))
 				w.WriteHeader(500)
 			}
 		}()
 
-		response := endpointHandle(request)
+		traceMark(w, "prehandle")
+		var response HTTPResponse
+		if timeout := h.server.handleTimeout(options); timeout > 0 {
+			var cancel context.CancelFunc
+			request.HTTP, cancel = withTimeout(r.HTTP, timeout)
+			defer cancel()
+			result, ok := callWithTimeout(request.HTTP.Context(), func() interface{} {
+				return endpointHandle(request)
+			}, h.server.abandonHandle(r.HTTP, start, func(value interface{}) {
+				if reader := value.(HTTPResponse).Reader; reader != nil {
+					reader.Close()
+				}
+			}))
+			if !ok {
+				h.server.logTimeout(w, r.HTTP, timeout)
+				h.server.writeTimeout(w)
+				return
+			}
+			response = result.(HTTPResponse)
+		} else {
+			response = endpointHandle(request)
+		}
+		traceMark(w, "handle")
 		elapsed := time.Since(start)
 
 		if response.Reader != nil {
 			defer response.Reader.Close()
 		}
 
+		if response.Template != "" && response.Stream == nil {
+			if err := h.server.renderTemplateResponse(&response); err != nil {
+				log.PError("Error rendering template", map[string]interface{}{
+					"template": response.Template,
+					"route":    r.HTTP.URL.Path,
+					"error":    err.Error(),
+				})
+				setResponseError(w, err.Error())
+				w.WriteHeader(500)
+				return
+			}
+		}
+
+		// The Reader is ignored when the response is streamed
+		if response.Stream != nil {
+			response.Reader = nil
+		}
+
+		// Discover the length of seekable readers so that range requests and HEAD responses work without the handle
+		// having to determine it
+		lengthKnown := response.ContentLength > 0
+		if seeker, ok := response.Reader.(io.Seeker); ok && !lengthKnown {
+			if length, err := seekLength(seeker); err == nil {
+				response.ContentLength = length
+				lengthKnown = true
+			} else {
+				log.PWarn("Error determining length of response", map[string]interface{}{
+					"url":   r.HTTP.URL,
+					"error": err.Error(),
+				})
+			}
+		}
+
+		// Answer conditional requests for responses with an ETag or Last-Modified header, such as from FileResponse
+		etag := responseHeader(response.Headers, "ETag")
+		lastModified := responseHeader(response.Headers, "Last-Modified")
+		if (response.Status == 0 || response.Status == 200) && (r.HTTP.Method == "GET" || r.HTTP.Method == "HEAD") {
+			notModified := etagMatches(r.HTTP.Header.Get("If-None-Match"), etag)
+			if r.HTTP.Header.Get("If-None-Match") == "" {
+				notModified = notModifiedSince(r.HTTP.Header.Get("If-Modified-Since"), lastModified)
+			}
+			if notModified {
+				for k, v := range response.Headers {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(http.StatusNotModified)
+				if !options.DontLogRequests {
+					h.server.logRequest(w, r.HTTP, "HTTP Request", http.StatusNotModified, elapsed, map[string]interface{}{
+						"remote_addr":   h.server.realRemoteAddr(r.HTTP),
+						"method":        r.HTTP.Method,
+						"url":           r.HTTP.URL,
+						"elapsed":       elapsed.String(),
+						"status":        http.StatusNotModified,
+						"request_bytes": requestBytes(r.HTTP),
+					})
+				}
+				return
+			}
+		}
+
 		// Return a HTTP range response only if:
 		// 1. A range was actually requested by the client
 		// 2. The reader implemented Seek
 		// 3. The response was either default or 200
+		// 4. Any 'If-Range' header matches the ETag or Last-Modified header of the response
 		ranges := router.ParseRangeHeader(r.HTTP.Header.Get("range"))
+		if ifRange := r.HTTP.Header.Get("If-Range"); ifRange != "" && ifRange != etag && ifRange != lastModified {
+			ranges = nil
+		}
 		_, canSeek := response.Reader.(io.ReadSeekCloser)
 		if len(ranges) > 0 && (response.Status == 0 || response.Status == 200) && !h.server.Options.IgnoreHTTPRangeRequests && canSeek {
-			router.ServeHTTPRange(router.ServeHTTPRangeOptions{
+			config := h.server.runtimeConfig()
+			h.server.setCookies(w, response.Cookies)
+			err := router.ServeHTTPRange(router.ServeHTTPRangeOptions{
 				Headers:     response.Headers,
 				Ranges:      ranges,
 				Reader:      response.Reader.(io.ReadSeekCloser),
 				TotalLength: response.ContentLength,
 				MIMEType:    response.ContentType,
 				Writer:      w,
+				MaxRanges:   config.MaxHTTPRanges,
+				MaxBytes:    config.MaxHTTPRangeBytes,
 			})
-			log.PWrite(h.server.Options.RequestLogLevel, "HTTP Request", map[string]interface{}{
-				"remote_addr": RealRemoteAddr(r.HTTP),
-				"method":      r.HTTP.Method,
-				"url":         r.HTTP.URL,
-				"elapsed":     elapsed.String(),
-				"status":      response.Status,
-				"range":       true,
+			if errors.Is(err, router.ErrRangeLimitExceeded) {
+				log.PWarn("Rejected HTTP range request exceeding limits", map[string]interface{}{
+					"remote_addr": h.server.realRemoteAddr(r.HTTP),
+					"url":         r.HTTP.URL,
+					"range":       r.HTTP.Header.Get("range"),
+				})
+			}
+			h.server.logRequest(w, r.HTTP, "HTTP Request", responseStatus(w), elapsed, map[string]interface{}{
+				"remote_addr":    h.server.realRemoteAddr(r.HTTP),
+				"method":         r.HTTP.Method,
+				"url":            r.HTTP.URL,
+				"elapsed":        elapsed.String(),
+				"status":         responseStatus(w),
+				"range":          true,
+				"request_bytes":  requestBytes(r.HTTP),
+				"response_bytes": responseBytes(w),
 			})
 			return
 		}
@@ -213,7 +429,7 @@ func (h HTTPEasy) httpPostHandle(endpointHandle HTTPEasyHandle, userData interfa
 			w.Header().Set("Content-Type", response.ContentType)
 		}
 
-		if response.ContentLength > 0 {
+		if lengthKnown {
 			w.Header().Set("Content-Length", fmt.Sprintf("%d", response.ContentLength))
 		}
 
@@ -221,28 +437,35 @@ func (h HTTPEasy) httpPostHandle(endpointHandle HTTPEasyHandle, userData interfa
 			w.Header().Set(k, v)
 		}
 
-		for _, cookie := range response.Cookies {
-			http.SetCookie(w, &cookie)
-		}
+		h.server.setCookies(w, response.Cookies)
 
 		code := 200
 		if response.Status != 0 {
 			code = response.Status
 		}
 		if !options.DontLogRequests {
-			log.PWrite(h.server.Options.RequestLogLevel, "HTTP Request", map[string]interface{}{
-				"remote_addr": RealRemoteAddr(r.HTTP),
-				"method":      r.HTTP.Method,
-				"url":         r.HTTP.URL,
-				"elapsed":     elapsed.String(),
-				"status":      code,
-			})
+			// Logged once the response has been written so the number of bytes written is known
+			defer func() {
+				h.server.logRequest(w, r.HTTP, "HTTP Request", code, elapsed, map[string]interface{}{
+					"remote_addr":    h.server.realRemoteAddr(r.HTTP),
+					"method":         r.HTTP.Method,
+					"url":            r.HTTP.URL,
+					"elapsed":        elapsed.String(),
+					"status":         code,
+					"request_bytes":  requestBytes(r.HTTP),
+					"response_bytes": responseBytes(w),
+				})
+			}()
 		}
 		w.WriteHeader(code)
 
+		if r.HTTP.Method != "HEAD" && response.Stream != nil {
+			h.writeStream(w, r.HTTP, response.Stream)
+			return
+		}
 		if r.HTTP.Method != "HEAD" && response.Reader != nil {
 			if copied, err := io.Copy(w, response.Reader); err != nil {
-				if strings.Contains(err.Error(), "write: broken pipe") {
+				if isClientAbort(err) {
 					return
 				}
 
@@ -1,6 +1,7 @@
 package web_test
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/rand"
 	"encoding/hex"
@@ -9,9 +10,11 @@ import (
 	"mime"
 	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/ecnepsnai/web"
@@ -646,6 +649,50 @@ func TestHTTPEasyRangeGet(t *testing.T) {
 	}
 }
 
+func TestHTTPEasySeekLength(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	data := []byte("0123456789")
+	handle := func(request web.Request) web.HTTPResponse {
+		return web.HTTPResponse{
+			Reader:      nopSeekCloser{bytes.NewReader(data)},
+			ContentType: "text/plain",
+		}
+	}
+
+	path := randomString(5)
+	server.HTTPEasy.GETHEAD("/"+path, handle, web.HandleOptions{})
+	url := fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path)
+
+	resp, err := http.Head(url)
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	resp.Body.Close()
+	if resp.ContentLength != 10 {
+		t.Errorf("Unexpected content length for HEAD. Expected %d got %d", 10, resp.ContentLength)
+	}
+
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("range", "bytes=2-4")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 206 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 206, resp.StatusCode)
+	}
+	if string(body) != "234" {
+		t.Errorf("Unexpected body. Expected '%s' got '%s'", "234", body)
+	}
+	if contentRange := resp.Header.Get("Content-Range"); contentRange != "bytes 2-4/10" {
+		t.Errorf("Unexpected content range. Expected '%s' got '%s'", "bytes 2-4/10", contentRange)
+	}
+}
+
 func TestHTTPEasyPreHandle(t *testing.T) {
 	t.Parallel()
 	server := newServer()
@@ -718,3 +765,169 @@ func TestHTTPEasyPanic(t *testing.T) {
 		t.Fatalf("Unexpected HTTP status code. Expected %d got %d", 500, resp.StatusCode)
 	}
 }
+
+func TestHTTPEasyStaticWithOptions(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	tmp := t.TempDir()
+	if err := os.WriteFile(path.Join(tmp, "index.html"), []byte("index"), 0644); err != nil {
+		t.Fatalf("Error making temporary file: %s", err.Error())
+	}
+	if err := os.WriteFile(path.Join(tmp, ".htpasswd"), []byte("secret"), 0644); err != nil {
+		t.Fatalf("Error making temporary file: %s", err.Error())
+	}
+
+	root := "/" + randomString(5) + "/"
+	server.HTTPEasy.StaticWithOptions(root, tmp, web.StaticOptions{
+		IndexFallback: true,
+		CacheControl: map[string]string{
+			".html": "no-cache",
+		},
+		DenyDotfiles: true,
+	})
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d%susers/1", server.ListenPort, root))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		t.Fatalf("Unexpected HTTP status code. Expected %d got %d", 200, resp.StatusCode)
+	}
+	if string(body) != "index" {
+		t.Errorf("Unexpected body. Expected '%s' got '%s'", "index", body)
+	}
+	if cacheControl := resp.Header.Get("Cache-Control"); cacheControl != "no-cache" {
+		t.Errorf("Unexpected cache control. Expected '%s' got '%s'", "no-cache", cacheControl)
+	}
+
+	resp, err = http.Get(fmt.Sprintf("http://localhost:%d%s.htpasswd", server.ListenPort, root))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 404 {
+		t.Errorf("Unexpected HTTP status code. Expected %d got %d", 404, resp.StatusCode)
+	}
+}
+
+func TestHTTPEasyStaticFS(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	fsys := fstest.MapFS{
+		"index.html": &fstest.MapFile{Data: []byte("index")},
+		".env":       &fstest.MapFile{Data: []byte("secret")},
+	}
+
+	root := "/" + randomString(5) + "/"
+	server.HTTPEasy.StaticFS(root, fsys, web.StaticOptions{DenyDotfiles: true})
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d%s", server.ListenPort, root))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		t.Fatalf("Unexpected HTTP status code. Expected %d got %d", 200, resp.StatusCode)
+	}
+	if string(body) != "index" {
+		t.Errorf("Unexpected body. Expected '%s' got '%s'", "index", body)
+	}
+
+	resp, err = http.Get(fmt.Sprintf("http://localhost:%d%s.env", server.ListenPort, root))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 404 {
+		t.Errorf("Unexpected HTTP status code. Expected %d got %d", 404, resp.StatusCode)
+	}
+}
+
+func TestHTTPEasyRangeLimits(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+	server.Options.MaxHTTPRanges = 2
+	server.Options.MaxHTTPRangeBytes = 200
+
+	server.HTTPEasy.GET("/data", func(request web.Request) web.HTTPResponse {
+		return web.HTTPResponse{
+			Reader:      nopSeekCloser{bytes.NewReader(make([]byte, 500))},
+			ContentType: "application/octet-stream",
+		}
+	}, web.HandleOptions{})
+
+	check := func(rangeHeader string, expectedStatus int) {
+		req := httptest.NewRequest("GET", "/data", nil)
+		req.Header.Set("Range", rangeHeader)
+		if response := server.Do(req); response.Status != expectedStatus {
+			t.Errorf("Unexpected status code for range '%s'. Expected %d got %d", rangeHeader, expectedStatus, response.Status)
+		}
+	}
+
+	check("bytes=0-99,100-199", 206)
+	check("bytes=0-9,10-19,20-29", 416)
+	check("bytes=0-199,0-199", 416)
+	check("bytes=100-", 416)
+}
+
+func TestHTTPEasyStream(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	path := randomString(5)
+	next := make(chan struct{})
+	disconnected := make(chan struct{})
+	server.HTTPEasy.GET("/"+path, func(request web.Request) web.HTTPResponse {
+		return web.HTTPResponse{
+			ContentType: "text/plain",
+			Stream: func(writer *web.StreamWriter) error {
+				for i := 1; ; i++ {
+					if _, err := fmt.Fprintf(writer, "%d\n", i); err != nil {
+						return err
+					}
+					writer.Flush()
+					select {
+					case <-next:
+					case <-writer.Done():
+						close(disconnected)
+						return nil
+					}
+				}
+			},
+		}
+	}, web.HandleOptions{})
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 200, resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/plain" {
+		t.Errorf("Unexpected content type. Expected '%s' got '%s'", "text/plain", contentType)
+	}
+
+	// Each line is received as soon as it is flushed, before the stream finishes
+	reader := bufio.NewReader(resp.Body)
+	for i := 1; i <= 3; i++ {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Error reading stream: %s", err.Error())
+		}
+		if line != fmt.Sprintf("%d\n", i) {
+			t.Errorf("Unexpected line. Expected '%d' got '%s'", i, line)
+		}
+		if i < 3 {
+			next <- struct{}{}
+		}
+	}
+
+	resp.Body.Close()
+	select {
+	case <-disconnected:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Stream was not notified of client disconnect")
+	}
+}
@@ -0,0 +1,243 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JobState describes the state of a background job
+type JobState string
+
+const (
+	// JobPending is the state of a job that has been accepted but has not yet started
+	JobPending JobState = "pending"
+	// JobRunning is the state of a job that is currently running
+	JobRunning JobState = "running"
+	// JobComplete is the state of a job that finished successfully
+	JobComplete JobState = "complete"
+	// JobFailed is the state of a job that returned an error
+	JobFailed JobState = "failed"
+)
+
+// Job describes a background job
+type Job struct {
+	// The unique identifier for this job
+	ID string `json:"id"`
+	// The current state of the job
+	State JobState `json:"state"`
+	// The data returned by the job handle. Only populated once the job is complete.
+	Result interface{} `json:"result,omitempty"`
+	// The error returned by the job handle. Only populated if the job failed.
+	Error *Error `json:"error,omitempty"`
+	// When the job was accepted
+	Created time.Time `json:"created"`
+	// When the state of the job last changed
+	Updated time.Time `json:"updated"`
+}
+
+// JobStore describes an interface for storing the state of background jobs
+type JobStore interface {
+	// SetJob will save the job, replacing any existing job with the same ID.
+	SetJob(job Job) error
+	// GetJob will return the job with the given ID, or nil if no job exists.
+	GetJob(id string) (*Job, error)
+}
+
+// JobHandle describes a method signature for performing a background job. The returned data is saved as the result of
+// the job.
+type JobHandle func(request Request) (interface{}, *Error)
+
+// MemoryJobStore is a [web.JobStore] that keeps jobs in memory. Finished jobs are removed once they are older than
+// the retention period.
+type MemoryJobStore struct {
+	// The amount of time to keep finished jobs for. Defaults to 1 hour.
+	Retention time.Duration
+
+	jobs map[string]Job
+	lock *sync.Mutex
+}
+
+// NewMemoryJobStore will return a new, empty, in-memory job store
+func NewMemoryJobStore() *MemoryJobStore {
+	return &MemoryJobStore{
+		Retention: time.Hour,
+		jobs:      map[string]Job{},
+		lock:      &sync.Mutex{},
+	}
+}
+
+// SetJob will save the job, replacing any existing job with the same ID.
+func (s *MemoryJobStore) SetJob(job Job) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.prune()
+	s.jobs[job.ID] = job
+	return nil
+}
+
+// GetJob will return the job with the given ID, or nil if no job exists.
+func (s *MemoryJobStore) GetJob(id string) (*Job, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.prune()
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+// prune removes finished jobs that are older than the retention period. The lock must be held.
+func (s *MemoryJobStore) prune() {
+	for id, existing := range s.jobs {
+		if (existing.State == JobComplete || existing.State == JobFailed) && time.Since(existing.Updated) > s.Retention {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// Job registers a new HTTP POST handle at path that starts a background job, and a HTTP GET handle at path/:job_id that
+// returns the state of the job.
+//
+// Requests to start a job are immediately answered with a 202 response whose data is the pending [web.Job], and whose
+// Location header is the path for the status of the job, built from the path of the request. The handle is then called
+// in the background, and its result saved to the JobStore of the server. The body of the request is read into memory
+// before the response is sent so that it remains available to the handle.
+//
+// The same options are used for both the POST and GET handles.
+func (a API) Job(path string, handle JobHandle, options HandleOptions) {
+	path = strings.TrimSuffix(path, "/")
+
+	a.POST(path, func(request Request) (interface{}, *APIResponse, *Error) {
+		body, err := io.ReadAll(request.HTTP.Body)
+		if err != nil {
+			log.PError("Error reading job request body", map[string]interface{}{
+				"url":   request.HTTP.URL,
+				"error": err.Error(),
+			})
+			maxBytesError := &http.MaxBytesError{}
+			if errors.As(err, &maxBytesError) {
+				return nil, nil, CommonErrors.PayloadTooLarge
+			}
+			return nil, nil, CommonErrors.BadRequest
+		}
+
+		now := time.Now()
+		job := Job{
+			ID:      newRandomID(),
+			State:   JobPending,
+			Created: now,
+			Updated: now,
+		}
+		if err := a.server.jobStore().SetJob(job); err != nil {
+			log.PError("Error saving job", map[string]interface{}{
+				"job_id": job.ID,
+				"error":  err.Error(),
+			})
+			return nil, nil, CommonErrors.ServerError
+		}
+
+		// The original request is finished once the response is sent, so give the job its own copy
+		background := request
+		background.HTTP = request.HTTP.Clone(context.Background())
+		background.HTTP.Body = io.NopCloser(bytes.NewReader(body))
+		go a.runJob(job, handle, background)
+
+		return job, &APIResponse{
+			Status: 202,
+			Headers: map[string]string{
+				"Location": strings.TrimSuffix(request.HTTP.URL.Path, "/") + "/" + job.ID,
+			},
+		}, nil
+	}, options)
+
+	a.GET(path+"/:job_id", func(request Request) (interface{}, *APIResponse, *Error) {
+		job, err := a.server.jobStore().GetJob(request.Parameters["job_id"])
+		if err != nil {
+			log.PError("Error getting job", map[string]interface{}{
+				"job_id": request.Parameters["job_id"],
+				"error":  err.Error(),
+			})
+			return nil, nil, CommonErrors.ServerError
+		}
+		if job == nil {
+			return nil, nil, CommonErrors.NotFound
+		}
+		return job, nil, nil
+	}, options)
+}
+
+func (a API) runJob(job Job, handle JobHandle, request Request) {
+	store := a.server.jobStore()
+	finish := func(result interface{}, err *Error) {
+		job.Updated = time.Now()
+		if err != nil {
+			job.State = JobFailed
+			job.Error = err
+		} else {
+			job.State = JobComplete
+			job.Result = result
+		}
+		if err := store.SetJob(job); err != nil {
+			log.PError("Error saving job", map[string]interface{}{
+				"job_id": job.ID,
+				"error":  err.Error(),
+			})
+		}
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			log.PError("Recovered from panic during background job", map[string]interface{}{
+				"error":  fmt.Sprintf("%v", p),
+				"job_id": job.ID,
+				"route":  request.HTTP.URL.Path,
+				"stack":  string(debug.Stack()),
+			})
+			finish(nil, CommonErrors.ServerError)
+		}
+	}()
+
+	job.State = JobRunning
+	job.Updated = time.Now()
+	if err := store.SetJob(job); err != nil {
+		log.PError("Error saving job", map[string]interface{}{
+			"job_id": job.ID,
+			"error":  err.Error(),
+		})
+	}
+
+	start := time.Now()
+	result, err := handle(request)
+	log.PDebug("Background job finished", map[string]interface{}{
+		"job_id":  job.ID,
+		"route":   request.HTTP.URL.Path,
+		"elapsed": time.Since(start).String(),
+	})
+	finish(result, err)
+}
+
+func (s *Server) jobStore() JobStore {
+	if s.Options.JobStore != nil {
+		return s.Options.JobStore
+	}
+	return s.jobs
+}
+
+func newRandomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,161 @@
+package web_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestAPIJob(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	type exampleType struct {
+		Value string `json:"value"`
+	}
+
+	finish := make(chan bool)
+	handle := func(request web.Request) (interface{}, *web.Error) {
+		example := exampleType{}
+		if err := request.DecodeJSON(&example); err != nil {
+			return nil, err
+		}
+		<-finish
+		return example.Value, nil
+	}
+
+	path := randomString(5)
+	server.API.Job("/"+path, handle, web.HandleOptions{})
+
+	body, _ := json.Marshal(exampleType{"hello"})
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path), "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 202 {
+		t.Fatalf("Unexpected status code. Expected %d got %d", 202, resp.StatusCode)
+	}
+	location := resp.Header.Get("Location")
+	if location == "" {
+		t.Fatalf("No location header in job response")
+	}
+
+	getJob := func() web.Job {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d%s", server.ListenPort, location))
+		if err != nil {
+			t.Fatalf("Network error: %s", err.Error())
+		}
+		if resp.StatusCode != 200 {
+			t.Fatalf("Unexpected status code. Expected %d got %d", 200, resp.StatusCode)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		response := struct {
+			Data web.Job `json:"data"`
+		}{}
+		if err := json.Unmarshal(data, &response); err != nil {
+			t.Fatalf("Error decoding job: %s", err.Error())
+		}
+		return response.Data
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if job := getJob(); job.State != web.JobRunning {
+		t.Fatalf("Unexpected job state. Expected '%s' got '%s'", web.JobRunning, job.State)
+	}
+
+	finish <- true
+	time.Sleep(5 * time.Millisecond)
+	job := getJob()
+	if job.State != web.JobComplete {
+		t.Fatalf("Unexpected job state. Expected '%s' got '%s'", web.JobComplete, job.State)
+	}
+	if job.Result != "hello" {
+		t.Fatalf("Unexpected job result. Expected '%s' got '%v'", "hello", job.Result)
+	}
+
+	resp, err = http.Get(fmt.Sprintf("http://localhost:%d/%s/%s", server.ListenPort, path, randomString(16)))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 404 {
+		t.Fatalf("Unexpected status code. Expected %d got %d", 404, resp.StatusCode)
+	}
+}
+
+func TestAPIJobFailed(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+	store := web.NewMemoryJobStore()
+	server.Options.JobStore = store
+
+	path := randomString(5)
+	server.API.Job("/"+path, func(request web.Request) (interface{}, *web.Error) {
+		panic("oops")
+	}, web.HandleOptions{})
+
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path), "application/json", nil)
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	response := struct {
+		Data web.Job `json:"data"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding job: %s", err.Error())
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	job, _ := store.GetJob(response.Data.ID)
+	if job == nil {
+		t.Fatalf("Job not found in store")
+	}
+	if job.State != web.JobFailed {
+		t.Fatalf("Unexpected job state. Expected '%s' got '%s'", web.JobFailed, job.State)
+	}
+}
+
+func TestAPIJobParameterizedPath(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	server.API.Job("/users/:id/export", func(request web.Request) (interface{}, *web.Error) {
+		return request.Parameters["id"], nil
+	}, web.HandleOptions{MaxBodyLength: 10})
+
+	response := server.Request("POST", "/users/42/export", nil)
+	if response.Status != 202 {
+		t.Fatalf("Unexpected status code. Expected %d got %d", 202, response.Status)
+	}
+	location := response.Header.Get("Location")
+	if !strings.HasPrefix(location, "/users/42/export/") {
+		t.Fatalf("Unexpected location header '%s'", location)
+	}
+	if response := server.Request("GET", location, nil); response.Status != 200 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 200, response.Status)
+	}
+
+	req := httptest.NewRequest("POST", "/users/42/export", strings.NewReader(strings.Repeat("a", 100)))
+	req.ContentLength = -1
+	if response := server.Do(req); response.Status != 413 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 413, response.Status)
+	}
+}
+
+func TestMemoryJobStorePrune(t *testing.T) {
+	store := web.NewMemoryJobStore()
+	store.Retention = time.Millisecond
+	store.SetJob(web.Job{ID: "1", State: web.JobComplete, Updated: time.Now()})
+
+	time.Sleep(5 * time.Millisecond)
+	if job, _ := store.GetJob("1"); job != nil {
+		t.Errorf("Expired job was not pruned")
+	}
+}
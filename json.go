@@ -0,0 +1,52 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Encoder describes a generic interface that has an Encode function
+type Encoder interface {
+	Encode(v any) error
+}
+
+// JSONEncoder describes an interface for creating JSON encoders. Implement this interface to replace encoding/json
+// with another JSON library.
+type JSONEncoder interface {
+	// NewEncoder will return a new encoder that writes to w
+	NewEncoder(w io.Writer) Encoder
+}
+
+// JSONDecoder describes an interface for creating JSON decoders. Implement this interface to replace encoding/json
+// with another JSON library.
+type JSONDecoder interface {
+	// NewDecoder will return a new decoder that reads from r
+	NewDecoder(r io.Reader) Decoder
+}
+
+// StandardJSON is the default [web.JSONEncoder] and [web.JSONDecoder], which uses encoding/json.
+var StandardJSON = standardJSON{}
+
+type standardJSON struct{}
+
+func (standardJSON) NewEncoder(w io.Writer) Encoder {
+	return json.NewEncoder(w)
+}
+
+func (standardJSON) NewDecoder(r io.Reader) Decoder {
+	return json.NewDecoder(r)
+}
+
+func (s *Server) jsonEncoder() JSONEncoder {
+	if s.Options.JSONEncoder != nil {
+		return s.Options.JSONEncoder
+	}
+	return StandardJSON
+}
+
+func (s *Server) jsonDecoder() JSONDecoder {
+	if s.Options.JSONDecoder != nil {
+		return s.Options.JSONDecoder
+	}
+	return StandardJSON
+}
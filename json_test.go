@@ -0,0 +1,95 @@
+package web_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+type indentJSON struct {
+	decoded *int32
+}
+
+func (j indentJSON) NewEncoder(w io.Writer) web.Encoder {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+	return encoder
+}
+
+func (j indentJSON) NewDecoder(r io.Reader) web.Decoder {
+	atomic.AddInt32(j.decoded, 1)
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	return decoder
+}
+
+func TestCustomJSONEncoder(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+	codec := indentJSON{decoded: new(int32)}
+	server.Options.JSONEncoder = codec
+	server.Options.JSONDecoder = codec
+
+	type exampleType struct {
+		Value string `json:"value"`
+	}
+
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		example := exampleType{}
+		if err := request.DecodeJSON(&example); err != nil {
+			return nil, nil, err
+		}
+		return example, nil, nil
+	}
+
+	path := randomString(5)
+	server.API.POST("/"+path, handle, web.HandleOptions{})
+
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path), "application/json", strings.NewReader(`{"value":"hello"}`))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "\n\t") {
+		t.Errorf("Response was not encoded using custom encoder: %s", body)
+	}
+	if atomic.LoadInt32(codec.decoded) != 1 {
+		t.Errorf("Request was not decoded using custom decoder")
+	}
+
+	resp, err = http.Post(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path), "application/json", strings.NewReader(`{"value":"hello","unknown":true}`))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 400 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 400, resp.StatusCode)
+	}
+}
+
+func TestMockCustomJSONDecoder(t *testing.T) {
+	codec := indentJSON{decoded: new(int32)}
+	request := web.MockRequest(web.MockRequestParameters{
+		JSONBody:    map[string]string{"value": "hello"},
+		JSONEncoder: codec,
+		JSONDecoder: codec,
+	})
+
+	example := struct {
+		Value string `json:"value"`
+	}{}
+	if err := request.DecodeJSON(&example); err != nil {
+		t.Fatalf("Error decoding mock request: %s", err.Message)
+	}
+	if example.Value != "hello" {
+		t.Errorf("Unexpected value. Expected '%s' got '%s'", "hello", example.Value)
+	}
+	if atomic.LoadInt32(codec.decoded) != 1 {
+		t.Errorf("Request was not decoded using custom decoder")
+	}
+}
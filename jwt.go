@@ -0,0 +1,415 @@
+package web
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultJWKSRefreshInterval = time.Hour
+	// The minimum time between refreshes of the key set when a token is signed with an unknown key
+	jwksMinRefreshInterval = time.Minute
+)
+
+// JWTOptions describes options for validating JSON web tokens with [web.JWTAuth]. At least one of Key, Keys, or JWKSURL
+// is required.
+//
+// Keys must be a []byte for HS256, a *rsa.PublicKey for RS256, or an ed25519.PublicKey for EdDSA. The algorithm of a
+// token must match the type of its key, other algorithms are rejected.
+type JWTOptions struct {
+	// The key used to verify tokens that do not specify a key ID, or that specify a key ID not in Keys.
+	Key interface{}
+	// Keys used to verify tokens, by their key ID ("kid").
+	Keys map[string]interface{}
+	// Optional URL of a JSON web key set (JWKS) to get RSA and Ed25519 keys from, by their key ID. The key set is
+	// refreshed every JWKSRefreshInterval, or when a token is signed with a key ID that is not in the set, allowing keys
+	// to be rotated by the issuer. Refreshes for unknown key IDs happen at most once a minute. Tokens signed with a key
+	// already in the set never wait for the key set to be refreshed.
+	JWKSURL string
+	// How often the key set is refreshed. Defaults to 1 hour.
+	JWKSRefreshInterval time.Duration
+	// Optional HTTP client used to get the key set. Defaults to a client with a 10 second timeout.
+	JWKSClient *http.Client
+	// If set, the 'iss' claim of tokens must match this value.
+	Issuer string
+	// If set, the 'aud' claim of tokens must contain this value.
+	Audience string
+	// Optional name of a cookie to read the token from if the request has no 'Authorization' header.
+	Cookie string
+	// The amount of clock skew allowed when checking the 'exp' and 'nbf' claims.
+	Leeway time.Duration
+	// If true then tokens without an 'exp' claim are accepted. Defaults to false, which rejects tokens that never
+	// expire.
+	AllowMissingExpiry bool
+}
+
+// JWTClaims describes the claims of a validated JSON web token, which is the user data of requests authenticated with
+// [web.JWTAuth]
+type JWTClaims map[string]interface{}
+
+// Subject returns the 'sub' claim of the token, or an empty string if there is none
+func (c JWTClaims) Subject() string {
+	subject, _ := c["sub"].(string)
+	return subject
+}
+
+// JWTAuth returns an AuthenticateMethod for JSON web tokens from the 'Authorization' header of the request, or from a
+// cookie. The signature, expiry, issuer, and audience of the token are verified, and the claims of the token are used
+// as the user data for the request as a [web.JWTClaims]. HS256, RS256, and EdDSA (Ed25519) tokens are supported.
+//
+// For example:
+//
+//	options := web.HandleOptions{
+//	    AuthenticateMethod: web.JWTAuth(web.JWTOptions{
+//	        JWKSURL:  "https://auth.example.com/.well-known/jwks.json",
+//	        Issuer:   "https://auth.example.com/",
+//	        Audience: "api",
+//	    }),
+//	}
+func JWTAuth(options JWTOptions) func(request *http.Request) interface{} {
+	verifier := &jwtVerifier{options: options}
+	if options.JWKSURL != "" {
+		verifier.jwks = &jwksCache{
+			url:      options.JWKSURL,
+			interval: options.JWKSRefreshInterval,
+			client:   options.JWKSClient,
+		}
+		if verifier.jwks.interval <= 0 {
+			verifier.jwks.interval = defaultJWKSRefreshInterval
+		}
+		if verifier.jwks.client == nil {
+			verifier.jwks.client = &http.Client{Timeout: 10 * time.Second}
+		}
+	}
+
+	return func(request *http.Request) interface{} {
+		token := ""
+		if scheme, value, ok := strings.Cut(request.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+			token = strings.TrimSpace(value)
+		}
+		if token == "" && options.Cookie != "" {
+			if cookie, err := request.Cookie(options.Cookie); err == nil {
+				token = cookie.Value
+			}
+		}
+		if token == "" {
+			setAuthChallenge(request, "Bearer")
+			return nil
+		}
+
+		setAuthChallenge(request, `Bearer error="invalid_token"`)
+		claims, err := verifier.verify(token, time.Now())
+		if err != nil {
+			log.PDebug("Rejected JSON web token", map[string]interface{}{
+				"url":   request.URL.String(),
+				"error": err.Error(),
+			})
+			return nil
+		}
+		return claims
+	}
+}
+
+type jwtVerifier struct {
+	options JWTOptions
+	jwks    *jwksCache
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// verify returns the claims of the token if its signature and claims are valid
+func (v *jwtVerifier) verify(token string, now time.Time) (JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	header := jwtHeader{}
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid header: %s", err.Error())
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding")
+	}
+	key, err := v.key(header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyJWTSignature(header.Algorithm, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	claims := JWTClaims{}
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid claims: %s", err.Error())
+	}
+	if err := v.validateClaims(claims, now); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// key returns the key for the given key ID
+func (v *jwtVerifier) key(keyID string) (interface{}, error) {
+	if key, ok := v.options.Keys[keyID]; ok {
+		return key, nil
+	}
+	if v.jwks != nil {
+		if key, ok := v.jwks.key(keyID); ok {
+			return key, nil
+		}
+	}
+	if v.options.Key != nil {
+		return v.options.Key, nil
+	}
+	return nil, fmt.Errorf("unknown key %s", keyID)
+}
+
+func (v *jwtVerifier) validateClaims(claims JWTClaims, now time.Time) error {
+	if expires, ok := claims["exp"].(float64); ok {
+		if now.Add(-v.options.Leeway).After(time.Unix(int64(expires), 0)) {
+			return fmt.Errorf("token expired")
+		}
+	} else if _, present := claims["exp"]; present {
+		return fmt.Errorf("invalid exp claim")
+	} else if !v.options.AllowMissingExpiry {
+		return fmt.Errorf("missing exp claim")
+	}
+	if notBefore, ok := claims["nbf"].(float64); ok {
+		if now.Add(v.options.Leeway).Before(time.Unix(int64(notBefore), 0)) {
+			return fmt.Errorf("token not yet valid")
+		}
+	} else if _, present := claims["nbf"]; present {
+		return fmt.Errorf("invalid nbf claim")
+	}
+
+	if v.options.Issuer != "" {
+		if issuer, _ := claims["iss"].(string); issuer != v.options.Issuer {
+			return fmt.Errorf("unexpected issuer %s", issuer)
+		}
+	}
+	if v.options.Audience != "" {
+		found := false
+		switch audience := claims["aud"].(type) {
+		case string:
+			found = audience == v.options.Audience
+		case []interface{}:
+			for _, value := range audience {
+				if value == v.options.Audience {
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			return fmt.Errorf("unexpected audience")
+		}
+	}
+	return nil
+}
+
+// verifyJWTSignature verifies the signature of a token with the key, which must be the type expected for the algorithm
+func verifyJWTSignature(algorithm string, key interface{}, signed string, signature []byte) error {
+	switch algorithm {
+	case "HS256":
+		secret, ok := key.([]byte)
+		if !ok {
+			return fmt.Errorf("key not valid for algorithm %s", algorithm)
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return fmt.Errorf("invalid signature")
+		}
+	case "RS256":
+		publicKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key not valid for algorithm %s", algorithm)
+		}
+		hash := sha256.Sum256([]byte(signed))
+		if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hash[:], signature); err != nil {
+			return fmt.Errorf("invalid signature")
+		}
+	case "EdDSA":
+		publicKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("key not valid for algorithm %s", algorithm)
+		}
+		if !ed25519.Verify(publicKey, []byte(signed), signature) {
+			return fmt.Errorf("invalid signature")
+		}
+	default:
+		return fmt.Errorf("unsupported algorithm %s", algorithm)
+	}
+	return nil
+}
+
+func decodeJWTSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// jwksCache describes a JSON web key set that is refreshed from a URL
+type jwksCache struct {
+	url      string
+	interval time.Duration
+	client   *http.Client
+	lock     sync.Mutex
+	keys     map[string]interface{}
+	fetched  time.Time
+	// Closed once the refresh in progress, if any, is finished
+	refreshing chan struct{}
+}
+
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+}
+
+// key returns the key with the given ID, refreshing the key set if it is out of date or does not have the key. Only one
+// refresh happens at a time, and the lock is not held while the key set is fetched. Callers with a key in the current
+// set don't wait for the refresh.
+func (c *jwksCache) key(keyID string) (interface{}, bool) {
+	c.lock.Lock()
+	now := time.Now()
+	key, ok := c.keys[keyID]
+	stale := now.Sub(c.fetched) > c.interval
+	if ok && !stale {
+		c.lock.Unlock()
+		return key, true
+	}
+
+	done := c.refreshing
+	if done == nil {
+		if !ok && !stale && now.Sub(c.fetched) <= jwksMinRefreshInterval {
+			// Limit how often unknown key IDs, which anybody can send, cause a refresh
+			c.lock.Unlock()
+			return nil, false
+		}
+		c.fetched = now
+		done = make(chan struct{})
+		c.refreshing = done
+		go c.refresh(done)
+	}
+	c.lock.Unlock()
+
+	if ok {
+		return key, true
+	}
+	<-done
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	key, ok = c.keys[keyID]
+	return key, ok
+}
+
+// refresh replaces the keys with the current key set from the URL, then closes done
+func (c *jwksCache) refresh(done chan struct{}) {
+	keys, err := c.fetch()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err != nil {
+		// Keep using the previous keys until the key set can be refreshed
+		log.PError("Error refreshing JSON web key set", map[string]interface{}{
+			"url":   c.url,
+			"error": err.Error(),
+		})
+	} else {
+		c.keys = keys
+	}
+	c.refreshing = nil
+	close(done)
+}
+
+// fetch returns the keys of the key set from the URL
+func (c *jwksCache) fetch() (map[string]interface{}, error) {
+	resp, err := c.client.Get(c.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	set := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]interface{}{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			log.PWarn("Ignoring invalid JSON web key", map[string]interface{}{
+				"url":   c.url,
+				"kid":   jwk.KeyID,
+				"error": err.Error(),
+			})
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+	log.PDebug("Refreshed JSON web key set", map[string]interface{}{
+		"url":  c.url,
+		"keys": len(keys),
+	})
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid exponent")
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %s", k.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid public key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.KeyType)
+}
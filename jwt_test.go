@@ -0,0 +1,290 @@
+package web_test
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/web"
+)
+
+func signJWT(t *testing.T, algorithm, keyID string, key interface{}, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": algorithm, "typ": "JWT", "kid": keyID})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		hash := sha256.Sum256([]byte(signed))
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, hash[:])
+		if err != nil {
+			t.Fatalf("Error signing token: %s", err.Error())
+		}
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(k, []byte(signed))
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTAuth(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	secret := []byte(randomString(32))
+	server.API.GET("/", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return request.UserData.(web.JWTClaims).Subject(), nil, nil
+	}, web.HandleOptions{
+		AuthenticateMethod: web.JWTAuth(web.JWTOptions{
+			Key:      secret,
+			Issuer:   "issuer",
+			Audience: "api",
+			Cookie:   "token",
+		}),
+	})
+
+	valid := map[string]interface{}{
+		"sub": "user",
+		"iss": "issuer",
+		"aud": []string{"other", "api"},
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	with := func(key string, value interface{}) map[string]interface{} {
+		claims := map[string]interface{}{}
+		for k, v := range valid {
+			claims[k] = v
+		}
+		claims[key] = value
+		return claims
+	}
+
+	check := func(name, token string, cookie bool, expectedStatus int) {
+		req := httptest.NewRequest("GET", "/", nil)
+		if cookie {
+			req.AddCookie(&http.Cookie{Name: "token", Value: token})
+		} else if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		response := server.Do(req)
+		if response.Status != expectedStatus {
+			t.Errorf("Unexpected status code for %s. Expected %d got %d", name, expectedStatus, response.Status)
+		}
+	}
+
+	check("no token", "", false, 401)
+	check("valid", signJWT(t, "HS256", "", secret, valid), false, 200)
+	check("cookie", signJWT(t, "HS256", "", secret, valid), true, 200)
+	check("wrong key", signJWT(t, "HS256", "", []byte("wrong"), valid), false, 401)
+	check("expired", signJWT(t, "HS256", "", secret, with("exp", time.Now().Add(-time.Minute).Unix())), false, 401)
+	check("not before", signJWT(t, "HS256", "", secret, with("nbf", time.Now().Add(time.Hour).Unix())), false, 401)
+	check("issuer", signJWT(t, "HS256", "", secret, with("iss", "other")), false, 401)
+	check("audience", signJWT(t, "HS256", "", secret, with("aud", "other")), false, 401)
+	check("malformed", "not.a.token", false, 401)
+	noExpiry := with("sub", "user")
+	delete(noExpiry, "exp")
+	check("no expiry", signJWT(t, "HS256", "", secret, noExpiry), false, 401)
+
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	check("wrong algorithm", signJWT(t, "EdDSA", "", edKey, valid), false, 401)
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	payload, _ := json.Marshal(valid)
+	check("none algorithm", header+"."+base64.RawURLEncoding.EncodeToString(payload)+".", false, 401)
+}
+
+func TestJWTAuthJWKS(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err.Error())
+	}
+	edPublic, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	var rotated int32
+	var fetches int32
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		keys := []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": "rsa",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+		}
+		if atomic.LoadInt32(&rotated) == 1 {
+			keys = append(keys, map[string]string{
+				"kty": "OKP",
+				"kid": "ed",
+				"crv": "Ed25519",
+				"x":   base64.RawURLEncoding.EncodeToString(edPublic),
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	defer jwks.Close()
+
+	server.API.GET("/", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return request.UserData.(web.JWTClaims).Subject(), nil, nil
+	}, web.HandleOptions{
+		AuthenticateMethod: web.JWTAuth(web.JWTOptions{
+			JWKSURL: jwks.URL,
+		}),
+	})
+
+	server.API.GET("/rotating", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return request.UserData.(web.JWTClaims).Subject(), nil, nil
+	}, web.HandleOptions{
+		AuthenticateMethod: web.JWTAuth(web.JWTOptions{
+			JWKSURL:             jwks.URL,
+			JWKSRefreshInterval: 10 * time.Millisecond,
+		}),
+	})
+
+	check := func(path, token string, expectedStatus int) {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		response := server.Do(req)
+		if response.Status != expectedStatus {
+			t.Errorf("Unexpected status code for %s. Expected %d got %d", path, expectedStatus, response.Status)
+		}
+	}
+
+	claims := map[string]interface{}{"sub": "user", "exp": time.Now().Add(time.Hour).Unix()}
+	check("/", signJWT(t, "RS256", "rsa", rsaKey, claims), 200)
+	check("/", signJWT(t, "RS256", "rsa", rsaKey, claims), 200)
+	if count := atomic.LoadInt32(&fetches); count != 1 {
+		t.Errorf("Unexpected number of key set requests. Expected %d got %d", 1, count)
+	}
+
+	// Keys are not refreshed again immediately for an unknown key
+	atomic.StoreInt32(&rotated, 1)
+	check("/", signJWT(t, "EdDSA", "ed", edKey, claims), 401)
+	if count := atomic.LoadInt32(&fetches); count != 1 {
+		t.Errorf("Unexpected number of key set requests. Expected %d got %d", 1, count)
+	}
+
+	// Rotated keys are used once the key set is refreshed
+	check("/rotating", signJWT(t, "RS256", "rsa", rsaKey, claims), 200)
+	time.Sleep(20 * time.Millisecond)
+	check("/rotating", signJWT(t, "EdDSA", "ed", edKey, claims), 200)
+}
+
+func TestJWTAuthAllowMissingExpiry(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	secret := []byte(randomString(32))
+	server.API.GET("/", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return request.UserData.(web.JWTClaims).Subject(), nil, nil
+	}, web.HandleOptions{
+		AuthenticateMethod: web.JWTAuth(web.JWTOptions{
+			Key:                secret,
+			AllowMissingExpiry: true,
+		}),
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+signJWT(t, "HS256", "", secret, map[string]interface{}{"sub": "user"}))
+	if response := server.Do(req); response.Status != 200 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 200, response.Status)
+	}
+}
+
+func TestJWTAuthJWKSSlowRefresh(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err.Error())
+	}
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	var fetches int32
+	release := make(chan bool)
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&fetches, 1) > 1 {
+			<-release
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": "rsa",
+				"n":   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+		}})
+	}))
+	defer jwks.Close()
+
+	server.API.GET("/", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return request.UserData.(web.JWTClaims).Subject(), nil, nil
+	}, web.HandleOptions{
+		AuthenticateMethod: web.JWTAuth(web.JWTOptions{
+			JWKSURL:             jwks.URL,
+			JWKSRefreshInterval: 10 * time.Millisecond,
+		}),
+	})
+
+	claims := map[string]interface{}{"sub": "user", "exp": time.Now().Add(time.Hour).Unix()}
+	request := func(token string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return server.Do(req).Status
+	}
+
+	if status := request(signJWT(t, "RS256", "rsa", rsaKey, claims)); status != 200 {
+		t.Fatalf("Unexpected status code. Expected %d got %d", 200, status)
+	}
+
+	// An unknown key starts a refresh, which blocks until released
+	time.Sleep(20 * time.Millisecond)
+	unknown := make(chan int)
+	go func() {
+		unknown <- request(signJWT(t, "EdDSA", "ed", edKey, claims))
+	}()
+	for i := 0; i < 100 && atomic.LoadInt32(&fetches) < 2; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Tokens signed with a known key must not wait for the refresh
+	known := make(chan int)
+	go func() {
+		known <- request(signJWT(t, "RS256", "rsa", rsaKey, claims))
+	}()
+	select {
+	case status := <-known:
+		if status != 200 {
+			t.Errorf("Unexpected status code. Expected %d got %d", 200, status)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Request with known key waited for key set refresh")
+	}
+
+	close(release)
+	if status := <-unknown; status != 401 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 401, status)
+	}
+	if count := atomic.LoadInt32(&fetches); count != 2 {
+		t.Errorf("Unexpected number of key set requests. Expected %d got %d", 2, count)
+	}
+}
@@ -0,0 +1,215 @@
+package web
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const defaultListenRetryDelay = 250 * time.Millisecond
+
+// listen will open a listener on the bind address of the server, retrying if the address is in use. If a listener was
+// inherited from a parent process with [web.Server.Handoff] then it is used instead.
+func (s *Server) listen() (net.Listener, error) {
+	if listener, ok, err := inheritedListener(); ok {
+		if err != nil {
+			return nil, err
+		}
+		log.PInfo("Using inherited listener", map[string]interface{}{
+			"listen_address": listener.Addr().String(),
+		})
+		return listener, nil
+	}
+
+	delay := s.Options.ListenRetryDelay
+	if delay <= 0 {
+		delay = defaultListenRetryDelay
+	}
+
+	network := s.Options.ListenNetwork
+	if network == "" {
+		network = "tcp"
+	}
+	address, err := s.listenAddress(network)
+	if err != nil {
+		return nil, err
+	}
+
+	attempt := 0
+	for {
+		listener, err := net.Listen(network, address)
+		if err == nil {
+			return listener, nil
+		}
+		if !errors.Is(err, syscall.EADDRINUSE) || attempt >= s.Options.ListenRetries {
+			return nil, err
+		}
+
+		attempt++
+		log.PWarn("Address in use, retrying listen", map[string]interface{}{
+			"listen_address": address,
+			"attempt":        attempt,
+			"delay":          delay.String(),
+		})
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// listenAddress returns the address to listen on, taking the bind interface into consideration
+func (s *Server) listenAddress(network string) (string, error) {
+	if s.Options.BindInterface == "" {
+		return s.BindAddress, nil
+	}
+
+	_, port, err := net.SplitHostPort(s.BindAddress)
+	if err != nil {
+		return "", err
+	}
+	iface, err := net.InterfaceByName(s.Options.BindInterface)
+	if err != nil {
+		return "", err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", err
+	}
+
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		isIPv4 := ipNet.IP.To4() != nil
+		if (network == "tcp4" && !isIPv4) || (network == "tcp6" && isIPv4) {
+			continue
+		}
+		return net.JoinHostPort(ipNet.IP.String(), port), nil
+	}
+
+	return "", fmt.Errorf("no suitable %s address on interface %s", network, s.Options.BindInterface)
+}
+
+// tuneListener wraps the listener to apply the connection options of the server
+func (s *Server) tuneListener(listener net.Listener) net.Listener {
+	tuned := &tunedListener{
+		Listener:  listener,
+		options:   s.Options,
+		closed:    make(chan struct{}),
+		closeOnce: &sync.Once{},
+	}
+	if s.Options.MaxConnections > 0 {
+		tuned.slots = make(chan struct{}, s.Options.MaxConnections)
+	}
+	if s.Options.TLSConfig != nil {
+		return tls.NewListener(tuned, s.tlsConfig())
+	}
+	return tuned
+}
+
+// TLSConfig returns a copy of the TLS configuration used for connections to the server, including the verification of
+// client certificates with the ClientCAs option, or nil if the TLSConfig option is not set.
+func (s *Server) TLSConfig() *tls.Config {
+	if s.Options.TLSConfig == nil {
+		return nil
+	}
+	return s.tlsConfig()
+}
+
+// tlsConfig returns the TLS configuration of the server with the protocols the server supports advertised to clients
+func (s *Server) tlsConfig() *tls.Config {
+	config := s.Options.TLSConfig.Clone()
+	if s.Options.ClientCAs != nil && config.ClientAuth == tls.NoClientCert {
+		config.ClientCAs = s.Options.ClientCAs
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		if s.Options.RequireClientCertificate {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	if len(config.NextProtos) > 0 {
+		return config
+	}
+	if !s.Options.DisableHTTP2 {
+		config.NextProtos = append(config.NextProtos, "h2")
+	}
+	config.NextProtos = append(config.NextProtos, "http/1.1")
+	return config
+}
+
+// protocols returns the HTTP protocols the server accepts
+func (s *Server) protocols() *http.Protocols {
+	protocols := &http.Protocols{}
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(!s.Options.DisableHTTP2)
+	protocols.SetUnencryptedHTTP2(s.Options.EnableH2C)
+	return protocols
+}
+
+type tunedListener struct {
+	net.Listener
+	options   ServerOptions
+	slots     chan struct{}
+	closed    chan struct{}
+	closeOnce *sync.Once
+}
+
+func (l *tunedListener) Accept() (net.Conn, error) {
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-l.closed:
+			return nil, net.ErrClosed
+		}
+	}
+
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		if l.slots != nil {
+			<-l.slots
+		}
+		return nil, err
+	}
+
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		if l.options.TCPKeepAlive > 0 {
+			tcpConn.SetKeepAlive(true)
+			tcpConn.SetKeepAlivePeriod(l.options.TCPKeepAlive)
+		} else if l.options.TCPKeepAlive < 0 {
+			tcpConn.SetKeepAlive(false)
+		}
+		if l.options.DisableTCPNoDelay {
+			tcpConn.SetNoDelay(false)
+		}
+	}
+
+	if l.slots == nil {
+		return conn, nil
+	}
+	return &slotConn{Conn: conn, slots: l.slots, once: &sync.Once{}}, nil
+}
+
+func (l *tunedListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+	})
+	return l.Listener.Close()
+}
+
+// slotConn is a connection that releases its slot on the listener when closed
+type slotConn struct {
+	net.Conn
+	slots chan struct{}
+	once  *sync.Once
+}
+
+func (c *slotConn) Close() error {
+	c.once.Do(func() {
+		<-c.slots
+	})
+	return c.Conn.Close()
+}
@@ -0,0 +1,472 @@
+package web_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestListenRetry(t *testing.T) {
+	t.Parallel()
+
+	existing, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err.Error())
+	}
+	address := existing.Addr().String()
+
+	server := web.New(address)
+	server.Options.ListenRetries = 5
+	server.Options.ListenRetryDelay = 10 * time.Millisecond
+	listening := make(chan net.Addr, 1)
+	server.Options.OnListen = func(address net.Addr) {
+		listening <- address
+	}
+	server.API.GET("/", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}, web.HandleOptions{})
+
+	go server.Start()
+	time.Sleep(15 * time.Millisecond)
+	existing.Close()
+
+	select {
+	case bound := <-listening:
+		if bound.String() != address {
+			t.Errorf("Unexpected listen address. Expected '%s' got '%s'", address, bound.String())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Server did not listen after address became available")
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/", address))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("Unexpected status code. Expected %d got %d", 200, resp.StatusCode)
+	}
+	server.Stop()
+}
+
+func TestListenNoRetry(t *testing.T) {
+	t.Parallel()
+
+	existing, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err.Error())
+	}
+	defer existing.Close()
+
+	server := web.New(existing.Addr().String())
+	if err := server.Start(); err == nil {
+		t.Fatalf("No error seen when one expected")
+	}
+}
+
+func TestListenOnListenPortZero(t *testing.T) {
+	t.Parallel()
+
+	server := web.New("127.0.0.1:0")
+	listening := make(chan net.Addr, 1)
+	server.Options.OnListen = func(address net.Addr) {
+		listening <- address
+	}
+	go server.Start()
+
+	select {
+	case bound := <-listening:
+		port := bound.(*net.TCPAddr).Port
+		if port == 0 {
+			t.Errorf("No port reported to OnListen")
+		}
+		if uint16(port) != server.ListenPort {
+			t.Errorf("Unexpected listen port. Expected %d got %d", server.ListenPort, port)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("OnListen not called")
+	}
+	server.Stop()
+}
+
+func TestListenNetwork(t *testing.T) {
+	t.Parallel()
+
+	server := web.New("[::1]:0")
+	server.Options.ListenNetwork = "tcp4"
+	if err := server.Start(); err == nil {
+		server.Stop()
+		t.Fatalf("No error seen when listening on IPv6 address with IPv4 network")
+	}
+}
+
+func TestListenBindInterface(t *testing.T) {
+	t.Parallel()
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatalf("Error listing interfaces: %s", err.Error())
+	}
+	loopback := ""
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			loopback = iface.Name
+			break
+		}
+	}
+	if loopback == "" {
+		t.Skip("No loopback interface")
+	}
+
+	server := web.New(":0")
+	server.Options.ListenNetwork = "tcp4"
+	server.Options.BindInterface = loopback
+	listening := make(chan net.Addr, 1)
+	server.Options.OnListen = func(address net.Addr) {
+		listening <- address
+	}
+	go server.Start()
+
+	select {
+	case bound := <-listening:
+		if !bound.(*net.TCPAddr).IP.IsLoopback() {
+			t.Errorf("Server did not bind to loopback interface address: %s", bound.String())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Server did not listen")
+	}
+	server.Stop()
+
+	server = web.New(":0")
+	server.Options.BindInterface = randomString(4)
+	if err := server.Start(); err == nil {
+		t.Fatalf("No error seen when binding to unknown interface")
+	}
+}
+
+func selfSignedCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err.Error())
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error generating certificate: %s", err.Error())
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{certificate},
+		PrivateKey:  key,
+	}
+}
+
+func TestListenTLS(t *testing.T) {
+	t.Parallel()
+
+	server := web.New("127.0.0.1:0")
+	server.Options.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{selfSignedCertificate(t)},
+	}
+	server.Options.TCPKeepAlive = time.Minute
+	server.Options.DisableTCPNoDelay = true
+	listening := make(chan net.Addr, 1)
+	server.Options.OnListen = func(address net.Addr) {
+		listening <- address
+	}
+	server.API.GET("/", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return request.HTTP.TLS != nil, nil, nil
+	}, web.HandleOptions{})
+	go server.Start()
+	address := <-listening
+
+	httpc := http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	resp, err := httpc.Get(fmt.Sprintf("https://%s/", address.String()))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "true") {
+		t.Errorf("Request was not made over TLS: %s", body)
+	}
+
+	if resp, err := http.Get(fmt.Sprintf("http://%s/", address.String())); err == nil && resp.StatusCode != 400 {
+		t.Errorf("Unexpected status code for plain HTTP request to TLS server. Expected %d got %d", 400, resp.StatusCode)
+	}
+	server.Stop()
+}
+
+func TestListenClientCertificate(t *testing.T) {
+	t.Parallel()
+
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caData, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Error generating certificate: %s", err.Error())
+	}
+	caCertificate, _ := x509.ParseCertificate(caData)
+	clientKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	clientTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "device-1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientData, err := x509.CreateCertificate(rand.Reader, clientTemplate, caCertificate, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Error generating certificate: %s", err.Error())
+	}
+	clientCertificate := tls.Certificate{Certificate: [][]byte{clientData}, PrivateKey: clientKey}
+	pool := x509.NewCertPool()
+	pool.AddCert(caCertificate)
+
+	start := func(require bool) string {
+		server := web.New("127.0.0.1:0")
+		server.Options.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{selfSignedCertificate(t)},
+		}
+		server.Options.ClientCAs = pool
+		server.Options.RequireClientCertificate = require
+		listening := make(chan net.Addr, 1)
+		server.Options.OnListen = func(address net.Addr) {
+			listening <- address
+		}
+		server.API.GET("/", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+			return request.UserData, nil, nil
+		}, web.HandleOptions{
+			AuthenticateMethod: web.ClientCertificateAuth(func(certificate *x509.Certificate) interface{} {
+				return certificate.Subject.CommonName
+			}),
+		})
+		go server.Start()
+		t.Cleanup(server.Stop)
+		return (<-listening).String()
+	}
+	get := func(address string, certificates []tls.Certificate) (*http.Response, string, error) {
+		httpc := http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true, Certificates: certificates},
+			},
+		}
+		resp, err := httpc.Get(fmt.Sprintf("https://%s/", address))
+		if err != nil {
+			return nil, "", err
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body), nil
+	}
+
+	address := start(false)
+	resp, body, err := get(address, []tls.Certificate{clientCertificate})
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 200 || !strings.Contains(body, "device-1") {
+		t.Errorf("Unexpected response for client with certificate. Status %d body '%s'", resp.StatusCode, body)
+	}
+	if resp, _, err := get(address, nil); err != nil || resp.StatusCode != 401 {
+		t.Errorf("Unexpected response for client without certificate: %v", err)
+	}
+
+	address = start(true)
+	if _, _, err := get(address, []tls.Certificate{clientCertificate}); err != nil {
+		t.Errorf("Network error: %s", err.Error())
+	}
+	if _, _, err := get(address, nil); err == nil {
+		t.Errorf("No error for client without certificate when one is required")
+	}
+}
+
+func TestListenMaxConnections(t *testing.T) {
+	t.Parallel()
+
+	server := web.New("127.0.0.1:0")
+	server.Options.MaxConnections = 1
+	listening := make(chan net.Addr, 1)
+	server.Options.OnListen = func(address net.Addr) {
+		listening <- address
+	}
+	server.API.GET("/", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}, web.HandleOptions{})
+	go server.Start()
+	address := <-listening
+
+	idle, err := net.Dial("tcp", address.String())
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	httpc := http.Client{Timeout: 50 * time.Millisecond}
+	if _, err := httpc.Get(fmt.Sprintf("http://%s/", address.String())); err == nil {
+		t.Fatalf("No error seen for request over connection limit")
+	}
+
+	idle.Close()
+	httpc = http.Client{Timeout: time.Second}
+	resp, err := httpc.Get(fmt.Sprintf("http://%s/", address.String()))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("Unexpected status code. Expected %d got %d", 200, resp.StatusCode)
+	}
+
+	stopped := make(chan bool)
+	go func() {
+		server.Stop()
+		stopped <- true
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatalf("Server did not stop")
+	}
+}
+
+func TestListenHTTP2(t *testing.T) {
+	t.Parallel()
+
+	server := web.New("127.0.0.1:0")
+	server.Options.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{selfSignedCertificate(t)},
+	}
+	listening := make(chan net.Addr, 1)
+	server.Options.OnListen = func(address net.Addr) {
+		listening <- address
+	}
+	server.API.GET("/", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return request.HTTP.Proto, nil, nil
+	}, web.HandleOptions{})
+	go server.Start()
+	address := <-listening
+
+	httpc := http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			ForceAttemptHTTP2: true,
+		},
+	}
+	resp, err := httpc.Get(fmt.Sprintf("https://%s/", address.String()))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	io.ReadAll(resp.Body)
+	if resp.ProtoMajor != 2 {
+		t.Errorf("Unexpected protocol. Expected %d got %d", 2, resp.ProtoMajor)
+	}
+	server.Stop()
+}
+
+func TestListenDisableHTTP2(t *testing.T) {
+	t.Parallel()
+
+	server := web.New("127.0.0.1:0")
+	server.Options.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{selfSignedCertificate(t)},
+	}
+	server.Options.DisableHTTP2 = true
+	listening := make(chan net.Addr, 1)
+	server.Options.OnListen = func(address net.Addr) {
+		listening <- address
+	}
+	server.API.GET("/", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}, web.HandleOptions{})
+	go server.Start()
+	address := <-listening
+
+	httpc := http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			ForceAttemptHTTP2: true,
+		},
+	}
+	resp, err := httpc.Get(fmt.Sprintf("https://%s/", address.String()))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	io.ReadAll(resp.Body)
+	if resp.ProtoMajor != 1 {
+		t.Errorf("Unexpected protocol. Expected %d got %d", 1, resp.ProtoMajor)
+	}
+	server.Stop()
+}
+
+func TestListenH2C(t *testing.T) {
+	t.Parallel()
+
+	server := web.New("127.0.0.1:0")
+	server.Options.EnableH2C = true
+	listening := make(chan net.Addr, 1)
+	server.Options.OnListen = func(address net.Addr) {
+		listening <- address
+	}
+	server.API.GET("/", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}, web.HandleOptions{})
+	go server.Start()
+	address := <-listening
+
+	protocols := &http.Protocols{}
+	protocols.SetUnencryptedHTTP2(true)
+	httpc := http.Client{
+		Transport: &http.Transport{
+			Protocols: protocols,
+		},
+	}
+	resp, err := httpc.Get(fmt.Sprintf("http://%s/", address.String()))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	io.ReadAll(resp.Body)
+	if resp.ProtoMajor != 2 {
+		t.Errorf("Unexpected protocol. Expected %d got %d", 2, resp.ProtoMajor)
+	}
+
+	// HTTP/1.1 clients must still be accepted
+	resp, err = http.Get(fmt.Sprintf("http://%s/", address.String()))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 200, resp.StatusCode)
+	}
+	server.Stop()
+}
@@ -0,0 +1,369 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"runtime/metrics"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ecnepsnai/web/router"
+)
+
+// sizeBuckets are the upper bounds, in bytes, of the buckets used for size histograms
+var sizeBuckets = []uint64{0, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20}
+
+// SizeHistogram describes the distribution of sizes, in bytes
+type SizeHistogram struct {
+	// The inclusive upper bound of each bucket, in bytes. Counts has one more element than Buckets, which counts all
+	// sizes larger than the last bucket.
+	Buckets []uint64 `json:"buckets"`
+	// The number of observations that fell within each bucket
+	Counts []uint64 `json:"counts"`
+	// The total of all observed sizes
+	Sum uint64 `json:"sum"`
+	// The number of observations
+	Count uint64 `json:"count"`
+}
+
+func newSizeHistogram() SizeHistogram {
+	return SizeHistogram{
+		Buckets: sizeBuckets,
+		Counts:  make([]uint64, len(sizeBuckets)+1),
+	}
+}
+
+func (h *SizeHistogram) observe(size uint64) {
+	i := sort.Search(len(h.Buckets), func(i int) bool { return size <= h.Buckets[i] })
+	h.Counts[i]++
+	h.Sum += size
+	h.Count++
+}
+
+func (h SizeHistogram) copy() SizeHistogram {
+	counts := make([]uint64, len(h.Counts))
+	copy(counts, h.Counts)
+	h.Counts = counts
+	return h
+}
+
+// RouteMetrics describes metrics collected for a registered route
+type RouteMetrics struct {
+	// The HTTP method of the route
+	Method string `json:"method"`
+	// The path of the route as it was registered, including any parameters
+	Path string `json:"path"`
+	// The name of the variant of the route, from the Variant handle option. Each variant of a route has its own
+	// metrics.
+	Variant string `json:"variant,omitempty"`
+	// The number of requests handled by the route
+	Requests uint64 `json:"requests"`
+	// The distribution of request body sizes. Only bytes read by the handle are counted.
+	RequestBytes SizeHistogram `json:"request_bytes"`
+	// The distribution of response body sizes
+	ResponseBytes SizeHistogram `json:"response_bytes"`
+	// The number of responses with each status code, such as 200 or 500. Requests for hijacked connections, such as
+	// websockets, are not counted.
+	Statuses map[int]uint64 `json:"statuses"`
+	// The number of requests where the client went away before the response was written, such as by closing the
+	// connection. These requests are also counted in Statuses with the status the handle wrote, but are not reported
+	// as server errors.
+	ClientAborts uint64 `json:"client_aborts"`
+	// The status of the service level objectives of the route, if the route has the SLO handle option
+	SLO *SLOStatus `json:"slo,omitempty"`
+	// The resources used by sampled requests. Only populated if ExecutionSampling is enabled on the server.
+	Execution ExecutionMetrics `json:"execution"`
+}
+
+// ExecutionMetrics describes the resources used by the sampled requests of a route. Values are measured using
+// process-wide runtime metrics for the duration of the handle, so they include any work done concurrently by other
+// requests. They are best used to compare routes with each other rather than as exact measurements.
+type ExecutionMetrics struct {
+	// The number of requests that were sampled
+	Samples uint64 `json:"samples"`
+	// The estimated CPU time spent running Go code while handling the sampled requests, in seconds
+	CPUSeconds float64 `json:"cpu_seconds"`
+	// The number of bytes allocated on the heap while handling the sampled requests
+	AllocatedBytes uint64 `json:"allocated_bytes"`
+	// The number of heap allocations made while handling the sampled requests
+	Allocations uint64 `json:"allocations"`
+}
+
+type routeMetrics struct {
+	metrics  RouteMetrics
+	lock     *sync.Mutex
+	requests *uint64
+	slo      *sloTracker
+}
+
+// shouldSample returns true if the current request to the route should have its execution sampled, with one out of
+// every rate requests being sampled
+func (m *routeMetrics) shouldSample(rate int) bool {
+	if rate <= 0 {
+		return false
+	}
+	return atomic.AddUint64(m.requests, 1)%uint64(rate) == 0
+}
+
+func (m *routeMetrics) recordExecution(before, after []metrics.Sample) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.metrics.Execution.Samples++
+	m.metrics.Execution.CPUSeconds += after[0].Value.Float64() - before[0].Value.Float64()
+	m.metrics.Execution.AllocatedBytes += after[1].Value.Uint64() - before[1].Value.Uint64()
+	m.metrics.Execution.Allocations += after[2].Value.Uint64() - before[2].Value.Uint64()
+}
+
+// readExecutionSample reads the runtime metrics used for execution sampling
+func readExecutionSample() []metrics.Sample {
+	samples := []metrics.Sample{
+		{Name: "/cpu/classes/user:cpu-seconds"},
+		{Name: "/gc/heap/allocs:bytes"},
+		{Name: "/gc/heap/allocs:objects"},
+	}
+	metrics.Read(samples)
+	return samples
+}
+
+func (m *routeMetrics) record(requestBytes, responseBytes uint64, status int, aborted bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.metrics.Requests++
+	if status != 0 {
+		m.metrics.Statuses[status]++
+	}
+	if aborted {
+		m.metrics.ClientAborts++
+	}
+	m.metrics.RequestBytes.observe(requestBytes)
+	m.metrics.ResponseBytes.observe(responseBytes)
+}
+
+// ServerStats describes the total number of requests and bytes transferred by all routes of the server
+type ServerStats struct {
+	// The number of requests handled by all routes
+	Requests uint64 `json:"requests"`
+	// The number of bytes read from request bodies by all handles
+	RequestBytes uint64 `json:"request_bytes"`
+	// The number of bytes written to response bodies by all handles
+	ResponseBytes uint64 `json:"response_bytes"`
+}
+
+// RequestEvent describes a request that was handled by a registered route
+type RequestEvent struct {
+	// The HTTP method of the request
+	Method string
+	// The path of the route as it was registered, including any parameters
+	Route string
+	// The name of the variant of the route that handled the request, from the Variant handle option
+	Variant string
+	// The full URL of the request
+	URL string
+	// The address of the client, as determined with the TrustedProxies option
+	RemoteAddr string
+	// The status code of the response, or 0 if the connection was hijacked, such as for websockets. Handles that did
+	// not write a status have a status of 200.
+	Status int
+	// The number of bytes read from the request body by the handle
+	RequestBytes uint64
+	// The number of bytes written to the response body
+	ResponseBytes uint64
+	// The amount of time the request took
+	Elapsed time.Duration
+	// If the client went away before the response was written, such as by closing the connection
+	ClientAborted bool
+}
+
+type metricsStore struct {
+	routes        map[string]*routeMetrics
+	lock          *sync.RWMutex
+	requests      *uint64
+	requestBytes  *uint64
+	responseBytes *uint64
+}
+
+func newMetricsStore() *metricsStore {
+	return &metricsStore{
+		routes:        map[string]*routeMetrics{},
+		lock:          &sync.RWMutex{},
+		requests:      new(uint64),
+		requestBytes:  new(uint64),
+		responseBytes: new(uint64),
+	}
+}
+
+func (s *metricsStore) recordTotal(requestBytes, responseBytes uint64) {
+	atomic.AddUint64(s.requests, 1)
+	atomic.AddUint64(s.requestBytes, requestBytes)
+	atomic.AddUint64(s.responseBytes, responseBytes)
+}
+
+func (s *metricsStore) route(method, path, variant string) *routeMetrics {
+	key := method + " " + path + " " + variant
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if m, ok := s.routes[key]; ok {
+		return m
+	}
+	m := &routeMetrics{
+		metrics: RouteMetrics{
+			Method:        method,
+			Path:          path,
+			Variant:       variant,
+			RequestBytes:  newSizeHistogram(),
+			ResponseBytes: newSizeHistogram(),
+			Statuses:      map[int]uint64{},
+		},
+		lock:     &sync.Mutex{},
+		requests: new(uint64),
+	}
+	s.routes[key] = m
+	return m
+}
+
+// RouteMetrics returns the metrics collected for each registered API, HTTP, HTTPEasy, and Socket route, sorted by path
+// and method.
+func (s *Server) RouteMetrics() []RouteMetrics {
+	s.metrics.lock.RLock()
+	routes := make([]RouteMetrics, 0, len(s.metrics.routes))
+	for _, m := range s.metrics.routes {
+		m.lock.Lock()
+		route := m.metrics
+		route.RequestBytes = route.RequestBytes.copy()
+		route.ResponseBytes = route.ResponseBytes.copy()
+		route.Statuses = make(map[int]uint64, len(m.metrics.Statuses))
+		for status, count := range m.metrics.Statuses {
+			route.Statuses[status] = count
+		}
+		slo := m.slo
+		m.lock.Unlock()
+		if slo != nil {
+			status := slo.current(time.Now())
+			route.SLO = &status
+		}
+		routes = append(routes, route)
+	}
+	s.metrics.lock.RUnlock()
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path == routes[j].Path {
+			if routes[i].Method == routes[j].Method {
+				return routes[i].Variant < routes[j].Variant
+			}
+			return routes[i].Method < routes[j].Method
+		}
+		return routes[i].Path < routes[j].Path
+	})
+	return routes
+}
+
+// Stats returns the total number of requests and bytes transferred by all API, HTTP, HTTPEasy, and Socket routes since
+// the server was created. Bytes transferred over websocket connections are not included.
+func (s *Server) Stats() ServerStats {
+	return ServerStats{
+		Requests:      atomic.LoadUint64(s.metrics.requests),
+		RequestBytes:  atomic.LoadUint64(s.metrics.requestBytes),
+		ResponseBytes: atomic.LoadUint64(s.metrics.responseBytes),
+	}
+}
+
+// reportRequest calls the OnRequest hook of the server for a completed request
+func (s *Server) reportRequest(route, variant string, r *http.Request, w *responseWriter, requestBytes uint64, elapsed time.Duration, aborted bool) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.PError("Recovered from panic during request hook", map[string]interface{}{
+				"error": fmt.Sprintf("%v", p),
+				"route": route,
+				"stack": string(debug.Stack()),
+			})
+		}
+	}()
+	s.Options.OnRequest(RequestEvent{
+		Method:        r.Method,
+		Route:         route,
+		Variant:       variant,
+		URL:           r.URL.String(),
+		RemoteAddr:    s.realRemoteAddr(r).String(),
+		Status:        w.statusCode(),
+		RequestBytes:  requestBytes,
+		ResponseBytes: w.written,
+		Elapsed:       elapsed,
+		ClientAborted: aborted,
+	})
+}
+
+// Stats registers a GET API handle at path that responds with the metrics of all routes on the server, the same as
+// [web.Server.RouteMetrics]. Use the options to require authentication, as metrics may reveal the routes of the
+// application.
+func (a API) Stats(path string, options HandleOptions) {
+	a.GET(path, func(request Request) (interface{}, *APIResponse, *Error) {
+		return a.server.RouteMetrics(), nil, nil
+	}, options)
+}
+
+// measure wraps the handle for a route to count the bytes read from the request and written to the response, to trace
+// requests, to start spans with the Tracer of the server, to report server errors and client aborts, and to close
+// connections in lame duck mode
+func (s *Server) measure(method, path string, options HandleOptions, handle router.Handle) router.Handle {
+	route := s.metrics.route(method, path, options.Variant)
+	route.lock.Lock()
+	route.slo = nil
+	if options.SLO != nil {
+		route.slo = newSLOTracker(method, path, options.Variant, *options.SLO)
+	}
+	slo := route.slo
+	route.lock.Unlock()
+	return func(w http.ResponseWriter, r router.Request) {
+		start := time.Now()
+		writer := &responseWriter{ResponseWriter: w}
+		if isSynthetic(r.HTTP) {
+			handle(writer, r)
+			return
+		}
+		body := &countingReader{ReadCloser: r.HTTP.Body}
+		if r.HTTP.Body != nil {
+			r.HTTP.Body = body
+		}
+		if s.isLameDuck() && r.HTTP.ProtoMajor < 3 {
+			writer.Header().Set("Connection", "close")
+		}
+		if s.Options.AltSvc != "" && r.HTTP.ProtoMajor < 3 {
+			writer.Header().Set("Alt-Svc", s.Options.AltSvc)
+		}
+		if s.isTraceRequest(r.HTTP) {
+			writer.trace = newRequestTrace(start)
+		}
+		var span Span
+		r.HTTP, span = s.startSpan(method, path, r.HTTP)
+		if span != nil {
+			defer endSpan(span, writer)
+		}
+		if route.shouldSample(s.Options.ExecutionSampling) {
+			before := readExecutionSample()
+			handle(writer, r)
+			route.recordExecution(before, readExecutionSample())
+		} else {
+			handle(writer, r)
+		}
+		aborted := clientAborted(writer, r.HTTP)
+		route.record(body.read, writer.written, writer.statusCode(), aborted)
+		s.metrics.recordTotal(body.read, writer.written)
+		if s.Options.OnRequest != nil {
+			s.reportRequest(path, options.Variant, r.HTTP, writer, body.read, time.Since(start), aborted)
+		}
+		if writer.trace != nil {
+			s.logTrace(path, r.HTTP, writer)
+		}
+		if writer.status >= 500 && !aborted {
+			s.reportServerError(path, r.HTTP, writer, time.Since(start))
+		}
+		if slo != nil && !aborted && !writer.hijacked {
+			if status, changed := slo.observe(time.Now(), writer.statusCode(), time.Since(start)); changed {
+				s.reportSLO(status)
+			}
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package web_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestRouteMetrics(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	apiHandle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		io.ReadAll(request.HTTP.Body)
+		return strings.Repeat("a", 2048), nil, nil
+	}
+	easyHandle := func(request web.Request) web.HTTPResponse {
+		return web.HTTPResponse{
+			Reader: io.NopCloser(bytes.NewReader([]byte("hello"))),
+		}
+	}
+
+	apiPath := randomString(5)
+	easyPath := randomString(5)
+	server.API.POST("/"+apiPath, apiHandle, web.HandleOptions{})
+	server.HTTPEasy.GET("/"+easyPath+"/:id", easyHandle, web.HandleOptions{})
+
+	for i := 0; i < 2; i++ {
+		resp, err := http.Post(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, apiPath), "application/json", strings.NewReader(`{"value":"hello"}`))
+		if err != nil {
+			t.Fatalf("Network error: %s", err.Error())
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/%s/1", server.ListenPort, easyPath))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	metrics := server.RouteMetrics()
+	if len(metrics) != 2 {
+		t.Fatalf("Unexpected number of routes. Expected %d got %d", 2, len(metrics))
+	}
+
+	for _, route := range metrics {
+		switch route.Path {
+		case "/" + apiPath:
+			if route.Method != "POST" {
+				t.Errorf("Unexpected method. Expected %s got %s", "POST", route.Method)
+			}
+			if route.Requests != 2 {
+				t.Errorf("Unexpected number of requests. Expected %d got %d", 2, route.Requests)
+			}
+			if route.RequestBytes.Sum != 34 {
+				t.Errorf("Unexpected request bytes. Expected %d got %d", 34, route.RequestBytes.Sum)
+			}
+			if route.ResponseBytes.Sum < 4096 {
+				t.Errorf("Unexpected response bytes. Expected at least %d got %d", 4096, route.ResponseBytes.Sum)
+			}
+			if route.ResponseBytes.Counts[2] != 2 {
+				t.Errorf("Responses not counted in expected bucket: %v", route.ResponseBytes.Counts)
+			}
+		case "/" + easyPath + "/:id":
+			if route.Requests != 1 {
+				t.Errorf("Unexpected number of requests. Expected %d got %d", 1, route.Requests)
+			}
+			if route.RequestBytes.Sum != 0 {
+				t.Errorf("Unexpected request bytes. Expected %d got %d", 0, route.RequestBytes.Sum)
+			}
+			if route.ResponseBytes.Sum != 5 {
+				t.Errorf("Unexpected response bytes. Expected %d got %d", 5, route.ResponseBytes.Sum)
+			}
+		default:
+			t.Errorf("Unexpected route %s", route.Path)
+		}
+	}
+}
+
+func TestRouteMetricsExecution(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+	server.Options.ExecutionSampling = 2
+
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		data := make([][]byte, 100)
+		for i := range data {
+			data[i] = bytes.Repeat([]byte{0}, 1024)
+		}
+		return len(data), nil, nil
+	}
+
+	path := randomString(5)
+	statsPath := randomString(5)
+	server.API.GET("/"+path, handle, web.HandleOptions{})
+	server.API.Stats("/"+statsPath, web.HandleOptions{})
+
+	for i := 0; i < 4; i++ {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path))
+		if err != nil {
+			t.Fatalf("Network error: %s", err.Error())
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, statsPath))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	response := struct {
+		Data []web.RouteMetrics `json:"data"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %s", err.Error())
+	}
+
+	var route *web.RouteMetrics
+	for i, metrics := range response.Data {
+		if metrics.Path == "/"+path {
+			route = &response.Data[i]
+		}
+	}
+	if route == nil {
+		t.Fatalf("No metrics returned for route")
+	}
+	if route.Execution.Samples != 2 {
+		t.Errorf("Unexpected number of samples. Expected %d got %d", 2, route.Execution.Samples)
+	}
+	// Allocations are read from process-wide runtime metrics, which are approximate and include other goroutines, so the
+	// exact amount can't be checked
+	if route.Execution.AllocatedBytes == 0 {
+		t.Errorf("No allocated bytes recorded")
+	}
+	if route.Execution.Allocations == 0 {
+		t.Errorf("No allocations recorded")
+	}
+}
+
+func TestServerStats(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	events := make(chan web.RequestEvent, 2)
+	server.Options.OnRequest = func(event web.RequestEvent) {
+		events <- event
+	}
+	server.HTTP.POST("/echo/:name", func(w http.ResponseWriter, r web.Request) {
+		body, _ := io.ReadAll(r.HTTP.Body)
+		w.Write(body)
+		w.Write(body)
+	}, web.HandleOptions{})
+
+	for i := 0; i < 2; i++ {
+		server.Do(httptest.NewRequest("POST", "/echo/test", strings.NewReader("hello")))
+	}
+
+	stats := server.Stats()
+	if stats.Requests != 2 || stats.RequestBytes != 10 || stats.ResponseBytes != 20 {
+		t.Errorf("Unexpected stats. Expected %d/%d/%d got %d/%d/%d", 2, 10, 20, stats.Requests, stats.RequestBytes, stats.ResponseBytes)
+	}
+
+	event := <-events
+	if event.Route != "/echo/:name" || event.URL != "/echo/test" || event.Method != "POST" {
+		t.Errorf("Unexpected request event %+v", event)
+	}
+	if event.Status != 200 || event.RequestBytes != 5 || event.ResponseBytes != 10 {
+		t.Errorf("Unexpected request event %+v", event)
+	}
+}
+
+func TestRouteMetricsStatus(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	server.HTTP.GET("/empty", func(w http.ResponseWriter, r web.Request) {}, web.HandleOptions{})
+	server.HTTP.GET("/missing", func(w http.ResponseWriter, r web.Request) {
+		w.WriteHeader(404)
+		w.Write([]byte("missing"))
+	}, web.HandleOptions{})
+	server.HTTP.GET("/panic", func(w http.ResponseWriter, r web.Request) {
+		panic("handle panic")
+	}, web.HandleOptions{})
+
+	var events []web.RequestEvent
+	server.Options.OnRequest = func(event web.RequestEvent) {
+		events = append(events, event)
+	}
+
+	server.Request("GET", "/empty", nil)
+	server.Request("GET", "/missing", nil)
+	server.Request("GET", "/missing", nil)
+	server.Request("GET", "/panic", nil)
+
+	expected := map[string]map[int]uint64{
+		"/empty":   {200: 1},
+		"/missing": {404: 2},
+		"/panic":   {500: 1},
+	}
+	for _, route := range server.RouteMetrics() {
+		if fmt.Sprintf("%v", route.Statuses) != fmt.Sprintf("%v", expected[route.Path]) {
+			t.Errorf("Unexpected statuses for %s. Expected %v got %v", route.Path, expected[route.Path], route.Statuses)
+		}
+	}
+	if len(events) != 4 || events[0].Status != 200 || events[1].Status != 404 || events[1].ResponseBytes != 7 || events[3].Status != 500 {
+		t.Errorf("Unexpected request events %+v", events)
+	}
+}
+
+func TestRouteMetricsClientAborts(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	server.HTTP.GET("/export", func(w http.ResponseWriter, r web.Request) {
+		if r.HTTP.URL.Query().Get("abort") != "" {
+			// The client goes away while the response is being prepared
+			cancel()
+			<-r.HTTP.Context().Done()
+			w.WriteHeader(500)
+			return
+		}
+		w.Write([]byte("export"))
+	}, web.HandleOptions{})
+
+	var events []web.RequestEvent
+	server.Options.OnRequest = func(event web.RequestEvent) {
+		events = append(events, event)
+	}
+	serverErrors := 0
+	server.Options.OnServerError = func(event web.ServerErrorEvent) {
+		serverErrors++
+	}
+
+	server.Request("GET", "/export", nil)
+	server.Do(httptest.NewRequest("GET", "/export?abort=1", nil).WithContext(ctx))
+
+	metrics := server.RouteMetrics()
+	if len(metrics) != 1 || metrics[0].Requests != 2 || metrics[0].ClientAborts != 1 {
+		t.Errorf("Unexpected route metrics %+v", metrics)
+	}
+	if serverErrors != 0 {
+		t.Errorf("Client abort was reported as a server error")
+	}
+	if len(events) != 2 || events[0].ClientAborted || !events[1].ClientAborted {
+		t.Errorf("Unexpected request events %+v", events)
+	}
+}
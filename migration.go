@@ -0,0 +1,91 @@
+package web
+
+import (
+	"bytes"
+)
+
+const defaultBodyVersionHeader = "API-Version"
+
+// BodyMigration describes a hook that transforms a JSON request body from one version of an API into the shape of the
+// next version, so that a single handle can accept requests from clients of older versions.
+//
+// For example, where version 2 renamed the "name" property to "display_name":
+//
+//	web.BodyMigration{
+//	    From: "1",
+//	    Migrate: func(body map[string]interface{}) error {
+//	        body["display_name"] = body["name"]
+//	        delete(body, "name")
+//	        return nil
+//	    },
+//	}
+type BodyMigration struct {
+	// The version of request bodies that this migration applies to
+	From string
+	// Migrate transforms the body in place into the shape of the next version. Numbers in the body are json.Number
+	// values when using the default JSONDecoder of the server. Return an error if the body can not be migrated, which is
+	// sent to the client as a validation error.
+	Migrate func(body map[string]interface{}) error
+}
+
+// bodyMigrations returns the migrations to apply to the body of the request, starting from the migration for the
+// version of the request
+func (r Request) bodyMigrations() []BodyMigration {
+	if len(r.options.BodyMigrations) == 0 || r.HTTP == nil {
+		return nil
+	}
+
+	header := r.options.BodyVersionHeader
+	if header == "" {
+		header = defaultBodyVersionHeader
+	}
+	version := r.HTTP.Header.Get(header)
+	if version == "" {
+		return nil
+	}
+	for i, migration := range r.options.BodyMigrations {
+		if migration.From == version {
+			return r.options.BodyMigrations[i:]
+		}
+	}
+	return nil
+}
+
+// decodeMigratedJSON applies the migrations to the JSON object body of the request before decoding it into v. The body
+// is decoded and encoded again using the JSONDecoder and JSONEncoder of the server.
+func (r Request) decodeMigratedJSON(v any, decoder JSONDecoder, migrations []BodyMigration) *Error {
+	body := map[string]interface{}{}
+	objectDecoder := decoder.NewDecoder(r.HTTP.Body)
+	// Keep numbers as they were sent, where the decoder supports it, so that large integers are not rounded
+	if numberDecoder, ok := objectDecoder.(interface{ UseNumber() }); ok {
+		numberDecoder.UseNumber()
+	}
+	if err := r.Decode(&body, objectDecoder); err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		if err := migration.Migrate(body); err != nil {
+			log.PWarn("Error migrating request body", map[string]interface{}{
+				"url":     r.HTTP.URL,
+				"version": migration.From,
+				"error":   err.Error(),
+			})
+			return ValidationError("%s", err.Error())
+		}
+	}
+
+	var encoder JSONEncoder = StandardJSON
+	if r.server != nil {
+		encoder = r.server.jsonEncoder()
+	}
+	data := &bytes.Buffer{}
+	if err := encoder.NewEncoder(data).Encode(body); err != nil {
+		log.PError("Error encoding migrated request body", map[string]interface{}{
+			"url":   r.HTTP.URL,
+			"error": err.Error(),
+		})
+		return CommonErrors.ServerError
+	}
+	return r.Decode(v, decoder.NewDecoder(data))
+}
@@ -0,0 +1,122 @@
+package web_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestBodyMigrations(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	type user struct {
+		DisplayName string `json:"display_name"`
+		Age         int64  `json:"age"`
+	}
+	server.API.POST("/users", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		params := user{}
+		if err := request.DecodeJSON(&params); err != nil {
+			return nil, nil, err
+		}
+		return fmt.Sprintf("%s %d", params.DisplayName, params.Age), nil, nil
+	}, web.HandleOptions{
+		BodyMigrations: []web.BodyMigration{
+			{
+				// Version 2 renamed name to display_name
+				From: "1",
+				Migrate: func(body map[string]interface{}) error {
+					body["display_name"] = body["name"]
+					delete(body, "name")
+					return nil
+				},
+			},
+			{
+				// Version 3 replaced birth_year with age
+				From: "2",
+				Migrate: func(body map[string]interface{}) error {
+					year, err := json.Number(fmt.Sprintf("%v", body["birth_year"])).Int64()
+					if err != nil {
+						return fmt.Errorf("invalid birth_year")
+					}
+					body["age"] = 2000 - year
+					delete(body, "birth_year")
+					return nil
+				},
+			},
+		},
+	})
+
+	check := func(version, body string, expectedStatus int, expectedData string) {
+		req := httptest.NewRequest("POST", "/users", bytes.NewReader([]byte(body)))
+		if version != "" {
+			req.Header.Set("API-Version", version)
+		}
+		response := server.Do(req)
+		if response.Status != expectedStatus {
+			t.Errorf("Unexpected status code for version '%s'. Expected %d got %d", version, expectedStatus, response.Status)
+			return
+		}
+		if expectedStatus != 200 {
+			return
+		}
+		result, err := response.JSON()
+		if err != nil {
+			t.Fatalf("Error decoding response: %s", err.Error())
+		}
+		if result.Data != expectedData {
+			t.Errorf("Unexpected data for version '%s'. Expected '%s' got '%v'", version, expectedData, result.Data)
+		}
+	}
+
+	check("1", `{"name":"alice","birth_year":1970}`, 200, "alice 30")
+	check("2", `{"display_name":"bob","birth_year":1980}`, 200, "bob 20")
+	check("3", `{"display_name":"carol","age":40}`, 200, "carol 40")
+	check("", `{"display_name":"dave","age":50}`, 200, "dave 50")
+	check("2", `{"display_name":"erin","birth_year":"old"}`, 400, "")
+	check("1", `["not an object"]`, 400, "")
+}
+
+func TestBodyMigrationsCustomJSONDecoder(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+	codec := indentJSON{decoded: new(int32)}
+	server.Options.JSONDecoder = codec
+
+	server.API.POST("/users", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		params := struct {
+			DisplayName string `json:"display_name"`
+		}{}
+		if err := request.DecodeJSON(&params); err != nil {
+			return nil, nil, err
+		}
+		return params.DisplayName, nil, nil
+	}, web.HandleOptions{
+		BodyMigrations: []web.BodyMigration{
+			{
+				From: "1",
+				Migrate: func(body map[string]interface{}) error {
+					body["display_name"] = body["name"]
+					delete(body, "name")
+					return nil
+				},
+			},
+		},
+	})
+
+	req := httptest.NewRequest("POST", "/users", bytes.NewReader([]byte(`{"name":"alice"}`)))
+	req.Header.Set("API-Version", "1")
+	response := server.Do(req)
+	if response.Status != 200 {
+		t.Fatalf("Unexpected status code. Expected %d got %d", 200, response.Status)
+	}
+	// Once for the body being migrated, then again for the migrated body
+	if decoded := atomic.LoadInt32(codec.decoded); decoded != 2 {
+		t.Errorf("Unexpected number of decoders. Expected %d got %d", 2, decoded)
+	}
+}
@@ -5,6 +5,10 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/ecnepsnai/web/router"
 )
 
 // Parameters for creating a mock request for uses in tests
@@ -19,6 +23,10 @@ type MockRequestParameters struct {
 	Body io.ReadCloser
 	// Optional HTTP request to pass to the handler.
 	Request *http.Request
+	// Optional encoder used to encode the JSONBody. Defaults to [web.StandardJSON].
+	JSONEncoder JSONEncoder
+	// Optional decoder used by the request to decode JSON bodies. Defaults to [web.StandardJSON].
+	JSONDecoder JSONDecoder
 }
 
 // MockRequest will generate a mock request for testing your handlers. Will panic for invalid parameters.
@@ -37,9 +45,18 @@ func MockRequest(parameters MockRequestParameters) Request {
 		panic("cannot provide both JSON and data body")
 	}
 
+	encoder := parameters.JSONEncoder
+	if encoder == nil {
+		encoder = StandardJSON
+	}
+	decoder := parameters.JSONDecoder
+	if decoder == nil {
+		decoder = StandardJSON
+	}
+
 	if parameters.JSONBody != nil {
 		b := &bytes.Buffer{}
-		if err := json.NewEncoder(b).Encode(parameters.JSONBody); err != nil {
+		if err := encoder.NewEncoder(b).Encode(parameters.JSONBody); err != nil {
 			panic(err)
 		}
 		httpRequest.Body = io.NopCloser(b)
@@ -53,5 +70,172 @@ func MockRequest(parameters MockRequestParameters) Request {
 		HTTP:       httpRequest,
 		Parameters: parameters.Parameters,
 		UserData:   parameters.UserData,
+		decoder:    decoder,
+	}
+}
+
+// Parameters for creating a mock request with [web.MockRequestFull]
+type MockRequestOptions struct {
+	MockRequestParameters
+	// The HTTP method of the request. Defaults to GET.
+	Method string
+	// The path of the request, which may include a query, such as "/users/1?expand=true". Defaults to "/".
+	Path string
+	// Query parameters to add to the query of the path. May be nil.
+	Query url.Values
+	// Headers to set on the request. May be nil.
+	Headers map[string]string
+	// Cookies to add to the request. May be nil.
+	Cookies []*http.Cookie
+	// The remote address of the request, including the port. Defaults to "[::1]:65535".
+	RemoteAddr string
+}
+
+// MockRequestFull will generate a mock request for testing your handlers, like [web.MockRequest], with the given
+// method, path, query, headers, cookies, and remote address. If the Request parameter is set then these options are
+// applied to that request. A Content-Type header of "application/json" is added if there is a JSONBody and the header
+// isn't set. Will panic for invalid parameters.
+func MockRequestFull(options MockRequestOptions) Request {
+	parameters := options.MockRequestParameters
+	if parameters.Request == nil {
+		method := options.Method
+		if method == "" {
+			method = "GET"
+		}
+		path := options.Path
+		if path == "" {
+			path = "/"
+		}
+		parameters.Request = httptest.NewRequest(method, path, nil)
+	} else if options.Method != "" {
+		parameters.Request.Method = options.Method
+	}
+	httpRequest := parameters.Request
+	if httpRequest.Header == nil {
+		httpRequest.Header = http.Header{}
 	}
+
+	if len(options.Query) > 0 {
+		if httpRequest.URL == nil {
+			httpRequest.URL = &url.URL{Path: "/"}
+		}
+		query := httpRequest.URL.Query()
+		for key, values := range options.Query {
+			for _, value := range values {
+				query.Add(key, value)
+			}
+		}
+		httpRequest.URL.RawQuery = query.Encode()
+	}
+	for key, value := range options.Headers {
+		httpRequest.Header.Set(key, value)
+	}
+	for _, cookie := range options.Cookies {
+		httpRequest.AddCookie(cookie)
+	}
+	if parameters.JSONBody != nil && httpRequest.Header.Get("Content-Type") == "" {
+		httpRequest.Header.Set("Content-Type", "application/json")
+	}
+
+	request := MockRequest(parameters)
+	if options.RemoteAddr != "" {
+		request.HTTP.RemoteAddr = options.RemoteAddr
+	}
+	return request
+}
+
+// MockAPIHandle will execute the API handle with a mock request created from the options, and return the response
+// exactly as it would be written to the client, including the status, headers, and cookies. Use
+// [web.MockResponse.JSON] to decode the [web.JSONResponse] from the body. The UserData and Parameters of the options
+// are passed to the handle, but no other handle options, such as authentication, are applied. To test those, register
+// the handle on a [web.MockServer] instead. Will panic for invalid parameters.
+func MockAPIHandle(handle APIHandle, options MockRequestOptions) MockResponse {
+	request := MockRequestFull(options)
+	server := newServer("", nil)
+	server.Options.JSONEncoder = options.JSONEncoder
+	server.Options.JSONDecoder = options.JSONDecoder
+
+	w := httptest.NewRecorder()
+	server.API.apiPostHandle(handle, request.UserData, HandleOptions{DontLogRequests: true})(w, router.Request{
+		HTTP:       request.HTTP,
+		Parameters: request.Parameters,
+	})
+	return MockResponse{
+		Status: w.Code,
+		Header: w.Header(),
+		Body:   w.Body.Bytes(),
+	}
+}
+
+// MockServer is a server for testing handles end-to-end without listening on a network port. Handles are registered
+// the same as with a real [web.Server], and requests are executed in-process, including authentication, rate limiting,
+// and all other handle and server options.
+type MockServer struct {
+	*Server
+}
+
+// MockResponse describes the response to a request made to a [web.MockServer]
+type MockResponse struct {
+	// The status code of the response
+	Status int
+	// The headers of the response
+	Header http.Header
+	// The body of the response
+	Body []byte
+}
+
+// NewMockServer will return a new mock server with no registered handles.
+func NewMockServer() *MockServer {
+	return &MockServer{newServer("", nil)}
+}
+
+// Do will execute the request against the handles of the server and return the response. The remote address of the
+// request is "[::1]:65535" unless it has already been set.
+func (m *MockServer) Do(r *http.Request) MockResponse {
+	if r.RemoteAddr == "" {
+		r.RemoteAddr = "[::1]:65535"
+	}
+	m.router.SetTrailingSlashPolicy(m.Options.TrailingSlashPolicy.routerPolicy())
+	m.router.SetDefaultHeaders(m.Options.DefaultHeaders)
+	w := httptest.NewRecorder()
+	m.router.ServeHTTP(w, r)
+	return MockResponse{
+		Status: w.Code,
+		Header: w.Header(),
+		Body:   w.Body.Bytes(),
+	}
+}
+
+// Request will execute a request with the given method and path, such as "/users/1?expand=true", against the handles
+// of the server and return the response. If body is not nil then it is encoded as JSON using the JSONEncoder of the
+// server.
+func (m *MockServer) Request(method, path string, body interface{}) MockResponse {
+	var reader io.Reader
+	if body != nil {
+		b := &bytes.Buffer{}
+		if err := m.jsonEncoder().NewEncoder(b).Encode(body); err != nil {
+			panic(err)
+		}
+		reader = b
+	}
+	r := httptest.NewRequest(method, path, reader)
+	r.RemoteAddr = ""
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	return m.Do(r)
+}
+
+// JSON will decode the body of the response as a [web.JSONResponse], as returned by API handles.
+func (r MockResponse) JSON() (*JSONResponse, error) {
+	response := &JSONResponse{}
+	if err := r.DecodeJSON(response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// DecodeJSON will decode the body of the response into v.
+func (r MockResponse) DecodeJSON(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
 }
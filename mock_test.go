@@ -1,6 +1,10 @@
 package web_test
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/ecnepsnai/web"
@@ -41,3 +45,154 @@ func TestMock(t *testing.T) {
 	})
 	handle(request)
 }
+
+func TestMockServer(t *testing.T) {
+	t.Parallel()
+
+	server := web.NewMockServer()
+	server.Options.MaxRequestsPerSecond = 2
+
+	type userType struct {
+		Name string `json:"name"`
+	}
+
+	server.API.POST("/users/:id", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		user := userType{}
+		if err := request.DecodeJSON(&user); err != nil {
+			return nil, nil, err
+		}
+		return userType{Name: request.Parameters["id"] + ":" + user.Name}, nil, nil
+	}, web.HandleOptions{
+		AuthenticateMethod: func(request *http.Request) interface{} {
+			if request.Header.Get("X-User") == "" {
+				return nil
+			}
+			return 1
+		},
+	})
+
+	req := httptest.NewRequest("POST", "/users/1", strings.NewReader(`{"name":"alice"}`))
+	req.Header.Set("X-User", "1")
+	response := server.Do(req)
+	if response.Status != 200 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 200, response.Status)
+	}
+	result := struct {
+		Data userType `json:"data"`
+	}{}
+	if err := response.DecodeJSON(&result); err != nil {
+		t.Fatalf("Error decoding response: %s", err.Error())
+	}
+	if result.Data.Name != "1:alice" {
+		t.Errorf("Unexpected response. Expected '%s' got '%s'", "1:alice", result.Data.Name)
+	}
+
+	response = server.Request("POST", "/users/1", userType{Name: "bob"})
+	if response.Status != 401 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 401, response.Status)
+	}
+
+	// Rate limit of 2 requests per second has been reached
+	server.Request("POST", "/users/1", userType{Name: "bob"})
+	response = server.Request("POST", "/users/1", userType{Name: "bob"})
+	if response.Status != 429 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 429, response.Status)
+	}
+
+	response = server.Request("GET", "/missing", nil)
+	if response.Status != 404 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 404, response.Status)
+	}
+
+	server.API.GET("/error", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return nil, nil, web.ValidationError("bad")
+	}, web.HandleOptions{DisableRateLimit: true})
+	jsonResponse, err := server.Request("GET", "/error", nil).JSON()
+	if err != nil {
+		t.Fatalf("Error decoding response: %s", err.Error())
+	}
+	if jsonResponse.Error == nil || jsonResponse.Error.Message != "bad" {
+		t.Errorf("Unexpected error in response: %+v", jsonResponse.Error)
+	}
+}
+
+func TestMockRequestFull(t *testing.T) {
+	request := web.MockRequestFull(web.MockRequestOptions{
+		MockRequestParameters: web.MockRequestParameters{
+			Parameters: map[string]string{"id": "1"},
+			JSONBody:   map[string]string{"name": "alice"},
+		},
+		Method:     "PUT",
+		Path:       "/users/1?expand=true",
+		Query:      url.Values{"fields": []string{"name", "email"}},
+		Headers:    map[string]string{"X-Request-ID": "abc"},
+		Cookies:    []*http.Cookie{{Name: "session", Value: "123"}},
+		RemoteAddr: "192.0.2.1:1234",
+	})
+
+	if request.HTTP.Method != "PUT" || request.HTTP.URL.Path != "/users/1" {
+		t.Errorf("Unexpected request %s %s", request.HTTP.Method, request.HTTP.URL.Path)
+	}
+	if query := request.HTTP.URL.Query(); query.Get("expand") != "true" || len(query["fields"]) != 2 {
+		t.Errorf("Unexpected query '%s'", request.HTTP.URL.RawQuery)
+	}
+	if request.HTTP.Header.Get("X-Request-ID") != "abc" || request.HTTP.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected headers %v", request.HTTP.Header)
+	}
+	if cookie, err := request.HTTP.Cookie("session"); err != nil || cookie.Value != "123" {
+		t.Errorf("Missing cookie from request")
+	}
+	if request.HTTP.RemoteAddr != "192.0.2.1:1234" {
+		t.Errorf("Unexpected remote address. Expected '%s' got '%s'", "192.0.2.1:1234", request.HTTP.RemoteAddr)
+	}
+	body := map[string]string{}
+	if err := request.DecodeJSON(&body); err != nil || body["name"] != "alice" {
+		t.Errorf("Unexpected body %v", body)
+	}
+}
+
+func TestMockAPIHandle(t *testing.T) {
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		if request.UserData.(int) != 1 {
+			return nil, nil, web.CommonErrors.Unauthorized
+		}
+		if request.Parameters["id"] == "" {
+			return nil, nil, web.ValidationError("missing id")
+		}
+		return request.Parameters["id"], &web.APIResponse{
+			Status:  201,
+			Headers: map[string]string{"X-Created": "true"},
+		}, nil
+	}
+
+	response := web.MockAPIHandle(handle, web.MockRequestOptions{
+		MockRequestParameters: web.MockRequestParameters{
+			UserData:   1,
+			Parameters: map[string]string{"id": "7"},
+		},
+		Method: "POST",
+	})
+	if response.Status != 201 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 201, response.Status)
+	}
+	if response.Header.Get("X-Created") != "true" || response.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected headers %v", response.Header)
+	}
+	jsonResponse, err := response.JSON()
+	if err != nil {
+		t.Fatalf("Error decoding response: %s", err.Error())
+	}
+	if jsonResponse.Data != "7" {
+		t.Errorf("Unexpected data. Expected '%s' got '%v'", "7", jsonResponse.Data)
+	}
+
+	response = web.MockAPIHandle(handle, web.MockRequestOptions{
+		MockRequestParameters: web.MockRequestParameters{UserData: 1},
+	})
+	if response.Status != 400 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 400, response.Status)
+	}
+	if jsonResponse, _ := response.JSON(); jsonResponse == nil || jsonResponse.Error == nil || jsonResponse.Error.Message != "missing id" {
+		t.Errorf("Unexpected error in response: %s", response.Body)
+	}
+}
@@ -0,0 +1,145 @@
+package web
+
+import (
+	"strconv"
+)
+
+const (
+	defaultPageSize    = 25
+	defaultMaxPageSize = 100
+)
+
+// PageParams describes the pagination parameters of a request, from the 'limit', 'offset', and 'cursor' URL query
+// parameters
+type PageParams struct {
+	// The number of items to return
+	Limit int
+	// The number of items to skip, for offset pagination
+	Offset int
+	// The opaque position to continue from, for cursor pagination. Empty for the first page.
+	Cursor string
+}
+
+// Page describes a consistent envelope for a page of items returned by list handles. Use [web.NewPage] or
+// [web.NewCursorPage] to create a page from the parameters of the request.
+type Page[T any] struct {
+	// The items on this page
+	Items []T `json:"items"`
+	// The total number of items across all pages. Only included for offset pagination.
+	Total *int `json:"total,omitempty"`
+	// The maximum number of items on each page
+	Limit int `json:"limit"`
+	// The number of items skipped before this page. Only included for offset pagination.
+	Offset *int `json:"offset,omitempty"`
+	// The cursor of the next page, for cursor pagination. Empty if this is the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+	// The URL of the next page, relative to the host. Empty if this is the last page.
+	Next string `json:"next,omitempty"`
+}
+
+// PageParams returns the pagination parameters of the request from the 'limit', 'offset', and 'cursor' URL query
+// parameters. The limit defaults to the PageSize of the handle, or 25, and is capped to the MaxPageSize of the handle,
+// or 100. The offset defaults to 0.
+//
+// Returns a 400 error if the limit or offset are not positive integers.
+func (r Request) PageParams() (PageParams, *Error) {
+	params := PageParams{
+		Limit: r.options.PageSize,
+	}
+	if params.Limit <= 0 {
+		params.Limit = defaultPageSize
+	}
+	maxLimit := r.options.MaxPageSize
+	if maxLimit <= 0 {
+		maxLimit = defaultMaxPageSize
+	}
+
+	query := r.HTTP.URL.Query()
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return PageParams{}, ValidationError("invalid limit %s", value)
+		}
+		params.Limit = limit
+	}
+	if params.Limit > maxLimit {
+		params.Limit = maxLimit
+	}
+	if value := query.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return PageParams{}, ValidationError("invalid offset %s", value)
+		}
+		params.Offset = offset
+	}
+	params.Cursor = query.Get("cursor")
+	return params, nil
+}
+
+// NewPage returns a page of items for offset pagination, where total is the number of items across all pages. A link to
+// the next page is included if there are more items after this page.
+//
+// For example:
+//
+//	server.API.GET("/users", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+//	    params, err := request.PageParams()
+//	    if err != nil {
+//	        return nil, nil, err
+//	    }
+//	    users, total := listUsers(params.Offset, params.Limit)
+//	    return web.NewPage(request, params, users, total), nil, nil
+//	}, web.HandleOptions{})
+func NewPage[T any](request Request, params PageParams, items []T, total int) Page[T] {
+	page := Page[T]{
+		Items:  pageItems(items),
+		Total:  &total,
+		Limit:  params.Limit,
+		Offset: &params.Offset,
+	}
+	if next := params.Offset + len(items); len(items) > 0 && next < total {
+		page.Next = pageLink(request, map[string]string{
+			"limit":  strconv.Itoa(params.Limit),
+			"offset": strconv.Itoa(next),
+		})
+	}
+	return page
+}
+
+// NewCursorPage returns a page of items for cursor pagination, where nextCursor is the opaque position of the next page,
+// or empty if this is the last page. A link to the next page is included if there is a next cursor.
+func NewCursorPage[T any](request Request, params PageParams, items []T, nextCursor string) Page[T] {
+	page := Page[T]{
+		Items:      pageItems(items),
+		Limit:      params.Limit,
+		NextCursor: nextCursor,
+	}
+	if nextCursor != "" {
+		page.Next = pageLink(request, map[string]string{
+			"limit":  strconv.Itoa(params.Limit),
+			"cursor": nextCursor,
+		})
+	}
+	return page
+}
+
+// pageItems returns items, or an empty slice if items is nil, so that the items are always encoded as an array
+func pageItems[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
+
+// pageLink returns the path and query of the request with the given query parameters replaced
+func pageLink(request Request, set map[string]string) string {
+	if request.HTTP == nil || request.HTTP.URL == nil {
+		return ""
+	}
+	link := *request.HTTP.URL
+	query := link.Query()
+	for key, value := range set {
+		query.Set(key, value)
+	}
+	link.RawQuery = query.Encode()
+	return link.RequestURI()
+}
@@ -0,0 +1,80 @@
+package web_test
+
+import (
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestPagination(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	items := make([]int, 30)
+	for i := range items {
+		items[i] = i
+	}
+
+	server.API.GET("/items", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		params, err := request.PageParams()
+		if err != nil {
+			return nil, nil, err
+		}
+		end := min(params.Offset+params.Limit, len(items))
+		start := min(params.Offset, end)
+		return web.NewPage(request, params, items[start:end], len(items)), nil, nil
+	}, web.HandleOptions{PageSize: 10, MaxPageSize: 20})
+
+	server.API.GET("/cursor", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		params, err := request.PageParams()
+		if err != nil {
+			return nil, nil, err
+		}
+		if params.Cursor == "" {
+			return web.NewCursorPage(request, params, []string{"a"}, "b"), nil, nil
+		}
+		return web.NewCursorPage[string](request, params, nil, ""), nil, nil
+	}, web.HandleOptions{})
+
+	get := func(path string) web.Page[int] {
+		page := web.Page[int]{}
+		response := server.Request("GET", path, nil)
+		if response.Status != 200 {
+			t.Fatalf("Unexpected status code for '%s'. Expected %d got %d", path, 200, response.Status)
+		}
+		if err := response.DecodeJSON(&web.JSONResponse{Data: &page}); err != nil {
+			t.Fatalf("Error decoding response: %s", err.Error())
+		}
+		return page
+	}
+
+	page := get("/items?filter=x")
+	if len(page.Items) != 10 || *page.Total != 30 || page.Limit != 10 || *page.Offset != 0 {
+		t.Errorf("Unexpected first page %+v", page)
+	}
+	if page.Next != "/items?filter=x&limit=10&offset=10" {
+		t.Errorf("Unexpected next link '%s'", page.Next)
+	}
+
+	page = get("/items?limit=500&offset=20")
+	if len(page.Items) != 10 || page.Limit != 20 || page.Items[0] != 20 || page.Next != "" {
+		t.Errorf("Unexpected last page %+v", page)
+	}
+
+	for _, path := range []string{"/items?limit=0", "/items?limit=x", "/items?offset=-1"} {
+		if response := server.Request("GET", path, nil); response.Status != 400 {
+			t.Errorf("Unexpected status code for '%s'. Expected %d got %d", path, 400, response.Status)
+		}
+	}
+
+	cursor := web.Page[string]{}
+	server.Request("GET", "/cursor", nil).DecodeJSON(&web.JSONResponse{Data: &cursor})
+	if cursor.NextCursor != "b" || cursor.Next != "/cursor?cursor=b&limit=25" || cursor.Total != nil || cursor.Limit != 25 {
+		t.Errorf("Unexpected cursor page %+v", cursor)
+	}
+	cursor = web.Page[string]{}
+	server.Request("GET", "/cursor?cursor=b", nil).DecodeJSON(&web.JSONResponse{Data: &cursor})
+	if cursor.Items == nil || len(cursor.Items) != 0 || cursor.Next != "" {
+		t.Errorf("Unexpected last cursor page %+v", cursor)
+	}
+}
@@ -0,0 +1,29 @@
+package web
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// reportPanic calls the PanicHandler of the handle, or of the server if the handle has none, with a panic recovered
+// from the handle of the request
+func (s *Server) reportPanic(recovered interface{}, stack []byte, request Request, options HandleOptions) {
+	handler := options.PanicHandler
+	if handler == nil {
+		handler = s.Options.PanicHandler
+	}
+	if handler == nil {
+		return
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			log.PError("Recovered from panic during panic handler", map[string]interface{}{
+				"error": fmt.Sprintf("%v", p),
+				"route": request.HTTP.URL.Path,
+				"stack": string(debug.Stack()),
+			})
+		}
+	}()
+	handler(recovered, stack, request)
+}
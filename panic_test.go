@@ -0,0 +1,65 @@
+package web_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestPanicHandler(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	type panicReport struct {
+		Handler  string
+		Value    interface{}
+		Stack    string
+		Path     string
+		UserData interface{}
+	}
+	reports := []panicReport{}
+	report := func(name string) func(recovered interface{}, stack []byte, request web.Request) {
+		return func(recovered interface{}, stack []byte, request web.Request) {
+			reports = append(reports, panicReport{name, recovered, string(stack), request.HTTP.URL.Path, request.UserData})
+		}
+	}
+	server.Options.PanicHandler = report("server")
+
+	authenticate := func(request *http.Request) interface{} {
+		return "user"
+	}
+	server.API.GET("/api", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		panic("api")
+	}, web.HandleOptions{AuthenticateMethod: authenticate})
+	server.HTTP.GET("/http", func(w http.ResponseWriter, r web.Request) {
+		panic("http")
+	}, web.HandleOptions{PanicHandler: report("handle")})
+	server.HTTPEasy.GET("/easy", func(request web.Request) web.HTTPResponse {
+		panic("easy")
+	}, web.HandleOptions{})
+
+	expected := []panicReport{
+		{Handler: "server", Value: "api", Path: "/api", UserData: "user"},
+		{Handler: "handle", Value: "http", Path: "/http"},
+		{Handler: "server", Value: "easy", Path: "/easy"},
+	}
+	for _, path := range []string{"/api", "/http", "/easy"} {
+		if response := server.Request("GET", path, nil); response.Status != 500 {
+			t.Errorf("Unexpected status code. Expected %d got %d", 500, response.Status)
+		}
+	}
+
+	if len(reports) != len(expected) {
+		t.Fatalf("Unexpected number of panic reports. Expected %d got %d", len(expected), len(reports))
+	}
+	for i, report := range reports {
+		if report.Handler != expected[i].Handler || report.Value != expected[i].Value || report.Path != expected[i].Path || report.UserData != expected[i].UserData {
+			t.Errorf("Unexpected panic report. Expected %s %v %s got %s %v %s", expected[i].Handler, expected[i].Value, expected[i].Path, report.Handler, report.Value, report.Path)
+		}
+		if !strings.Contains(report.Stack, "panic_test.go") {
+			t.Errorf("Stack trace does not include the panicking handle")
+		}
+	}
+}
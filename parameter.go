@@ -0,0 +1,53 @@
+package web
+
+import (
+	"strconv"
+)
+
+// ParameterInt returns the value of the URL path parameter with the given name as a signed integer. Returns a
+// validation error if the parameter is missing or not a base 10 integer, which can't happen for parameters with the
+// int type, such as {id:int}.
+func (r Request) ParameterInt(name string) (int64, *Error) {
+	value, err := strconv.ParseInt(r.Parameters[name], 10, 64)
+	if err != nil {
+		return 0, invalidParameter(name)
+	}
+	return value, nil
+}
+
+// ParameterUint returns the value of the URL path parameter with the given name as an unsigned integer. Returns a
+// validation error if the parameter is missing or not a base 10 unsigned integer, which can't happen for parameters
+// with the uint type, such as {id:uint}.
+func (r Request) ParameterUint(name string) (uint64, *Error) {
+	value, err := strconv.ParseUint(r.Parameters[name], 10, 64)
+	if err != nil {
+		return 0, invalidParameter(name)
+	}
+	return value, nil
+}
+
+// ParameterFloat returns the value of the URL path parameter with the given name as a floating point number. Returns a
+// validation error if the parameter is missing or not a number, which can't happen for parameters with the float type,
+// such as {amount:float}.
+func (r Request) ParameterFloat(name string) (float64, *Error) {
+	value, err := strconv.ParseFloat(r.Parameters[name], 64)
+	if err != nil {
+		return 0, invalidParameter(name)
+	}
+	return value, nil
+}
+
+// ParameterBool returns the value of the URL path parameter with the given name as a boolean, as accepted by
+// [strconv.ParseBool]. Returns a validation error if the parameter is missing or not a boolean, which can't happen for
+// parameters with the bool type, such as {enabled:bool}.
+func (r Request) ParameterBool(name string) (bool, *Error) {
+	value, err := strconv.ParseBool(r.Parameters[name])
+	if err != nil {
+		return false, invalidParameter(name)
+	}
+	return value, nil
+}
+
+func invalidParameter(name string) *Error {
+	return ValidationError("Invalid value for parameter %s", name)
+}
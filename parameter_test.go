@@ -0,0 +1,77 @@
+package web_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestTypedParameters(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	path := randomString(5)
+	server.API.GET("/"+path+"/{id:int}/{enabled:bool}", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		id, err := request.ParameterInt("id")
+		if err != nil {
+			return nil, nil, err
+		}
+		enabled, err := request.ParameterBool("enabled")
+		if err != nil {
+			return nil, nil, err
+		}
+		return fmt.Sprintf("%d %v", id, enabled), nil, nil
+	}, web.HandleOptions{})
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/%s/42/true", server.ListenPort, path))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("Unexpected HTTP status code. Expected %d got %d", 200, resp.StatusCode)
+	}
+
+	resp, err = http.Get(fmt.Sprintf("http://localhost:%d/%s/forty-two/true", server.ListenPort, path))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 404 {
+		t.Errorf("Unexpected HTTP status code. Expected %d got %d", 404, resp.StatusCode)
+	}
+
+	if err := server.SelfTest(); err != nil {
+		t.Errorf("Unexpected self test error: %s", err.Error())
+	}
+}
+
+func TestTypedParameterGetters(t *testing.T) {
+	request := web.MockRequest(web.MockRequestParameters{
+		Parameters: map[string]string{
+			"int":   "-12",
+			"uint":  "12",
+			"float": "1.5",
+			"bool":  "false",
+		},
+	})
+
+	if value, err := request.ParameterInt("int"); err != nil || value != -12 {
+		t.Errorf("Unexpected int parameter. Expected %d got %d", -12, value)
+	}
+	if value, err := request.ParameterUint("uint"); err != nil || value != 12 {
+		t.Errorf("Unexpected uint parameter. Expected %d got %d", 12, value)
+	}
+	if value, err := request.ParameterFloat("float"); err != nil || value != 1.5 {
+		t.Errorf("Unexpected float parameter. Expected %f got %f", 1.5, value)
+	}
+	if value, err := request.ParameterBool("bool"); err != nil || value {
+		t.Errorf("Unexpected bool parameter. Expected %v got %v", false, value)
+	}
+	if _, err := request.ParameterUint("int"); err == nil || err.Code != 400 {
+		t.Errorf("No error seen for invalid parameter")
+	}
+	if _, err := request.ParameterInt("missing"); err == nil {
+		t.Errorf("No error seen for missing parameter")
+	}
+}
@@ -0,0 +1,323 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	pathpkg "path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ecnepsnai/web/router"
+)
+
+// ProxyCachePolicy describes how the 'Cache-Control' and 'Expires' headers of responses from the upstream of a proxy
+// interact with the local response cache, enabled with the Cache handle option.
+type ProxyCachePolicy int
+
+const (
+	// ProxyCacheRespect caches responses for as long as the 'Cache-Control' or 'Expires' headers from the upstream
+	// allow, and never caches responses marked as no-store, no-cache, or private. The TTL of the Cache handle option is
+	// used if the upstream does not specify a lifetime. The headers are sent to the client unchanged.
+	ProxyCacheRespect ProxyCachePolicy = iota
+	// ProxyCacheOverride ignores the caching headers from the upstream and caches every successful response for the TTL
+	// of the Cache handle option. The headers are replaced with a 'Cache-Control' header for the same TTL.
+	ProxyCacheOverride
+	// ProxyCacheStrip ignores the caching headers from the upstream and caches every successful response for the TTL
+	// of the Cache handle option. The headers are removed and not replaced, so that only this server caches the
+	// response.
+	ProxyCacheStrip
+)
+
+// ProxyOptions describes options for a reverse proxy handle
+type ProxyOptions struct {
+	// Options for the handle, such as authentication and rate limiting, which apply to all proxied requests.
+	HandleOptions
+	// If true then the Host header from the incoming request is sent to the upstream, otherwise the host of the target
+	// is used.
+	PreserveHost bool
+	// Headers to set on requests sent to the upstream, replacing any existing value.
+	RequestHeaders map[string]string
+	// Headers to remove from requests before they are sent to the upstream.
+	RemoveRequestHeaders []string
+	// Headers to set on responses sent to the client, replacing any existing value from the upstream.
+	ResponseHeaders map[string]string
+	// Headers to remove from responses from the upstream before they are sent to the client.
+	RemoveResponseHeaders []string
+	// Optional transport used for requests to the upstream. Defaults to [http.DefaultTransport].
+	Transport http.RoundTripper
+	// How the caching headers from the upstream interact with the local response cache, when the Cache handle option
+	// is set. Defaults to [web.ProxyCacheRespect]. The caching headers are also removed with [web.ProxyCacheStrip] when
+	// the local cache is not enabled.
+	CachePolicy ProxyCachePolicy
+	// Optional hedging of GET and HEAD requests, where a second copy of a slow request is sent to another upstream and
+	// the first response is used. Defaults to nil, which does not hedge requests.
+	Hedge *ProxyHedgeOptions
+}
+
+type proxyCaptureKey struct{}
+
+// Proxy registers a reverse proxy handle for all requests under path, forwarding them to the target upstream. The
+// remainder of the request path after path is appended to the path of the target, and any query is preserved.
+// WebSocket upgrade requests are also forwarded.
+//
+// For example:
+//
+//	target = http://localhost:8080/api/
+//	path   = /internal/
+//
+//	Request for '/internal/users?id=1' would be forwarded to 'http://localhost:8080/api/users?id=1'
+//
+// Requests with a '..' segment in the remainder of the path are rejected with a 400 response, so that they can't reach
+// paths on the upstream outside of the target.
+//
+// Errors connecting to the upstream are answered with a JSON error response, the same as API handles: 503 if the
+// upstream could not be reached, 504 if the upstream did not respond in time, or 502 for any other error.
+//
+// Proxied requests go through the same authentication and rate limiting as other handles. The 'X-Forwarded-For',
+// 'X-Forwarded-Host', and 'X-Forwarded-Proto' headers are set on requests to the upstream. Responses are cached
+// locally if the Cache handle option is set, following the CachePolicy option. The connections and latency of each
+// upstream are available with [web.Server.ProxyMetrics].
+//
+// Will panic if any handle is registered under path. Attempting to register a new handle under path after calling
+// Proxy will panic.
+func (h HTTP) Proxy(path string, target url.URL, options ProxyOptions) {
+	log.PDebug("Proxying requests to upstream", map[string]interface{}{
+		"path":   path,
+		"target": target.String(),
+	})
+
+	transport := options.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	var roundTripper http.RoundTripper = &metricsTransport{transport: transport, store: h.server.proxies}
+	if options.Hedge != nil {
+		roundTripper = newHedgingTransport(roundTripper, target, *options.Hedge)
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Director: func(r *http.Request) {
+			r.Header.Set("X-Forwarded-Host", r.Host)
+			if r.TLS != nil {
+				r.Header.Set("X-Forwarded-Proto", "https")
+			} else {
+				r.Header.Set("X-Forwarded-Proto", "http")
+			}
+
+			r.URL.Scheme = target.Scheme
+			r.URL.Host = target.Host
+			if target.RawQuery != "" {
+				if r.URL.RawQuery == "" {
+					r.URL.RawQuery = target.RawQuery
+				} else {
+					r.URL.RawQuery = target.RawQuery + "&" + r.URL.RawQuery
+				}
+			}
+			if !options.PreserveHost {
+				r.Host = target.Host
+			}
+			for _, key := range options.RemoveRequestHeaders {
+				r.Header.Del(key)
+			}
+			for key, value := range options.RequestHeaders {
+				r.Header.Set(key, value)
+			}
+		},
+		ModifyResponse: func(r *http.Response) error {
+			capture, _ := r.Request.Context().Value(proxyCaptureKey{}).(*responseCapture)
+			applyProxyCachePolicy(options.CachePolicy, r.Header, capture)
+			for _, key := range options.RemoveResponseHeaders {
+				r.Header.Del(key)
+			}
+			for key, value := range options.ResponseHeaders {
+				r.Header.Set(key, value)
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if errors.Is(err, context.Canceled) {
+				// The client went away, there's nobody to respond to
+				log.PDebug("Proxied request cancelled by client", map[string]interface{}{
+					"url":      r.URL.String(),
+					"upstream": target.Host,
+				})
+				return
+			}
+
+			proxyErr := translateProxyError(err)
+			log.PError("Error proxying request to upstream", map[string]interface{}{
+				"url":      r.URL.String(),
+				"upstream": target.Host,
+				"error":    err.Error(),
+				"status":   proxyErr.Code,
+			})
+			setResponseError(w, err.Error())
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(proxyErr.Code)
+			h.server.jsonEncoder().NewEncoder(w).Encode(h.server.serializeError(proxyErr, JSONResponse{Error: proxyErr}))
+		},
+		Transport: roundTripper,
+	}
+
+	proxyHandle := func(w http.ResponseWriter, request router.Request) {
+		ctx := request.HTTP.Context()
+		if writer, ok := w.(*responseWriter); ok && writer.capture != nil {
+			ctx = context.WithValue(ctx, proxyCaptureKey{}, writer.capture)
+		}
+		upstreamPath, ok := proxyUpstreamPath(target, request.Parameters["proxy_path"])
+		if !ok {
+			log.PWarn("Rejected proxied request with path outside of target", map[string]interface{}{
+				"remote_addr": h.server.realRemoteAddr(request.HTTP),
+				"url":         request.HTTP.URL.String(),
+				"upstream":    target.Host,
+			})
+			setResponseError(w, "path outside of target")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(CommonErrors.BadRequest.Code)
+			h.server.jsonEncoder().NewEncoder(w).Encode(h.server.serializeError(CommonErrors.BadRequest, JSONResponse{Error: CommonErrors.BadRequest}))
+			return
+		}
+
+		upstreamRequest := request.HTTP.Clone(ctx)
+		upstreamRequest.URL.Path = upstreamPath
+		upstreamRequest.URL.RawPath = ""
+		proxy.ServeHTTP(w, upstreamRequest)
+	}
+	handle := func(w http.ResponseWriter, request Request) {
+		h.server.withCache(proxyHandle, request.UserData, options.HandleOptions)(w, router.Request{
+			HTTP:       request.HTTP,
+			Parameters: request.Parameters,
+		})
+	}
+
+	if path[len(path)-1] != '/' {
+		path += "/"
+	}
+	path += "*proxy_path"
+
+	for _, method := range []string{"GET", "HEAD", "OPTIONS", "POST", "PUT", "PATCH", "DELETE"} {
+		h.registerHTTPEndpoint(method, path, handle, options.HandleOptions)
+	}
+}
+
+// proxyUpstreamPath returns the path of the upstream request for the remainder of the request path after the path of
+// the proxy, or false if the remainder has a '..' segment, which could reach paths outside of the target on upstreams
+// that normalize paths.
+func proxyUpstreamPath(target url.URL, proxyPath string) (string, bool) {
+	for _, segment := range strings.Split(proxyPath, "/") {
+		if segment == ".." {
+			return "", false
+		}
+	}
+
+	prefix := strings.TrimSuffix(target.Path, "/")
+	cleaned := pathpkg.Clean("/" + proxyPath)
+	if cleaned != "/" && strings.HasSuffix(proxyPath, "/") {
+		cleaned += "/"
+	}
+	upstreamPath := prefix + cleaned
+	if !strings.HasPrefix(upstreamPath, prefix+"/") {
+		return "", false
+	}
+	return upstreamPath, true
+}
+
+// applyProxyCachePolicy updates the caching headers of a response from the upstream and the capture of the response
+// for the local cache, if it is being cached, according to the policy
+func applyProxyCachePolicy(policy ProxyCachePolicy, header http.Header, capture *responseCapture) {
+	switch policy {
+	case ProxyCacheRespect:
+		if capture == nil {
+			return
+		}
+		ttl, cacheable := upstreamCacheTTL(header, time.Now())
+		if !cacheable {
+			capture.skip = true
+		} else if ttl > 0 {
+			capture.maxAge = ttl
+		}
+	case ProxyCacheOverride:
+		if capture == nil {
+			return
+		}
+		header.Del("Cache-Control")
+		header.Del("Expires")
+	case ProxyCacheStrip:
+		header.Del("Cache-Control")
+		header.Del("Expires")
+		if capture != nil {
+			capture.hideMaxAge = true
+		}
+	}
+}
+
+// upstreamCacheTTL returns how long a response with the given headers may be cached for by a shared cache, and false if
+// it must not be cached. A TTL of 0 means the headers do not specify a lifetime.
+func upstreamCacheTTL(header http.Header, now time.Time) (time.Duration, bool) {
+	maxAge := -1
+	sharedMaxAge := -1
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store", "no-cache", "private":
+			return 0, false
+		case "max-age":
+			if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil {
+				maxAge = seconds
+			}
+		case "s-maxage":
+			if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil {
+				sharedMaxAge = seconds
+			}
+		}
+	}
+	if sharedMaxAge >= 0 {
+		maxAge = sharedMaxAge
+	}
+	if maxAge == 0 {
+		return 0, false
+	}
+	if maxAge > 0 {
+		return time.Duration(maxAge) * time.Second, true
+	}
+
+	if value := header.Get("Expires"); value != "" {
+		expires, err := http.ParseTime(value)
+		if err != nil || !expires.After(now) {
+			// Invalid dates, such as "0", mean the response has already expired
+			return 0, false
+		}
+		if date, err := http.ParseTime(header.Get("Date")); err == nil {
+			now = date
+		}
+		return expires.Sub(now), true
+	}
+	return 0, true
+}
+
+// translateProxyError returns the error to respond with for an error from the upstream of a proxy
+func translateProxyError(err error) *Error {
+	maxBytesError := &http.MaxBytesError{}
+	if errors.As(err, &maxBytesError) {
+		// The request body was larger than the MaxBodyLength option of the handle
+		return CommonErrors.PayloadTooLarge
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return CommonErrors.GatewayTimeout
+	}
+
+	dnsErr := &net.DNSError{}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH) || errors.As(err, &dnsErr) {
+		return CommonErrors.ServiceUnavailable
+	}
+
+	return CommonErrors.BadGateway
+}
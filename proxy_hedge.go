@@ -0,0 +1,143 @@
+package web
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ProxyHedgeOptions describes options for hedging requests from a reverse proxy handle. A hedged request is a second
+// copy of a request sent to another upstream when the first has not responded in time, where the client is sent
+// whichever response arrives first. This reduces tail latency at the cost of extra load on the upstreams.
+//
+// Only GET and HEAD requests without a body are hedged, as they are safe to send twice. WebSocket upgrade requests are
+// never hedged.
+type ProxyHedgeOptions struct {
+	// The amount of time to wait for the response headers from the target before sending a hedged request. Required.
+	Delay time.Duration
+	// Additional upstreams serving the same content as the target, which hedged requests are sent to in turn. The path
+	// of the request after the path of the target is appended to the path of the upstream. Defaults to nil, which sends
+	// hedged requests to the target.
+	Upstreams []url.URL
+}
+
+// hedgingTransport is a round tripper that hedges idempotent requests to the upstreams of a proxy
+type hedgingTransport struct {
+	transport http.RoundTripper
+	target    url.URL
+	options   ProxyHedgeOptions
+	next      *uint64
+}
+
+func newHedgingTransport(transport http.RoundTripper, target url.URL, options ProxyHedgeOptions) *hedgingTransport {
+	return &hedgingTransport{
+		transport: transport,
+		target:    target,
+		options:   options,
+		next:      new(uint64),
+	}
+}
+
+type hedgeAttempt struct {
+	response *http.Response
+	err      error
+	index    int
+}
+
+func (t *hedgingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if (r.Method != "GET" && r.Method != "HEAD") || r.ContentLength != 0 || r.Header.Get("Upgrade") != "" || t.options.Delay <= 0 {
+		return t.transport.RoundTrip(r)
+	}
+
+	attempts := make(chan hedgeAttempt, 2)
+	cancels := []context.CancelFunc{}
+	start := func(request *http.Request) {
+		ctx, cancel := context.WithCancel(request.Context())
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			response, err := t.transport.RoundTrip(request.WithContext(ctx))
+			attempts <- hedgeAttempt{response: response, err: err, index: index}
+		}()
+	}
+	start(r)
+
+	timer := time.NewTimer(t.options.Delay)
+	defer timer.Stop()
+	pending := 1
+	var lastErr error
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if len(cancels) > 1 {
+				continue
+			}
+			hedged := t.hedgeRequest(r)
+			log.PDebug("Sending hedged request to upstream", map[string]interface{}{
+				"url":      r.URL.String(),
+				"upstream": hedged.URL.Host,
+				"delay":    t.options.Delay.String(),
+			})
+			start(hedged)
+			pending++
+		case attempt := <-attempts:
+			pending--
+			if attempt.err != nil {
+				cancels[attempt.index]()
+				lastErr = attempt.err
+				continue
+			}
+
+			// Stop the other attempt, if any, and discard its response
+			for i, cancel := range cancels {
+				if i != attempt.index {
+					cancel()
+				}
+			}
+			if pending > 0 {
+				go func() {
+					if loser := <-attempts; loser.response != nil {
+						loser.response.Body.Close()
+					}
+				}()
+			}
+			attempt.response.Body = &cancelOnClose{ReadCloser: attempt.response.Body, cancel: cancels[attempt.index]}
+			return attempt.response, nil
+		}
+	}
+	return nil, lastErr
+}
+
+// hedgeRequest returns a copy of the request for the next upstream
+func (t *hedgingTransport) hedgeRequest(r *http.Request) *http.Request {
+	hedged := r.Clone(r.Context())
+	if len(t.options.Upstreams) == 0 {
+		return hedged
+	}
+
+	upstream := t.options.Upstreams[(atomic.AddUint64(t.next, 1)-1)%uint64(len(t.options.Upstreams))]
+	hedged.URL.Scheme = upstream.Scheme
+	hedged.URL.Host = upstream.Host
+	hedged.URL.Path = strings.TrimSuffix(upstream.Path, "/") + strings.TrimPrefix(r.URL.Path, strings.TrimSuffix(t.target.Path, "/"))
+	hedged.URL.RawPath = ""
+	if hedged.Host == t.target.Host {
+		hedged.Host = upstream.Host
+	}
+	return hedged
+}
+
+// cancelOnClose cancels the context of a request once the body of its response is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -0,0 +1,134 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ProxyUpstreamMetrics describes the connections and requests made by reverse proxy handles to an upstream. The
+// transport does not expose the number of idle connections in its pool, so how often a request reused a connection is
+// recorded instead.
+type ProxyUpstreamMetrics struct {
+	// The host and port of the upstream
+	Upstream string `json:"upstream"`
+	// The number of requests sent to the upstream, including hedged requests
+	Requests uint64 `json:"requests"`
+	// The number of requests to the upstream that failed without a response, not including requests cancelled by the
+	// client or by hedging
+	Errors uint64 `json:"errors"`
+	// The number of requests currently waiting for a response from the upstream, each of which is using a connection
+	InFlight int64 `json:"in_flight"`
+	// The number of requests that opened a new connection to the upstream
+	NewConnections uint64 `json:"new_connections"`
+	// The number of requests that reused an idle connection to the upstream from the connection pool of the transport
+	ReusedConnections uint64 `json:"reused_connections"`
+	// The number of failed attempts to resolve or connect to the upstream
+	DialErrors uint64 `json:"dial_errors"`
+	// The total time spent waiting for the response headers from the upstream, for requests that received a response
+	Latency time.Duration `json:"latency"`
+	// The longest time spent waiting for the response headers from the upstream
+	MaxLatency time.Duration `json:"max_latency"`
+}
+
+type proxyMetricsStore struct {
+	upstreams map[string]*ProxyUpstreamMetrics
+	lock      *sync.Mutex
+}
+
+func newProxyMetricsStore() *proxyMetricsStore {
+	return &proxyMetricsStore{
+		upstreams: map[string]*ProxyUpstreamMetrics{},
+		lock:      &sync.Mutex{},
+	}
+}
+
+// update calls fn with the metrics for the upstream while holding the lock
+func (s *proxyMetricsStore) update(upstream string, fn func(metrics *ProxyUpstreamMetrics)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	metrics := s.upstreams[upstream]
+	if metrics == nil {
+		metrics = &ProxyUpstreamMetrics{Upstream: upstream}
+		s.upstreams[upstream] = metrics
+	}
+	fn(metrics)
+}
+
+// ProxyMetrics returns the metrics of each upstream that reverse proxy handles have sent requests to, sorted by
+// upstream, such as to monitor the connection pools of a gateway.
+func (s *Server) ProxyMetrics() []ProxyUpstreamMetrics {
+	s.proxies.lock.Lock()
+	upstreams := make([]ProxyUpstreamMetrics, 0, len(s.proxies.upstreams))
+	for _, metrics := range s.proxies.upstreams {
+		upstreams = append(upstreams, *metrics)
+	}
+	s.proxies.lock.Unlock()
+
+	sort.Slice(upstreams, func(i, j int) bool {
+		return upstreams[i].Upstream < upstreams[j].Upstream
+	})
+	return upstreams
+}
+
+// metricsTransport is a round tripper that records the metrics of each upstream
+type metricsTransport struct {
+	transport http.RoundTripper
+	store     *proxyMetricsStore
+}
+
+func (t *metricsTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	upstream := r.URL.Host
+	t.store.update(upstream, func(metrics *ProxyUpstreamMetrics) {
+		metrics.Requests++
+		metrics.InFlight++
+	})
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.store.update(upstream, func(metrics *ProxyUpstreamMetrics) {
+				if info.Reused {
+					metrics.ReusedConnections++
+				} else {
+					metrics.NewConnections++
+				}
+			})
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			if info.Err != nil {
+				t.store.update(upstream, func(metrics *ProxyUpstreamMetrics) {
+					metrics.DialErrors++
+				})
+			}
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err != nil {
+				t.store.update(upstream, func(metrics *ProxyUpstreamMetrics) {
+					metrics.DialErrors++
+				})
+			}
+		},
+	}
+
+	start := time.Now()
+	response, err := t.transport.RoundTrip(r.WithContext(httptrace.WithClientTrace(r.Context(), trace)))
+	elapsed := time.Since(start)
+	t.store.update(upstream, func(metrics *ProxyUpstreamMetrics) {
+		metrics.InFlight--
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				metrics.Errors++
+			}
+			return
+		}
+		metrics.Latency += elapsed
+		if elapsed > metrics.MaxLatency {
+			metrics.MaxLatency = elapsed
+		}
+	})
+	return response, err
+}
@@ -0,0 +1,466 @@
+package web_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/web"
+	"github.com/gorilla/websocket"
+)
+
+func TestHTTPProxy(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Removed") != "" {
+			t.Errorf("Removed request header was sent to upstream")
+		}
+		if r.Header.Get("X-Forwarded-Host") == "" {
+			t.Errorf("No X-Forwarded-Host header sent to upstream")
+		}
+		w.Header().Set("X-Upstream-Secret", "secret")
+		w.Header().Set("X-Upstream-Token", r.Header.Get("X-Token"))
+		w.Write([]byte(r.URL.Path + "?" + r.URL.RawQuery))
+	}))
+	defer upstream.Close()
+
+	target, _ := url.Parse(upstream.URL + "/api/")
+	server.HTTP.Proxy("/internal", *target, web.ProxyOptions{
+		RequestHeaders:        map[string]string{"X-Token": "token"},
+		RemoveRequestHeaders:  []string{"X-Removed"},
+		RemoveResponseHeaders: []string{"X-Upstream-Secret"},
+	})
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d/internal/users/1?id=1", server.ListenPort), nil)
+	req.Header.Set("X-Removed", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("Unexpected status code. Expected %d got %d", 200, resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "/api/users/1?id=1" {
+		t.Errorf("Unexpected upstream path. Expected '%s' got '%s'", "/api/users/1?id=1", body)
+	}
+	if resp.Header.Get("X-Upstream-Secret") != "" {
+		t.Errorf("Removed response header was sent to client")
+	}
+	if resp.Header.Get("X-Upstream-Token") != "token" {
+		t.Errorf("Request header was not set on upstream request")
+	}
+}
+
+func TestHTTPProxyUnauthenticated(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Unauthenticated request was sent to upstream")
+	}))
+	defer upstream.Close()
+
+	target, _ := url.Parse(upstream.URL)
+	server.HTTP.Proxy("/internal/", *target, web.ProxyOptions{
+		HandleOptions: web.HandleOptions{
+			AuthenticateMethod: func(request *http.Request) interface{} {
+				return nil
+			},
+		},
+	})
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/internal/users", server.ListenPort))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 401 {
+		t.Fatalf("Unexpected status code. Expected %d got %d", 401, resp.StatusCode)
+	}
+}
+
+func TestHTTPProxyWebsocket(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	upgrader := websocket.Upgrader{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Error upgrading upstream connection: %s", err.Error())
+			return
+		}
+		defer conn.Close()
+		messageType, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.WriteMessage(messageType, message)
+	}))
+	defer upstream.Close()
+
+	target, _ := url.Parse(upstream.URL)
+	server.HTTP.Proxy("/socket/", *target, web.ProxyOptions{})
+
+	conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://localhost:%d/socket/echo", server.ListenPort), nil)
+	if err != nil {
+		t.Fatalf("Error connecting to websocket: %s", err.Error())
+	}
+	defer conn.Close()
+
+	expected := randomString(6)
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(expected)); err != nil {
+		t.Fatalf("Error writing message: %s", err.Error())
+	}
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Error reading message: %s", err.Error())
+	}
+	if string(message) != expected {
+		t.Errorf("Unexpected message. Expected '%s' got '%s'", expected, message)
+	}
+}
+
+func TestHTTPProxyUpstreamDown(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	target, _ := url.Parse(upstream.URL)
+	upstream.Close()
+
+	server.HTTP.Proxy("/down/", *target, web.ProxyOptions{})
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/down/", server.ListenPort))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 503 {
+		t.Fatalf("Unexpected status code. Expected %d got %d", 503, resp.StatusCode)
+	}
+	response := web.JSONResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %s", err.Error())
+	}
+	if response.Error == nil || response.Error.Code != 503 {
+		t.Errorf("Unexpected error in response: %+v", response.Error)
+	}
+}
+
+func TestHTTPProxyUpstreamTimeout(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	finished := make(chan bool)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-finished
+	}))
+	defer upstream.Close()
+	defer close(finished)
+
+	target, _ := url.Parse(upstream.URL)
+	server.HTTP.Proxy("/slow/", *target, web.ProxyOptions{
+		Transport: &http.Transport{
+			ResponseHeaderTimeout: 10 * time.Millisecond,
+		},
+	})
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/slow/", server.ListenPort))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 504 {
+		t.Fatalf("Unexpected status code. Expected %d got %d", 504, resp.StatusCode)
+	}
+}
+
+func TestHTTPProxyCachePolicy(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	var calls int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		switch r.URL.Path {
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/max-age":
+			w.Header().Set("Cache-Control", "public, max-age=10")
+		default:
+			w.Header().Set("Expires", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer upstream.Close()
+
+	target, _ := url.Parse(upstream.URL)
+	cache := web.HandleOptions{Cache: &web.CacheOptions{TTL: time.Minute}}
+	server.HTTP.Proxy("/respect", *target, web.ProxyOptions{HandleOptions: cache})
+	server.HTTP.Proxy("/override", *target, web.ProxyOptions{HandleOptions: cache, CachePolicy: web.ProxyCacheOverride})
+	server.HTTP.Proxy("/strip", *target, web.ProxyOptions{HandleOptions: cache, CachePolicy: web.ProxyCacheStrip})
+
+	get := func(path string) *http.Response {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d%s", server.ListenPort, path))
+		if err != nil {
+			t.Fatalf("Network error: %s", err.Error())
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != 200 {
+			t.Fatalf("Unexpected status code. Expected %d got %d", 200, resp.StatusCode)
+		}
+		return resp
+	}
+
+	type testCase struct {
+		Path         string
+		Cached       bool
+		CacheControl string
+		Expires      bool
+	}
+	cases := []testCase{
+		{"/respect/no-store", false, "no-store", false},
+		{"/respect/max-age", true, "public, max-age=10", false},
+		{"/respect/expires", true, "max-age=3600", true},
+		{"/override/no-store", true, "max-age=60", false},
+		{"/strip/no-store", true, "", false},
+		{"/strip/expires", true, "", false},
+	}
+	for _, test := range cases {
+		before := atomic.LoadInt32(&calls)
+		resp := get(test.Path)
+		if cacheControl := resp.Header.Get("Cache-Control"); cacheControl != test.CacheControl {
+			// The lifetime from Expires may be off by a second
+			if test.CacheControl != "max-age=3600" || cacheControl != "max-age=3599" {
+				t.Errorf("Unexpected Cache-Control header for %s. Expected '%s' got '%s'", test.Path, test.CacheControl, cacheControl)
+			}
+		}
+		if expires := resp.Header.Get("Expires") != ""; expires != test.Expires {
+			t.Errorf("Unexpected Expires header for %s. Expected %v got %v", test.Path, test.Expires, expires)
+		}
+		get(test.Path)
+		upstreamCalls := atomic.LoadInt32(&calls) - before
+		if test.Cached && upstreamCalls != 1 {
+			t.Errorf("Response for %s was not cached. Expected %d upstream requests got %d", test.Path, 1, upstreamCalls)
+		} else if !test.Cached && upstreamCalls != 2 {
+			t.Errorf("Response for %s was cached. Expected %d upstream requests got %d", test.Path, 2, upstreamCalls)
+		}
+	}
+}
+
+func TestHTTPProxyLargeChunkedBody(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+	}))
+	defer upstream.Close()
+
+	target, _ := url.Parse(upstream.URL)
+	server.HTTP.Proxy("/internal/", *target, web.ProxyOptions{
+		HandleOptions: web.HandleOptions{
+			MaxBodyLength: 10,
+		},
+	})
+
+	body := io.MultiReader(strings.NewReader(randomString(1024)))
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%d/internal/upload", server.ListenPort), "text/plain", body)
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 413 {
+		t.Fatalf("Unexpected status code. Expected %d got %d", 413, resp.StatusCode)
+	}
+}
+
+func TestHTTPProxyHedge(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	finished := make(chan bool)
+	defer close(finished)
+	slowRequests := uint32(0)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddUint32(&slowRequests, 1)
+		select {
+		case <-finished:
+		case <-r.Context().Done():
+		}
+		w.Write([]byte("slow"))
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fast " + r.Method + " " + r.URL.Path))
+	}))
+	defer fast.Close()
+
+	target, _ := url.Parse(slow.URL + "/api/")
+	alternate, _ := url.Parse(fast.URL + "/mirror/")
+	server.HTTP.Proxy("/hedged/", *target, web.ProxyOptions{
+		Hedge: &web.ProxyHedgeOptions{
+			Delay:     20 * time.Millisecond,
+			Upstreams: []url.URL{*alternate},
+		},
+	})
+
+	start := time.Now()
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/hedged/users/1", server.ListenPort))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "fast GET /mirror/users/1" {
+		t.Errorf("Unexpected response body. Expected '%s' got '%s'", "fast GET /mirror/users/1", body)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Hedged request took too long: %s", elapsed)
+	}
+
+	// Requests with side effects are never hedged
+	done := make(chan string)
+	go func() {
+		resp, err := http.Post(fmt.Sprintf("http://localhost:%d/hedged/users/1", server.ListenPort), "text/plain", strings.NewReader("body"))
+		if err != nil {
+			done <- err.Error()
+			return
+		}
+		body, _ := io.ReadAll(resp.Body)
+		done <- string(body)
+	}()
+	select {
+	case body := <-done:
+		t.Errorf("POST request was hedged: %s", body)
+	case <-time.After(100 * time.Millisecond):
+	}
+	finished <- true
+	if body := <-done; body != "slow" {
+		t.Errorf("Unexpected response body. Expected '%s' got '%s'", "slow", body)
+	}
+	if requests := atomic.LoadUint32(&slowRequests); requests != 2 {
+		t.Errorf("Unexpected number of requests to target. Expected %d got %d", 2, requests)
+	}
+}
+
+func TestHTTPProxyMetrics(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	target, _ := url.Parse(upstream.URL)
+	server.HTTP.Proxy("/internal/", *target, web.ProxyOptions{})
+
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/internal/", server.ListenPort))
+		if err != nil {
+			t.Fatalf("Network error: %s", err.Error())
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	metrics := server.ProxyMetrics()
+	if len(metrics) != 1 {
+		t.Fatalf("Unexpected number of upstreams. Expected %d got %d", 1, len(metrics))
+	}
+	if metrics[0].Upstream != target.Host {
+		t.Errorf("Unexpected upstream. Expected '%s' got '%s'", target.Host, metrics[0].Upstream)
+	}
+	if metrics[0].Requests != 2 {
+		t.Errorf("Unexpected number of requests. Expected %d got %d", 2, metrics[0].Requests)
+	}
+	if metrics[0].NewConnections+metrics[0].ReusedConnections != 2 {
+		t.Errorf("Unexpected number of connections. Expected %d got %d", 2, metrics[0].NewConnections+metrics[0].ReusedConnections)
+	}
+	if metrics[0].NewConnections < 1 {
+		t.Errorf("No new connection recorded")
+	}
+	if metrics[0].InFlight != 0 {
+		t.Errorf("Unexpected number of in-flight requests. Expected %d got %d", 0, metrics[0].InFlight)
+	}
+	if metrics[0].Errors != 0 || metrics[0].DialErrors != 0 {
+		t.Errorf("Unexpected errors recorded")
+	}
+}
+
+func TestHTTPProxyMetricsUpstreamDown(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	target, _ := url.Parse(upstream.URL)
+	upstream.Close()
+	server.HTTP.Proxy("/internal/", *target, web.ProxyOptions{})
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/internal/", server.ListenPort))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	resp.Body.Close()
+
+	metrics := server.ProxyMetrics()
+	if len(metrics) != 1 {
+		t.Fatalf("Unexpected number of upstreams. Expected %d got %d", 1, len(metrics))
+	}
+	if metrics[0].Errors != 1 {
+		t.Errorf("Unexpected number of errors. Expected %d got %d", 1, metrics[0].Errors)
+	}
+	if metrics[0].DialErrors < 1 {
+		t.Errorf("No dial error recorded")
+	}
+}
+
+func TestHTTPProxyPathTraversal(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "..") || !strings.HasPrefix(r.URL.Path, "/public/") {
+			t.Errorf("Request outside of target sent to upstream: '%s'", r.URL.Path)
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer upstream.Close()
+
+	target, _ := url.Parse(upstream.URL + "/public/")
+	server.HTTP.Proxy("/p/", *target, web.ProxyOptions{})
+
+	rawRequest := func(path string) int {
+		conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", server.ListenPort))
+		if err != nil {
+			t.Fatalf("Network error: %s", err.Error())
+		}
+		defer conn.Close()
+		fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n", path)
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatalf("Error reading response: %s", err.Error())
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	for _, path := range []string{"/p/../admin/secret", "/p/docs/../../admin/secret", "/p/.."} {
+		if status := rawRequest(path); status != 400 {
+			t.Errorf("Unexpected status code for '%s'. Expected %d got %d", path, 400, status)
+		}
+	}
+	if status := rawRequest("/p/docs/./index.html"); status != 200 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 200, status)
+	}
+}
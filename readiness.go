@@ -0,0 +1,80 @@
+package web
+
+import (
+	"io"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	stateReady int32 = iota
+	stateWarmup
+	stateLameDuck
+)
+
+// Warmup marks the server as warming up, such as while caches are being populated after starting. Requests are served
+// normally, but the readiness check registered with [web.Server.ReadinessCheck] fails until [web.Server.Ready] is
+// called.
+func (s *Server) Warmup() {
+	log.Info("Server warming up")
+	atomic.StoreInt32(s.state, stateWarmup)
+}
+
+// Ready marks the server as ready to receive traffic. Servers are ready by default.
+func (s *Server) Ready() {
+	log.Info("Server ready")
+	atomic.StoreInt32(s.state, stateReady)
+}
+
+// LameDuck marks the server as about to shut down, such as during a rolling deployment. Requests continue to be served,
+// but the readiness check registered with [web.Server.ReadinessCheck] fails so that load balancers stop sending new
+// traffic, and responses include a 'Connection: close' header so that clients reconnect to another instance.
+func (s *Server) LameDuck() {
+	log.Info("Server entering lame duck mode")
+	atomic.StoreInt32(s.state, stateLameDuck)
+}
+
+// IsReady returns true if the server is ready to receive traffic, meaning it is not warming up or in lame duck mode.
+func (s *Server) IsReady() bool {
+	return atomic.LoadInt32(s.state) == stateReady
+}
+
+func (s *Server) isLameDuck() bool {
+	return atomic.LoadInt32(s.state) == stateLameDuck
+}
+
+// ReadinessCheck registers a GET and HEAD handle at path for load balancers and orchestrators to check if the server is
+// ready to receive traffic. The handle responds with "200 OK" when ready, or "503 Service Unavailable" while the server
+// is warming up or in lame duck mode. Requests to the readiness check are not logged or rate limited, and continue to be
+// served in maintenance mode.
+func (s *Server) ReadinessCheck(path string) {
+	options := HandleOptions{
+		DontLogRequests:        true,
+		DisableRateLimit:       true,
+		AllowDuringMaintenance: true,
+	}
+	s.HTTPEasy.GET(path, s.readinessHandle, options)
+	s.HTTPEasy.HEAD(path, s.readinessHandle, options)
+}
+
+func (s *Server) readinessHandle(request Request) HTTPResponse {
+	body := "ready"
+	status := 200
+	switch atomic.LoadInt32(s.state) {
+	case stateWarmup:
+		body = "warming up"
+		status = 503
+	case stateLameDuck:
+		body = "lame duck"
+		status = 503
+	}
+	return HTTPResponse{
+		Reader:        io.NopCloser(strings.NewReader(body)),
+		Status:        status,
+		ContentType:   "text/plain",
+		ContentLength: uint64(len(body)),
+		Headers: map[string]string{
+			"Cache-Control": "no-store",
+		},
+	}
+}
@@ -0,0 +1,57 @@
+package web_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestReadinessCheck(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+	server.ReadinessCheck("/ready")
+	server.API.GET("/data", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}, web.HandleOptions{})
+
+	doTest := func(path string, expectedStatus int, expectedBody string) *http.Response {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path))
+		if err != nil {
+			t.Fatalf("Network error: %s", err.Error())
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != expectedStatus {
+			t.Errorf("Unexpected status code for %s. Expected %d got %d", path, expectedStatus, resp.StatusCode)
+		}
+		if expectedBody != "" && string(body) != expectedBody {
+			t.Errorf("Unexpected body for %s. Expected '%s' got '%s'", path, expectedBody, body)
+		}
+		return resp
+	}
+
+	if !server.IsReady() {
+		t.Errorf("Server should be ready by default")
+	}
+	doTest("ready", 200, "ready")
+
+	server.Warmup()
+	if server.IsReady() {
+		t.Errorf("Server should not be ready while warming up")
+	}
+	doTest("ready", 503, "warming up")
+	doTest("data", 200, "")
+
+	server.Ready()
+	doTest("ready", 200, "ready")
+
+	server.LameDuck()
+	doTest("ready", 503, "lame duck")
+	resp := doTest("data", 200, "")
+	if !resp.Close {
+		t.Errorf("Response in lame duck mode did not close the connection")
+	}
+}
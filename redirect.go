@@ -0,0 +1,68 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/ecnepsnai/web/router"
+)
+
+// TrailingSlashPolicy describes how the server handles requests for a path that only differs from a registered path by
+// a trailing slash, such as a request for "/users/" when only "/users" is registered.
+type TrailingSlashPolicy int
+
+const (
+	// TrailingSlashStrict treats paths with and without a trailing slash as distinct. This is the default.
+	TrailingSlashStrict TrailingSlashPolicy = iota
+	// TrailingSlashRedirect redirects the client to the registered path, using a "301 Moved Permanently" for GET and
+	// HEAD requests and a "308 Permanent Redirect" otherwise.
+	TrailingSlashRedirect
+	// TrailingSlashStrip serves the request with the handle of the registered path, without redirecting the client.
+	TrailingSlashStrip
+)
+
+func (p TrailingSlashPolicy) routerPolicy() router.TrailingSlashPolicy {
+	switch p {
+	case TrailingSlashRedirect:
+		return router.TrailingSlashRedirect
+	case TrailingSlashStrip:
+		return router.TrailingSlashStrip
+	}
+	return router.TrailingSlashStrict
+}
+
+// Redirect returns a HTTPResponse that redirects the client to location with the given status, such as
+// http.StatusFound. If status is 0 then "302 Found" is used.
+//
+// For example:
+//
+//	server.HTTPEasy.GET("/old", func(request web.Request) web.HTTPResponse {
+//	    return web.Redirect(http.StatusMovedPermanently, "/new")
+//	}, web.HandleOptions{})
+func Redirect(status int, location string) HTTPResponse {
+	return HTTPResponse{
+		Status:  redirectStatus(status),
+		Headers: map[string]string{"Location": location},
+	}
+}
+
+// APIRedirect returns an APIResponse that redirects the client to location with the given status, such as
+// http.StatusSeeOther. If status is 0 then "302 Found" is used.
+//
+// For example:
+//
+//	server.API.POST("/users", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+//	    return nil, web.APIRedirect(http.StatusSeeOther, "/users/1"), nil
+//	}, web.HandleOptions{})
+func APIRedirect(status int, location string) *APIResponse {
+	return &APIResponse{
+		Status:  redirectStatus(status),
+		Headers: map[string]string{"Location": location},
+	}
+}
+
+func redirectStatus(status int) int {
+	if status == 0 {
+		return http.StatusFound
+	}
+	return status
+}
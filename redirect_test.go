@@ -0,0 +1,82 @@
+package web_test
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+var noRedirectClient = http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+func TestRedirect(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	path := randomString(5)
+	server.HTTPEasy.GET("/"+path, func(request web.Request) web.HTTPResponse {
+		return web.Redirect(http.StatusMovedPermanently, "/new")
+	}, web.HandleOptions{})
+	server.API.POST("/"+path, func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, web.APIRedirect(0, "/created"), nil
+	}, web.HandleOptions{})
+
+	resp, err := noRedirectClient.Get(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 301 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 301, resp.StatusCode)
+	}
+	if location := resp.Header.Get("Location"); location != "/new" {
+		t.Errorf("Unexpected location. Expected '%s' got '%s'", "/new", location)
+	}
+
+	resp, err = noRedirectClient.Post(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path), "application/json", nil)
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 302 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 302, resp.StatusCode)
+	}
+	if location := resp.Header.Get("Location"); location != "/created" {
+		t.Errorf("Unexpected location. Expected '%s' got '%s'", "/created", location)
+	}
+}
+
+func TestTrailingSlashPolicy(t *testing.T) {
+	t.Parallel()
+
+	server := web.New("127.0.0.1:0")
+	server.Options.TrailingSlashPolicy = web.TrailingSlashRedirect
+	listening := make(chan net.Addr, 1)
+	server.Options.OnListen = func(address net.Addr) {
+		listening <- address
+	}
+	server.API.GET("/users", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}, web.HandleOptions{})
+	go server.Start()
+	defer server.Stop()
+	address := <-listening
+
+	resp, err := noRedirectClient.Get(fmt.Sprintf("http://%s/users/", address.String()))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 301 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 301, resp.StatusCode)
+	}
+	if location := resp.Header.Get("Location"); location != "/users" {
+		t.Errorf("Unexpected location. Expected '%s' got '%s'", "/users", location)
+	}
+}
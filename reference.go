@@ -0,0 +1,61 @@
+package web
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+
+	_ "embed"
+)
+
+//go:embed route_reference.html
+var routeReference string
+
+var routeReferenceTemplate = template.Must(template.New("reference").Parse(routeReference))
+
+type routeReferenceTemplateType struct {
+	Title  string
+	Routes []RouteConfig
+}
+
+// RouteReference registers a GET handle at path that responds with a browsable HTML reference of every API, HTTP,
+// HTTPEasy, and Socket route registered on the server, generated from the routes and their Description and
+// Annotations handle options. This is useful for internal APIs that don't have separate documentation.
+//
+// The options are used for the handle of the reference itself, such as to require an AuthenticateMethod so that the
+// reference is only available to administrators.
+func (s *Server) RouteReference(path string, options HandleOptions) {
+	if options.Description == "" {
+		options.Description = "Reference of all routes registered on the server"
+	}
+	s.HTTPEasy.GET(path, s.routeReferenceHandle, options)
+}
+
+func (s *Server) routeReferenceHandle(request Request) HTTPResponse {
+	templateData := routeReferenceTemplateType{
+		Title:  "Route reference",
+		Routes: s.Config().Routes,
+	}
+	if request.HTTP.Host != "" {
+		templateData.Title = "Route reference: " + request.HTTP.Host
+	}
+
+	buf := &bytes.Buffer{}
+	if err := routeReferenceTemplate.ExecuteTemplate(buf, "main", templateData); err != nil {
+		log.PError("Error executing template for route reference", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return HTTPResponse{
+			Status: 500,
+		}
+	}
+
+	return HTTPResponse{
+		Reader:        io.NopCloser(buf),
+		ContentType:   "text/html; charset=utf-8",
+		ContentLength: uint64(buf.Len()),
+		Headers: map[string]string{
+			"Cache-Control": "no-store",
+		},
+	}
+}
@@ -0,0 +1,53 @@
+package web_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestRouteReference(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}
+	server.API.GET("/users/:id", handle, web.HandleOptions{
+		Description: "Returns the user with the given ID",
+		Annotations: map[string]string{"Permission": "<admin>"},
+		AuthenticateMethod: func(request *http.Request) interface{} {
+			return 1
+		},
+	})
+	server.API.DELETE("/users/:id", handle, web.HandleOptions{})
+	server.RouteReference("/routes", web.HandleOptions{})
+
+	response := server.Request("GET", "/routes", nil)
+	if response.Status != 200 {
+		t.Fatalf("Unexpected status code. Expected %d got %d", 200, response.Status)
+	}
+	if contentType := response.Header.Get("Content-Type"); contentType != "text/html; charset=utf-8" {
+		t.Errorf("Unexpected content type '%s'", contentType)
+	}
+	body := string(response.Body)
+	for _, expected := range []string{
+		"<code>/users/:id</code>",
+		"<code>DELETE</code>",
+		"Returns the user with the given ID",
+		"<dt>Permission</dt>",
+		"&lt;admin&gt;",
+		"Reference of all routes registered on the server",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Route reference does not contain '%s'", expected)
+		}
+	}
+
+	routes := server.Config().Routes
+	if len(routes) != 3 || !routes[2].Authenticated || routes[1].Authenticated || routes[2].Description == "" {
+		t.Errorf("Unexpected routes %+v", routes)
+	}
+}
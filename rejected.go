@@ -0,0 +1,161 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/ecnepsnai/logtic"
+)
+
+var rejectedLog = logtic.Log.Connect("HTTP Rejected")
+
+// The maximum number of clients counted individually by [web.Server.RejectedRequests]
+const maxRejectedClients = 1000
+
+// RejectionReason describes why a request was rejected by the server before reaching a handle
+type RejectionReason string
+
+const (
+	// RejectedUnauthenticated requests were rejected with a 401 status because the AuthenticateMethod returned nil
+	RejectedUnauthenticated RejectionReason = "unauthenticated"
+	// RejectedForbidden requests were rejected with a 403 status by the AuthorizeMethod of the handle
+	RejectedForbidden RejectionReason = "forbidden"
+	// RejectedBanned requests were rejected with a 403 status because the client is banned
+	RejectedBanned RejectionReason = "banned"
+	// RejectedNotFound requests were rejected with a 404 status because no handle is registered for the path
+	RejectedNotFound RejectionReason = "not_found"
+	// RejectedMethodNotAllowed requests were rejected with a 405 status because no handle is registered for the method
+	RejectedMethodNotAllowed RejectionReason = "method_not_allowed"
+	// RejectedPayloadTooLarge requests were rejected with a 413 status because the body exceeded the MaxBodyLength
+	RejectedPayloadTooLarge RejectionReason = "payload_too_large"
+	// RejectedRateLimited requests were rejected with a 429 status because the client exceeded its rate limit
+	RejectedRateLimited RejectionReason = "rate_limited"
+	// RejectedMisdirected requests were rejected with a 421 status because the host is not one of the AllowedHosts
+	RejectedMisdirected RejectionReason = "misdirected"
+	// RejectedMaintenance requests were rejected with a 503 status because the server is in maintenance mode
+	RejectedMaintenance RejectionReason = "maintenance"
+	// RejectedOverloaded requests were rejected with a 503 status because every worker of the WorkerPool was busy and
+	// the queue was full, or the request waited longer than the QueueTimeout
+	RejectedOverloaded RejectionReason = "overloaded"
+)
+
+// RejectedRequestEvent describes a request that was rejected by the server before reaching a handle
+type RejectedRequestEvent struct {
+	// Why the request was rejected
+	Reason RejectionReason
+	// The HTTP status code of the response
+	Status int
+	// The HTTP method of the request
+	Method string
+	// The full URL of the request
+	URL string
+	// The address of the client, as determined with the TrustedProxies option
+	RemoteAddr string
+	// The value of the User-Agent header of the request
+	UserAgent string
+	// When the request was rejected
+	Time time.Time
+}
+
+// RejectionStats describes the number of requests rejected by the server before reaching a handle
+type RejectionStats struct {
+	// The total number of rejected requests
+	Total uint64 `json:"total"`
+	// The number of rejected requests for each reason
+	ByReason map[RejectionReason]uint64 `json:"by_reason"`
+	// The number of rejected requests from each client address. Only the first 1000 clients are counted individually,
+	// requests from any other clients are counted in OtherClients.
+	ByClient map[string]uint64 `json:"by_client"`
+	// The number of rejected requests from clients not included in ByClient
+	OtherClients uint64 `json:"other_clients"`
+}
+
+type rejectionStore struct {
+	stats RejectionStats
+	lock  *sync.Mutex
+}
+
+func newRejectionStore() *rejectionStore {
+	return &rejectionStore{
+		stats: RejectionStats{
+			ByReason: map[RejectionReason]uint64{},
+			ByClient: map[string]uint64{},
+		},
+		lock: &sync.Mutex{},
+	}
+}
+
+func (s *rejectionStore) record(reason RejectionReason, client string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.stats.Total++
+	s.stats.ByReason[reason]++
+	if _, tracked := s.stats.ByClient[client]; tracked || len(s.stats.ByClient) < maxRejectedClients {
+		s.stats.ByClient[client]++
+	} else {
+		s.stats.OtherClients++
+	}
+}
+
+// RejectedRequests returns the number of requests rejected by the server before reaching a handle since the server was
+// created, by reason and by client, such as to identify abusive clients.
+func (s *Server) RejectedRequests() RejectionStats {
+	s.rejections.lock.Lock()
+	defer s.rejections.lock.Unlock()
+	stats := RejectionStats{
+		Total:        s.rejections.stats.Total,
+		ByReason:     make(map[RejectionReason]uint64, len(s.rejections.stats.ByReason)),
+		ByClient:     make(map[string]uint64, len(s.rejections.stats.ByClient)),
+		OtherClients: s.rejections.stats.OtherClients,
+	}
+	for reason, count := range s.rejections.stats.ByReason {
+		stats.ByReason[reason] = count
+	}
+	for client, count := range s.rejections.stats.ByClient {
+		stats.ByClient[client] = count
+	}
+	return stats
+}
+
+// reportRejected records a request rejected by the server, logs it to the "HTTP Rejected" log source, and calls the
+// OnRejectedRequest hook of the server
+func (s *Server) reportRejected(r *http.Request, reason RejectionReason, status int) {
+	if isSynthetic(r) {
+		return
+	}
+	client := s.realRemoteAddr(r).String()
+	s.rejections.record(reason, client)
+	rejectedLog.PWrite(s.Options.RequestLogLevel, "Rejected request", map[string]interface{}{
+		"remote_addr": client,
+		"method":      r.Method,
+		"url":         r.URL,
+		"reason":      string(reason),
+		"status":      status,
+		"user_agent":  r.UserAgent(),
+	})
+
+	if s.Options.OnRejectedRequest == nil {
+		return
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			log.PError("Recovered from panic during rejected request hook", map[string]interface{}{
+				"error": fmt.Sprintf("%v", p),
+				"url":   r.URL,
+				"stack": string(debug.Stack()),
+			})
+		}
+	}()
+	s.Options.OnRejectedRequest(RejectedRequestEvent{
+		Reason:     reason,
+		Status:     status,
+		Method:     r.Method,
+		URL:        r.URL.String(),
+		RemoteAddr: client,
+		UserAgent:  r.UserAgent(),
+		Time:       time.Now(),
+	})
+}
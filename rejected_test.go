@@ -0,0 +1,83 @@
+package web_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestRejectedRequests(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	events := []web.RejectedRequestEvent{}
+	lock := sync.Mutex{}
+	server.Options.OnRejectedRequest = func(event web.RejectedRequestEvent) {
+		lock.Lock()
+		defer lock.Unlock()
+		events = append(events, event)
+	}
+
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}
+	server.API.GET("/private", handle, web.HandleOptions{
+		AuthenticateMethod: func(request *http.Request) interface{} {
+			return nil
+		},
+	})
+	server.API.GET("/admin", handle, web.HandleOptions{
+		AuthorizeMethod: func(userData interface{}, request *http.Request) *web.Error {
+			return web.CommonErrors.Forbidden
+		},
+	})
+	server.API.POST("/upload", handle, web.HandleOptions{MaxBodyLength: 1})
+	server.API.GET("/public", handle, web.HandleOptions{})
+
+	doTest := func(method, path string, expectedStatus int, expectedReason web.RejectionReason) {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte("{}")))
+		req.Header.Set("Content-Length", "2")
+		response := server.Do(req)
+		if response.Status != expectedStatus {
+			t.Errorf("Unexpected status code for %s %s. Expected %d got %d", method, path, expectedStatus, response.Status)
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		if expectedReason == "" {
+			if len(events) > 0 {
+				t.Errorf("Unexpected rejected request event for %s %s: %v", method, path, events)
+			}
+			return
+		}
+		if len(events) != 1 {
+			t.Fatalf("Unexpected number of rejected request events for %s %s. Expected 1 got %d", method, path, len(events))
+		}
+		if events[0].Reason != expectedReason || events[0].Status != expectedStatus || events[0].RemoteAddr != "192.0.2.1" {
+			t.Errorf("Unexpected rejected request event for %s %s: %v", method, path, events[0])
+		}
+		events = events[:0]
+	}
+
+	doTest("GET", "/private", 401, web.RejectedUnauthenticated)
+	doTest("GET", "/admin", 403, web.RejectedForbidden)
+	doTest("POST", "/upload", 413, web.RejectedPayloadTooLarge)
+	doTest("GET", "/missing", 404, web.RejectedNotFound)
+	doTest("DELETE", "/public", 405, web.RejectedMethodNotAllowed)
+	doTest("GET", "/public", 200, "")
+	doTest("GET", "/missing", 404, web.RejectedNotFound)
+
+	stats := server.RejectedRequests()
+	if stats.Total != 6 {
+		t.Errorf("Unexpected total rejected requests. Expected %d got %d", 6, stats.Total)
+	}
+	if stats.ByReason[web.RejectedNotFound] != 2 {
+		t.Errorf("Unexpected not found rejected requests. Expected %d got %d", 2, stats.ByReason[web.RejectedNotFound])
+	}
+	if stats.ByClient["192.0.2.1"] != 6 {
+		t.Errorf("Unexpected rejected requests for client. Expected %d got %d", 6, stats.ByClient["192.0.2.1"])
+	}
+}
@@ -0,0 +1,231 @@
+package web
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// RecordedRequest describes a previously captured HTTP request that can be replayed against a server, along with the
+// response it is expected to produce.
+type RecordedRequest struct {
+	// The HTTP method of the request.
+	Method string
+	// The path of the request, including any query string.
+	Path string
+	// Optional headers to include with the request.
+	Headers map[string]string
+	// Optional body of the request.
+	Body []byte
+	// The expected HTTP status code of the response. If 0 then 200 is implied.
+	ExpectedStatus int
+	// Optional schema that the data property of the JSON response must match. The schema is any Go value, typically
+	// the zero value of a struct, whose type describes the expected shape of the data.
+	//
+	// Every JSON field of the schema must be present in the response unless it is tagged with omitempty, and the
+	// response must not contain any fields that are not described by the schema.
+	Schema interface{}
+}
+
+// ReplayRequests will replay each of the recorded requests through the routing table of this server and assert that
+// the responses match what was expected. The server does not need to be listening. This is intended for use in tests
+// to catch accidental changes to the contract of an API.
+//
+// Returns an error describing every request that did not match, or nil if all requests matched.
+func (s *Server) ReplayRequests(requests []RecordedRequest) error {
+	failures := []string{}
+	for i, recorded := range requests {
+		if err := s.replayRequest(recorded); err != nil {
+			failures = append(failures, fmt.Sprintf("request %d (%s %s): %s", i, recorded.Method, recorded.Path, err.Error()))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d of %d recorded requests did not match:\n%s", len(failures), len(requests), strings.Join(failures, "\n"))
+	}
+	return nil
+}
+
+// replayResponse is the response writer for replayed requests, which discards the response. The body is recorded by
+// the capture of the responseWriter wrapping it.
+type replayResponse struct {
+	header http.Header
+}
+
+func (r *replayResponse) Header() http.Header {
+	return r.header
+}
+
+func (r *replayResponse) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (r *replayResponse) WriteHeader(statusCode int) {}
+
+func (s *Server) replayRequest(recorded RecordedRequest) error {
+	request, err := http.NewRequest(recorded.Method, recorded.Path, bytes.NewReader(recorded.Body))
+	if err != nil {
+		return fmt.Errorf("invalid request: %s", err.Error())
+	}
+	request.RemoteAddr = "192.0.2.1:1234"
+	request.RequestURI = recorded.Path
+	for key, value := range recorded.Headers {
+		request.Header.Set(key, value)
+	}
+	writer := &responseWriter{
+		ResponseWriter: &replayResponse{header: http.Header{}},
+		capture:        &responseCapture{limit: math.MaxInt, hideMaxAge: true},
+	}
+	s.router.ServeHTTP(writer, request)
+
+	expectedStatus := recorded.ExpectedStatus
+	if expectedStatus == 0 {
+		expectedStatus = 200
+	}
+	if status := writer.statusCode(); status != expectedStatus {
+		return fmt.Errorf("unexpected status code: expected %d got %d", expectedStatus, status)
+	}
+
+	if recorded.Schema == nil {
+		return nil
+	}
+
+	response := struct {
+		Data json.RawMessage `json:"data"`
+	}{}
+	if err := json.Unmarshal(writer.capture.body.Bytes(), &response); err != nil {
+		return fmt.Errorf("invalid JSON response: %s", err.Error())
+	}
+	return validateSchema(response.Data, reflect.TypeOf(recorded.Schema), "data")
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// validateSchema checks that raw is a JSON value with the same shape as the given type
+func validateSchema(raw json.RawMessage, t reflect.Type, path string) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+			return nil
+		}
+		return fmt.Errorf("%s: expected %s got null", path, t.String())
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	// Types that decode themselves can only be checked by decoding them
+	if reflect.PointerTo(t).Implements(jsonUnmarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		if err := json.Unmarshal(raw, reflect.New(t).Interface()); err != nil {
+			return fmt.Errorf("%s: %s", path, err.Error())
+		}
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return nil
+	case reflect.Struct:
+		object := map[string]json.RawMessage{}
+		if err := json.Unmarshal(raw, &object); err != nil {
+			return fmt.Errorf("%s: expected object", path)
+		}
+		fields := map[string]bool{}
+		if err := validateStructSchema(object, t, path, fields); err != nil {
+			return err
+		}
+		unexpected := []string{}
+		for key := range object {
+			if !fields[key] {
+				unexpected = append(unexpected, key)
+			}
+		}
+		if len(unexpected) > 0 {
+			sort.Strings(unexpected)
+			return fmt.Errorf("%s: unexpected fields %s", path, strings.Join(unexpected, ", "))
+		}
+		return nil
+	case reflect.Map:
+		object := map[string]json.RawMessage{}
+		if err := json.Unmarshal(raw, &object); err != nil {
+			return fmt.Errorf("%s: expected object", path)
+		}
+		for key, value := range object {
+			if err := validateSchema(value, t.Elem(), path+"."+key); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		array := []json.RawMessage{}
+		if err := json.Unmarshal(raw, &array); err != nil {
+			return fmt.Errorf("%s: expected array", path)
+		}
+		for i, value := range array {
+			if err := validateSchema(value, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := json.Unmarshal(raw, reflect.New(t).Interface()); err != nil {
+		return fmt.Errorf("%s: expected %s", path, t.String())
+	}
+	return nil
+}
+
+func validateStructSchema(object map[string]json.RawMessage, t reflect.Type, path string, fields map[string]bool) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, flags, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if err := validateStructSchema(object, embedded, path, fields); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = true
+
+		value, present := object[name]
+		if !present {
+			if strings.Contains(flags, "omitempty") {
+				continue
+			}
+			return fmt.Errorf("%s: missing field %s", path, name)
+		}
+		if err := validateSchema(value, field.Type, path+"."+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
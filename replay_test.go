@@ -0,0 +1,113 @@
+package web_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestReplayRequests(t *testing.T) {
+	t.Parallel()
+	server := web.New("127.0.0.1:0")
+
+	type userType struct {
+		Username string   `json:"username"`
+		Email    string   `json:"email,omitempty"`
+		Groups   []string `json:"groups"`
+	}
+
+	server.API.GET("/users/:username", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		if request.Parameters["username"] == "missing" {
+			return nil, nil, web.CommonErrors.NotFound
+		}
+		return userType{Username: request.Parameters["username"], Groups: []string{"users"}}, nil, nil
+	}, web.HandleOptions{})
+
+	err := server.ReplayRequests([]web.RecordedRequest{
+		{
+			Method: "GET",
+			Path:   "/users/example",
+			Schema: userType{},
+		},
+		{
+			Method:         "GET",
+			Path:           "/users/missing",
+			ExpectedStatus: 404,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error replaying requests: %s", err.Error())
+	}
+
+	type changedUserType struct {
+		Username string `json:"username"`
+		Admin    bool   `json:"admin"`
+	}
+
+	err = server.ReplayRequests([]web.RecordedRequest{
+		{
+			Method: "GET",
+			Path:   "/users/example",
+			Schema: changedUserType{},
+		},
+		{
+			Method: "GET",
+			Path:   "/users/missing",
+		},
+	})
+	if err == nil {
+		t.Fatalf("No error seen when one expected")
+	}
+	if !strings.Contains(err.Error(), "missing field admin") {
+		t.Errorf("Error does not describe missing field: %s", err.Error())
+	}
+	if !strings.Contains(err.Error(), "expected 200 got 404") {
+		t.Errorf("Error does not describe status code: %s", err.Error())
+	}
+}
+
+func TestReplayRequestsNullPointer(t *testing.T) {
+	t.Parallel()
+	server := web.New("127.0.0.1:0")
+
+	type profileType struct {
+		Bio string `json:"bio"`
+	}
+	type userType struct {
+		Username string       `json:"username"`
+		Profile  *profileType `json:"profile"`
+		Age      *int         `json:"age"`
+	}
+
+	server.API.GET("/users/:username", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return userType{Username: request.Parameters["username"]}, nil, nil
+	}, web.HandleOptions{})
+
+	err := server.ReplayRequests([]web.RecordedRequest{
+		{
+			Method: "GET",
+			Path:   "/users/example",
+			Schema: userType{},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error replaying requests: %s", err.Error())
+	}
+
+	type strictType struct {
+		Username string      `json:"username"`
+		Profile  profileType `json:"profile"`
+		Age      *int        `json:"age"`
+	}
+	err = server.ReplayRequests([]web.RecordedRequest{
+		{
+			Method: "GET",
+			Path:   "/users/example",
+			Schema: strictType{},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "data.profile: expected") {
+		t.Fatalf("Unexpected error for null struct field: %v", err)
+	}
+}
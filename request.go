@@ -1,9 +1,11 @@
 package web
 
 import (
-	"encoding/json"
+	"crypto/x509"
+	"errors"
 	"net"
 	"net/http"
+	"time"
 )
 
 // Request describes an API request
@@ -14,6 +16,13 @@ type Request struct {
 	Parameters map[string]string
 	// User data provided from the result of the AuthenticateRequest method on the handle options
 	UserData any
+
+	server  *Server
+	options HandleOptions
+	start   time.Time
+	decoder JSONDecoder
+	traced  bool
+	writer  http.ResponseWriter
 }
 
 // Decoder describes a generic interface that has a Decode function
@@ -21,18 +30,45 @@ type Decoder interface {
 	Decode(v any) error
 }
 
-// DecodeJSON unmarshal the JSON body to the provided interface.
+// DecodeJSON unmarshal the JSON body to the provided interface using the JSONDecoder of the server.
 //
 // Equal to calling:
 //
-//	r.Decode(v, json.NewDecoder(r.HTTP.Body))
+//	r.Decode(v, server.Options.JSONDecoder.NewDecoder(r.HTTP.Body))
+//
+// If the handle has BodyMigrations for the version of the request then the body must be a JSON object, which is
+// migrated to the current version before being decoded.
 func (r Request) DecodeJSON(v any) *Error {
-	return r.Decode(v, json.NewDecoder(r.HTTP.Body))
+	decoder := r.decoder
+	if decoder == nil {
+		decoder = StandardJSON
+	}
+	if migrations := r.bodyMigrations(); len(migrations) > 0 {
+		return r.decodeMigratedJSON(v, decoder, migrations)
+	}
+	return r.Decode(v, decoder.NewDecoder(r.HTTP.Body))
 }
 
 // Decode will unmarshal the request body to v using the given decoder
+//
+// Returns a 413 error if the body is larger than the MaxBodyLength option of the handle, or a 408 error if the body was
+// read with [web.Request.ReadBodyWithin] and its deadline passed.
 func (r Request) Decode(v any, decoder Decoder) *Error {
-	if err := json.NewDecoder(r.HTTP.Body).Decode(v); err != nil {
+	if err := decoder.Decode(v); err != nil {
+		maxBytesError := &http.MaxBytesError{}
+		if errors.As(err, &maxBytesError) {
+			log.PError("Rejecting request with oversized body", map[string]interface{}{
+				"max_length": maxBytesError.Limit,
+			})
+			return CommonErrors.PayloadTooLarge
+		}
+		if errors.Is(err, ErrBodyReadTimeout) {
+			log.PError("Timed out reading request body", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return CommonErrors.RequestTimeout
+		}
+
 		log.PError("Invalid request", map[string]interface{}{
 			"error": err.Error(),
 		})
@@ -42,11 +78,57 @@ func (r Request) Decode(v any, decoder Decoder) *Error {
 	return nil
 }
 
+// Deadline returns the time at which the soft deadline for this request will be reached, as defined by the
+// SoftDeadline option of the handle. If no soft deadline is defined then ok is false.
+//
+// Handles may use the deadline to decide how much work to perform, returning partial results once it has passed.
+func (r Request) Deadline() (deadline time.Time, ok bool) {
+	if r.options.SoftDeadline <= 0 || r.start.IsZero() {
+		return time.Time{}, false
+	}
+	return r.start.Add(r.options.SoftDeadline), true
+}
+
+// DeadlineExceeded returns true if the soft deadline for this request has passed. Always returns false if the handle
+// does not define a SoftDeadline.
+//
+// When an API handle returns data after its soft deadline has passed, the response is marked as truncated.
+func (r Request) DeadlineExceeded() bool {
+	deadline, ok := r.Deadline()
+	if !ok {
+		return false
+	}
+	return time.Now().After(deadline)
+}
+
+// Traced returns true if tracing was enabled for this request with the TraceHeader server option. Handles may use this
+// to log additional detail for the request.
+func (r Request) Traced() bool {
+	return r.traced
+}
+
 // RealRemoteAddr will try to get the real IP address of the incoming connection taking proxies into
 // consideration. This function looks for the `X-Real-IP`, `X-Forwarded-For`, and `CF-Connecting-IP`
 // headers, and if those don't exist will return the remote address of the connection.
 //
+// If the server has TrustedProxies configured then the headers are only used when the connection comes from a trusted
+// proxy.
+//
 // Will never return nil, if it is unable to get a valid address it will return 0.0.0.0
 func (r Request) RealRemoteAddr() net.IP {
-	return RealRemoteAddr(r.HTTP)
+	if r.server == nil {
+		return RealRemoteAddr(r.HTTP)
+	}
+	return r.server.realRemoteAddr(r.HTTP)
+}
+
+// ClientCertificate returns the certificate the client presented over TLS, if it was verified by the ClientCAs of the
+// server, or nil. Handles may use the subject of the certificate to identify the client.
+func (r Request) ClientCertificate() *x509.Certificate {
+	return ClientCertificate(r.HTTP)
+}
+
+// ClientCertificateChain returns the verified chain of the certificate the client presented over TLS, or nil
+func (r Request) ClientCertificateChain() []*x509.Certificate {
+	return ClientCertificateChain(r.HTTP)
 }
@@ -0,0 +1,32 @@
+package web
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// logRequest writes the log entry for a request to a handle. Requests slower than the SlowRequestThreshold of the
+// server are always logged as a warning, and successful requests are sampled with the LogSampling option. Requests
+// where the client went away before the response was written are always logged, and are marked as client aborted.
+func (s *Server) logRequest(w http.ResponseWriter, r *http.Request, event string, status int, elapsed time.Duration, parameters map[string]interface{}) {
+	if clientAborted(w, r) {
+		parameters["client_aborted"] = true
+		log.PWrite(s.Options.RequestLogLevel, event, parameters)
+		return
+	}
+
+	if s.Options.SlowRequestThreshold > 0 && elapsed >= s.Options.SlowRequestThreshold {
+		parameters["slow"] = true
+		log.PWarn(event, parameters)
+		return
+	}
+
+	if rate := s.Options.LogSampling; rate > 1 && status < 400 {
+		if atomic.AddUint64(s.requestLogs, 1)%uint64(rate) != 0 {
+			return
+		}
+		parameters["sample_rate"] = rate
+	}
+	log.PWrite(s.Options.RequestLogLevel, event, parameters)
+}
@@ -0,0 +1,140 @@
+package web_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/logtic"
+	"github.com/ecnepsnai/web"
+)
+
+func TestRequestLogSampling(t *testing.T) {
+	logtic.Log.Reset()
+	logFilePath := path.Join(t.TempDir(), "web.log")
+	logtic.Log.FilePath = logFilePath
+
+	stdout := &bytes.Buffer{}
+	logtic.Log.Stdout = stdout
+	logtic.Log.Stderr = stdout
+
+	logtic.Log.Level = logtic.LevelDebug
+	logtic.Log.Open()
+	defer logtic.Log.Close()
+
+	server := web.NewMockServer()
+	server.Options.LogSampling = 5
+	server.Options.SlowRequestThreshold = 50 * time.Millisecond
+
+	server.API.GET("/fast", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}, web.HandleOptions{})
+	server.API.GET("/fail", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return nil, nil, web.CommonErrors.BadRequest
+	}, web.HandleOptions{})
+	server.API.GET("/slow", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		time.Sleep(60 * time.Millisecond)
+		return true, nil, nil
+	}, web.HandleOptions{})
+
+	for i := 0; i < 10; i++ {
+		server.Request("GET", "/fast", nil)
+	}
+	server.Request("GET", "/fail", nil)
+	server.Request("GET", "/fail", nil)
+	server.Request("GET", "/slow", nil)
+
+	logtic.Log.Close()
+	logFileData, err := os.ReadFile(logFilePath)
+	if err != nil {
+		panic(err)
+	}
+	var fast, fail, slow int
+	for _, line := range strings.Split(string(logFileData), "\n") {
+		if !strings.Contains(line, "API Request") {
+			continue
+		}
+		switch {
+		case strings.Contains(line, "url='/fast'"):
+			fast++
+			if !strings.Contains(line, "sample_rate=5") {
+				t.Errorf("Sampled log line does not include sample rate: %s", line)
+			}
+		case strings.Contains(line, "url='/fail'"):
+			fail++
+		case strings.Contains(line, "url='/slow'"):
+			slow++
+			if !strings.Contains(line, "[WARN]") || !strings.Contains(line, "slow='true'") {
+				t.Errorf("Slow request not flagged: %s", line)
+			}
+		}
+	}
+	if fast != 2 || fail != 2 || slow != 1 {
+		t.Errorf("Unexpected number of log lines. Expected 2 fast, 2 fail, 1 slow got %d, %d, %d\n----\n%s\n----", fast, fail, slow, logFileData)
+	}
+
+	logtic.Log.Reset()
+	for _, arg := range os.Args {
+		if arg == "-test.v=true" {
+			logtic.Log.Level = logtic.LevelDebug
+			logtic.Log.Open()
+		}
+	}
+}
+
+func TestRequestLogBytesMaxBodyLength(t *testing.T) {
+	logtic.Log.Reset()
+	logFilePath := path.Join(t.TempDir(), "web.log")
+	logtic.Log.FilePath = logFilePath
+
+	stdout := &bytes.Buffer{}
+	logtic.Log.Stdout = stdout
+	logtic.Log.Stderr = stdout
+
+	logtic.Log.Level = logtic.LevelDebug
+	logtic.Log.Open()
+	defer logtic.Log.Close()
+
+	server := web.NewMockServer()
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		io.ReadAll(request.HTTP.Body)
+		return true, nil, nil
+	}
+	server.API.POST("/limited", handle, web.HandleOptions{MaxBodyLength: 64})
+	server.API.POST("/unlimited", handle, web.HandleOptions{})
+
+	// Encoded as JSON the body is 10 bytes
+	server.Request("POST", "/limited", "abcdefg")
+	server.Request("POST", "/unlimited", "abcdefg")
+
+	logtic.Log.Close()
+	logFileData, err := os.ReadFile(logFilePath)
+	if err != nil {
+		panic(err)
+	}
+	lines := 0
+	for _, line := range strings.Split(string(logFileData), "\n") {
+		if !strings.Contains(line, "API Request") {
+			continue
+		}
+		lines++
+		if !strings.Contains(line, "request_bytes=10") {
+			t.Errorf("Unexpected number of request bytes logged: %s", line)
+		}
+	}
+	if lines != 2 {
+		t.Errorf("Unexpected number of log lines. Expected %d got %d\n----\n%s\n----", 2, lines, logFileData)
+	}
+
+	logtic.Log.Reset()
+	for _, arg := range os.Args {
+		if arg == "-test.v=true" {
+			logtic.Log.Level = logtic.LevelDebug
+			logtic.Log.Open()
+		}
+	}
+}
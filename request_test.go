@@ -1,9 +1,14 @@
 package web_test
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ecnepsnai/web"
 )
@@ -60,3 +65,183 @@ func TestRequestRealIP(t *testing.T) {
 		t.Fatalf("Network error: %s", err.Error())
 	}
 }
+
+func TestRequestRealIPTrustedProxies(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+	server.Options.TrustedProxies = []string{"127.0.0.0/8", "::1", "10.0.0.0/8"}
+
+	untrustedServer := newServer()
+	untrustedServer.Options.TrustedProxies = []string{"10.0.0.0/8"}
+
+	handle := func(request web.Request) web.HTTPResponse {
+		return web.HTTPResponse{
+			Reader: io.NopCloser(strings.NewReader(request.RealRemoteAddr().String())),
+		}
+	}
+	path := randomString(5)
+	server.HTTPEasy.GET("/"+path, handle, web.HandleOptions{})
+	untrustedServer.HTTPEasy.GET("/"+path, handle, web.HandleOptions{})
+
+	check := func(server *web.Server, header, value, expected string) {
+		req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path), nil)
+		if err != nil {
+			t.Fatalf("Error forming request: %s", err.Error())
+		}
+		req.Header.Add(header, value)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Network error: %s", err.Error())
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if expected == "" {
+			if string(body) == value {
+				t.Errorf("Header %s from untrusted peer was used", header)
+			}
+			return
+		}
+		if string(body) != expected {
+			t.Errorf("Unexpected client IP address for %s: %s. Expected '%s' got '%s'", header, value, expected, body)
+		}
+	}
+
+	check(server, "X-Real-IP", "1.1.1.1", "1.1.1.1")
+	check(server, "X-Forwarded-For", "1.1.1.1", "1.1.1.1")
+	check(server, "X-Forwarded-For", "2.2.2.2, 1.1.1.1, 10.0.0.1", "1.1.1.1")
+	check(server, "X-Forwarded-For", "10.0.0.2, 10.0.0.1", "10.0.0.2")
+	check(server, "CF-Connecting-IP", "1.1.1.1", "1.1.1.1")
+	check(untrustedServer, "X-Real-IP", "1.1.1.1", "")
+	check(untrustedServer, "X-Forwarded-For", "1.1.1.1", "")
+	check(untrustedServer, "CF-Connecting-IP", "1.1.1.1", "")
+}
+
+func TestRequestSoftDeadline(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		deadline, ok := request.Deadline()
+		if !ok {
+			t.Errorf("No deadline returned for handle with soft deadline")
+		}
+		results := []int{}
+		for i := 0; i < 100; i++ {
+			if time.Now().After(deadline) {
+				break
+			}
+			results = append(results, i)
+			time.Sleep(5 * time.Millisecond)
+		}
+		return results, nil, nil
+	}
+
+	path := randomString(5)
+	server.API.GET("/"+path, handle, web.HandleOptions{
+		SoftDeadline: 20 * time.Millisecond,
+	})
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	response := web.JSONResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %s", err.Error())
+	}
+	if !response.Truncated {
+		t.Errorf("Response not marked as truncated")
+	}
+	if len(response.Data.([]interface{})) >= 100 {
+		t.Errorf("Handle did not stop at deadline")
+	}
+}
+
+func TestRequestNoSoftDeadline(t *testing.T) {
+	request := web.MockRequest(web.MockRequestParameters{})
+	if _, ok := request.Deadline(); ok {
+		t.Errorf("Deadline returned for request without soft deadline")
+	}
+	if request.DeadlineExceeded() {
+		t.Errorf("Deadline exceeded for request without soft deadline")
+	}
+}
+
+func TestRequestReadBodyWithin(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	path := randomString(5)
+	server.API.POST("/"+path, func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		v := map[string]string{}
+		if err := request.Decode(&v, json.NewDecoder(request.ReadBodyWithin(50*time.Millisecond))); err != nil {
+			return nil, nil, err
+		}
+		return v, nil, nil
+	}, web.HandleOptions{})
+
+	// Send part of the body then stall
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	go writer.Write([]byte(`{"name":`))
+
+	start := time.Now()
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path), "application/json", reader)
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 408 {
+		t.Errorf("Unexpected HTTP status code. Expected %d got %d", 408, resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Body read was not bounded by deadline, took %s", elapsed)
+	}
+}
+
+func TestRequestReadBodyWithinMock(t *testing.T) {
+	request := web.MockRequest(web.MockRequestParameters{
+		JSONBody: map[string]string{"name": "value"},
+	})
+
+	v := map[string]string{}
+	if err := request.Decode(&v, json.NewDecoder(request.ReadBodyWithin(time.Second))); err != nil {
+		t.Fatalf("Unexpected error decoding body: %s", err.Message)
+	}
+	if v["name"] != "value" {
+		t.Errorf("Unexpected body. Expected %s got %s", "value", v["name"])
+	}
+
+	body, err := io.ReadAll(request.ReadBodyWithin(-time.Second))
+	if err != web.ErrBodyReadTimeout {
+		t.Errorf("Unexpected error reading body after deadline: %v", err)
+	}
+	if len(body) > 0 {
+		t.Errorf("Body read after deadline")
+	}
+}
+
+func TestRequestRealIPTrustedProxiesChanged(t *testing.T) {
+	server := web.NewMockServer()
+	server.Options.TrustedProxies = []string{"10.0.0.0/8"}
+
+	remoteAddr := ""
+	server.HTTPEasy.GET("/ip", func(request web.Request) web.HTTPResponse {
+		remoteAddr = request.RealRemoteAddr().String()
+		return web.HTTPResponse{}
+	}, web.HandleOptions{})
+
+	request := func() string {
+		req := httptest.NewRequest("GET", "/ip", nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		req.Header.Set("X-Real-IP", "1.1.1.1")
+		server.Do(req)
+		return remoteAddr
+	}
+
+	if addr := request(); addr != "192.168.1.1" {
+		t.Errorf("Unexpected client IP address. Expected '%s' got '%s'", "192.168.1.1", addr)
+	}
+	server.Options.TrustedProxies = []string{"192.168.0.0/16"}
+	if addr := request(); addr != "1.1.1.1" {
+		t.Errorf("Unexpected client IP address after changing trusted proxies. Expected '%s' got '%s'", "1.1.1.1", addr)
+	}
+}
@@ -7,18 +7,27 @@ import (
 
 // APIResponse describes additional response properties for API handles
 type APIResponse struct {
+	// The status code for the response. If 0 then 200 is implied. Ignored if the handle returned an error. A status of
+	// 204 sends the response without a body, regardless of the data returned by the handle.
+	Status int
 	// Additional headers to append to the response.
 	Headers map[string]string
-	// Cookies to set on the response.
+	// Cookies to set on the response. The CookieDefaults of the server are applied to each cookie.
 	Cookies []http.Cookie
 }
 
+// NoContent may be returned as the data from an API handle to send a 204 No Content response without a body, rather
+// than a JSON response object with no data. Headers and cookies from the APIResponse are still sent.
+type NoContent struct{}
+
 // JSONResponse describes an API response object
 type JSONResponse struct {
 	// The actual data of the response
 	Data interface{} `json:"data,omitempty"`
 	// If an error occured, details about the error
 	Error *Error `json:"error,omitempty"`
+	// If true then the handle returned after its soft deadline had passed, and the data may be incomplete
+	Truncated bool `json:"truncated,omitempty"`
 }
 
 // HTTPResponse describes a HTTP response
@@ -31,10 +40,22 @@ type HTTPResponse struct {
 	Status int
 	// Additional headers to append to the response.
 	Headers map[string]string
-	// Cookies to set on the response.
+	// Cookies to set on the response. The CookieDefaults of the server are applied to each cookie.
 	Cookies []http.Cookie
 	// The content type of the response. Will overwrite any 'content-type' header in Headers.
 	ContentType string
-	// The length of the content. Will overwrite any 'content-length' header in Headers.
+	// The length of the content. Will overwrite any 'content-length' header in Headers. If 0 and the Reader implements
+	// [io.Seeker] then the length is determined by seeking to the end of the reader.
 	ContentLength uint64
+	// Optional name of a page from the templates loaded with [web.Server.LoadTemplates] to render as the response, such
+	// as "index.html". When set the Reader and ContentLength are ignored, and the ContentType defaults to HTML.
+	Template string
+	// The data passed to the Template when it is rendered.
+	Data interface{}
+	// Optional method called to write the body of the response to the client as it is produced, such as for long
+	// polling, progress updates, or large generated downloads. The status and headers of the response are sent first,
+	// then the method is called with a [web.StreamWriter] that can flush data to the client and report when the client
+	// disconnects. When set the Reader and Template are ignored, and the method is not called for HEAD requests. The
+	// Timeout of the handle does not apply to the stream. Streamed responses are never cached.
+	Stream func(writer *StreamWriter) error
 }
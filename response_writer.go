@@ -0,0 +1,159 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+)
+
+// requestBytes returns the number of bytes read from the body of the request
+func requestBytes(r *http.Request) uint64 {
+	if body, ok := r.Body.(*countingReader); ok {
+		return body.read
+	}
+	return 0
+}
+
+// responseBytes returns the number of bytes written to the response
+func responseBytes(w http.ResponseWriter) uint64 {
+	if writer, ok := w.(*responseWriter); ok {
+		return writer.written
+	}
+	return 0
+}
+
+// responseStatus returns the status of the response, see [responseWriter.statusCode]
+func responseStatus(w http.ResponseWriter) int {
+	if writer, ok := w.(*responseWriter); ok {
+		return writer.statusCode()
+	}
+	return 0
+}
+
+// isClientAbort returns true if the error from writing a response was caused by the client going away, such as by
+// closing the connection or cancelling the stream, rather than by the server
+func isClientAbort(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, context.Canceled) {
+		return true
+	}
+	message := err.Error()
+	return strings.Contains(message, "broken pipe") || strings.Contains(message, "connection reset by peer") ||
+		strings.Contains(message, "http2: stream closed") || strings.Contains(message, "client disconnected")
+}
+
+// clientAborted returns true if the client went away before the response to the request was written
+func clientAborted(w http.ResponseWriter, r *http.Request) bool {
+	if writer, ok := w.(*responseWriter); ok && writer.aborted {
+		return true
+	}
+	return errors.Is(r.Context().Err(), context.Canceled)
+}
+
+// countingReader counts the number of bytes read from the request body
+type countingReader struct {
+	io.ReadCloser
+	read uint64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.read += uint64(n)
+	return n, err
+}
+
+// limitRequestBody limits the number of bytes that can be read from the body of the request. The body is still counted
+// if it is a countingReader.
+func limitRequestBody(w http.ResponseWriter, r *http.Request, limit uint64) {
+	if body, ok := r.Body.(*countingReader); ok {
+		body.ReadCloser = http.MaxBytesReader(w, body.ReadCloser, int64(limit))
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, int64(limit))
+}
+
+// setResponseError records the reason for an error response, for use with [web.ServerOptions.OnServerError]. Only the
+// first reason recorded is kept.
+func setResponseError(w http.ResponseWriter, err string) {
+	if writer, ok := w.(*responseWriter); ok && writer.err == "" {
+		writer.err = err
+	}
+}
+
+// responseWriter records the status and number of bytes written to the response
+type responseWriter struct {
+	http.ResponseWriter
+	written  uint64
+	status   int
+	err      string
+	trace    *requestTrace
+	capture  *responseCapture
+	hijacked bool
+	// If writing the response failed because the client went away
+	aborted bool
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+		if w.trace != nil {
+			w.Header().Set("Server-Timing", w.trace.serverTiming())
+		}
+		if w.capture != nil && !w.capture.hideMaxAge && !w.capture.skip && statusCode == http.StatusOK && w.Header().Get("Cache-Control") == "" {
+			w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(w.capture.maxAge.Seconds())))
+		}
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// statusCode returns the status of the response. Handles that return without writing anything are sent a 200 status
+// by net/http, so this is used when no status was written. Returns 0 if the connection was hijacked.
+func (w *responseWriter) statusCode() int {
+	if w.status == 0 && !w.hijacked {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.written += uint64(n)
+	if isClientAbort(err) {
+		w.aborted = true
+	}
+	if w.capture != nil {
+		w.capture.write(p[:n])
+	}
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	w.hijacked = true
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the original response writer, for use with [http.ResponseController]
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
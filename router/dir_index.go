@@ -6,8 +6,8 @@ import (
 	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
 	"net/http"
-	"os"
 	"time"
 
 	_ "embed"
@@ -38,7 +38,7 @@ type dirIndexFileType struct {
 	Size string
 }
 
-func (s *impl) makeDirectoryIndex(dir, requestPath string, w http.ResponseWriter) {
+func (s *impl) makeDirectoryIndex(fsys fs.FS, dir, requestPath string, w http.ResponseWriter) {
 	s.log.PDebug("Serving directory listing", map[string]interface{}{
 		"request_path":   requestPath,
 		"directory_path": dir,
@@ -55,7 +55,7 @@ func (s *impl) makeDirectoryIndex(dir, requestPath string, w http.ResponseWriter
 		FileImageBase64:   base64.StdEncoding.EncodeToString(fileImage),
 	}
 
-	entries, err := os.ReadDir(dir)
+	entries, err := fs.ReadDir(fsys, dir)
 	if err != nil {
 		s.log.PError("Error reading directory", map[string]interface{}{
 			"dir":   dir,
@@ -46,3 +46,20 @@ func defaultMethodNotAllowedHandle(w http.ResponseWriter, req *http.Request) {
 	w.Header().Set("Date", timeToHTTPDate(time.Now().UTC()))
 	w.Write(body)
 }
+
+func defaultBadRequestHandle(w http.ResponseWriter, req *http.Request) {
+	body := []byte("400 bad request")
+	contentType := "text/plain; charset=utf-8"
+	accept := strings.ToLower(req.Header.Get("Accept"))
+	if strings.Contains(accept, "html") {
+		body = []byte("<html><body><h1>400 Bad Request</h1></body></html>")
+		contentType = "text/html; charset=utf-8"
+	}
+
+	// Headers must be set before the status is written or they are not sent
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(body)))
+	w.Header().Set("Date", timeToHTTPDate(time.Now().UTC()))
+	w.WriteHeader(400)
+	w.Write(body)
+}
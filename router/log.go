@@ -28,7 +28,7 @@ func (l muteLogger) Write(p []byte) (n int, err error) {
 		}
 	}
 	if !mute {
-		l.source.Write(l.level, string(p[0:length-1]))
+		l.source.Write(l.level, "%s", p[0:length-1])
 	}
 
 	return len(p), nil
@@ -0,0 +1,70 @@
+package router
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// parameterTypes are the types that a parameter may be constrained to, mapped to a method that returns true if the
+// value from the request path is valid for the type
+var parameterTypes = map[string]func(value string) bool{
+	"int": func(value string) bool {
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err == nil
+	},
+	"uint": func(value string) bool {
+		_, err := strconv.ParseUint(value, 10, 64)
+		return err == nil
+	},
+	"float": func(value string) bool {
+		_, err := strconv.ParseFloat(value, 64)
+		return err == nil
+	},
+	"bool": func(value string) bool {
+		_, err := strconv.ParseBool(value)
+		return err == nil
+	},
+	"uuid": uuidPattern.MatchString,
+}
+
+// parseSegment returns the key of the endpoint for a segment of a registered path, and the name and type of the
+// parameter of the segment, if any. Will panic if the type is unknown.
+func parseSegment(segment string) (key, parameter, parameterType string) {
+	if len(segment) <= 1 {
+		return segment, "", ""
+	}
+
+	switch segment[0] {
+	case '*':
+		return pathKeyWildcard, segment[1:], ""
+	case ':':
+		return pathKeyParameter, segment[1:], ""
+	case '{':
+		if segment[len(segment)-1] != '}' || len(segment) == 2 {
+			return segment, "", ""
+		}
+		inner := segment[1 : len(segment)-1]
+		if name, wildcard := strings.CutSuffix(inner, "..."); wildcard {
+			return pathKeyWildcard, name, ""
+		}
+		name, parameterType, _ := strings.Cut(inner, ":")
+		if parameterType != "" {
+			if _, known := parameterTypes[parameterType]; !known {
+				panic("Unknown type '" + parameterType + "' for path parameter " + name)
+			}
+		}
+		return pathKeyParameter, name, parameterType
+	}
+	return segment, "", ""
+}
+
+// parameterMatches returns true if the value from the request path is valid for the type of the parameter
+func parameterMatches(parameterType, value string) bool {
+	if parameterType == "" {
+		return true
+	}
+	return parameterTypes[parameterType](value)
+}
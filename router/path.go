@@ -0,0 +1,33 @@
+package router
+
+import (
+	"net/url"
+	"strings"
+)
+
+// rejectPath returns a description of why the request URL was rejected, or an empty string if the path is safe to
+// route. Plain "." and ".." segments are left to be normalized by the handle, but percent-encoded dots, double-encoded
+// dots and separators, and encoded separators that form a ".." segment are only used to sneak a traversal past
+// filters that decode the path once, so they are rejected outright.
+func rejectPath(u *url.URL) string {
+	escaped := strings.ToLower(u.EscapedPath())
+	if strings.Contains(escaped, "%00") {
+		return "encoded nul byte"
+	}
+	if strings.Contains(escaped, "%2e") {
+		return "encoded dot"
+	}
+	for _, sequence := range []string{"%252e", "%252f", "%255c"} {
+		if strings.Contains(escaped, sequence) {
+			return "double encoded sequence"
+		}
+	}
+	if strings.Contains(escaped, "%2f") || strings.Contains(escaped, "%5c") {
+		for _, segment := range strings.FieldsFunc(u.Path, func(r rune) bool { return r == '/' || r == '\\' }) {
+			if segment == ".." {
+				return "encoded separator"
+			}
+		}
+	}
+	return ""
+}
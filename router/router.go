@@ -11,8 +11,11 @@ package router
 
 import (
 	"fmt"
+	"io/fs"
 	"net/http"
+	"os"
 	"runtime/debug"
+	"sort"
 	"strings"
 )
 
@@ -38,9 +41,10 @@ type Request struct {
 type Handle func(http.ResponseWriter, Request)
 
 type endpoint struct {
-	Methods   map[string]Handle
-	Children  map[string]endpoint
-	Parameter string
+	Methods       map[string]Handle
+	Children      map[string]endpoint
+	Parameter     string
+	ParameterType string
 }
 
 func newEndpoint() endpoint {
@@ -50,11 +54,60 @@ func newEndpoint() endpoint {
 	}
 }
 
-func (s *impl) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+type lookupResult int
+
+const (
+	lookupFound lookupResult = iota
+	lookupNotFound
+	lookupMethodNotAllowed
+	// The request is an OPTIONS request for a path without an OPTIONS handle
+	lookupOptions
+)
+
+// routing describes how a request is answered, as found by impl.route
+type routing struct {
+	handler        Handle
+	parameters     map[string]string
+	allow          string
+	result         lookupResult
+	redirect       string
+	defaultHeaders http.Header
+	notFound       func(http.ResponseWriter, *http.Request)
+	notAllowed     func(http.ResponseWriter, *http.Request)
+}
+
+// route finds how the request is answered while holding the read lock. The lock is released before any handle is
+// called, so that changes to the routing table never wait for requests that are being handled.
+func (s *impl) route(req *http.Request) routing {
 	s.Lock.RLock()
+	defer s.Lock.RUnlock()
+
+	r := routing{
+		defaultHeaders: s.DefaultHeaders,
+		notFound:       s.NotFoundHandle,
+		notAllowed:     s.MethodNotAllowedHandle,
+	}
+	r.handler, r.parameters, r.allow, r.result = s.lookup(req.Method, req.URL.Path)
+	if r.result == lookupNotFound && s.TrailingSlashPolicy != TrailingSlashStrict {
+		if alternate := toggleTrailingSlash(req.URL.Path); alternate != "" {
+			if alternateHandler, alternateParameters, _, alternateResult := s.lookup(req.Method, alternate); alternateResult == lookupFound {
+				if s.TrailingSlashPolicy == TrailingSlashRedirect {
+					r.redirect = alternate
+					return r
+				}
+				r.handler, r.parameters, r.result = alternateHandler, alternateParameters, alternateResult
+			}
+		}
+	}
+	return r
+}
+
+func (s *impl) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	defer func() {
-		s.Lock.RUnlock()
 		if r := recover(); r != nil {
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
 			s.log.PError("Recovered from router panic", map[string]interface{}{
 				"request_method": req.Method,
 				"request_path":   req.URL.Path,
@@ -65,23 +118,59 @@ func (s *impl) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		}
 	}()
 
+	route := s.route(req)
+	for key, values := range route.defaultHeaders {
+		w.Header()[key] = append([]string(nil), values...)
+	}
+
+	if reason := rejectPath(req.URL); reason != "" {
+		s.log.PWarn("Rejected request with path traversal", map[string]interface{}{
+			"request_method": req.Method,
+			"request_path":   req.URL.EscapedPath(),
+			"remote_addr":    req.RemoteAddr,
+			"reason":         reason,
+		})
+		defaultBadRequestHandle(w, req)
+		return
+	}
+
+	if route.redirect != "" {
+		redirectTrailingSlash(w, req, route.redirect)
+		return
+	}
+
+	switch route.result {
+	case lookupFound:
+		route.handler(w, Request{req, route.parameters})
+	case lookupOptions:
+		w.Header().Set("Allow", route.allow)
+		w.WriteHeader(http.StatusNoContent)
+	case lookupMethodNotAllowed:
+		w.Header().Set("Allow", route.allow)
+		route.notAllowed(w, req)
+	default:
+		route.notFound(w, req)
+	}
+}
+
+// lookup finds the handle and parameters for the given method and request path. If the path exists but has no handle
+// for the method, then the value for the 'Allow' header listing the methods of the path is returned.
+func (s *impl) lookup(method, requestPath string) (Handle, map[string]string, string, lookupResult) {
 	// Handle wildcard roots
 	if wildcardChild, exists := s.Index.Children[pathKeyWildcard]; exists {
-		handler, present := wildcardChild.Methods[req.Method]
+		handler, present := wildcardChild.Methods[method]
 		if !present {
-			s.MethodNotAllowedHandle(w, req)
-			return
+			return nil, nil, allowHeader(wildcardChild.Methods), methodMissing(method)
 		}
-		handler(w, Request{req, map[string]string{
-			wildcardChild.Parameter: req.URL.Path[1:], // trim the leading /
-		}})
-		return
+		return handler, map[string]string{
+			wildcardChild.Parameter: requestPath[1:], // trim the leading /
+		}, "", lookupFound
 	}
 
 	parameters := map[string]string{}
 
 	// If the request path ends in a slash, append the index path key
-	path := req.URL.Path
+	path := requestPath
 	if path[len(path)-1] == '/' {
 		path += pathKeyIndex
 	}
@@ -99,23 +188,20 @@ func (s *impl) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 		if !exists {
 			if wildcardChild, exists := parent.Children[pathKeyWildcard]; exists {
-				handler, present := wildcardChild.Methods[req.Method]
+				handler, present := wildcardChild.Methods[method]
 				if !present {
-					s.MethodNotAllowedHandle(w, req)
-					return
+					return nil, nil, allowHeader(wildcardChild.Methods), methodMissing(method)
 				}
 				value := strings.Join(segments[i:], "/")
-				if req.URL.Path[len(req.URL.Path)-1] == '/' {
+				if requestPath[len(requestPath)-1] == '/' {
 					value = value[0 : len(value)-len(pathKeyIndex)]
 				}
 				parameters[wildcardChild.Parameter] = value
-				handler(w, Request{req, parameters})
-				return
+				return handler, parameters, "", lookupFound
 			}
 			parameterChild, exists := parent.Children[pathKeyParameter]
-			if !exists {
-				s.NotFoundHandle(w, req)
-				return
+			if !exists || !parameterMatches(parameterChild.ParameterType, segment) {
+				return nil, nil, "", lookupNotFound
 			}
 			child = parameterChild
 			parameters[parameterChild.Parameter] = segment
@@ -124,24 +210,41 @@ func (s *impl) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		parent = &child
 
 		if i == len(segments)-1 { // last segment
-			handler, present := parent.Methods[req.Method]
+			handler, present := parent.Methods[method]
 			if !present {
 				if len(parent.Methods) > 0 {
-					s.MethodNotAllowedHandle(w, req)
-					return
+					return nil, nil, allowHeader(parent.Methods), methodMissing(method)
 				}
-
-				s.NotFoundHandle(w, req)
-				return
+				return nil, nil, "", lookupNotFound
 			}
 
-			handler(w, Request{req, parameters})
-			return
+			return handler, parameters, "", lookupFound
 		}
 	}
 
 	// should never actually hit this
-	s.NotFoundHandle(w, req)
+	return nil, nil, "", lookupNotFound
+}
+
+// methodMissing returns the result for a request to a path that has no handle for the method. OPTIONS requests are
+// answered automatically, all other methods are not allowed.
+func methodMissing(method string) lookupResult {
+	if method == "OPTIONS" {
+		return lookupOptions
+	}
+	return lookupMethodNotAllowed
+}
+
+// allowHeader returns the value for the 'Allow' header listing the given methods, which always includes OPTIONS
+func allowHeader(methods map[string]Handle) string {
+	allowed := []string{"OPTIONS"}
+	for method := range methods {
+		if method != "OPTIONS" {
+			allowed = append(allowed, method)
+		}
+	}
+	sort.Strings(allowed)
+	return strings.Join(allowed, ", ")
 }
 
 func (s *Server) registerHandle(method, path string, handler Handle) {
@@ -155,19 +258,11 @@ func (s *Server) registerHandle(method, path string, handler Handle) {
 
 	parent := s.impl.Index
 	for i, segment := range segments {
-		parameter := ""
-
 		// Since you can only have one unique parameter per segment, we don't
 		// have to worry about what the parameter name is.
-		if len(segment) > 1 {
-			if segment[0] == '*' {
-				parameter = segment[1:]
-				segment = pathKeyWildcard
-				i = len(segments) - 1
-			} else if segment[0] == ':' {
-				parameter = segment[1:]
-				segment = pathKeyParameter
-			}
+		segment, parameter, parameterType := parseSegment(segment)
+		if segment == pathKeyWildcard {
+			i = len(segments) - 1
 		}
 
 		if wc, exists := parent.Children[pathKeyWildcard]; exists {
@@ -187,7 +282,10 @@ func (s *Server) registerHandle(method, path string, handler Handle) {
 
 			child = newEndpoint()
 			child.Parameter = parameter
+			child.ParameterType = parameterType
 			parent.Children[segment] = child
+		} else if segment == pathKeyParameter && child.ParameterType != parameterType {
+			panic("Path parameter :" + parameter + " collides with existing parameter :" + child.Parameter + " of a different type")
 		}
 
 		parent = &child
@@ -230,6 +328,26 @@ func (s *Server) registerHandle(method, path string, handler Handle) {
 //	request path = "/proxy/some/multi/segmented/value"
 //	parameters   = { "url": "some/multi/segmented/value" }
 //
+// Parameters may also be written in braces, where {name} is the same as :name and {name...} is the same as *name.
+// Parameters in braces may be constrained to a type with {name:type}, in which case requests where the segment is not
+// valid for the type do not match the path and are answered with a "404 Not Found" response, before any handle is
+// called. The types are:
+//
+//	int   - a signed base 10 integer
+//	uint  - an unsigned base 10 integer
+//	float - a floating point number
+//	bool  - a boolean, as accepted by strconv.ParseBool
+//	uuid  - a UUID in the form xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx
+//
+// For example:
+//
+//	handle path  = "/users/{user_id:int}/files/{path...}"
+//	request path = "/users/1234/files/documents/report.pdf"
+//	parameters   = { "user_id": "1234", "path": "documents/report.pdf" }
+//
+// Will panic if a parameter is registered with a different type than an existing parameter at the same segment, or if
+// the type is not known.
+//
 // Parameter segments are exclusive, meaning you can not have a static segment at the same position as a
 // parameterized element. For example, these both will panic:
 //
@@ -246,6 +364,9 @@ func (s *Server) registerHandle(method, path string, handler Handle) {
 //
 //	server.Handle("GET", "/users/all/", ...)
 //	server.Handle("GET", "/users/all", ...)
+//
+// OPTIONS requests to a path without an OPTIONS handle are answered with a "204 No Content" response, with an 'Allow'
+// header listing the methods registered for the path. The same header is included in "405 Method Not Allowed" responses.
 func (s *Server) Handle(method, path string, handler Handle) {
 	methods := map[string]bool{
 		"CONNECT": true,
@@ -298,20 +419,23 @@ func (s *Server) RemoveHandle(method, path string) {
 	}
 	segments := strings.Split(path[1:], "/")
 
+	// The parents of each segment, so that segments left without any handles can be removed
+	parents := []*endpoint{}
+	keys := []string{}
 	parent := s.impl.Index
 	for i, segment := range segments {
-		if len(segment) > 1 {
-			if segment[0] == '*' {
-				segment = pathKeyWildcard
-			} else if segment[0] == ':' {
-				segment = pathKeyParameter
-			}
+		segment, _, _ = parseSegment(segment)
+		if segment == pathKeyWildcard {
+			// Any segments after a wildcard are ignored, the same as when the handle was registered
+			i = len(segments) - 1
 		}
 
 		child, exists := parent.Children[segment]
 		if !exists {
 			return
 		}
+		parents = append(parents, parent)
+		keys = append(keys, segment)
 
 		if i == len(segments)-1 {
 			delete(child.Methods, method)
@@ -319,8 +443,12 @@ func (s *Server) RemoveHandle(method, path string) {
 				"method": method,
 				"path":   path,
 			})
-			if len(child.Methods) == 0 {
-				delete(parent.Children, segment)
+			for j := len(parents) - 1; j >= 0; j-- {
+				node := parents[j].Children[keys[j]]
+				if len(node.Methods) > 0 || len(node.Children) > 0 {
+					break
+				}
+				delete(parents[j].Children, keys[j])
 			}
 			return
 		} else {
@@ -356,9 +484,37 @@ func (s *Server) RemoveHandle(method, path string) {
 // GenerateDirectoryListing variable.
 func (s *Server) ServeFiles(localRoot string, urlRoot string) {
 	var handle Handle = func(rw http.ResponseWriter, r Request) {
-		s.impl.serveStatic(localRoot, r.Parameters["path"], rw, r.HTTP)
+		s.impl.serveStatic(os.DirFS(localRoot), r.Parameters["path"], defaultServeFilesOptions(), rw, r.HTTP)
+	}
+
+	s.serveFilesHandle(urlRoot, handle)
+}
+
+// ServeFilesWithOptions registers a handler for all requests under urlRoot to serve any files matching the same path in
+// a local filesystem directory localRoot, the same as ServeFiles, using the given options in place of the
+// GenerateDirectoryListing variable.
+func (s *Server) ServeFilesWithOptions(localRoot string, urlRoot string, options ServeFilesOptions) {
+	var handle Handle = func(rw http.ResponseWriter, r Request) {
+		s.impl.serveStatic(os.DirFS(localRoot), r.Parameters["path"], options, rw, r.HTTP)
 	}
 
+	s.serveFilesHandle(urlRoot, handle)
+}
+
+// ServeFS registers a handler for all requests under urlRoot to serve any files matching the same path in the
+// filesystem fsys, such as an [embed.FS], the same as ServeFiles. Range requests are supported if the files of fsys
+// implement [io.Seeker].
+//
+// Files embedded with go:embed do not have a modification time, so no Last-Modified date is available to clients.
+func (s *Server) ServeFS(fsys fs.FS, urlRoot string, options ServeFilesOptions) {
+	var handle Handle = func(rw http.ResponseWriter, r Request) {
+		s.impl.serveStatic(fsys, r.Parameters["path"], options, rw, r.HTTP)
+	}
+
+	s.serveFilesHandle(urlRoot, handle)
+}
+
+func (s *Server) serveFilesHandle(urlRoot string, handle Handle) {
 	if urlRoot[len(urlRoot)-1] != '/' {
 		urlRoot += "/"
 	}
@@ -1,9 +1,11 @@
 package router_test
 
 import (
+	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -122,6 +124,46 @@ func TestRouterMethodNotAllowed(t *testing.T) {
 	testURL(t, "POST", "http://"+listenAddress+"/", 405)
 }
 
+func TestRouterAllowedMethods(t *testing.T) {
+	t.Parallel()
+
+	listenAddress := getListenAddress()
+
+	server := router.New()
+	handle := func(rw http.ResponseWriter, request router.Request) {
+		rw.WriteHeader(200)
+	}
+	server.Handle("GET", "/users/:id", handle)
+	server.Handle("DELETE", "/users/:id", handle)
+	server.Handle("GET", "/custom", handle)
+	server.Handle("OPTIONS", "/custom", handle)
+	go func() {
+		server.ListenAndServe(listenAddress)
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	doTest := func(method, path string, expectedStatus int, expectedAllow string) {
+		req, _ := http.NewRequest(method, "http://"+listenAddress+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Network error: %s", err.Error())
+		}
+		resp.Body.Close()
+		if resp.StatusCode != expectedStatus {
+			t.Errorf("Unexpected status code for %s %s. Expected %d got %d", method, path, expectedStatus, resp.StatusCode)
+		}
+		if allow := resp.Header.Get("Allow"); allow != expectedAllow {
+			t.Errorf("Unexpected Allow header for %s %s. Expected '%s' got '%s'", method, path, expectedAllow, allow)
+		}
+	}
+
+	doTest("OPTIONS", "/users/1", 204, "DELETE, GET, OPTIONS")
+	doTest("POST", "/users/1", 405, "DELETE, GET, OPTIONS")
+	doTest("GET", "/users/1", 200, "")
+	doTest("OPTIONS", "/custom", 200, "")
+	doTest("OPTIONS", "/missing", 404, "")
+}
+
 func TestRouterMultiplePaths(t *testing.T) {
 	t.Parallel()
 
@@ -302,6 +344,34 @@ func TestRouterRemoveHandle(t *testing.T) {
 	testURL(t, "POST", "http://"+listenAddress+"/one/two/", 404)
 }
 
+func TestRouterRemoveHandleChildren(t *testing.T) {
+	t.Parallel()
+
+	listenAddress := getListenAddress()
+
+	server := router.New()
+	handle := func(rw http.ResponseWriter, request router.Request) {
+		//
+	}
+	server.Handle("GET", "/users", handle)
+	server.Handle("GET", "/users/:id/posts/:post_id", handle)
+	go func() {
+		server.ListenAndServe(listenAddress)
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	// Removing a path does not remove paths beneath it
+	server.RemoveHandle("GET", "/users")
+	testURL(t, "GET", "http://"+listenAddress+"/users", 404)
+	testURL(t, "GET", "http://"+listenAddress+"/users/1/posts/2", 200)
+
+	// Segments left without any handles are removed, so that a static segment can take the place of a parameter
+	server.RemoveHandle("GET", "/users/:id/posts/:post_id")
+	server.Handle("GET", "/users/all", handle)
+	testURL(t, "GET", "http://"+listenAddress+"/users/all", 200)
+	testURL(t, "GET", "http://"+listenAddress+"/users/1/posts/2", 404)
+}
+
 func TestRouterNotFoundHandle(t *testing.T) {
 	t.Parallel()
 
@@ -554,3 +624,226 @@ func TestRouterHandlePanic(t *testing.T) {
 
 	testURL(t, "GET", "http://"+listenAddress+"/", 500)
 }
+
+func TestRouterTrailingSlashPolicy(t *testing.T) {
+	t.Parallel()
+
+	server := router.New()
+	server.Handle("GET", "/users", func(rw http.ResponseWriter, request router.Request) {
+		rw.Write([]byte("users"))
+	})
+	server.Handle("POST", "/groups/", func(rw http.ResponseWriter, request router.Request) {
+		rw.Write([]byte("groups"))
+	})
+	server.Handle("GET", "/both", func(rw http.ResponseWriter, request router.Request) {
+		rw.Write([]byte("without"))
+	})
+	server.Handle("GET", "/both/", func(rw http.ResponseWriter, request router.Request) {
+		rw.Write([]byte("with"))
+	})
+
+	serve := func(method, url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(method, url, nil))
+		return w
+	}
+
+	// Strict
+	if w := serve("GET", "/users/"); w.Code != 404 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 404, w.Code)
+	}
+
+	// Redirect
+	server.SetTrailingSlashPolicy(router.TrailingSlashRedirect)
+	w := serve("GET", "/users/?page=2")
+	if w.Code != 301 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 301, w.Code)
+	}
+	if location := w.Header().Get("Location"); location != "/users?page=2" {
+		t.Errorf("Unexpected location. Expected '%s' got '%s'", "/users?page=2", location)
+	}
+	w = serve("POST", "/groups")
+	if w.Code != 308 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 308, w.Code)
+	}
+	if location := w.Header().Get("Location"); location != "/groups/" {
+		t.Errorf("Unexpected location. Expected '%s' got '%s'", "/groups/", location)
+	}
+	if w := serve("GET", "//users/"); w.Code != 404 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 404, w.Code)
+	}
+
+	// Strip
+	server.SetTrailingSlashPolicy(router.TrailingSlashStrip)
+	if w := serve("GET", "/users/"); w.Code != 200 || w.Body.String() != "users" {
+		t.Errorf("Unexpected response. Expected %d 'users' got %d '%s'", 200, w.Code, w.Body.String())
+	}
+	if w := serve("GET", "/both/"); w.Body.String() != "with" {
+		t.Errorf("Unexpected response. Expected 'with' got '%s'", w.Body.String())
+	}
+	if w := serve("GET", "/nothing/"); w.Code != 404 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 404, w.Code)
+	}
+}
+
+func TestRouterEncodedPathTraversal(t *testing.T) {
+	t.Parallel()
+
+	server := router.New()
+	server.Handle("GET", "/files/:name", func(rw http.ResponseWriter, request router.Request) {
+		rw.Write([]byte(request.Parameters["name"]))
+	})
+
+	serve := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		return w
+	}
+
+	rejected := []string{
+		"/files/%2e%2e",
+		"/files/%2E%2E%2Fetc",
+		"/files/%252e%252e",
+		"/files/..%252fetc",
+		"/files/..%2f..%2fetc",
+		"/files/..%5Cetc",
+		"/files/name%00",
+	}
+	for _, url := range rejected {
+		if w := serve(url); w.Code != 400 {
+			t.Errorf("Unexpected status code for URL '%s'. Expected %d got %d", url, 400, w.Code)
+		}
+	}
+
+	w := serve("/files/%2e%2e")
+	if contentType := w.Result().Header.Get("Content-Type"); contentType != "text/plain; charset=utf-8" {
+		t.Errorf("Unexpected content type. Expected '%s' got '%s'", "text/plain; charset=utf-8", contentType)
+	}
+	if length := w.Result().Header.Get("Content-Length"); length != fmt.Sprintf("%d", w.Body.Len()) {
+		t.Errorf("Unexpected content length. Expected '%d' got '%s'", w.Body.Len(), length)
+	}
+
+	if w := serve("/files/a%20b"); w.Code != 200 || w.Body.String() != "a b" {
+		t.Errorf("Unexpected response. Expected %d 'a b' got %d '%s'", 200, w.Code, w.Body.String())
+	}
+	if w := serve("/files/report.txt"); w.Code != 200 || w.Body.String() != "report.txt" {
+		t.Errorf("Unexpected response. Expected %d 'report.txt' got %d '%s'", 200, w.Code, w.Body.String())
+	}
+}
+
+func TestRouterDefaultHeaders(t *testing.T) {
+	t.Parallel()
+
+	server := router.New()
+	server.SetDefaultHeaders(map[string]string{
+		"X-Content-Type-Options": "nosniff",
+		"X-Frame-Options":        "DENY",
+	})
+	server.Handle("GET", "/frame", func(rw http.ResponseWriter, request router.Request) {
+		rw.Header().Set("X-Frame-Options", "SAMEORIGIN")
+		rw.Write([]byte("frame"))
+	})
+
+	for _, url := range []string{"/frame", "/missing", "/../etc/passwd"} {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		if w.Header().Get("X-Content-Type-Options") != "nosniff" {
+			t.Errorf("Missing default header for '%s'", url)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/frame", nil))
+	if frameOptions := w.Header().Get("X-Frame-Options"); frameOptions != "SAMEORIGIN" {
+		t.Errorf("Default header not replaced by handle. Expected '%s' got '%s'", "SAMEORIGIN", frameOptions)
+	}
+
+	server.SetDefaultHeaders(nil)
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/missing", nil))
+	if w.Header().Get("X-Content-Type-Options") != "" {
+		t.Errorf("Default header not removed")
+	}
+}
+
+func TestRouterTypedParameters(t *testing.T) {
+	t.Parallel()
+
+	server := router.New()
+	parameters := map[string]string{}
+	server.Handle("GET", "/users/{user_id:int}/files/{path...}", func(rw http.ResponseWriter, request router.Request) {
+		parameters = request.Parameters
+	})
+	server.Handle("GET", "/sessions/{session_id:uuid}", func(rw http.ResponseWriter, request router.Request) {
+		parameters = request.Parameters
+	})
+	server.Handle("GET", "/groups/{name}", func(rw http.ResponseWriter, request router.Request) {
+		parameters = request.Parameters
+	})
+
+	check := func(url string, expectedStatus int) {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		if w.Code != expectedStatus {
+			t.Errorf("Unexpected status code for URL '%s'. Expected %d got %d", url, expectedStatus, w.Code)
+		}
+	}
+
+	check("/users/1234/files/documents/report.pdf", 200)
+	if parameters["user_id"] != "1234" {
+		t.Errorf("Incorrect parameter value, Expected '1234' got '%s'", parameters["user_id"])
+	}
+	if parameters["path"] != "documents/report.pdf" {
+		t.Errorf("Incorrect parameter value, Expected 'documents/report.pdf' got '%s'", parameters["path"])
+	}
+	check("/users/-5/files/a", 200)
+	check("/users/abc/files/a", 404)
+	check("/users/1.5/files/a", 404)
+
+	check("/sessions/0b5e4a0e-3c9f-4d7e-9d0a-2f6c1e2b7a41", 200)
+	if parameters["session_id"] != "0b5e4a0e-3c9f-4d7e-9d0a-2f6c1e2b7a41" {
+		t.Errorf("Incorrect parameter value, Expected '0b5e4a0e-3c9f-4d7e-9d0a-2f6c1e2b7a41' got '%s'", parameters["session_id"])
+	}
+	check("/sessions/1234", 404)
+
+	check("/groups/admins", 200)
+	if parameters["name"] != "admins" {
+		t.Errorf("Incorrect parameter value, Expected 'admins' got '%s'", parameters["name"])
+	}
+
+	server.RemoveHandle("GET", "/users/{user_id:int}/files/{path...}")
+	check("/users/1234/files/a", 404)
+}
+
+func TestRouterTypedParameterClash(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		recover()
+	}()
+
+	server := router.New()
+	server.Handle("GET", "/users/{id:int}", func(rw http.ResponseWriter, request router.Request) {
+		//
+	})
+	server.Handle("DELETE", "/users/{id:uuid}", func(rw http.ResponseWriter, request router.Request) {
+		//
+	})
+
+	t.Errorf("No panic seen when one expected for adding parameter with a different type")
+}
+
+func TestRouterUnknownParameterType(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		recover()
+	}()
+
+	server := router.New()
+	server.Handle("GET", "/users/{id:number}", func(rw http.ResponseWriter, request router.Request) {
+		//
+	})
+
+	t.Errorf("No panic seen when one expected for adding parameter with an unknown type")
+}
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"context"
 	golog "log"
 	"net"
 	"net/http"
@@ -22,6 +23,8 @@ type impl struct {
 	Index                  *endpoint
 	NotFoundHandle         func(http.ResponseWriter, *http.Request)
 	MethodNotAllowedHandle func(http.ResponseWriter, *http.Request)
+	TrailingSlashPolicy    TrailingSlashPolicy
+	DefaultHeaders         http.Header
 	log                    *logtic.Source
 }
 
@@ -91,6 +94,26 @@ func (s *Server) Stop() {
 	s.impl.log.Info("Server stopped")
 }
 
+// Shutdown will gracefully stop the server, closing the listener and then waiting for active requests to complete or
+// for ctx to be done. Server.ListenAndServe or Server.Serve will return [http.ErrServerClosed]. The server can not be
+// started again after it has been shut down.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.impl.log.Debug("Shutting down server")
+	return s.httpServer.Shutdown(ctx)
+}
+
+// ServeHTTP will dispatch the HTTP request to the handle registered for the method and path of the request. This
+// allows the router to be used as a [http.Handler] without it needing to be listening, such as in tests.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.impl.ServeHTTP(w, r)
+}
+
+// SetProtocols will set the HTTP protocols the server will accept, such as to enable unencrypted HTTP/2. Must be
+// called before the server is started. Passing nil restores the default of HTTP/1.1, and HTTP/2 over TLS.
+func (s *Server) SetProtocols(protocols *http.Protocols) {
+	s.httpServer.Protocols = protocols
+}
+
 // SetNotFoundHandle will set the handle called when a request that did not match any registered path comes in.
 //
 // A default handle is set when the server is created.
@@ -105,3 +128,16 @@ func (s *Server) SetNotFoundHandle(handle func(w http.ResponseWriter, r *http.Re
 func (s *Server) SetMethodNotAllowedHandle(handle func(w http.ResponseWriter, r *http.Request)) {
 	s.impl.MethodNotAllowedHandle = handle
 }
+
+// SetDefaultHeaders will set headers that are added to every response before the request is dispatched, including
+// responses for static files and for requests that did not match any registered path. Handles may replace these
+// headers. Passing nil removes any default headers.
+func (s *Server) SetDefaultHeaders(headers map[string]string) {
+	defaultHeaders := http.Header{}
+	for key, value := range headers {
+		defaultHeaders.Set(key, value)
+	}
+	s.impl.Lock.Lock()
+	defer s.impl.Lock.Unlock()
+	s.impl.DefaultHeaders = defaultHeaders
+}
@@ -2,11 +2,12 @@ package router
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"mime/multipart"
 	"net/http"
-	"os"
 	"path"
 	"strconv"
 	"strings"
@@ -24,27 +25,87 @@ var IndexFileName = "index.html"
 // an index file (see also IndexFileName)
 var GenerateDirectoryListing = true
 
-func (s *impl) serveStatic(dir, url string, w http.ResponseWriter, req *http.Request) {
+// ErrRangeLimitExceeded is returned by ServeHTTPRange when the requested ranges exceed the MaxRanges or MaxBytes
+// limits. A "416 Range Not Satisfiable" response will have been written.
+var ErrRangeLimitExceeded = errors.New("range limit exceeded")
+
+// ServeFilesOptions describes options for serving static files
+type ServeFilesOptions struct {
+	// If true then requests for paths that do not match a file are answered with the index file from the root of the
+	// directory, allowing a single-page application to handle routing on the client.
+	IndexFallback bool
+	// Optional Cache-Control header values by file extension, such as ".html": "no-cache". Extensions include the
+	// leading dot and are not case sensitive. Files with any other extension use the CacheMaxAge variable.
+	CacheControl map[string]string
+	// If true then a directory listing is generated for directories that do not have an index file, otherwise they are
+	// not found.
+	DirectoryListing bool
+	// If true then requests for any file or directory with a name beginning with '.', such as '.git' or '.env', are
+	// not found.
+	DenyDotfiles bool
+	// The maximum number of ranges permitted in a single range request. Requests with more ranges receive a
+	// "416 Range Not Satisfiable" response. The default value of 0 has no limit.
+	MaxRanges int
+	// The maximum total number of bytes, across all ranges, permitted in a single range request. Overlapping ranges
+	// are counted each time they are requested. Requests for more bytes receive a "416 Range Not Satisfiable"
+	// response. The default value of 0 has no limit.
+	MaxRangeBytes uint64
+	// If true then a precompressed sibling of the requested file, such as 'app.js.br', 'app.js.zst', or 'app.js.gz', is
+	// served in its place with the matching Content-Encoding header if the client accepts that encoding. Brotli is
+	// preferred over zstd, which is preferred over gzip.
+	Precompressed bool
+}
+
+// precompressedEncodings are the content encodings of precompressed files and their file extension, in order of
+// preference
+var precompressedEncodings = []struct {
+	encoding  string
+	extension string
+}{
+	{"br", ".br"},
+	{"zstd", ".zst"},
+	{"gzip", ".gz"},
+}
+
+// defaultServeFilesOptions returns the options used by ServeFiles, which are based off of the package variables
+func defaultServeFilesOptions() ServeFilesOptions {
+	return ServeFilesOptions{
+		DirectoryListing: GenerateDirectoryListing,
+	}
+}
+
+func (s *impl) serveStatic(fsys fs.FS, url string, options ServeFilesOptions, w http.ResponseWriter, req *http.Request) {
 	requestPath := stripPath(url)
+	if options.DenyDotfiles && hasDotfile(requestPath) {
+		s.log.PInfo("Denying static request for dotfile", map[string]interface{}{
+			"request_path": requestPath,
+		})
+		s.NotFoundHandle(w, req)
+		return
+	}
+
 	shouldRenderDirectoryListing := false
 	if requestPath == "" || strings.HasSuffix(requestPath, "/") {
 		// First check if an index file is found
-		if fileExists(path.Join(dir, requestPath+IndexFileName)) {
+		if fileExists(fsys, fsPath(requestPath+IndexFileName)) {
 			requestPath += IndexFileName
-		} else if fileExists(path.Join(dir, requestPath)) {
+		} else if fileExists(fsys, fsPath(requestPath)) {
 			// If an index file is not found, check if the directory exists
-			shouldRenderDirectoryListing = true
+			shouldRenderDirectoryListing = options.DirectoryListing || !options.IndexFallback
 		}
 	}
-	filePath := path.Join(dir, requestPath)
+	if options.IndexFallback && !shouldRenderDirectoryListing && !isFile(fsys, fsPath(requestPath)) {
+		requestPath = IndexFileName
+	}
+	filePath := fsPath(requestPath)
 
 	if shouldRenderDirectoryListing {
-		if !GenerateDirectoryListing {
+		if !options.DirectoryListing {
 			s.NotFoundHandle(w, req)
 			return
 		}
 
-		s.makeDirectoryIndex(filePath, requestPath, w)
+		s.makeDirectoryIndex(fsys, filePath, requestPath, w)
 		return
 	}
 
@@ -53,7 +114,16 @@ func (s *impl) serveStatic(dir, url string, w http.ResponseWriter, req *http.Req
 		"file_path":    filePath,
 	})
 
-	f, err := os.OpenFile(filePath, os.O_RDONLY, os.ModePerm)
+	openPath := filePath
+	if options.Precompressed {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if encoding, extension := precompressedFile(fsys, filePath, req.Header.Get("Accept-Encoding")); encoding != "" {
+			w.Header().Set("Content-Encoding", encoding)
+			openPath += extension
+		}
+	}
+
+	f, err := fsys.Open(openPath)
 	if err != nil {
 		s.log.PInfo("Static file not found", map[string]interface{}{
 			"request_path": requestPath,
@@ -68,15 +138,18 @@ func (s *impl) serveStatic(dir, url string, w http.ResponseWriter, req *http.Req
 	if err != nil {
 		s.log.PError("Error getting static file info", map[string]interface{}{
 			"request_path": requestPath,
-			"file_path":    filePath,
+			"file_path":    openPath,
 			"error":        err.Error(),
 		})
 		s.NotFoundHandle(w, req)
 		return
 	}
 
+	// Files from some filesystems, such as embed.FS, don't have a modification time
+	hasModTime := !info.ModTime().IsZero()
+
 	sendBody := req.Method == "GET"
-	if modifiedSinceStr := req.Header.Get("If-Modified-Since"); modifiedSinceStr != "" {
+	if modifiedSinceStr := req.Header.Get("If-Modified-Since"); modifiedSinceStr != "" && hasModTime {
 		modifiedSince, err := httpDateToTime(modifiedSinceStr)
 		if err != nil {
 			modifiedSince = time.Now()
@@ -87,22 +160,32 @@ func (s *impl) serveStatic(dir, url string, w http.ResponseWriter, req *http.Req
 		}
 	}
 
-	if ranges := ParseRangeHeader(req.Header.Get("range")); len(ranges) > 0 && sendBody {
-		headers := map[string]string{
-			"Last-Modified": timeToHTTPDate(info.ModTime().UTC()),
+	seeker, canSeek := f.(io.ReadSeeker)
+	if ranges := ParseRangeHeader(req.Header.Get("range")); len(ranges) > 0 && sendBody && canSeek {
+		headers := map[string]string{}
+		if hasModTime {
+			headers["Last-Modified"] = timeToHTTPDate(info.ModTime().UTC())
 		}
-		if CacheMaxAge > 0 {
-			headers["Cache-Control"] = fmt.Sprintf("max-age=%d; public", int(CacheMaxAge.Seconds()))
+		if cacheControl := options.cacheControl(filePath); cacheControl != "" {
+			headers["Cache-Control"] = cacheControl
 		}
 		err = ServeHTTPRange(ServeHTTPRangeOptions{
 			Headers:     headers,
 			Ranges:      ranges,
-			Reader:      f,
+			Reader:      seeker,
 			TotalLength: uint64(info.Size()),
 			MIMEType:    MimeGetter.GetMime(filePath),
 			Writer:      w,
+			MaxRanges:   options.MaxRanges,
+			MaxBytes:    options.MaxRangeBytes,
 		})
-		if err != nil {
+		if errors.Is(err, ErrRangeLimitExceeded) {
+			s.log.PWarn("Rejected static range request exceeding limits", map[string]interface{}{
+				"request_path": requestPath,
+				"range":        req.Header.Get("range"),
+				"remote_addr":  req.RemoteAddr,
+			})
+		} else if err != nil {
 			s.log.PError("Error serving ranged static file", map[string]interface{}{
 				"request_path": requestPath,
 				"file_path":    filePath,
@@ -112,14 +195,18 @@ func (s *impl) serveStatic(dir, url string, w http.ResponseWriter, req *http.Req
 		return
 	}
 
-	if CacheMaxAge > 0 {
-		w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d; public", int(CacheMaxAge.Seconds())))
+	if cacheControl := options.cacheControl(filePath); cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
 	}
 	w.Header().Set("Content-Type", MimeGetter.GetMime(filePath))
 	w.Header().Set("Content-Length", fmt.Sprintf("%d", info.Size()))
-	w.Header().Add("Last-Modified", timeToHTTPDate(info.ModTime().UTC()))
+	if hasModTime {
+		w.Header().Add("Last-Modified", timeToHTTPDate(info.ModTime().UTC()))
+	}
 	w.Header().Set("Date", timeToHTTPDate(time.Now().UTC()))
-	w.Header().Set("Accept-Ranges", "bytes")
+	if canSeek {
+		w.Header().Set("Accept-Ranges", "bytes")
+	}
 	if sendBody {
 		io.Copy(w, f)
 	} else {
@@ -144,10 +231,20 @@ type ServeHTTPRangeOptions struct {
 	MIMEType string
 	// The outgoing HTTP response writer
 	Writer http.ResponseWriter
+	// The maximum number of ranges permitted. The default value of 0 has no limit.
+	MaxRanges int
+	// The maximum total number of bytes permitted across all ranges. The default value of 0 has no limit.
+	MaxBytes uint64
 }
 
-// ServeHTTPRange serve a HTTP range
+// ServeHTTPRange serve a HTTP range. If the ranges exceed the MaxRanges or MaxBytes limits then a
+// "416 Range Not Satisfiable" response is written and ErrRangeLimitExceeded is returned.
 func ServeHTTPRange(options ServeHTTPRangeOptions) error {
+	if options.MaxRanges > 0 && len(options.Ranges) > options.MaxRanges {
+		rejectHTTPRange(options)
+		return ErrRangeLimitExceeded
+	}
+
 	for i := 0; i < len(options.Ranges); i++ {
 		r := options.Ranges[i]
 		if r.Start >= int64(options.TotalLength) {
@@ -159,6 +256,17 @@ func ServeHTTPRange(options ServeHTTPRangeOptions) error {
 		}
 	}
 
+	if options.MaxBytes > 0 {
+		total := uint64(0)
+		for _, r := range options.Ranges {
+			total += r.Length(options.TotalLength)
+			if total > options.MaxBytes {
+				rejectHTTPRange(options)
+				return ErrRangeLimitExceeded
+			}
+		}
+	}
+
 	if len(options.Ranges) == 1 {
 		return serveHTTPRangeSingle(options)
 	}
@@ -166,6 +274,12 @@ func ServeHTTPRange(options ServeHTTPRangeOptions) error {
 	return serveHTTPRangeMulti(options)
 }
 
+func rejectHTTPRange(options ServeHTTPRangeOptions) {
+	options.Writer.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", options.TotalLength))
+	options.Writer.Header().Set("Date", timeToHTTPDate(time.Now().UTC()))
+	options.Writer.WriteHeader(416)
+}
+
 func handleRange(reader io.ReadSeeker, writer io.Writer, r ByteRange) error {
 	if r.Start >= 0 {
 		if _, err := reader.Seek(r.Start, 0); err != nil {
@@ -201,6 +315,13 @@ func serveHTTPRangeSingle(options ServeHTTPRangeOptions) error {
 	if CacheMaxAge > 0 {
 		options.Writer.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d; public", int(CacheMaxAge.Seconds())))
 	}
+	// Applied after the default Cache-Control header so that a supplied value replaces it
+	for k, v := range options.Headers {
+		options.Writer.Header().Set(k, v)
+	}
+	for _, cookie := range options.Cookies {
+		http.SetCookie(options.Writer, &cookie)
+	}
 	options.Writer.Header().Set("Content-Type", options.MIMEType)
 	options.Writer.Header().Set("Content-Length", fmt.Sprintf("%d", r.Length(options.TotalLength)))
 	options.Writer.Header().Set("Content-Range", r.ContentRangeValue(options.TotalLength))
@@ -214,7 +335,7 @@ func serveHTTPRangeMulti(options ServeHTTPRangeOptions) error {
 	mp := multipart.NewWriter(options.Writer)
 	options.Writer.Header().Set("Content-Type", fmt.Sprintf("multipart/byteranges; boundary=%s", mp.Boundary()))
 	for k, v := range options.Headers {
-		options.Writer.Header().Add(k, v)
+		options.Writer.Header().Set(k, v)
 	}
 	for _, cookie := range options.Cookies {
 		http.SetCookie(options.Writer, &cookie)
@@ -258,11 +379,84 @@ func stripPath(inS string) (outS string) {
 	return
 }
 
-func fileExists(filePath string) bool {
-	_, err := os.Stat(filePath)
+// fsPath returns the name of the file in a filesystem for the request path
+func fsPath(requestPath string) string {
+	name := strings.TrimPrefix(path.Clean("/"+requestPath), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+func fileExists(fsys fs.FS, name string) bool {
+	_, err := fs.Stat(fsys, name)
 	return err == nil
 }
 
+func isFile(fsys fs.FS, name string) bool {
+	info, err := fs.Stat(fsys, name)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// precompressedFile returns the content encoding and file extension of the most preferred precompressed sibling of the
+// file that is accepted by the client, or empty strings if there are none
+func precompressedFile(fsys fs.FS, name string, acceptEncoding string) (string, string) {
+	if acceptEncoding == "" {
+		return "", ""
+	}
+	for _, precompressed := range precompressedEncodings {
+		if acceptsEncoding(acceptEncoding, precompressed.encoding) && isFile(fsys, name+precompressed.extension) {
+			return precompressed.encoding, precompressed.extension
+		}
+	}
+	return "", ""
+}
+
+// acceptsEncoding returns true if the value of an Accept-Encoding request header permits the content encoding
+func acceptsEncoding(acceptEncoding string, encoding string) bool {
+	accepted := false
+	for _, value := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(value, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != encoding && name != "*" {
+			continue
+		}
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+		if name == encoding {
+			// An explicit quality for the encoding takes precedence over the wildcard
+			return quality > 0
+		}
+		accepted = quality > 0
+	}
+	return accepted
+}
+
+// hasDotfile returns true if any component of the request path begins with a '.'
+func hasDotfile(requestPath string) bool {
+	for _, component := range strings.Split(requestPath, "/") {
+		if strings.HasPrefix(component, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheControl returns the value for the Cache-Control header for the file, if any
+func (o ServeFilesOptions) cacheControl(filePath string) string {
+	if cacheControl, ok := o.CacheControl[strings.ToLower(path.Ext(filePath))]; ok {
+		return cacheControl
+	}
+	if CacheMaxAge > 0 {
+		return fmt.Sprintf("max-age=%d; public", int(CacheMaxAge.Seconds()))
+	}
+	return ""
+}
+
 // ByteRange describes a range of offsets for reading from a byte slice.
 //
 // There are thee possabilities for byte ranges:
@@ -7,6 +7,7 @@ import (
 	"mime"
 	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"testing"
@@ -794,3 +795,80 @@ func TestRangeEndIndexOutOfRange(t *testing.T) {
 		t.Fatalf("invalid data returned")
 	}
 }
+
+func TestRangeLimits(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(path.Join(dir, "data.txt"), sampleData, os.ModePerm)
+
+	listenAddress := getListenAddress()
+
+	server := router.New()
+	server.ServeFilesWithOptions(dir, "/", router.ServeFilesOptions{
+		MaxRanges:     3,
+		MaxRangeBytes: 300,
+	})
+	go func() {
+		server.ListenAndServe(listenAddress)
+	}()
+	time.Sleep(5 * time.Millisecond)
+	url := "http://" + listenAddress + "/data.txt"
+
+	check := func(rangeHeader string, expectedStatus int) {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			panic(err)
+		}
+		req.Header.Add("Range", rangeHeader)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			panic(err)
+		}
+		if resp.StatusCode != expectedStatus {
+			t.Errorf("unexpected HTTP status code for range '%s'. Expected %d got %d", rangeHeader, expectedStatus, resp.StatusCode)
+		}
+		if expectedStatus == 416 {
+			if value := resp.Header.Get("Content-Range"); value != "bytes */500" {
+				t.Errorf("incorrect value of Content-Range header. Expected 'bytes */500' got '%s'", value)
+			}
+		}
+	}
+
+	check("bytes=0-99,100-199,200-299", 206)
+	check("bytes=0-9,10-19,20-29,30-39", 416)
+	check("bytes=0-299", 206)
+	check("bytes=0-,0-", 416)
+	check("bytes=-301", 416)
+}
+
+func TestRangeSingleHeadersAndCookies(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := router.ServeHTTPRange(router.ServeHTTPRangeOptions{
+		Headers: map[string]string{
+			"Content-Disposition": "attachment; filename=data.txt",
+			"ETag":                `"abc"`,
+		},
+		Cookies:     []http.Cookie{{Name: "session", Value: "1"}},
+		Ranges:      []router.ByteRange{{Start: 0, End: 9}},
+		Reader:      bytes.NewReader(sampleData),
+		TotalLength: uint64(len(sampleData)),
+		MIMEType:    "text/plain",
+		Writer:      w,
+	})
+	if err != nil {
+		t.Fatalf("Error serving range: %s", err.Error())
+	}
+
+	resp := w.Result()
+	if resp.StatusCode != 206 {
+		t.Fatalf("unexpected HTTP status code. Expected %d got %d", 206, resp.StatusCode)
+	}
+	if value := resp.Header.Get("Content-Disposition"); value != "attachment; filename=data.txt" {
+		t.Errorf("incorrect or missing Content-Disposition header. Got '%s'", value)
+	}
+	if value := resp.Header.Get("ETag"); value != `"abc"` {
+		t.Errorf("incorrect or missing ETag header. Got '%s'", value)
+	}
+	if cookies := resp.Cookies(); len(cookies) != 1 || cookies[0].Name != "session" {
+		t.Errorf("missing cookie on range response")
+	}
+}
@@ -2,10 +2,13 @@ package router_test
 
 import (
 	"bytes"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/ecnepsnai/web/router"
@@ -127,12 +130,12 @@ func TestRouterStaticPathTransversal(t *testing.T) {
 
 	testStaticRequest(t, "GET", "http://"+listenAddress+"/static/../../../../../../index.html", 200, "text/html")
 	testStaticRequest(t, "GET", "http://"+listenAddress+"/static/../../../../../../etc/password", 404, "text/plain; charset=utf-8")
-	testStaticRequest(t, "GET", "http://"+listenAddress+"/static/%2e/%2e/%2e/%2e/%2e/%2e/etc/password", 404, "text/plain; charset=utf-8")
+	testStaticRequest(t, "GET", "http://"+listenAddress+"/static/%2e/%2e/%2e/%2e/%2e/%2e/etc/password", 400, "text/plain; charset=utf-8")
 	testStaticRequest(t, "GET", "http://"+listenAddress+"/static/../etc/password", 404, "text/plain; charset=utf-8")
-	testStaticRequest(t, "GET", "http://"+listenAddress+"/static/..\\etc/password", 404, "text/plain; charset=utf-8")
-	testStaticRequest(t, "GET", "http://"+listenAddress+"/static/..\\/etc/password", 404, "text/plain; charset=utf-8")
-	testStaticRequest(t, "GET", "http://"+listenAddress+"/static/%2e%2e%2fetc/password", 404, "text/plain; charset=utf-8")
-	testStaticRequest(t, "GET", "http://"+listenAddress+"/static/%252e%252e%252fetc/password", 404, "text/plain; charset=utf-8")
+	testStaticRequest(t, "GET", "http://"+listenAddress+"/static/..\\etc/password", 400, "text/plain; charset=utf-8")
+	testStaticRequest(t, "GET", "http://"+listenAddress+"/static/..\\/etc/password", 400, "text/plain; charset=utf-8")
+	testStaticRequest(t, "GET", "http://"+listenAddress+"/static/%2e%2e%2fetc/password", 400, "text/plain; charset=utf-8")
+	testStaticRequest(t, "GET", "http://"+listenAddress+"/static/%252e%252e%252fetc/password", 400, "text/plain; charset=utf-8")
 	testStaticRequest(t, "GET", "http://"+listenAddress+"/static/%c0%ae%c0%ae%c0%afetc/password", 404, "text/plain; charset=utf-8")
 	testStaticRequest(t, "GET", "http://"+listenAddress+"/static/..././etc/password", 404, "text/plain; charset=utf-8")
 	testStaticRequest(t, "GET", "http://"+listenAddress+"/static/...\\.\\etc/password", 404, "text/plain; charset=utf-8")
@@ -387,3 +390,195 @@ func TestRouterStaticCacheControlHeader(t *testing.T) {
 		t.Errorf("Unexpected cache control for URL '%s'.", url)
 	}
 }
+
+func TestRouterStaticWithOptions(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	os.WriteFile(path.Join(dir, "index.html"), []byte("index"), os.ModePerm)
+	os.WriteFile(path.Join(dir, "app.js"), []byte("app"), os.ModePerm)
+	os.WriteFile(path.Join(dir, ".env"), []byte("secret"), os.ModePerm)
+	os.Mkdir(path.Join(dir, ".git"), os.ModePerm)
+	os.WriteFile(path.Join(dir, ".git", "config"), []byte("secret"), os.ModePerm)
+	os.Mkdir(path.Join(dir, "files"), os.ModePerm)
+	os.WriteFile(path.Join(dir, "files", "a.txt"), []byte("a"), os.ModePerm)
+
+	listenAddress := getListenAddress()
+
+	server := router.New()
+	server.ServeFilesWithOptions(dir, "/", router.ServeFilesOptions{
+		IndexFallback: true,
+		CacheControl: map[string]string{
+			".html": "no-cache",
+			".js":   "max-age=31536000, immutable",
+		},
+		DenyDotfiles: true,
+	})
+	go func() {
+		server.ListenAndServe(listenAddress)
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	check := func(url string, expectedStatus int, expectedBody, expectedCacheControl string) {
+		resp, err := http.Get("http://" + listenAddress + url)
+		if err != nil {
+			t.Fatalf("Network error: %s", err.Error())
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != expectedStatus {
+			t.Errorf("Unexpected status code for URL '%s'. Expected %d got %d", url, expectedStatus, resp.StatusCode)
+		}
+		if expectedStatus != 200 {
+			return
+		}
+		if string(body) != expectedBody {
+			t.Errorf("Unexpected body for URL '%s'. Expected '%s' got '%s'", url, expectedBody, body)
+		}
+		if cacheControl := resp.Header.Get("Cache-Control"); expectedCacheControl != "" && cacheControl != expectedCacheControl {
+			t.Errorf("Unexpected cache control for URL '%s'. Expected '%s' got '%s'", url, expectedCacheControl, cacheControl)
+		}
+	}
+
+	check("/", 200, "index", "no-cache")
+	check("/app.js", 200, "app", "max-age=31536000, immutable")
+	check("/users/1/edit", 200, "index", "no-cache")
+	check("/files/", 200, "index", "no-cache")
+	check("/files/a.txt", 200, "a", "")
+	check("/.env", 404, "", "")
+	check("/.git/config", 404, "", "")
+}
+
+func TestRouterServeFS(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"index.html":    &fstest.MapFile{Data: []byte("index")},
+		"js/app.js":     &fstest.MapFile{Data: []byte("0123456789")},
+		"css/style.css": &fstest.MapFile{Data: []byte("body{}"), ModTime: time.Now().Add(-time.Hour)},
+	}
+
+	listenAddress := getListenAddress()
+
+	server := router.New()
+	server.ServeFS(fsys, "/assets/", router.ServeFilesOptions{})
+	go func() {
+		server.ListenAndServe(listenAddress)
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	testStaticRequest(t, "GET", "http://"+listenAddress+"/assets/", 200, "text/html")
+	testStaticRequest(t, "GET", "http://"+listenAddress+"/assets/js/app.js", 200, "text/javascript")
+	testStaticRequest(t, "HEAD", "http://"+listenAddress+"/assets/css/style.css", 200, "text/css")
+	testURL(t, "GET", "http://"+listenAddress+"/assets/../router.go", 404)
+
+	req, _ := http.NewRequest("GET", "http://"+listenAddress+"/assets/js/app.js", nil)
+	req.Header.Set("Range", "bytes=2-5")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 206 {
+		t.Errorf("Unexpected status code for range request. Expected %d got %d", 206, resp.StatusCode)
+	}
+	if string(body) != "2345" {
+		t.Errorf("Unexpected body for range request. Expected '%s' got '%s'", "2345", body)
+	}
+
+	// Files without a modification time must always be sent
+	req, _ = http.NewRequest("GET", "http://"+listenAddress+"/assets/js/app.js", nil)
+	req.Header.Set("If-Modified-Since", "Mon, 02 Jan 2006 15:04:05 GMT")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	body, _ = io.ReadAll(resp.Body)
+	if string(body) != "0123456789" {
+		t.Errorf("Unexpected body. Expected '%s' got '%s'", "0123456789", body)
+	}
+	if resp.Header.Get("Last-Modified") != "" {
+		t.Errorf("Unexpected Last-Modified header for file without modification time")
+	}
+}
+
+func TestRouterStaticPrecompressed(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"app.js":       &fstest.MapFile{Data: []byte("plain")},
+		"app.js.br":    &fstest.MapFile{Data: []byte("brotli")},
+		"app.js.gz":    &fstest.MapFile{Data: []byte("gzip")},
+		"style.css":    &fstest.MapFile{Data: []byte("plain")},
+		"style.css.gz": &fstest.MapFile{Data: []byte("gzip")},
+	}
+
+	listenAddress := getListenAddress()
+
+	server := router.New()
+	server.ServeFS(fsys, "/assets/", router.ServeFilesOptions{Precompressed: true})
+	go func() {
+		server.ListenAndServe(listenAddress)
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	check := func(file, acceptEncoding, expectedEncoding, expectedBody, expectedMime string) {
+		req, _ := http.NewRequest("GET", "http://"+listenAddress+"/assets/"+file, nil)
+		// Setting the header prevents the client from transparently decompressing the response
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Network error: %s", err.Error())
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if encoding := resp.Header.Get("Content-Encoding"); encoding != expectedEncoding {
+			t.Errorf("Unexpected content encoding for '%s' accepting '%s'. Expected '%s' got '%s'", file, acceptEncoding, expectedEncoding, encoding)
+		}
+		if string(body) != expectedBody {
+			t.Errorf("Unexpected body for '%s' accepting '%s'. Expected '%s' got '%s'", file, acceptEncoding, expectedBody, body)
+		}
+		if mime := resp.Header.Get("Content-Type"); mime != expectedMime {
+			t.Errorf("Unexpected content type for '%s'. Expected '%s' got '%s'", file, expectedMime, mime)
+		}
+		if resp.Header.Get("Vary") != "Accept-Encoding" {
+			t.Errorf("Missing Vary header for '%s'", file)
+		}
+	}
+
+	check("app.js", "gzip, deflate, br, zstd", "br", "brotli", "text/javascript")
+	check("app.js", "gzip, zstd", "gzip", "gzip", "text/javascript")
+	check("app.js", "br;q=0, *", "gzip", "gzip", "text/javascript")
+	check("app.js", "identity", "", "plain", "text/javascript")
+	check("style.css", "br, gzip;q=0.5", "gzip", "gzip", "text/css")
+	check("style.css", "gzip;q=0", "", "plain", "text/css")
+}
+
+func TestRouterServeFilesRangeCacheControl(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	os.WriteFile(path.Join(dir, "video.mp4"), sampleData, os.ModePerm)
+
+	server := router.New()
+	server.SetDefaultHeaders(map[string]string{"Cache-Control": "max-age=60"})
+	server.ServeFilesWithOptions(dir, "/", router.ServeFilesOptions{
+		CacheControl: map[string]string{
+			".mp4": "no-store",
+		},
+	})
+
+	for _, ranges := range []string{"bytes=0-99", "bytes=0-99,200-299"} {
+		req := httptest.NewRequest("GET", "/video.mp4", nil)
+		req.Header.Set("Range", ranges)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+
+		resp := w.Result()
+		if resp.StatusCode != 206 {
+			t.Fatalf("Unexpected status code for range '%s'. Expected %d got %d", ranges, 206, resp.StatusCode)
+		}
+		if values := resp.Header.Values("Cache-Control"); len(values) != 1 || values[0] != "no-store" {
+			t.Errorf("Unexpected cache control for range '%s'. Expected '%s' got %v", ranges, "no-store", values)
+		}
+	}
+}
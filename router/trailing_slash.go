@@ -0,0 +1,59 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+)
+
+// TrailingSlashPolicy describes how the router handles requests for a path that only differs from a registered path by
+// a trailing slash, such as a request for "/users/" when only "/users" is registered.
+type TrailingSlashPolicy int
+
+const (
+	// TrailingSlashStrict treats paths with and without a trailing slash as distinct. Requests that only match with or
+	// without the trailing slash are not found. This is the default.
+	TrailingSlashStrict TrailingSlashPolicy = iota
+	// TrailingSlashRedirect redirects requests that only match with or without the trailing slash to the registered
+	// path, using a "301 Moved Permanently" for GET and HEAD requests and a "308 Permanent Redirect" otherwise.
+	TrailingSlashRedirect
+	// TrailingSlashStrip serves requests that only match with or without the trailing slash with the handle of the
+	// registered path, without redirecting the client.
+	TrailingSlashStrip
+)
+
+// SetTrailingSlashPolicy will set how requests for a path that only differs from a registered path by a trailing slash
+// are handled. Paths that are registered both with and without the trailing slash are always handled by their own
+// handle.
+func (s *Server) SetTrailingSlashPolicy(policy TrailingSlashPolicy) {
+	s.impl.Lock.Lock()
+	defer s.impl.Lock.Unlock()
+	s.impl.TrailingSlashPolicy = policy
+}
+
+// toggleTrailingSlash returns the path with its trailing slash added or removed, or an empty string if there is no
+// alternate path
+func toggleTrailingSlash(path string) string {
+	if path == "/" {
+		return ""
+	}
+	alternate := path + "/"
+	if strings.HasSuffix(path, "/") {
+		alternate = strings.TrimSuffix(path, "/")
+	}
+	// A path beginning with two slashes would be treated as a protocol-relative URL by clients
+	if strings.HasPrefix(alternate, "//") {
+		return ""
+	}
+	return alternate
+}
+
+func redirectTrailingSlash(w http.ResponseWriter, r *http.Request, path string) {
+	status := http.StatusPermanentRedirect
+	if r.Method == "GET" || r.Method == "HEAD" {
+		status = http.StatusMovedPermanently
+	}
+	location := *r.URL
+	location.Path = path
+	location.RawPath = ""
+	http.Redirect(w, r, location.RequestURI(), status)
+}
@@ -0,0 +1,147 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+)
+
+type selfTestKey struct{}
+
+// isSelfTest returns true if the request was made by [web.Server.SelfTest]
+func isSelfTest(r *http.Request) bool {
+	selfTest, _ := r.Context().Value(selfTestKey{}).(bool)
+	return selfTest
+}
+
+// isSynthetic returns true if the request was made by the server itself, such as by [web.Server.SelfTest] or
+// [web.Server.WarmCache]. Synthetic requests are not banned, rate limited, rejected for maintenance, or recorded in
+// metrics.
+func isSynthetic(r *http.Request) bool {
+	return isSelfTest(r) || cacheWarming(r) != nil
+}
+
+// selfTestHandled writes an empty response and returns true if the request was made by [web.Server.SelfTest], in
+// which case the handle must not be called
+func selfTestHandled(w http.ResponseWriter, r *http.Request) bool {
+	if !isSelfTest(r) {
+		return false
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+type selfTestRoute struct {
+	method  string
+	path    string
+	headers map[string]string
+	// A problem with the configuration of the route found without making a request
+	problem string
+}
+
+// SelfTest checks every API, HTTP, HTTPEasy, and Socket route registered on the server by sending it a synthetic
+// request, such as before the server starts accepting traffic. Each request passes through the router and the
+// pre-handle pipeline of the route, including the PreHandle and AuthenticateMethod, but the handle itself is never
+// called. Requests are not rate limited and are not included in the metrics of the server. Parameters in the path of a
+// route are replaced with "selftest", or a valid value for parameters with a type.
+//
+// Routes fail the test if the request is not routed to a handle, or if the pipeline panics or responds with a server
+// error. Unauthenticated and forbidden responses are expected, as the synthetic requests have no credentials. Returns
+// an error describing every route that failed, or nil.
+func (s *Server) SelfTest() error {
+	routes := s.selfTestRoutes()
+	failures := []string{}
+	for _, route := range routes {
+		if err := s.selfTestRoute(route); err != nil {
+			log.PError("Route failed self test", map[string]interface{}{
+				"method": route.method,
+				"path":   route.path,
+				"error":  err.Error(),
+			})
+			failures = append(failures, fmt.Sprintf("%s %s: %s", route.method, route.path, err.Error()))
+			continue
+		}
+		log.PDebug("Route passed self test", map[string]interface{}{
+			"method": route.method,
+			"path":   route.path,
+		})
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%d of %d routes failed self test: %s", len(failures), len(routes), strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// selfTestRoutes returns the routes registered on the server, sorted by path and method
+func (s *Server) selfTestRoutes() []selfTestRoute {
+	s.variants.lock.RLock()
+	defer s.variants.lock.RUnlock()
+
+	routes := make([]selfTestRoute, 0, len(s.variants.routes))
+	for _, route := range s.variants.routes {
+		testRoute := selfTestRoute{method: route.method, path: route.path}
+		if route.fallback == nil && len(route.variants) > 0 {
+			// Requests without the headers of a variant are not routed to any handle
+			testRoute.headers = route.variants[0].headers
+			if len(testRoute.headers) == 0 && route.weight < 100 {
+				testRoute.problem = fmt.Sprintf("weighted variants only serve %d%% of requests and there is no handle for the remainder", route.weight)
+			}
+		}
+		routes = append(routes, testRoute)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].path == routes[j].path {
+			return routes[i].method < routes[j].method
+		}
+		return routes[i].path < routes[j].path
+	})
+	return routes
+}
+
+// selfTestParameter returns the value used in place of a parameter of the given type in the path of a route
+func selfTestParameter(parameterType string) string {
+	switch parameterType {
+	case "int", "uint", "float":
+		return "1"
+	case "bool":
+		return "true"
+	case "uuid":
+		return "00000000-0000-0000-0000-000000000000"
+	}
+	return "selftest"
+}
+
+func (s *Server) selfTestRoute(route selfTestRoute) error {
+	if route.problem != "" {
+		return fmt.Errorf("%s", route.problem)
+	}
+
+	segments := strings.Split(route.path, "/")
+	for i, segment := range segments {
+		if kind, parameterType := parameterSegment(segment); kind != 0 {
+			segments[i] = selfTestParameter(parameterType)
+		}
+	}
+	r := httptest.NewRequest(route.method, strings.Join(segments, "/"), nil)
+	r = r.WithContext(context.WithValue(r.Context(), selfTestKey{}, true))
+	for name, value := range route.headers {
+		if value == "*" {
+			value = "selftest"
+		}
+		r.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, r)
+
+	switch {
+	case w.Code == http.StatusNotFound || w.Code == http.StatusMethodNotAllowed:
+		return fmt.Errorf("request was not routed to the handle (status %d)", w.Code)
+	case w.Code >= 500:
+		return fmt.Errorf("server error (status %d)", w.Code)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package web_test
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestSelfTest(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	var calls int32
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		atomic.AddInt32(&calls, 1)
+		return true, nil, nil
+	}
+	server.API.GET("/users/:id", handle, web.HandleOptions{})
+	server.API.DELETE("/users/:id", handle, web.HandleOptions{
+		AuthenticateMethod: func(request *http.Request) interface{} {
+			return nil
+		},
+	})
+	server.HTTP.GET("/files/*path", func(w http.ResponseWriter, r web.Request) {
+		atomic.AddInt32(&calls, 1)
+	}, web.HandleOptions{})
+	server.API.GET("/beta", handle, web.HandleOptions{MatchHeaders: map[string]string{"X-Beta": "*"}})
+
+	if err := server.SelfTest(); err != nil {
+		t.Errorf("Unexpected self test error: %s", err.Error())
+	}
+	if calls != 0 {
+		t.Errorf("Handles were called during self test")
+	}
+	if stats := server.RejectedRequests(); stats.Total != 0 {
+		t.Errorf("Self test requests were counted as rejected")
+	}
+
+	server.API.GET("/broken", handle, web.HandleOptions{
+		AuthenticateMethod: func(request *http.Request) interface{} {
+			panic("database not configured")
+		},
+	})
+	server.API.POST("/pre", handle, web.HandleOptions{
+		PreHandle: func(w http.ResponseWriter, request *http.Request) error {
+			w.WriteHeader(503)
+			return web.CommonErrors.ServiceUnavailable
+		},
+	})
+	server.API.GET("/canary", handle, web.HandleOptions{Weight: 10, Variant: "canary"})
+
+	err := server.SelfTest()
+	if err == nil {
+		t.Fatalf("No error for misconfigured routes")
+	}
+	for _, route := range []string{"GET /broken", "POST /pre", "GET /canary"} {
+		if !strings.Contains(err.Error(), route) {
+			t.Errorf("Self test error does not include route '%s': %s", route, err.Error())
+		}
+	}
+	if strings.Contains(err.Error(), "/users") {
+		t.Errorf("Self test error includes a valid route: %s", err.Error())
+	}
+}
@@ -1,8 +1,13 @@
 package web
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"html/template"
 	"net"
 	"net/http"
+	"os"
 	"sync"
 	"time"
 
@@ -28,6 +33,8 @@ type Server struct {
 	HTTPEasy HTTPEasy
 	// The HTTP server. HTTP handles are exposed to the raw http request and response writers.
 	HTTP HTTP
+	// The health checks of the server, which can be exposed with '/healthz' and '/readyz' handles.
+	Health Health
 	// The handler called when a request that does not match a registered path occurs. Defaults to a plain
 	// HTTP 404 with "Not found" as the body.
 	NotFoundHandler func(w http.ResponseWriter, r *http.Request)
@@ -40,65 +47,288 @@ type Server struct {
 	// Additional options for the server
 	Options ServerOptions
 
-	router       *router.Server
-	listener     net.Listener
-	shuttingDown bool
-	limits       map[string]*rate.Limiter
-	limitLock    *sync.Mutex
+	router         *router.Server
+	listener       net.Listener
+	baseListener   net.Listener
+	shuttingDown   bool
+	limits         map[string]*rate.Limiter
+	limitLock      *sync.Mutex
+	bans           map[string]time.Time
+	banLock        *sync.Mutex
+	jobs           JobStore
+	metrics        *metricsStore
+	templates      *templateRegistry
+	templateFuncs  template.FuncMap
+	cache          *MemoryCacheStore
+	state          *int32
+	health         *healthRegistry
+	variants       *variantRegistry
+	dedup          *dedupRegistry
+	rejections     *rejectionStore
+	configLock     *sync.RWMutex
+	requestLogs    *uint64
+	sockets        *socketRegistry
+	pool           *workerPool
+	poolOnce       *sync.Once
+	trustedProxies *networkCache
+	traceSources   *networkCache
+	exemptNetworks *networkCache
+	proxies        *proxyMetricsStore
 }
 
 type ServerOptions struct {
 	// Specify the maximum number of requests any given client IP address can make per second. Requests that are rate
 	// limited will call the RateLimitedHandler, which you can override to customize the response.
-	// Setting this to 0 disables rate limiting.
+	// Setting this to 0 disables rate limiting, except for keys from RateLimitKey that have their own limit.
 	MaxRequestsPerSecond int
+	// Optional list of CIDR ranges or IP addresses of clients that are never rate limited, such as internal monitoring
+	// systems. The address of the client is determined with the TrustedProxies option.
+	RateLimitExemptNetworks []string
+	// Optional method called for each request subject to rate limiting, before it counts towards the limit. If it
+	// returns true then the request is not rate limited and does not use up the limit of its client, such as for an
+	// administrator. Unless RateLimitKey is set, rate limiting happens before the AuthenticateMethod of the handle is
+	// called, so this method must identify the user from the request itself.
+	RateLimitExempt func(r *http.Request) bool
+	// Optional method that returns the key identifying the client of a request for rate limiting, and the maximum
+	// number of requests per second for that key, such as the ID of the user from the user data and a limit for their
+	// plan. Each key has its own limit, so that users sharing an IP address are not limited together. Return an empty
+	// key to limit the request by the IP address of the client with MaxRequestsPerSecond, and a limit of 0 to use
+	// MaxRequestsPerSecond for the key.
+	//
+	// For handles with an AuthenticateMethod, rate limiting happens after the request is authenticated and userData
+	// is the value returned by the AuthenticateMethod, or nil if the request was not authenticated. For other handles
+	// userData is always nil.
+	RateLimitKey func(request *http.Request, userData interface{}) (key string, limit int)
+	// The amount of time that clients who request a route registered with [web.Server.Honeypot] are banned for.
+	// Defaults to 24 hours.
+	HoneypotBanDuration time.Duration
+	// Optional challenge, such as a CAPTCHA or proof-of-work puzzle, sent to clients that are rate limited in place of
+	// the RateLimitedHandler. Clients that solve the challenge are not rate limited.
+	Challenger Challenger
 	// The level to use when logging out HTTP requests. Maps to github.com/ecnepsnai/logtic levels. Defaults to Debug.
 	RequestLogLevel logtic.LogLevel
+	// Log only one out of every LogSampling successful requests to API, HTTP, and HTTPEasy handles, such as on busy
+	// servers where logging every request is too costly. Sampled log entries include the sample rate. Requests with an
+	// error status (4xx or 5xx) and slow requests are always logged. Defaults to 0, which logs every request.
+	LogSampling int
+	// Requests to API, HTTP, and HTTPEasy handles that take at least this long are always logged at the Warn level,
+	// regardless of the RequestLogLevel and LogSampling, and flagged as slow. Defaults to 0, which does not flag slow
+	// requests.
+	SlowRequestThreshold time.Duration
 	// If true then the server will not try to reply with chunked data for a HTTP range request
 	IgnoreHTTPRangeRequests bool
+	// The maximum number of ranges permitted in a single HTTP range request to a HTTPEasy handle. Requests with more
+	// ranges receive a "416 Range Not Satisfiable" response. The default value of 0 has no limit. Use
+	// [web.StaticOptions] to limit ranges for static files.
+	MaxHTTPRanges int
+	// The maximum total number of bytes, across all ranges, permitted in a single HTTP range request to a HTTPEasy
+	// handle. Overlapping ranges are counted each time they are requested. The default value of 0 has no limit.
+	MaxHTTPRangeBytes uint64
+	// The store used to track the state of background jobs started by [web.API.Job]. Defaults to an in-memory store.
+	JobStore JobStore
+	// The store used for responses cached with the Cache handle option. Defaults to an in-memory store.
+	CacheStore CacheStore
+	// The encoder used for all JSON responses, including API responses and errors. Defaults to [web.StandardJSON],
+	// which uses encoding/json.
+	JSONEncoder JSONEncoder
+	// The decoder used for all JSON requests, such as with [web.Request.DecodeJSON]. Defaults to [web.StandardJSON],
+	// which uses encoding/json.
+	JSONDecoder JSONDecoder
+	// Optional codecs by media type, such as "application/xml": [web.StandardXML], used to encode the data of API
+	// responses for clients that prefer that media type in their 'Accept' header. Only the data, or the error, of the
+	// response is encoded, without the [web.JSONResponse] wrapper. JSON is used if the client prefers it or does not
+	// accept any of the registered media types. Streamed responses are always JSON.
+	Codecs map[string]Codec
+	// The network to listen on when the server was created with web.New(). Use "tcp4" to listen on IPv4 only, or
+	// "tcp6" to listen on IPv6 only. Defaults to "tcp", which listens on both where the bind address allows it.
+	ListenNetwork string
+	// Optional name of a network interface to listen on, such as "eth0". When set the host of the bind address is
+	// ignored and the server listens on the first address of the interface matching the ListenNetwork, using the port
+	// from the bind address. Link-local addresses are not used.
+	BindInterface string
+	// The number of additional attempts to make to listen on the bind address if it is already in use, such as when a
+	// previous instance of the application is still shutting down. Defaults to 0, which does not retry.
+	ListenRetries int
+	// The amount of time to wait before the first retry to listen on the bind address. The delay doubles after each
+	// attempt. Defaults to 250ms.
+	ListenRetryDelay time.Duration
+	// Optional TLS configuration. When set, all connections to the server must use TLS. The configuration must include
+	// at least one certificate or a GetCertificate method.
+	TLSConfig *tls.Config
+	// Optional pool of certificate authorities used to verify the certificates of clients connecting over TLS (mutual
+	// TLS). Clients may present a certificate signed by one of these authorities, which is available to handles and
+	// AuthenticateMethods with [web.ClientCertificate], such as with [web.ClientCertificateAuth]. Connections with a
+	// certificate that can't be verified are rejected. Only used when TLSConfig is set, and ignored if the ClientAuth
+	// of the TLSConfig is already set.
+	ClientCAs *x509.CertPool
+	// If true then clients connecting over TLS must present a certificate signed by one of the ClientCAs, and
+	// connections without one are rejected during the handshake.
+	RequireClientCertificate bool
+	// The period between TCP keep-alive probes for accepted connections. Defaults to 0, which uses the operating
+	// system default. A negative value disables keep-alive probes.
+	TCPKeepAlive time.Duration
+	// If true then Nagle's algorithm is enabled on accepted TCP connections, which may reduce the number of packets
+	// sent at the cost of latency. By default Go disables Nagle's algorithm (TCP_NODELAY).
+	DisableTCPNoDelay bool
+	// The maximum number of connections the server will have open at once. Once reached, new connections wait in the
+	// operating systems accept queue until an existing connection is closed. Defaults to 0, which has no limit.
+	MaxConnections int
+	// If true then HTTP/2 will not be offered to clients connecting over TLS. By default HTTP/2 is negotiated with
+	// clients that support it when TLSConfig is set.
+	DisableHTTP2 bool
+	// If true then the server will also accept HTTP/2 connections without TLS (h2c) from clients with prior knowledge
+	// of HTTP/2, such as gRPC-aware load balancers. HTTP/1.1 requests continue to be accepted.
+	EnableH2C bool
+	// Optional value of the 'Alt-Svc' header included in responses from registered handles that were not sent over
+	// HTTP/3, advertising an alternative service to clients, such as `h3=":443"; ma=2592000`. This is set
+	// automatically by the github.com/ecnepsnai/web/http3 package, which serves the routes of the server over HTTP/3.
+	AltSvc string
+	// Optional list of CIDR ranges or IP addresses of proxies that are trusted to provide the real address of the client
+	// with the 'X-Real-IP', 'X-Forwarded-For', or 'CF-Connecting-IP' headers, such as "10.0.0.0/8". When set, these
+	// headers are ignored unless the connection comes from a trusted proxy, and rate limiting and request logs use the
+	// address of the client. Defaults to nil, which trusts these headers from any connection. The list is only parsed
+	// again when it is replaced, so assign a new list rather than modifying its elements.
+	TrustedProxies []string
+	// The name of a request header, such as "X-Trace", that enables tracing for a single request from one of the
+	// TraceSources when present with any value. Traced requests are logged in detail at the Info level regardless of
+	// the RequestLogLevel, and the 'Server-Timing' response header includes a breakdown of the time spent handling the
+	// request. Defaults to "", which disables tracing.
+	TraceHeader string
+	// CIDR ranges or IP addresses of clients permitted to enable tracing with the TraceHeader. The address of the
+	// client is determined with the TrustedProxies option. Tracing is disabled if empty. The list is only parsed again
+	// when it is replaced, so assign a new list rather than modifying its elements.
+	TraceSources []string
+	// Optional tracer used to start a span for every request to a registered handle, such as an adapter for
+	// OpenTelemetry. Spans include the route, response status, and the authenticated user, and continue the trace from
+	// the W3C 'traceparent' header of the request. Defaults to nil, which disables distributed tracing.
+	Tracer Tracer
+	// Sample the CPU time and heap allocations of one out of every ExecutionSampling requests to each route, which are
+	// included in [web.Server.RouteMetrics]. Defaults to 0, which disables sampling.
+	ExecutionSampling int
+	// Optional method called after every request to a registered handle that was answered with a server error (5xx)
+	// status, such as to page or increment alert counters. The method is called after the response has been written.
+	OnServerError func(event ServerErrorEvent)
+	// Optional method called when a route with the SLO handle option starts or stops alerting, because it is using the
+	// error budget of one of its objectives faster than the AlertBurnRate of the objective. The method is called after
+	// the response that caused the change has been written.
+	OnSLOAlert func(status SLOStatus)
+	// Optional method called after every request to a registered handle, with the number of bytes transferred, such as
+	// to record transfer volume for capacity planning. The method is called after the response has been written, and
+	// must return quickly as it is called from the goroutine of the request.
+	OnRequest func(event RequestEvent)
+	// Optional method called for every request that the server rejects before it reaches a handle, such as
+	// unauthenticated, forbidden, rate limited, or oversized requests, and requests for unknown paths, for abuse
+	// analysis. These requests are also logged to the "HTTP Rejected" log source, separate from other requests, and
+	// counted by [web.Server.RejectedRequests]. The method must return quickly as it is called from the goroutine of the
+	// request.
+	OnRejectedRequest func(event RejectedRequestEvent)
+	// Optional method called with the value and stack trace of any panic recovered from an API, HTTP, HTTPEasy, or
+	// websocket handle, along with the request, such as to report the panic to an error tracking service. The
+	// PanicHandler handle option is used instead for handles that set it. The panic is always logged and the client
+	// receives a 500 response, regardless of this method.
+	PanicHandler func(recovered interface{}, stack []byte, request Request)
+	// Optional bounded pool of goroutines that run API, HTTP, and HTTPEasy handles, such as to cap the number of
+	// requests handled at once during a flood of connections. Requests wait in a queue while every worker is busy, and
+	// receive a "503 Service Unavailable" response once the queue is full. Use [web.Server.WorkerPoolStats] to monitor
+	// the pool. Websocket handles do not use the pool. Defaults to nil, which runs every handle as soon as its request
+	// arrives. Changes after the first request are ignored.
+	WorkerPool *WorkerPoolOptions
+	// The timeout applied to all API, HTTP, and HTTPEasy handles that do not specify their own Timeout in their
+	// [web.HandleOptions]. Defaults to 0, which has no timeout.
+	DefaultTimeout time.Duration
+	// The status sent to clients when a handle exceeds its timeout. Either http.StatusServiceUnavailable (503) or
+	// http.StatusGatewayTimeout (504). Defaults to 503.
+	TimeoutStatus int
+	// Optional method called when a handle that exceeded its timeout finally returns, such as to record handles that
+	// ignore the cancellation of their request context. Any reader returned by a HTTPEasy handle is closed, and any
+	// stream returned by an API handle is drained, before this is called.
+	OnAbandonedHandle func(r *http.Request, elapsed time.Duration)
+	// Optional method that returns the value written as the JSON body of error responses, in place of the default
+	// [web.JSONResponse] with the Error property set. Use this to match the error format expected by existing clients.
+	// The status of the response is always the Code of err. When set, the default not found, method not allowed,
+	// payload too large, and rate limited responses are also written as JSON using this method.
+	ErrorSerializer func(err *Error) interface{}
+	// Optional attributes applied to all cookies set by API and HTTPEasy handles, such as to mark every cookie as
+	// HttpOnly. Cookies with the "__Host-" or "__Secure-" name prefix are always made Secure, regardless of this option.
+	CookieDefaults *CookiePolicy
+	// Optional attributes for specific cookies, keyed by the cookie name, which are used in place of the CookieDefaults.
+	CookieOverrides map[string]CookiePolicy
+	// Optional headers added to every response from the server, including responses from API, HTTP, and HTTPEasy
+	// handles, static files, websocket upgrade failures, and requests for unknown paths, such as security headers from
+	// [web.SecurityHeaders]. Handles may replace these headers with their own value.
+	DefaultHeaders map[string]string
+	// The maximum amount of time health checks registered with [web.Health.AddCheck] have to complete. Defaults to 5
+	// seconds.
+	HealthCheckTimeout time.Duration
+	// The amount of time the server continues to accept new connections in lame duck mode when Shutdown is called,
+	// giving load balancers time to notice that the server is no longer ready. Defaults to 0.
+	ShutdownDelay time.Duration
+	// The close code sent to websocket connections when Shutdown is called. Defaults to 1001 (going away).
+	SocketShutdownCode int
+	// The close reason sent to websocket connections when Shutdown is called. Defaults to "server shutting down".
+	SocketShutdownReason string
+	// The amount of time socket handles have to return after their connection is sent a close message when Shutdown is
+	// called, after which any remaining connections are closed. Defaults to 5 seconds.
+	SocketShutdownGracePeriod time.Duration
+	// How requests for a path that only differs from a registered path by a trailing slash are handled. Defaults to
+	// [web.TrailingSlashStrict], which treats "/users" and "/users/" as distinct paths.
+	TrailingSlashPolicy TrailingSlashPolicy
+	// If true then requests to all handles, except those with the AllowDuringMaintenance handle option, receive a "503
+	// Service Unavailable" response, such as while a database migration is running. Use [web.Server.ApplyConfig] to
+	// change this once the server has started.
+	Maintenance bool
+	// Optional list of host names that clients may use to reach the server, such as "example.com". Names beginning with
+	// "*." match any subdomain. Requests to handles with any other 'Host' header receive a "421 Misdirected Request"
+	// response, which protects against DNS rebinding. The port of the host is ignored. Defaults to nil, which allows
+	// any host.
+	AllowedHosts []string
+	// Optional method called once the server is listening with the address it is bound to. This is useful when binding
+	// to port 0, where the operating system assigns the port.
+	OnListen func(address net.Addr)
 }
 
 // New create a new server object that will bind to the provided address. Does not accept incoming connections until
 // the server is started.
 // Bind address must be in the format of "address:port", such as "localhost:8080" or "0.0.0.0:8080".
 func New(bindAddress string) *Server {
-	httpRouter := router.New()
-	server := Server{
-		BindAddress: bindAddress,
-		Options: ServerOptions{
-			RequestLogLevel: logtic.LevelDebug,
-		},
-		router:    httpRouter,
-		limits:    map[string]*rate.Limiter{},
-		limitLock: &sync.Mutex{},
-	}
-	httpRouter.SetNotFoundHandle(server.notFoundHandle)
-	httpRouter.SetMethodNotAllowedHandle(server.methodNotAllowedHandle)
-	server.API = API{
-		server: &server,
-	}
-	server.HTTPEasy = HTTPEasy{
-		server: &server,
-	}
-	server.HTTP = HTTP{
-		server: &server,
-	}
-
-	return &server
+	return newServer(bindAddress, nil)
 }
 
 // NewListener creates a new server object that will use the given listener. Does not accept incoming connections until
 // the server is started.
 func NewListener(listener net.Listener) *Server {
+	return newServer("", listener)
+}
+
+func newServer(bindAddress string, listener net.Listener) *Server {
 	httpRouter := router.New()
 	server := Server{
+		BindAddress: bindAddress,
 		Options: ServerOptions{
 			RequestLogLevel: logtic.LevelDebug,
 		},
-		router:    httpRouter,
-		listener:  listener,
-		limits:    map[string]*rate.Limiter{},
-		limitLock: &sync.Mutex{},
+		router:         httpRouter,
+		listener:       listener,
+		limits:         map[string]*rate.Limiter{},
+		limitLock:      &sync.Mutex{},
+		bans:           map[string]time.Time{},
+		banLock:        &sync.Mutex{},
+		jobs:           NewMemoryJobStore(),
+		metrics:        newMetricsStore(),
+		state:          new(int32),
+		health:         newHealthRegistry(),
+		cache:          NewMemoryCacheStore(),
+		variants:       newVariantRegistry(),
+		dedup:          newDedupRegistry(),
+		rejections:     newRejectionStore(),
+		configLock:     &sync.RWMutex{},
+		requestLogs:    new(uint64),
+		sockets:        newSocketRegistry(),
+		poolOnce:       &sync.Once{},
+		trustedProxies: newNetworkCache(),
+		traceSources:   newNetworkCache(),
+		exemptNetworks: newNetworkCache(),
+		proxies:        newProxyMetricsStore(),
 	}
 	httpRouter.SetNotFoundHandle(server.notFoundHandle)
 	httpRouter.SetMethodNotAllowedHandle(server.methodNotAllowedHandle)
@@ -111,15 +341,26 @@ func NewListener(listener net.Listener) *Server {
 	server.HTTP = HTTP{
 		server: &server,
 	}
+	server.Health = Health{
+		server: &server,
+	}
 
 	return &server
 }
 
+// Handler returns a [http.Handler] that dispatches requests to the routes registered on the server, such as to serve
+// them with another HTTP server implementation like HTTP/3. Routes registered after this is called are included.
+func (s *Server) Handler() http.Handler {
+	s.router.SetTrailingSlashPolicy(s.Options.TrailingSlashPolicy.routerPolicy())
+	s.router.SetDefaultHeaders(s.Options.DefaultHeaders)
+	return s.router
+}
+
 // Start will start the web server and listen on the socket address. This method blocks.
 // If a server is stopped using the Stop() method, this returns no error.
 func (s *Server) Start() error {
 	if s.BindAddress != "" {
-		listener, err := net.Listen("tcp", s.BindAddress)
+		listener, err := s.listen()
 		if err != nil {
 			log.PError("Error listening on address", map[string]interface{}{
 				"listen_address": s.BindAddress,
@@ -128,13 +369,72 @@ func (s *Server) Start() error {
 			return err
 		}
 		s.listener = listener
-		s.ListenPort = uint16(listener.Addr().(*net.TCPAddr).Port)
+		if addr, ok := listener.Addr().(*net.TCPAddr); ok {
+			s.ListenPort = uint16(addr.Port)
+		}
 		log.PInfo("HTTP server listen", map[string]interface{}{
 			"listen_address": s.BindAddress,
 			"listen_port":    s.ListenPort,
 		})
 	}
-	if err := s.router.Serve(s.listener); err != nil {
+	return s.Serve(s.listener)
+}
+
+// ListenUnix will start the web server and listen on a unix domain socket at the given path. Any existing socket file
+// at the path is removed first. This method blocks.
+// If a server is stopped using the Stop() method, this returns no error.
+func (s *Server) ListenUnix(socketPath string) error {
+	if info, err := os.Stat(socketPath); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(socketPath)
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		log.PError("Error listening on unix socket", map[string]interface{}{
+			"socket_path": socketPath,
+			"error":       err.Error(),
+		})
+		return err
+	}
+	log.PInfo("HTTP server listen", map[string]interface{}{
+		"socket_path": socketPath,
+	})
+	return s.Serve(listener)
+}
+
+// Serve will start the web server and accept incoming connections on the given listener, such as one provided by
+// systemd socket activation. This method blocks.
+// If a server is stopped using the Stop() method, this returns no error.
+func (s *Server) Serve(listener net.Listener) error {
+	s.baseListener = listener
+	listener = s.tuneListener(listener)
+	s.listener = listener
+	s.router.SetProtocols(s.protocols())
+	s.router.SetTrailingSlashPolicy(s.Options.TrailingSlashPolicy.routerPolicy())
+	s.router.SetDefaultHeaders(s.Options.DefaultHeaders)
+	if len(s.Options.TrustedProxies) > 0 {
+		if networks := s.trustedProxies.get(s.Options.TrustedProxies); len(networks) != len(s.Options.TrustedProxies) {
+			log.PError("Invalid trusted proxy address", map[string]interface{}{
+				"trusted_proxies": s.Options.TrustedProxies,
+			})
+		}
+	}
+	if len(s.Options.RateLimitExemptNetworks) > 0 {
+		if networks := s.exemptNetworks.get(s.Options.RateLimitExemptNetworks); len(networks) != len(s.Options.RateLimitExemptNetworks) {
+			log.PError("Invalid rate limit exempt address", map[string]interface{}{
+				"rate_limit_exempt_networks": s.Options.RateLimitExemptNetworks,
+			})
+		}
+	}
+	s.shuttingDown = false
+	if addr, ok := listener.Addr().(*net.TCPAddr); ok {
+		s.ListenPort = uint16(addr.Port)
+	}
+	if s.Options.OnListen != nil {
+		s.Options.OnListen(listener.Addr())
+	}
+
+	if err := s.router.Serve(listener); err != nil {
 		if s.shuttingDown {
 			log.Info("HTTP server stopped")
 			return nil
@@ -144,6 +444,29 @@ func (s *Server) Start() error {
 	return nil
 }
 
+// Shutdown will gracefully stop the server. The server enters lame duck mode, failing its readiness check, and
+// continues to accept new connections for the ShutdownDelay. Websocket connections are then sent a close message with
+// the SocketShutdownCode and SocketShutdownReason, so that clients can reconnect to another server, and their handles
+// have the SocketShutdownGracePeriod to return before the connections are closed. Finally, it stops listening and
+// waits for active requests to complete, or for ctx to be done, in which case the error of ctx is returned. The
+// Start() method will return without an error after shutting down.
+//
+// Unlike Stop, a server that has been shut down can not be started again.
+func (s *Server) Shutdown(ctx context.Context) error {
+	log.Warn("Shutting down HTTP server")
+	s.LameDuck()
+	if s.Options.ShutdownDelay > 0 {
+		select {
+		case <-time.After(s.Options.ShutdownDelay):
+		case <-ctx.Done():
+		}
+	}
+	s.shuttingDown = true
+	s.closeSockets(ctx)
+	s.ListenPort = 0
+	return s.router.Shutdown(ctx)
+}
+
 // Stop will stop the server. The Start() method will return without an error after stopping.
 func (s *Server) Stop() {
 	log.Warn("Stopping HTTP server")
@@ -154,71 +477,137 @@ func (s *Server) Stop() {
 
 func (s *Server) notFoundHandle(w http.ResponseWriter, r *http.Request) {
 	log.PWrite(s.Options.RequestLogLevel, "HTTP Request", map[string]interface{}{
-		"remote_addr": RealRemoteAddr(r),
+		"remote_addr": s.realRemoteAddr(r),
 		"method":      r.Method,
 		"url":         r.URL,
 		"elapsed":     time.Duration(0).String(),
 		"status":      404,
 	})
+	s.reportRejected(r, RejectedNotFound, 404)
 	if s.NotFoundHandler != nil {
 		s.NotFoundHandler(w, r)
 		return
 	}
-	w.WriteHeader(404)
-	w.Write([]byte("Not found"))
+	s.writeError(w, CommonErrors.NotFound, "Not found")
 }
 
 func (s *Server) methodNotAllowedHandle(w http.ResponseWriter, r *http.Request) {
 	log.PWrite(s.Options.RequestLogLevel, "HTTP Request", map[string]interface{}{
-		"remote_addr": RealRemoteAddr(r),
+		"remote_addr": s.realRemoteAddr(r),
 		"method":      r.Method,
 		"url":         r.URL,
 		"elapsed":     time.Duration(0).String(),
 		"status":      405,
 	})
+	s.reportRejected(r, RejectedMethodNotAllowed, 405)
 	if s.MethodNotAllowedHandler != nil {
 		s.MethodNotAllowedHandler(w, r)
 		return
 	}
-	w.WriteHeader(405)
-	w.Write([]byte("Method not allowed"))
+	s.writeError(w, CommonErrors.MethodNotAllowed, "Method not allowed")
+}
+
+func (s *Server) isRateLimited(w http.ResponseWriter, r *http.Request, options HandleOptions) bool {
+	if s.Options.RateLimitKey != nil && options.AuthenticateMethod != nil {
+		// Checked by isUserRateLimited once the request has been authenticated
+		return false
+	}
+	return s.checkRateLimit(w, r, nil, options)
+}
+
+// isUserRateLimited returns true if the authenticated request is rate limited by the RateLimitKey of the server, in
+// which case the rate limited response has been written to w.
+func (s *Server) isUserRateLimited(w http.ResponseWriter, r *http.Request, userData interface{}, options HandleOptions) bool {
+	if s.Options.RateLimitKey == nil || options.AuthenticateMethod == nil {
+		return false
+	}
+	if isUserdataNil(userData) {
+		userData = nil
+	}
+	return s.checkRateLimit(w, r, userData, options)
 }
 
-func (s *Server) isRateLimited(w http.ResponseWriter, r *http.Request) bool {
+func (s *Server) checkRateLimit(w http.ResponseWriter, r *http.Request, userData interface{}, options HandleOptions) bool {
+	if options.DisableRateLimit || isSynthetic(r) {
+		return false
+	}
+
+	// Clients are limited by their IP address unless the RateLimitKey identifies them
+	config := s.runtimeConfig()
+	key := s.realRemoteAddr(r).String()
+	limit := config.MaxRequestsPerSecond
+	rateLimitKey := ""
+	if s.Options.RateLimitKey != nil {
+		var keyLimit int
+		rateLimitKey, keyLimit = s.Options.RateLimitKey(r, userData)
+		if rateLimitKey != "" {
+			key = "key\x00" + rateLimitKey
+			if keyLimit > 0 {
+				limit = keyLimit
+			}
+		}
+	}
+
 	// If rate limiting is not configured return a new limiter for each connection
-	if s.Options.MaxRequestsPerSecond == 0 {
+	if limit <= 0 {
 		return false
 	}
 
-	s.limitLock.Lock()
-	defer s.limitLock.Unlock()
+	if len(config.RateLimitExemptNetworks) > 0 && networksContain(s.exemptNetworks.get(config.RateLimitExemptNetworks), s.realRemoteAddr(r)) {
+		return false
+	}
+	// Exempt requests are checked first so that they don't use up the limit of other clients sharing the same key
+	if s.Options.RateLimitExempt != nil && s.Options.RateLimitExempt(r) {
+		return false
+	}
+	if s.Options.Challenger != nil && s.Options.Challenger.Verify(r) {
+		return false
+	}
 
-	sourceIP := RealRemoteAddr(r).String()
-	limiter := s.limits[sourceIP]
+	// Only the map of limiters is locked, limiters are safe to use concurrently and the RateLimitExempt and Challenger
+	// methods must not hold up other requests
+	s.limitLock.Lock()
+	limiter := s.limits[key]
 	if limiter == nil {
-		// Allow MaxRequestsPerSecond every 1 second
-		limiter = rate.NewLimiter(rate.Limit(s.Options.MaxRequestsPerSecond), s.Options.MaxRequestsPerSecond)
-		s.limits[sourceIP] = limiter
+		// Allow limit every 1 second
+		limiter = rate.NewLimiter(rate.Limit(limit), limit)
+		s.limits[key] = limiter
+	}
+	s.limitLock.Unlock()
+	if limiter.Burst() != limit {
+		// The limit for the key has changed, such as when a user changes plans
+		limiter.SetLimit(rate.Limit(limit))
+		limiter.SetBurst(limit)
+	}
+
+	cost := options.RateLimitCost
+	if cost <= 0 {
+		cost = 1
+	} else if cost > limit {
+		cost = limit
 	}
 
-	if !limiter.Allow() {
+	if !limiter.AllowN(time.Now(), cost) {
 		log.PWarn("Rate-limiting request", map[string]interface{}{
-			"remote_addr": RealRemoteAddr(r),
-			"method":      r.Method,
-			"url":         r.URL,
+			"remote_addr":    s.realRemoteAddr(r),
+			"method":         r.Method,
+			"url":            r.URL,
+			"rate_limit_key": rateLimitKey,
 		})
 		log.PWrite(s.Options.RequestLogLevel, "HTTP Request", map[string]interface{}{
-			"remote_addr": RealRemoteAddr(r),
+			"remote_addr": s.realRemoteAddr(r),
 			"method":      r.Method,
 			"url":         r.URL,
 			"elapsed":     time.Duration(0).String(),
 			"status":      429,
 		})
-		if s.RateLimitedHandler != nil {
+		s.reportRejected(r, RejectedRateLimited, 429)
+		if s.Options.Challenger != nil {
+			s.writeChallenge(w, r)
+		} else if s.RateLimitedHandler != nil {
 			s.RateLimitedHandler(w, r)
 		} else {
-			w.WriteHeader(429)
-			w.Write([]byte("Too many requests"))
+			s.writeError(w, CommonErrors.TooManyRequests, "Too many requests")
 		}
 		return true
 	}
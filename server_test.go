@@ -260,3 +260,126 @@ func TestMethodNotAllowed(t *testing.T) {
 		}
 	}()
 }
+
+func TestListenUnix(t *testing.T) {
+	t.Parallel()
+	socketPath := path.Join(t.TempDir(), "TestListenUnix")
+	server := web.New("")
+	server.API.GET("/", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}, web.HandleOptions{})
+
+	started := make(chan error)
+	go func() {
+		started <- server.ListenUnix(socketPath)
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	httpc := http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", socketPath)
+			},
+		},
+	}
+	resp, err := httpc.Get("http://unix/")
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("Unexpected status code. Expected %d got %d", 200, resp.StatusCode)
+	}
+
+	server.Stop()
+	if err := <-started; err != nil {
+		t.Fatalf("Unexpected error from stopped server: %s", err.Error())
+	}
+}
+
+func TestServe(t *testing.T) {
+	t.Parallel()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err.Error())
+	}
+	server := web.New("")
+	server.API.GET("/", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}, web.HandleOptions{})
+	go server.Serve(l)
+	time.Sleep(5 * time.Millisecond)
+
+	if server.ListenPort != uint16(l.Addr().(*net.TCPAddr).Port) {
+		t.Errorf("Unexpected listen port. Expected %d got %d", l.Addr().(*net.TCPAddr).Port, server.ListenPort)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/", server.ListenPort))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("Unexpected status code. Expected %d got %d", 200, resp.StatusCode)
+	}
+	server.Stop()
+}
+
+func TestShutdown(t *testing.T) {
+	t.Parallel()
+
+	server := web.New("127.0.0.1:0")
+	server.Options.ShutdownDelay = 50 * time.Millisecond
+	listening := make(chan net.Addr, 1)
+	server.Options.OnListen = func(address net.Addr) {
+		listening <- address
+	}
+	started := make(chan bool)
+	server.API.GET("/slow", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		started <- true
+		time.Sleep(100 * time.Millisecond)
+		return true, nil, nil
+	}, web.HandleOptions{})
+	server.ReadinessCheck("/ready")
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- server.Start()
+	}()
+	address := <-listening
+
+	slow := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(fmt.Sprintf("http://%s/slow", address.String()))
+		if err != nil {
+			slow <- 0
+			return
+		}
+		resp.Body.Close()
+		slow <- resp.StatusCode
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- server.Shutdown(context.Background())
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// Still accepting connections during the shutdown delay, but not ready
+	resp, err := http.Get(fmt.Sprintf("http://%s/ready", address.String()))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 503 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 503, resp.StatusCode)
+	}
+
+	if status := <-slow; status != 200 {
+		t.Errorf("Active request was not completed during shutdown, got status %d", status)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("Unexpected error shutting down: %s", err.Error())
+	}
+	if err := <-stopped; err != nil {
+		t.Errorf("Unexpected error from Start: %s", err.Error())
+	}
+}
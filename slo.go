@@ -0,0 +1,195 @@
+package web
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// The number of buckets the window of an SLO is divided into
+const sloBuckets = 60
+
+// SLO describes the service level objectives of a handle, measured over a rolling window. At least one of
+// Availability or Latency must be set.
+type SLO struct {
+	// The fraction of requests that must not be answered with a server error (5xx) status, such as 0.999. Requests
+	// aborted by the client are not counted. Defaults to 0, which has no availability objective.
+	Availability float64 `json:"availability"`
+	// The amount of time requests should complete within. Defaults to 0, which has no latency objective.
+	Latency time.Duration `json:"latency"`
+	// The fraction of requests that must complete within Latency. Defaults to 0.99.
+	LatencyTarget float64 `json:"latency_target"`
+	// The rolling window the objectives are measured over. Defaults to 1 hour.
+	Window time.Duration `json:"window"`
+	// The burn rate at or above which the route is alerting, where a burn rate of 1 uses the error budget exactly over
+	// the window. Defaults to 2.
+	AlertBurnRate float64 `json:"alert_burn_rate"`
+	// The minimum number of requests within the window before the route can alert, so that a single failed request to
+	// a quiet route doesn't alert. Defaults to 10.
+	MinRequests uint64 `json:"min_requests"`
+}
+
+// SLOStatus describes the current state of the service level objectives of a route
+type SLOStatus struct {
+	// The HTTP method of the route
+	Method string `json:"method"`
+	// The path of the route as it was registered, including any parameters
+	Path string `json:"path"`
+	// The name of the variant of the route, from the Variant handle option
+	Variant string `json:"variant,omitempty"`
+	// The objectives of the route
+	Objective SLO `json:"objective"`
+	// The number of requests within the window
+	Requests uint64 `json:"requests"`
+	// The number of requests within the window answered with a server error
+	Errors uint64 `json:"errors"`
+	// The number of requests within the window that took longer than the Latency objective
+	SlowRequests uint64 `json:"slow_requests"`
+	// The rate the error budget of the availability objective is being used, where 1 uses the budget exactly over the
+	// window. Always 0 if there is no availability objective.
+	ErrorBurnRate float64 `json:"error_burn_rate"`
+	// The rate the error budget of the latency objective is being used, where 1 uses the budget exactly over the
+	// window. Always 0 if there is no latency objective.
+	LatencyBurnRate float64 `json:"latency_burn_rate"`
+	// If either burn rate is at or above the AlertBurnRate of the objective
+	Alerting bool `json:"alerting"`
+}
+
+type sloBucket struct {
+	index        int64
+	requests     uint64
+	errors       uint64
+	slowRequests uint64
+}
+
+type sloTracker struct {
+	method    string
+	path      string
+	variant   string
+	objective SLO
+	width     time.Duration
+	buckets   [sloBuckets]sloBucket
+	alerting  bool
+	lock      *sync.Mutex
+}
+
+func newSLOTracker(method, path, variant string, objective SLO) *sloTracker {
+	if objective.LatencyTarget <= 0 {
+		objective.LatencyTarget = 0.99
+	}
+	if objective.Window <= 0 {
+		objective.Window = time.Hour
+	}
+	if objective.AlertBurnRate <= 0 {
+		objective.AlertBurnRate = 2
+	}
+	if objective.MinRequests == 0 {
+		objective.MinRequests = 10
+	}
+	width := objective.Window / sloBuckets
+	if width <= 0 {
+		width = 1
+	}
+	return &sloTracker{
+		method:    method,
+		path:      path,
+		variant:   variant,
+		objective: objective,
+		width:     width,
+		lock:      &sync.Mutex{},
+	}
+}
+
+// observe records a completed request, and returns true if the route started or stopped alerting
+func (t *sloTracker) observe(now time.Time, status int, elapsed time.Duration) (SLOStatus, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	index := now.UnixNano() / int64(t.width)
+	bucket := &t.buckets[index%sloBuckets]
+	if bucket.index != index {
+		*bucket = sloBucket{index: index}
+	}
+	bucket.requests++
+	if status >= 500 {
+		bucket.errors++
+	}
+	if t.objective.Latency > 0 && elapsed > t.objective.Latency {
+		bucket.slowRequests++
+	}
+
+	current := t.status(index)
+	changed := current.Alerting != t.alerting
+	t.alerting = current.Alerting
+	return current, changed
+}
+
+// current returns the status of the objectives at the given time
+func (t *sloTracker) current(now time.Time) SLOStatus {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.status(now.UnixNano() / int64(t.width))
+}
+
+// status returns the status of the objectives for the window ending with the bucket at index. The caller must hold the
+// lock.
+func (t *sloTracker) status(index int64) SLOStatus {
+	status := SLOStatus{
+		Method:    t.method,
+		Path:      t.path,
+		Variant:   t.variant,
+		Objective: t.objective,
+	}
+	for _, bucket := range t.buckets {
+		if bucket.index > index-sloBuckets && bucket.index <= index {
+			status.Requests += bucket.requests
+			status.Errors += bucket.errors
+			status.SlowRequests += bucket.slowRequests
+		}
+	}
+	if status.Requests == 0 {
+		return status
+	}
+
+	if t.objective.Availability > 0 && t.objective.Availability < 1 {
+		status.ErrorBurnRate = float64(status.Errors) / float64(status.Requests) / (1 - t.objective.Availability)
+	}
+	if t.objective.Latency > 0 && t.objective.LatencyTarget < 1 {
+		status.LatencyBurnRate = float64(status.SlowRequests) / float64(status.Requests) / (1 - t.objective.LatencyTarget)
+	}
+	status.Alerting = status.Requests >= t.objective.MinRequests &&
+		(status.ErrorBurnRate >= t.objective.AlertBurnRate || status.LatencyBurnRate >= t.objective.AlertBurnRate)
+	return status
+}
+
+// reportSLO logs a route that started or stopped alerting and calls the OnSLOAlert hook of the server
+func (s *Server) reportSLO(status SLOStatus) {
+	parameters := map[string]interface{}{
+		"method":            status.Method,
+		"route":             status.Path,
+		"variant":           status.Variant,
+		"requests":          status.Requests,
+		"error_burn_rate":   fmt.Sprintf("%.2f", status.ErrorBurnRate),
+		"latency_burn_rate": fmt.Sprintf("%.2f", status.LatencyBurnRate),
+	}
+	if status.Alerting {
+		log.PWarn("Route is burning its SLO error budget", parameters)
+	} else {
+		log.PInfo("Route is no longer burning its SLO error budget", parameters)
+	}
+
+	if s.Options.OnSLOAlert == nil {
+		return
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			log.PError("Recovered from panic during SLO alert hook", map[string]interface{}{
+				"error": fmt.Sprintf("%v", p),
+				"route": status.Path,
+				"stack": string(debug.Stack()),
+			})
+		}
+	}()
+	s.Options.OnSLOAlert(status)
+}
@@ -0,0 +1,69 @@
+package web_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestSLO(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	server.HTTP.GET("/orders", func(w http.ResponseWriter, r web.Request) {
+		if r.HTTP.URL.Query().Get("fail") != "" {
+			w.WriteHeader(500)
+		}
+	}, web.HandleOptions{
+		SLO: &web.SLO{Availability: 0.9, MinRequests: 5},
+	})
+	server.HTTP.GET("/search", func(w http.ResponseWriter, r web.Request) {}, web.HandleOptions{
+		SLO: &web.SLO{Latency: time.Nanosecond, MinRequests: 2},
+	})
+	server.HTTP.GET("/users", func(w http.ResponseWriter, r web.Request) {}, web.HandleOptions{})
+
+	var alerts []web.SLOStatus
+	server.Options.OnSLOAlert = func(status web.SLOStatus) {
+		alerts = append(alerts, status)
+	}
+
+	for i := 0; i < 3; i++ {
+		server.Request("GET", "/orders", nil)
+	}
+	server.Request("GET", "/orders?fail=1", nil)
+	if len(alerts) != 0 {
+		t.Fatalf("Route alerting before reaching the minimum number of requests")
+	}
+	server.Request("GET", "/orders?fail=1", nil)
+	if len(alerts) != 1 || !alerts[0].Alerting || alerts[0].Path != "/orders" || alerts[0].Errors != 2 {
+		t.Fatalf("Unexpected SLO alerts %+v", alerts)
+	}
+	// An error rate of 40% uses a 10% error budget 4 times too fast
+	if alerts[0].ErrorBurnRate < 3.99 || alerts[0].ErrorBurnRate > 4.01 {
+		t.Errorf("Unexpected error burn rate. Expected %f got %f", 4.0, alerts[0].ErrorBurnRate)
+	}
+	server.Request("GET", "/orders?fail=1", nil)
+	if len(alerts) != 1 {
+		t.Errorf("Alert repeated for route that is still alerting")
+	}
+
+	server.Request("GET", "/search", nil)
+	server.Request("GET", "/search", nil)
+	if len(alerts) != 2 || alerts[1].Path != "/search" || alerts[1].SlowRequests != 2 || alerts[1].LatencyBurnRate < 99 {
+		t.Errorf("Unexpected SLO alerts %+v", alerts)
+	}
+
+	for _, route := range server.RouteMetrics() {
+		if route.Path == "/users" {
+			if route.SLO != nil {
+				t.Errorf("Unexpected SLO status for route without objectives")
+			}
+			continue
+		}
+		if route.SLO == nil || !route.SLO.Alerting {
+			t.Errorf("Missing or unexpected SLO status for %s: %+v", route.Path, route.SLO)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// defaultSocketPingTimeout is the time allowed to write a ping when the handle has no WebSocketWriteTimeout
+const defaultSocketPingTimeout = 10 * time.Second
+
+// applyLimits configures the read limit and pong handler of the connection from the options of the handle
+func (c *WSConn) applyLimits(options HandleOptions) {
+	c.readTimeout = options.WebSocketReadTimeout
+	c.writeTimeout = options.WebSocketWriteTimeout
+	if options.WebSocketMaxMessageSize > 0 {
+		c.SetReadLimit(options.WebSocketMaxMessageSize)
+	}
+	if c.readTimeout > 0 {
+		c.SetPongHandler(func(string) error {
+			return c.SetReadDeadline(time.Now().Add(c.readTimeout))
+		})
+	}
+}
+
+// extendReadDeadline sets the read deadline of the connection if the handle has a WebSocketReadTimeout
+func (c *WSConn) extendReadDeadline() {
+	if c.readTimeout > 0 {
+		c.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+}
+
+// extendWriteDeadline sets the write deadline of the connection if the handle has a WebSocketWriteTimeout
+func (c *WSConn) extendWriteDeadline() {
+	if c.writeTimeout > 0 {
+		c.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+}
+
+// checkReadError closes the connection if err is caused by the read timeout or message size limit being exceeded
+func (c *WSConn) checkReadError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, websocket.ErrReadLimit) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		log.PWarn("Closing websocket connection after exceeding limit", map[string]interface{}{
+			"remote_addr": c.RemoteAddr().String(),
+			"error":       err.Error(),
+		})
+		c.Close()
+	}
+	return err
+}
+
+// NextReader returns the next data message received from the client, applying the WebSocketReadTimeout of the handle.
+func (c *WSConn) NextReader() (int, io.Reader, error) {
+	c.extendReadDeadline()
+	messageType, r, err := c.Conn.NextReader()
+	if err != nil {
+		return messageType, r, c.checkReadError(err)
+	}
+	return messageType, r, nil
+}
+
+// ReadMessage reads the next data message received from the client, applying the WebSocketReadTimeout and
+// WebSocketMaxMessageSize of the handle.
+func (c *WSConn) ReadMessage() (int, []byte, error) {
+	c.extendReadDeadline()
+	messageType, p, err := c.Conn.ReadMessage()
+	if err != nil {
+		return messageType, p, c.checkReadError(err)
+	}
+	return messageType, p, nil
+}
+
+// NextWriter returns a writer for the next message to send to the client, applying the WebSocketWriteTimeout of the
+// handle.
+func (c *WSConn) NextWriter(messageType int) (io.WriteCloser, error) {
+	c.extendWriteDeadline()
+	return c.Conn.NextWriter(messageType)
+}
+
+// WriteMessage writes a message to the client, applying the WebSocketWriteTimeout of the handle.
+func (c *WSConn) WriteMessage(messageType int, data []byte) error {
+	c.extendWriteDeadline()
+	return c.Conn.WriteMessage(messageType, data)
+}
+
+// WriteJSON writes the JSON encoding of v as a message to the client, applying the WebSocketWriteTimeout of the
+// handle.
+func (c *WSConn) WriteJSON(v interface{}) error {
+	c.extendWriteDeadline()
+	return c.Conn.WriteJSON(v)
+}
+
+// keepalive sends a ping to the client every interval until done is closed, closing the connection if a ping can not be
+// sent
+func (c *WSConn) keepalive(interval time.Duration, done chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		timeout := c.writeTimeout
+		if timeout <= 0 {
+			timeout = defaultSocketPingTimeout
+		}
+		if err := c.WriteControl(websocket.PingMessage, nil, time.Now().Add(timeout)); err != nil {
+			if !errors.Is(err, websocket.ErrCloseSent) {
+				log.PWarn("Closing websocket connection after failed ping", map[string]interface{}{
+					"remote_addr": c.RemoteAddr().String(),
+					"error":       err.Error(),
+				})
+			}
+			c.Close()
+			return
+		}
+	}
+}
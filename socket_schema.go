@@ -0,0 +1,210 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// SocketSchema describes the JSON messages that a websocket handle accepts from clients. Each message must be a JSON
+// object with a property identifying its type, such as:
+//
+//	{"type": "chat", "room": "general", "message": "Hello"}
+//
+// When a schema is set on the handle options, messages read with [web.WSConn.ReadJSON] or
+// [web.WSConn.ReadMessageJSON] are validated before being returned to the handle.
+type SocketSchema struct {
+	// The name of the JSON property that identifies the type of the message. Defaults to "type".
+	TypeField string
+	// The accepted message types, keyed by the value of the type property. Each value is any Go value, typically the
+	// zero value of a struct, whose type describes the expected shape of the message. The type property itself does not
+	// need to be included in the struct.
+	//
+	// Every JSON field of the struct must be present in the message unless it is tagged with omitempty, and the message
+	// must not contain any fields that are not described by the struct.
+	Messages map[string]interface{}
+	// If true then the connection is closed when an invalid or unauthorized message is received. Otherwise a JSON error
+	// is sent to the client and the message is discarded.
+	CloseOnInvalid bool
+	// Optional method called for each valid message with the user data of the connection and the type of the message,
+	// allowing a single connection to accept messages that require different permissions. Return an error if the user
+	// is not permitted to send messages of this type, which always has a 403 code. The user data is refreshed each time
+	// the connection is re-authenticated.
+	//
+	// For example:
+	//
+	//	AuthorizeMessage: func(userData interface{}, messageType string) *web.Error {
+	//	    if messageType == "delete" && !userData.(*User).Admin {
+	//	        return web.CommonErrors.Forbidden
+	//	    }
+	//	    return nil
+	//	}
+	AuthorizeMessage func(userData interface{}, messageType string) *Error
+}
+
+func (s SocketSchema) typeField() string {
+	if s.TypeField == "" {
+		return "type"
+	}
+	return s.TypeField
+}
+
+// validate checks that the message matches the schema, returning the type of the message
+func (s SocketSchema) validate(message []byte) (string, error) {
+	object := map[string]json.RawMessage{}
+	if err := json.Unmarshal(message, &object); err != nil {
+		return "", fmt.Errorf("message: expected object")
+	}
+
+	messageType := ""
+	if err := json.Unmarshal(object[s.typeField()], &messageType); err != nil || messageType == "" {
+		return "", fmt.Errorf("message: missing field %s", s.typeField())
+	}
+	schema, ok := s.Messages[messageType]
+	if !ok {
+		return "", fmt.Errorf("message: unknown type %s", messageType)
+	}
+
+	t := reflect.TypeOf(schema)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct {
+		if _, describesType := structFieldNames(t)[s.typeField()]; !describesType {
+			delete(object, s.typeField())
+		}
+	}
+	raw, _ := json.Marshal(object)
+	if err := validateSchema(raw, t, messageType); err != nil {
+		return "", err
+	}
+	return messageType, nil
+}
+
+// structFieldNames returns the JSON names of all fields of the struct type
+func structFieldNames(t reflect.Type) map[string]bool {
+	fields := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for name := range structFieldNames(embedded) {
+					fields[name] = true
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = true
+	}
+	return fields
+}
+
+// ReadJSON reads the next JSON message from the connection and stores it in the value pointed to by v. If the handle
+// has a SocketSchema, invalid messages are rejected and ReadJSON waits for the next valid message, or returns an error
+// if the schema is set to close the connection.
+func (c *WSConn) ReadJSON(v interface{}) error {
+	_, message, err := c.readValidMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(message, v)
+}
+
+// ReadMessageJSON reads the next valid JSON message from the connection, returning its type and a pointer to a new
+// value of the type registered for it in the SocketSchema of the handle. Invalid messages are handled the same as
+// ReadJSON. Returns an error if the handle does not have a SocketSchema.
+func (c *WSConn) ReadMessageJSON() (string, interface{}, error) {
+	if c.schema == nil {
+		return "", nil, fmt.Errorf("websocket handle has no socket schema")
+	}
+
+	messageType, message, err := c.readValidMessage()
+	if err != nil {
+		return "", nil, err
+	}
+
+	t := reflect.TypeOf(c.schema.Messages[messageType])
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	value := reflect.New(t).Interface()
+	if err := json.Unmarshal(message, value); err != nil {
+		return "", nil, err
+	}
+	return messageType, value, nil
+}
+
+// authorizeMessage returns the error from the AuthorizeMessage method of the schema, or nil if the message is permitted
+func (c *WSConn) authorizeMessage(messageType string) *Error {
+	if c.schema.AuthorizeMessage == nil {
+		return nil
+	}
+	return forbiddenError(c.schema.AuthorizeMessage(c.currentUserData(), messageType))
+}
+
+// readValidMessage reads messages from the connection until one that matches the schema of the handle is found
+func (c *WSConn) readValidMessage() (string, []byte, error) {
+	for {
+		_, message, err := c.ReadMessage()
+		if err != nil {
+			return "", nil, err
+		}
+		if c.schema == nil {
+			return "", message, nil
+		}
+
+		messageType, err := c.schema.validate(message)
+		if err == nil {
+			authErr := c.authorizeMessage(messageType)
+			if authErr == nil {
+				return messageType, message, nil
+			}
+
+			log.PWarn("Rejected unauthorized websocket message", map[string]interface{}{
+				"remote_addr": c.RemoteAddr().String(),
+				"type":        messageType,
+				"message":     authErr.Message,
+			})
+			if c.schema.CloseOnInvalid {
+				c.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "forbidden"), time.Now().Add(time.Second))
+				c.Close()
+				return "", nil, fmt.Errorf("message: %s", authErr.Message)
+			}
+			if err := c.WriteJSON(JSONResponse{Error: authErr}); err != nil {
+				return "", nil, err
+			}
+			continue
+		}
+
+		log.PWarn("Rejected invalid websocket message", map[string]interface{}{
+			"remote_addr": c.RemoteAddr().String(),
+			"error":       err.Error(),
+		})
+		if c.schema.CloseOnInvalid {
+			c.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInvalidFramePayloadData, err.Error()), time.Now().Add(time.Second))
+			c.Close()
+			return "", nil, err
+		}
+		if err := c.WriteJSON(JSONResponse{Error: ValidationError("%s", err.Error())}); err != nil {
+			return "", nil, err
+		}
+	}
+}
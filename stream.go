@@ -0,0 +1,183 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// StreamFormat describes the format used for a streamed JSON response
+type StreamFormat int
+
+const (
+	// StreamJSONArray streams items as elements of the data array of a regular [web.JSONResponse]
+	StreamJSONArray StreamFormat = iota
+	// StreamNDJSON streams items as newline-delimited JSON values, without a wrapping response object. The content type
+	// of the response is application/x-ndjson.
+	StreamNDJSON
+)
+
+// JSONStream describes a stream of JSON values. Return a JSONStream as the data from an API handle to have each item
+// written to the client as it is produced, rather than buffering the entire response in memory.
+//
+// Exactly one of Items or Reader should be provided. If the client goes away part way through the stream, the
+// remaining items are discarded. Handles producing items should watch the context of the request to stop early.
+//
+// If the Reader returns an error or invalid JSON part way through the stream, the connection is closed without
+// finishing the response so that the client does not mistake it for a complete one.
+type JSONStream struct {
+	// Channel of items to stream. Each item is encoded using the JSONEncoder of the server. The handle must close the
+	// channel once all items have been sent.
+	Items <-chan interface{}
+	// Reader of a sequence of JSON values to stream, such as newline-delimited JSON. The reader is closed once the
+	// stream is finished if it implements io.Closer.
+	Reader io.Reader
+	// The format for the response.
+	Format StreamFormat
+	// The number of items to write before flushing the response to the client. Defaults to 0, which flushes after
+	// every item.
+	FlushEvery int
+}
+
+func (a API) writeJSONStream(w http.ResponseWriter, r *http.Request, stream JSONStream) {
+	flusher, _ := w.(http.Flusher)
+	encoder := a.server.jsonEncoder().NewEncoder(w)
+
+	if stream.Reader != nil {
+		if closer, ok := stream.Reader.(io.Closer); ok {
+			defer closer.Close()
+		}
+	}
+
+	if stream.Format == StreamJSONArray {
+		if _, err := io.WriteString(w, `{"data":[`); err != nil {
+			a.abortJSONStream(r, stream, err)
+			return
+		}
+	}
+
+	var decoder *json.Decoder
+	if stream.Reader != nil {
+		decoder = json.NewDecoder(stream.Reader)
+	}
+
+	count := 0
+	for {
+		var item interface{}
+		if decoder != nil {
+			value := json.RawMessage{}
+			if err := decoder.Decode(&value); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				log.PError("Error reading JSON stream", map[string]interface{}{
+					"method": r.Method,
+					"url":    r.URL,
+					"error":  err.Error(),
+				})
+				// The status has already been sent, so abort the response rather than finishing it as if the stream
+				// was complete
+				panic(http.ErrAbortHandler)
+			}
+			item = value
+		} else if stream.Items != nil {
+			value, open := <-stream.Items
+			if !open {
+				break
+			}
+			item = value
+		} else {
+			break
+		}
+
+		if stream.Format == StreamJSONArray && count > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				a.abortJSONStream(r, stream, err)
+				return
+			}
+		}
+		if err := encoder.Encode(item); err != nil {
+			a.abortJSONStream(r, stream, err)
+			return
+		}
+		count++
+
+		if flusher != nil && (stream.FlushEvery <= 0 || count%stream.FlushEvery == 0) {
+			flusher.Flush()
+		}
+	}
+
+	if stream.Format == StreamJSONArray {
+		io.WriteString(w, "]}\n")
+	}
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+func (a API) abortJSONStream(r *http.Request, stream JSONStream, err error) {
+	// Drain the remaining items so that the producer isn't blocked forever
+	if stream.Items != nil {
+		go func() {
+			for range stream.Items {
+			}
+		}()
+	}
+
+	if isClientAbort(err) {
+		return
+	}
+	log.PError("Error writing JSON stream", map[string]interface{}{
+		"method": r.Method,
+		"url":    r.URL,
+		"error":  err.Error(),
+	})
+}
+
+// StreamWriter describes a writer for a streamed HTTPEasy response, passed to the Stream method of a
+// [web.HTTPResponse]. Data written is sent to the client as it is flushed, using chunked transfer encoding unless the
+// response has a ContentLength.
+type StreamWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	request *http.Request
+}
+
+// Write writes data to the response. Returns an error if the client has gone away.
+func (s *StreamWriter) Write(p []byte) (int, error) {
+	return s.w.Write(p)
+}
+
+// Flush sends any buffered data to the client
+func (s *StreamWriter) Flush() {
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
+
+// Done returns a channel that is closed when the client disconnects. Streams should stop writing once the channel is
+// closed.
+func (s *StreamWriter) Done() <-chan struct{} {
+	return s.request.Context().Done()
+}
+
+// writeStream calls the Stream method of the response with a writer for w, flushing any remaining data once it returns
+func (h HTTPEasy) writeStream(w http.ResponseWriter, r *http.Request, stream func(writer *StreamWriter) error) {
+	if writer, ok := w.(*responseWriter); ok && writer.capture != nil {
+		// Streamed responses are never cached
+		writer.capture.skip = true
+	}
+	flusher, _ := w.(http.Flusher)
+	writer := &StreamWriter{w: w, flusher: flusher, request: r}
+	err := stream(writer)
+	writer.Flush()
+	if err == nil || r.Context().Err() != nil || isClientAbort(err) {
+		return
+	}
+	log.PError("Error writing response stream", map[string]interface{}{
+		"method": r.Method,
+		"url":    r.URL,
+		"error":  err.Error(),
+	})
+}
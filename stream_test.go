@@ -0,0 +1,112 @@
+package web_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestAPIStreamArray(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		items := make(chan interface{})
+		go func() {
+			for i := 0; i < 100; i++ {
+				items <- map[string]int{"i": i}
+			}
+			close(items)
+		}()
+		return web.JSONStream{Items: items, FlushEvery: 10}, nil, nil
+	}
+
+	path := randomString(5)
+	server.API.GET("/"+path, handle, web.HandleOptions{})
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected content type '%s'", resp.Header.Get("Content-Type"))
+	}
+	response := struct {
+		Data []map[string]int `json:"data"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding streamed response: %s", err.Error())
+	}
+	if len(response.Data) != 100 {
+		t.Fatalf("Unexpected number of items. Expected %d got %d", 100, len(response.Data))
+	}
+	if response.Data[99]["i"] != 99 {
+		t.Errorf("Unexpected item value")
+	}
+}
+
+func TestAPIStreamNDJSON(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		reader := strings.NewReader("{\"i\":1}\n{\"i\":2}\n{\"i\":3}\n")
+		return web.JSONStream{Reader: reader, Format: web.StreamNDJSON}, nil, nil
+	}
+
+	path := randomString(5)
+	server.API.GET("/"+path, handle, web.HandleOptions{})
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("Unexpected content type '%s'", resp.Header.Get("Content-Type"))
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	lines := 0
+	for scanner.Scan() {
+		item := map[string]int{}
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			t.Fatalf("Error decoding line: %s", err.Error())
+		}
+		lines++
+		if item["i"] != lines {
+			t.Errorf("Unexpected item value. Expected %d got %d", lines, item["i"])
+		}
+	}
+	if lines != 3 {
+		t.Errorf("Unexpected number of lines. Expected %d got %d", 3, lines)
+	}
+}
+
+func TestAPIStreamReaderError(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		reader := strings.NewReader("{\"i\":1}\n{\"i\":2}\n{\"i\":")
+		return web.JSONStream{Reader: reader}, nil, nil
+	}
+
+	path := randomString(5)
+	server.API.GET("/"+path, handle, web.HandleOptions{})
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path))
+	if err != nil {
+		// The response was aborted before the headers were sent
+		return
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); err == nil {
+		t.Fatalf("No error reading aborted stream")
+	}
+}
@@ -0,0 +1,216 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+)
+
+// TemplateOptions describes options for loading HTML templates with [web.Server.LoadTemplates]
+type TemplateOptions struct {
+	// The filesystem to load templates from, such as os.DirFS("templates") or an embed.FS.
+	FS fs.FS
+	// Glob patterns matching the page templates, such as "pages/*.html". Each page is rendered by its path within FS,
+	// such as "pages/index.html".
+	Pages []string
+	// Optional glob patterns matching layout and partial templates, such as "layouts/*.html". These templates are
+	// available to every page, and each page may define blocks used by the layouts without conflicting with other
+	// pages.
+	Shared []string
+	// Optional name of the template to execute when rendering a page, such as a layout that includes blocks defined by
+	// the page. If empty then the page itself is executed.
+	Layout string
+	// Optional functions available to all templates. These are combined with functions registered with
+	// [web.Server.AddTemplateFunc], replacing any registered functions with the same name.
+	Funcs template.FuncMap
+	// If true then templates are loaded from the filesystem each time a page is rendered, so that changes are picked up
+	// without restarting the server. Intended for development only.
+	AutoReload bool
+}
+
+type templateRegistry struct {
+	options TemplateOptions
+	pages   map[string]*template.Template
+	shared  *template.Template
+	lock    *sync.RWMutex
+}
+
+// AddTemplateFunc registers a function by name that is available to all templates loaded with
+// [web.Server.LoadTemplates], such as a helper for formatting dates shared by several packages of an application.
+// Functions must be registered before the templates are loaded.
+func (s *Server) AddTemplateFunc(name string, fn interface{}) {
+	if s.templateFuncs == nil {
+		s.templateFuncs = template.FuncMap{}
+	}
+	s.templateFuncs[name] = fn
+}
+
+// LoadTemplates loads the HTML templates described by options, replacing any previously loaded templates. Once loaded,
+// HTTPEasy handles may render a page by returning a [web.HTTPResponse] with the Template property set, and any handle
+// may render a page with [web.Server.RenderTemplate].
+//
+// Returns an error if any template could not be parsed.
+func (s *Server) LoadTemplates(options TemplateOptions) error {
+	funcs := template.FuncMap{}
+	for name, fn := range s.templateFuncs {
+		funcs[name] = fn
+	}
+	for name, fn := range options.Funcs {
+		funcs[name] = fn
+	}
+	options.Funcs = funcs
+
+	pages, shared, err := parseTemplates(options)
+	if err != nil {
+		log.PError("Error loading templates", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	log.PDebug("Loaded templates", map[string]interface{}{
+		"pages": len(pages),
+	})
+	s.templates = &templateRegistry{
+		options: options,
+		pages:   pages,
+		shared:  shared,
+		lock:    &sync.RWMutex{},
+	}
+	return nil
+}
+
+// RenderTemplate renders the page with the given name from the templates loaded with [web.Server.LoadTemplates] to w.
+func (s *Server) RenderTemplate(w io.Writer, name string, data interface{}) error {
+	if s.templates == nil {
+		return fmt.Errorf("no templates loaded")
+	}
+	return s.templates.render(w, name, data)
+}
+
+// RenderPartial renders a single named template to a string, such as a fragment of a page returned in response to a
+// HTMX request. The name is either a template from the shared templates, such as "footer" or "row.html", or the name
+// of a page and a template defined by that page separated by '#', such as "pages/index.html#content".
+func (s *Server) RenderPartial(name string, data interface{}) (string, error) {
+	if s.templates == nil {
+		return "", fmt.Errorf("no templates loaded")
+	}
+	buf := &strings.Builder{}
+	if err := s.templates.renderPartial(buf, name, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func parseTemplates(options TemplateOptions) (map[string]*template.Template, *template.Template, error) {
+	shared := []string{}
+	for _, pattern := range options.Shared {
+		matches, err := fs.Glob(options.FS, pattern)
+		if err != nil {
+			return nil, nil, err
+		}
+		shared = append(shared, matches...)
+	}
+
+	var sharedTemplate *template.Template
+	if len(shared) > 0 {
+		t, err := template.New("").Funcs(options.Funcs).ParseFS(options.FS, shared...)
+		if err != nil {
+			return nil, nil, err
+		}
+		sharedTemplate = t
+	}
+
+	pages := map[string]*template.Template{}
+	for _, pattern := range options.Pages {
+		matches, err := fs.Glob(options.FS, pattern)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, match := range matches {
+			t, err := template.New(path.Base(match)).Funcs(options.Funcs).ParseFS(options.FS, append(shared, match)...)
+			if err != nil {
+				return nil, nil, err
+			}
+			pages[match] = t
+		}
+	}
+	return pages, sharedTemplate, nil
+}
+
+// reload parses the templates again if AutoReload is enabled
+func (r *templateRegistry) reload() error {
+	if !r.options.AutoReload {
+		return nil
+	}
+	pages, shared, err := parseTemplates(r.options)
+	if err != nil {
+		return err
+	}
+	r.lock.Lock()
+	r.pages = pages
+	r.shared = shared
+	r.lock.Unlock()
+	return nil
+}
+
+func (r *templateRegistry) render(w io.Writer, name string, data interface{}) error {
+	if err := r.reload(); err != nil {
+		return err
+	}
+
+	r.lock.RLock()
+	t, ok := r.pages[name]
+	r.lock.RUnlock()
+	if !ok {
+		return fmt.Errorf("no template named %s", name)
+	}
+
+	execute := path.Base(name)
+	if r.options.Layout != "" {
+		execute = r.options.Layout
+	}
+	return t.ExecuteTemplate(w, execute, data)
+}
+
+func (r *templateRegistry) renderPartial(w io.Writer, name string, data interface{}) error {
+	if err := r.reload(); err != nil {
+		return err
+	}
+
+	r.lock.RLock()
+	t := r.shared
+	r.lock.RUnlock()
+	if page, partial, ok := strings.Cut(name, "#"); ok {
+		r.lock.RLock()
+		t = r.pages[page]
+		r.lock.RUnlock()
+		if t == nil {
+			return fmt.Errorf("no template named %s", page)
+		}
+		name = partial
+	}
+	if t == nil || t.Lookup(name) == nil {
+		return fmt.Errorf("no partial named %s", name)
+	}
+	return t.ExecuteTemplate(w, name, data)
+}
+
+// renderTemplateResponse renders the template of the response into its reader
+func (s *Server) renderTemplateResponse(response *HTTPResponse) error {
+	buf := &bytes.Buffer{}
+	if err := s.RenderTemplate(buf, response.Template, response.Data); err != nil {
+		return err
+	}
+	response.Reader = io.NopCloser(buf)
+	response.ContentLength = uint64(buf.Len())
+	if response.ContentType == "" {
+		response.ContentType = "text/html; charset=utf-8"
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package web_test
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestTemplateResponse(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	fsys := fstest.MapFS{
+		"layouts/main.html":  &fstest.MapFile{Data: []byte(`{{define "main"}}<title>{{block "title" .}}Default{{end}}</title>{{template "content" .}}{{template "footer"}}{{end}}`)},
+		"partials/foot.html": &fstest.MapFile{Data: []byte(`{{define "footer"}}<footer>{{year}}</footer>{{end}}`)},
+		"pages/index.html":   &fstest.MapFile{Data: []byte(`{{define "title"}}Home{{end}}{{define "content"}}<p>Hello {{.}}</p>{{end}}`)},
+		"pages/about.html":   &fstest.MapFile{Data: []byte(`{{define "content"}}<p>About</p>{{end}}`)},
+	}
+	err := server.LoadTemplates(web.TemplateOptions{
+		FS:     fsys,
+		Pages:  []string{"pages/*.html"},
+		Shared: []string{"layouts/*.html", "partials/*.html"},
+		Layout: "main",
+		Funcs: template.FuncMap{
+			"year": func() int { return 2006 },
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error loading templates: %s", err.Error())
+	}
+
+	render := func(page string, data interface{}) (int, string, string) {
+		path := randomString(5)
+		server.HTTPEasy.GET("/"+path, func(request web.Request) web.HTTPResponse {
+			return web.HTTPResponse{
+				Template: page,
+				Data:     data,
+			}
+		}, web.HandleOptions{})
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path))
+		if err != nil {
+			t.Fatalf("Network error: %s", err.Error())
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, resp.Header.Get("Content-Type"), string(body)
+	}
+
+	status, contentType, body := render("pages/index.html", "<world>")
+	if status != 200 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 200, status)
+	}
+	if contentType != "text/html; charset=utf-8" {
+		t.Errorf("Unexpected content type. Expected '%s' got '%s'", "text/html; charset=utf-8", contentType)
+	}
+	expected := "<title>Home</title><p>Hello &lt;world&gt;</p><footer>2006</footer>"
+	if body != expected {
+		t.Errorf("Unexpected body. Expected '%s' got '%s'", expected, body)
+	}
+
+	// Blocks defined by one page must not leak into another
+	_, _, body = render("pages/about.html", nil)
+	expected = "<title>Default</title><p>About</p><footer>2006</footer>"
+	if body != expected {
+		t.Errorf("Unexpected body. Expected '%s' got '%s'", expected, body)
+	}
+
+	if status, _, _ := render("pages/missing.html", nil); status != 500 {
+		t.Errorf("Unexpected status code for missing template. Expected %d got %d", 500, status)
+	}
+}
+
+func TestTemplateAutoReload(t *testing.T) {
+	t.Parallel()
+	server := web.New("localhost:0")
+
+	fsys := fstest.MapFS{
+		"index.html": &fstest.MapFile{Data: []byte(`one`)},
+	}
+	if err := server.LoadTemplates(web.TemplateOptions{
+		FS:         fsys,
+		Pages:      []string{"*.html"},
+		AutoReload: true,
+	}); err != nil {
+		t.Fatalf("Error loading templates: %s", err.Error())
+	}
+
+	buf := &bytes.Buffer{}
+	if err := server.RenderTemplate(buf, "index.html", nil); err != nil {
+		t.Fatalf("Error rendering template: %s", err.Error())
+	}
+	fsys["index.html"] = &fstest.MapFile{Data: []byte(`two`)}
+	if err := server.RenderTemplate(buf, "index.html", nil); err != nil {
+		t.Fatalf("Error rendering template: %s", err.Error())
+	}
+	if buf.String() != "onetwo" {
+		t.Errorf("Template not reloaded. Expected '%s' got '%s'", "onetwo", buf.String())
+	}
+}
+
+func TestTemplateLoadError(t *testing.T) {
+	t.Parallel()
+	server := web.New("localhost:0")
+
+	err := server.LoadTemplates(web.TemplateOptions{
+		FS: fstest.MapFS{
+			"index.html": &fstest.MapFile{Data: []byte(`{{ .Broken `)},
+		},
+		Pages: []string{"*.html"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "index.html") {
+		t.Errorf("Unexpected error for invalid template: %v", err)
+	}
+}
+
+func TestTemplateRenderPartial(t *testing.T) {
+	t.Parallel()
+	server := web.New("localhost:0")
+	server.AddTemplateFunc("upper", strings.ToUpper)
+	server.AddTemplateFunc("year", func() int { return 1999 })
+
+	err := server.LoadTemplates(web.TemplateOptions{
+		FS: fstest.MapFS{
+			"partials/row.html": &fstest.MapFile{Data: []byte(`{{define "row"}}<tr><td>{{upper .}}</td></tr>{{end}}`)},
+			"pages/index.html":  &fstest.MapFile{Data: []byte(`{{define "content"}}<p>{{.}} {{year}}</p>{{end}}<table>{{template "row" .}}</table>`)},
+		},
+		Pages:  []string{"pages/*.html"},
+		Shared: []string{"partials/*.html"},
+		Funcs: template.FuncMap{
+			"year": func() int { return 2006 },
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error loading templates: %s", err.Error())
+	}
+
+	row, err := server.RenderPartial("row", "<b>")
+	if err != nil {
+		t.Fatalf("Error rendering partial: %s", err.Error())
+	}
+	if expected := "<tr><td>&lt;B&gt;</td></tr>"; row != expected {
+		t.Errorf("Unexpected partial. Expected '%s' got '%s'", expected, row)
+	}
+
+	content, err := server.RenderPartial("pages/index.html#content", "hello")
+	if err != nil {
+		t.Fatalf("Error rendering partial: %s", err.Error())
+	}
+	if expected := "<p>hello 2006</p>"; content != expected {
+		t.Errorf("Unexpected partial. Expected '%s' got '%s'", expected, content)
+	}
+
+	if _, err := server.RenderPartial("missing", nil); err == nil {
+		t.Errorf("No error seen for missing partial")
+	}
+	if _, err := server.RenderPartial("pages/missing.html#content", nil); err == nil {
+		t.Errorf("No error seen for missing page")
+	}
+}
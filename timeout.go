@@ -0,0 +1,219 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+type timeoutResult struct {
+	value interface{}
+	panic interface{}
+	stack []byte
+}
+
+// callWithTimeout calls fn in a new goroutine and waits for it to return or for ctx to be done, in which case false is
+// returned. A panic in fn is repanicked in the calling goroutine. If fn returns after ctx is done then its result is
+// passed to abandon, if not nil.
+func callWithTimeout(ctx context.Context, fn func() interface{}, abandon func(value interface{})) (interface{}, bool) {
+	done := make(chan timeoutResult, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- timeoutResult{panic: p, stack: debug.Stack()}
+			}
+		}()
+		done <- timeoutResult{value: fn()}
+	}()
+
+	select {
+	case result := <-done:
+		if result.panic != nil {
+			panic(result.panic)
+		}
+		return result.value, true
+	case <-ctx.Done():
+		go func() {
+			result := <-done
+			if result.panic != nil {
+				log.PError("Recovered from panic during handle after timeout", map[string]interface{}{
+					"error": fmt.Sprintf("%v", result.panic),
+					"stack": string(result.stack),
+				})
+				return
+			}
+			if abandon != nil {
+				abandon(result.value)
+			}
+		}()
+		return nil, false
+	}
+}
+
+// handleTimeout returns the timeout for the handle, or 0 if the handle has no timeout
+func (s *Server) handleTimeout(options HandleOptions) time.Duration {
+	if options.Timeout < 0 {
+		return 0
+	}
+	if options.Timeout > 0 {
+		return options.Timeout
+	}
+	return s.runtimeConfig().DefaultTimeout
+}
+
+// withTimeout returns a copy of the request with a context that is cancelled once the timeout elapses. The returned
+// cancel function must always be called.
+func withTimeout(r *http.Request, timeout time.Duration) (*http.Request, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	return r.WithContext(ctx), cancel
+}
+
+// logTimeout logs a handle that did not complete within its timeout
+func (s *Server) logTimeout(w http.ResponseWriter, r *http.Request, timeout time.Duration) {
+	log.PWarn("Handle exceeded timeout", map[string]interface{}{
+		"remote_addr": s.realRemoteAddr(r),
+		"method":      r.Method,
+		"url":         r.URL,
+		"timeout":     timeout.String(),
+	})
+	setResponseError(w, "handle exceeded timeout of "+timeout.String())
+}
+
+// timeoutError returns the error sent to clients for a handle that did not complete within its timeout
+func (s *Server) timeoutError() *Error {
+	if s.Options.TimeoutStatus == http.StatusGatewayTimeout {
+		return CommonErrors.GatewayTimeout
+	}
+	return CommonErrors.ServiceUnavailable
+}
+
+// writeTimeout writes the JSON error response for a handle that did not complete within its timeout
+func (s *Server) writeTimeout(w http.ResponseWriter) {
+	err := s.timeoutError()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.Code)
+	s.jsonEncoder().NewEncoder(w).Encode(s.serializeError(err, JSONResponse{Error: err}))
+}
+
+// abandonHandle returns the method called with the result of a handle that returned after its timeout, which calls
+// cleanup with the result and then the OnAbandonedHandle method of the server
+func (s *Server) abandonHandle(r *http.Request, start time.Time, cleanup func(value interface{})) func(value interface{}) {
+	return func(value interface{}) {
+		if cleanup != nil {
+			cleanup(value)
+		}
+		elapsed := time.Since(start)
+		log.PWarn("Handle returned after timeout", map[string]interface{}{
+			"method":  r.Method,
+			"url":     r.URL,
+			"elapsed": elapsed.String(),
+		})
+		if s.Options.OnAbandonedHandle != nil {
+			s.Options.OnAbandonedHandle(r, elapsed)
+		}
+	}
+}
+
+// timeoutWriter is a response writer for HTTP handles with a timeout. Once timed out, any further writes from the
+// handle are discarded.
+type timeoutWriter struct {
+	ctx         context.Context
+	w           http.ResponseWriter
+	header      http.Header
+	lock        *sync.Mutex
+	timedOut    bool
+	wroteHeader bool
+}
+
+func newTimeoutWriter(ctx context.Context, w http.ResponseWriter) *timeoutWriter {
+	return &timeoutWriter{
+		ctx:    ctx,
+		w:      w,
+		header: w.Header().Clone(),
+		lock:   &sync.Mutex{},
+	}
+}
+
+func (t *timeoutWriter) Header() http.Header {
+	return t.header
+}
+
+// isTimedOut returns true if the timeout has elapsed. Checking the context as well avoids a race with a handle that
+// returns as soon as the context is done. The lock must be held.
+func (t *timeoutWriter) isTimedOut() bool {
+	return t.timedOut || errors.Is(t.ctx.Err(), context.DeadlineExceeded)
+}
+
+// writeHeader writes the status and headers to the underlying writer. The lock must be held.
+func (t *timeoutWriter) writeHeader(statusCode int) {
+	if t.wroteHeader {
+		return
+	}
+	t.wroteHeader = true
+	header := t.w.Header()
+	for key := range header {
+		delete(header, key)
+	}
+	for key, value := range t.header {
+		header[key] = value
+	}
+	t.w.WriteHeader(statusCode)
+}
+
+func (t *timeoutWriter) WriteHeader(statusCode int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.isTimedOut() {
+		return
+	}
+	t.writeHeader(statusCode)
+}
+
+func (t *timeoutWriter) Write(p []byte) (int, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.isTimedOut() {
+		return 0, http.ErrHandlerTimeout
+	}
+	t.writeHeader(http.StatusOK)
+	return t.w.Write(p)
+}
+
+func (t *timeoutWriter) Flush() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.isTimedOut() {
+		return
+	}
+	if flusher, ok := t.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (t *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.isTimedOut() {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	hijacker, ok := t.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	t.wroteHeader = true
+	return hijacker.Hijack()
+}
+
+// timeout marks the writer as timed out, returning true if the handle has not yet started writing a response
+func (t *timeoutWriter) timeout() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.timedOut = true
+	return !t.wroteHeader
+}
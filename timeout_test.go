@@ -0,0 +1,186 @@
+package web_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestHandleTimeoutAPI(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	cancelled := make(chan bool, 1)
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		select {
+		case <-request.HTTP.Context().Done():
+			cancelled <- true
+		case <-time.After(time.Second):
+			cancelled <- false
+		}
+		return true, nil, nil
+	}
+	path := randomString(5)
+	server.API.GET("/"+path, handle, web.HandleOptions{Timeout: 20 * time.Millisecond})
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 503 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 503, resp.StatusCode)
+	}
+	response := web.JSONResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %s", err.Error())
+	}
+	if response.Error == nil || response.Error.Code != 503 {
+		t.Errorf("Unexpected error in response: %+v", response.Error)
+	}
+	if !<-cancelled {
+		t.Errorf("Request context was not cancelled")
+	}
+}
+
+func TestHandleTimeoutAPIComplete(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return true, nil, nil
+	}
+	path := randomString(5)
+	server.API.GET("/"+path, handle, web.HandleOptions{Timeout: time.Second})
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 200, resp.StatusCode)
+	}
+}
+
+func TestHandleTimeoutHTTP(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	handle := func(w http.ResponseWriter, request web.Request) {
+		<-request.HTTP.Context().Done()
+		w.Header().Set("X-Late", "1")
+		w.WriteHeader(200)
+		w.Write([]byte("too late"))
+	}
+	path := randomString(5)
+	server.HTTP.GET("/"+path, handle, web.HandleOptions{Timeout: 20 * time.Millisecond})
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 503 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 503, resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if bytes.Contains(body, []byte("too late")) {
+		t.Errorf("Response from handle written after timeout")
+	}
+}
+
+func TestHandleTimeoutHTTPEasy(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	closed := make(chan bool, 1)
+	handle := func(request web.Request) web.HTTPResponse {
+		<-request.HTTP.Context().Done()
+		return web.HTTPResponse{
+			Reader: closeNotifier{Reader: bytes.NewReader([]byte("too late")), closed: closed},
+		}
+	}
+	path := randomString(5)
+	server.HTTPEasy.GET("/"+path, handle, web.HandleOptions{Timeout: 20 * time.Millisecond})
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 503 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 503, resp.StatusCode)
+	}
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Errorf("Reader from handle returned after timeout was not closed")
+	}
+}
+
+type closeNotifier struct {
+	io.Reader
+	closed chan bool
+}
+
+func (c closeNotifier) Close() error {
+	c.closed <- true
+	return nil
+}
+
+func TestHandleDefaultTimeout(t *testing.T) {
+	t.Parallel()
+
+	server := web.New("127.0.0.1:0")
+	server.Options.DefaultTimeout = 20 * time.Millisecond
+	server.Options.TimeoutStatus = http.StatusGatewayTimeout
+	abandoned := make(chan time.Duration, 1)
+	server.Options.OnAbandonedHandle = func(r *http.Request, elapsed time.Duration) {
+		abandoned <- elapsed
+	}
+	listening := make(chan net.Addr, 1)
+	server.Options.OnListen = func(address net.Addr) {
+		listening <- address
+	}
+
+	// Ignores the cancellation of the request context
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		time.Sleep(100 * time.Millisecond)
+		return true, nil, nil
+	}
+	server.API.GET("/default", handle, web.HandleOptions{})
+	server.API.GET("/disabled", handle, web.HandleOptions{Timeout: -1})
+	go server.Start()
+	defer server.Stop()
+	address := <-listening
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/default", address.String()))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 504 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 504, resp.StatusCode)
+	}
+	select {
+	case elapsed := <-abandoned:
+		if elapsed < 100*time.Millisecond {
+			t.Errorf("Unexpected elapsed time for abandoned handle: %s", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("OnAbandonedHandle not called")
+	}
+
+	resp, err = http.Get(fmt.Sprintf("http://%s/disabled", address.String()))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Errorf("Unexpected status code. Expected %d got %d", 200, resp.StatusCode)
+	}
+}
@@ -0,0 +1,89 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// requestTrace records the time spent in each phase of handling a traced request
+type requestTrace struct {
+	start  time.Time
+	last   time.Time
+	phases []tracePhase
+}
+
+type tracePhase struct {
+	name     string
+	duration time.Duration
+}
+
+func newRequestTrace(start time.Time) *requestTrace {
+	return &requestTrace{
+		start: start,
+		last:  start,
+	}
+}
+
+// mark records the end of the named phase, which started at the end of the previous phase
+func (t *requestTrace) mark(name string) {
+	now := time.Now()
+	t.phases = append(t.phases, tracePhase{name: name, duration: now.Sub(t.last)})
+	t.last = now
+}
+
+// serverTiming returns the recorded phases and the total elapsed time formatted for the Server-Timing header
+func (t *requestTrace) serverTiming() string {
+	metrics := make([]string, 0, len(t.phases)+1)
+	for _, phase := range t.phases {
+		metrics = append(metrics, fmt.Sprintf("%s;dur=%.3f", phase.name, durationMilliseconds(phase.duration)))
+	}
+	metrics = append(metrics, fmt.Sprintf("total;dur=%.3f", durationMilliseconds(time.Since(t.start))))
+	return strings.Join(metrics, ", ")
+}
+
+func durationMilliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// isTraceRequest returns true if the request asked to be traced and came from a permitted trace source
+func (s *Server) isTraceRequest(r *http.Request) bool {
+	if s.Options.TraceHeader == "" || len(s.Options.TraceSources) == 0 {
+		return false
+	}
+	if r.Header.Get(s.Options.TraceHeader) == "" {
+		return false
+	}
+	return networksContain(s.traceSources.get(s.Options.TraceSources), s.realRemoteAddr(r))
+}
+
+// logTrace writes the detailed log line for a traced request once the response has been written
+func (s *Server) logTrace(route string, r *http.Request, w *responseWriter) {
+	log.PInfo("Request trace", map[string]interface{}{
+		"remote_addr":    s.realRemoteAddr(r),
+		"method":         r.Method,
+		"url":            r.URL,
+		"route":          route,
+		"proto":          r.Proto,
+		"user_agent":     r.UserAgent(),
+		"status":         w.status,
+		"request_bytes":  requestBytes(r),
+		"response_bytes": w.written,
+		"timing":         w.trace.serverTiming(),
+		"elapsed":        time.Since(w.trace.start).String(),
+	})
+}
+
+// traceMark records the end of the named phase if the request is being traced
+func traceMark(w http.ResponseWriter, name string) {
+	if writer, ok := w.(*responseWriter); ok && writer.trace != nil {
+		writer.trace.mark(name)
+	}
+}
+
+// isTraced returns true if the request for the response is being traced
+func isTraced(w http.ResponseWriter) bool {
+	writer, ok := w.(*responseWriter)
+	return ok && writer.trace != nil
+}
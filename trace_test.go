@@ -0,0 +1,64 @@
+package web_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestTraceRequest(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+	server.Options.TraceHeader = "X-Trace"
+	server.Options.TraceSources = []string{"127.0.0.0/8", "::1"}
+
+	untrustedServer := newServer()
+	untrustedServer.Options.TraceHeader = "X-Trace"
+	untrustedServer.Options.TraceSources = []string{"10.0.0.0/8"}
+
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		return request.Traced(), nil, nil
+	}
+	path := randomString(5)
+	server.API.GET("/"+path, handle, web.HandleOptions{})
+	untrustedServer.API.GET("/"+path, handle, web.HandleOptions{})
+
+	request := func(server *web.Server, trace bool) *http.Response {
+		req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path), nil)
+		if err != nil {
+			t.Fatalf("Error forming request: %s", err.Error())
+		}
+		if trace {
+			req.Header.Set("X-Trace", "1")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Network error: %s", err.Error())
+		}
+		return resp
+	}
+
+	resp := request(server, true)
+	timing := resp.Header.Get("Server-Timing")
+	if !strings.Contains(timing, "prehandle;dur=") || !strings.Contains(timing, "handle;dur=") || !strings.Contains(timing, "total;dur=") {
+		t.Errorf("Unexpected Server-Timing header for traced request: '%s'", timing)
+	}
+	response := web.JSONResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %s", err.Error())
+	}
+	if traced, _ := response.Data.(bool); !traced {
+		t.Errorf("Request was not marked as traced")
+	}
+
+	if resp := request(server, false); resp.Header.Get("Server-Timing") != "" {
+		t.Errorf("Unexpected Server-Timing header for request without trace header")
+	}
+	if resp := request(untrustedServer, true); resp.Header.Get("Server-Timing") != "" {
+		t.Errorf("Unexpected Server-Timing header for request from untrusted source")
+	}
+}
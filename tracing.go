@@ -0,0 +1,143 @@
+package web
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Tracer describes an interface for distributed tracing of requests, such as an adapter for OpenTelemetry. When set on
+// the server, a span is started for every request to a registered API, HTTP, HTTPEasy, or Socket route.
+type Tracer interface {
+	// StartSpan starts a new span named name, such as "GET /users/:id", for the request. Parent is the trace context
+	// from the W3C 'traceparent' header of the request, and is not valid if the header was missing or malformed. The
+	// returned context is used as the context of the request for the handle.
+	StartSpan(r *http.Request, name string, parent TraceParent) (context.Context, Span)
+}
+
+// Span describes a single span started by a [web.Tracer]
+type Span interface {
+	// SetAttribute sets an attribute on the span. Attribute keys follow the OpenTelemetry semantic conventions, such as
+	// "http.route" or "http.response.status_code".
+	SetAttribute(key string, value interface{})
+	// TraceParent returns the trace context of the span, for propagating to other services
+	TraceParent() TraceParent
+	// End ends the span. No more attributes are set after End is called.
+	End()
+}
+
+// TraceParent describes a W3C trace context, as used in the 'traceparent' header
+type TraceParent struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+}
+
+// ParseTraceParent parses the value of a W3C 'traceparent' header, such as
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01". The returned trace parent is not valid if the value is
+// malformed.
+func ParseTraceParent(value string) TraceParent {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return TraceParent{}
+	}
+	// Future versions may append fields, but version 00 has exactly four and version ff is invalid
+	if parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return TraceParent{}
+	}
+
+	var version [1]byte
+	var flags [1]byte
+	parent := TraceParent{}
+	if _, err := hex.Decode(version[:], []byte(parts[0])); err != nil {
+		return TraceParent{}
+	}
+	if _, err := hex.Decode(parent.TraceID[:], []byte(parts[1])); err != nil {
+		return TraceParent{}
+	}
+	if _, err := hex.Decode(parent.SpanID[:], []byte(parts[2])); err != nil {
+		return TraceParent{}
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return TraceParent{}
+	}
+	parent.Flags = flags[0]
+	return parent
+}
+
+// Valid returns true if the trace and span IDs are not all zeros
+func (p TraceParent) Valid() bool {
+	return p.TraceID != [16]byte{} && p.SpanID != [8]byte{}
+}
+
+// Sampled returns true if the sampled flag is set
+func (p TraceParent) Sampled() bool {
+	return p.Flags&0x01 == 0x01
+}
+
+// String returns the trace parent formatted for the W3C 'traceparent' header
+func (p TraceParent) String() string {
+	return fmt.Sprintf("00-%x-%x-%02x", p.TraceID, p.SpanID, p.Flags)
+}
+
+type spanContextKey struct{}
+
+// SpanFromContext returns the span started for the request by the Tracer of the server, or nil if there is none
+func SpanFromContext(ctx context.Context) Span {
+	span, _ := ctx.Value(spanContextKey{}).(Span)
+	return span
+}
+
+// Span returns the span started for the request by the Tracer of the server, or nil if there is no Tracer. Handles can
+// use [web.Span.TraceParent] to propagate the trace to requests they make to other services.
+func (r Request) Span() Span {
+	if r.HTTP == nil {
+		return nil
+	}
+	return SpanFromContext(r.HTTP.Context())
+}
+
+// startSpan starts a span for the request to route if the server has a Tracer, returning the request to pass to the
+// handle and the span, or nil
+func (s *Server) startSpan(method, route string, r *http.Request) (*http.Request, Span) {
+	if s.Options.Tracer == nil {
+		return r, nil
+	}
+
+	ctx, span := s.Options.Tracer.StartSpan(r, method+" "+route, ParseTraceParent(r.Header.Get("traceparent")))
+	if span == nil {
+		return r, nil
+	}
+	span.SetAttribute("http.request.method", method)
+	span.SetAttribute("http.route", route)
+	span.SetAttribute("url.path", r.URL.Path)
+	span.SetAttribute("client.address", s.realRemoteAddr(r).String())
+	if ctx == nil {
+		ctx = r.Context()
+	}
+	return r.WithContext(context.WithValue(ctx, spanContextKey{}, span)), span
+}
+
+// endSpan records the status of the response and ends the span
+func endSpan(span Span, w *responseWriter) {
+	status := w.statusCode()
+	if status == 0 && w.hijacked {
+		status = http.StatusSwitchingProtocols
+	}
+	if status != 0 {
+		span.SetAttribute("http.response.status_code", status)
+	}
+	span.End()
+}
+
+// spanUser records the identity of the authenticated user on the span of the request, if there is one
+func spanUser(r *http.Request, userData interface{}) {
+	if isUserdataNil(userData) {
+		return
+	}
+	if span := SpanFromContext(r.Context()); span != nil {
+		span.SetAttribute("enduser.id", fmt.Sprintf("%v", userData))
+	}
+}
@@ -0,0 +1,136 @@
+package web_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+type testSpan struct {
+	name       string
+	parent     web.TraceParent
+	attributes map[string]interface{}
+	ended      bool
+	lock       *sync.Mutex
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.attributes[key] = value
+}
+
+func (s *testSpan) TraceParent() web.TraceParent {
+	return web.TraceParent{
+		TraceID: s.parent.TraceID,
+		SpanID:  [8]byte{1, 2, 3, 4, 5, 6, 7, 8},
+		Flags:   s.parent.Flags,
+	}
+}
+
+func (s *testSpan) End() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.ended = true
+}
+
+type testTracer struct {
+	spans []*testSpan
+	lock  *sync.Mutex
+}
+
+func (t *testTracer) StartSpan(r *http.Request, name string, parent web.TraceParent) (context.Context, web.Span) {
+	span := &testSpan{
+		name:       name,
+		parent:     parent,
+		attributes: map[string]interface{}{},
+		lock:       &sync.Mutex{},
+	}
+	t.lock.Lock()
+	t.spans = append(t.spans, span)
+	t.lock.Unlock()
+	return r.Context(), span
+}
+
+func TestTracer(t *testing.T) {
+	t.Parallel()
+	tracer := &testTracer{lock: &sync.Mutex{}}
+	server := web.NewMockServer()
+	server.Options.Tracer = tracer
+
+	propagated := ""
+	server.API.GET("/users/:id", func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		propagated = request.Span().TraceParent().String()
+		return true, nil, nil
+	}, web.HandleOptions{
+		AuthenticateMethod: func(request *http.Request) interface{} {
+			return "alice"
+		},
+	})
+
+	req := httptest.NewRequest("GET", "/users/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if response := server.Do(req); response.Status != 200 {
+		t.Fatalf("Unexpected status code. Expected %d got %d", 200, response.Status)
+	}
+
+	if len(tracer.spans) != 1 {
+		t.Fatalf("Unexpected number of spans. Expected %d got %d", 1, len(tracer.spans))
+	}
+	span := tracer.spans[0]
+	if span.name != "GET /users/:id" {
+		t.Errorf("Unexpected span name. Expected '%s' got '%s'", "GET /users/:id", span.name)
+	}
+	if !span.ended {
+		t.Errorf("Span was not ended")
+	}
+	if !span.parent.Valid() || !span.parent.Sampled() {
+		t.Errorf("Parent trace was not propagated")
+	}
+	if expected := "00-4bf92f3577b34da6a3ce929d0e0e4736-0102030405060708-01"; propagated != expected {
+		t.Errorf("Unexpected trace parent. Expected '%s' got '%s'", expected, propagated)
+	}
+
+	expected := map[string]interface{}{
+		"http.request.method":       "GET",
+		"http.route":                "/users/:id",
+		"url.path":                  "/users/1",
+		"http.response.status_code": 200,
+		"enduser.id":                "alice",
+	}
+	for key, value := range expected {
+		if span.attributes[key] != value {
+			t.Errorf("Unexpected value for attribute '%s'. Expected '%v' got '%v'", key, value, span.attributes[key])
+		}
+	}
+}
+
+func TestParseTraceParent(t *testing.T) {
+	t.Parallel()
+
+	valid := web.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	if !valid.Valid() || valid.Sampled() {
+		t.Errorf("Unexpected trace parent %s", valid)
+	}
+	if valid.String() != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00" {
+		t.Errorf("Unexpected trace parent string '%s'", valid.String())
+	}
+
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+	}
+	for _, value := range invalid {
+		if web.ParseTraceParent(value).Valid() {
+			t.Errorf("Trace parent '%s' should not be valid", value)
+		}
+	}
+}
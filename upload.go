@@ -0,0 +1,116 @@
+package web
+
+import (
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"sort"
+)
+
+const defaultMultipartMemoryLimit int64 = 32 << 20
+
+// UploadedFile describes a file that was uploaded as part of a multipart/form-data request
+type UploadedFile struct {
+	// The name of the form field that the file was uploaded with
+	Field string
+	// The name of the file as provided by the client. Do not trust this value as a path.
+	Filename string
+	// The size of the file in bytes
+	Size int64
+	// The content type of the file as provided by the client
+	ContentType string
+
+	header *multipart.FileHeader
+}
+
+// Open will return a reader for the contents of the uploaded file. The caller must close the reader when finished.
+func (f UploadedFile) Open() (multipart.File, error) {
+	return f.header.Open()
+}
+
+// File will return the first file uploaded with the given form field name, or nil if no file was uploaded for that
+// field. The multipart body is parsed the first time that File or Files is called, subject to the
+// MultipartMemoryLimit and MaxBodyLength options of the handle.
+//
+// Returns a 400 error if the request is not a multipart/form-data request, or a 413 error if the body is too large.
+func (r Request) File(field string) (*UploadedFile, *Error) {
+	if err := r.parseMultipartForm(); err != nil {
+		return nil, err
+	}
+
+	headers := r.HTTP.MultipartForm.File[field]
+	if len(headers) == 0 {
+		return nil, nil
+	}
+	file := newUploadedFile(field, headers[0])
+	return &file, nil
+}
+
+// Files will return all files uploaded with the request, sorted by form field name. Files uploaded with the same field
+// are in the order that they appear in the request. The multipart body is parsed the first time that File or
+// Files is called, subject to the MultipartMemoryLimit and MaxBodyLength options of the handle.
+//
+// Returns a 400 error if the request is not a multipart/form-data request, or a 413 error if the body is too large.
+func (r Request) Files() ([]UploadedFile, *Error) {
+	if err := r.parseMultipartForm(); err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(r.HTTP.MultipartForm.File))
+	for field := range r.HTTP.MultipartForm.File {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	files := []UploadedFile{}
+	for _, field := range fields {
+		for _, header := range r.HTTP.MultipartForm.File[field] {
+			files = append(files, newUploadedFile(field, header))
+		}
+	}
+	return files, nil
+}
+
+func newUploadedFile(field string, header *multipart.FileHeader) UploadedFile {
+	return UploadedFile{
+		Field:       field,
+		Filename:    header.Filename,
+		Size:        header.Size,
+		ContentType: header.Header.Get("Content-Type"),
+		header:      header,
+	}
+}
+
+func (r Request) parseMultipartForm() *Error {
+	if r.HTTP.MultipartForm != nil {
+		return nil
+	}
+
+	maxMemory := r.options.MultipartMemoryLimit
+	if maxMemory <= 0 {
+		maxMemory = defaultMultipartMemoryLimit
+	}
+	if r.options.MaxBodyLength > 0 {
+		if int64(r.options.MaxBodyLength) < maxMemory {
+			maxMemory = int64(r.options.MaxBodyLength)
+		}
+		limitRequestBody(nil, r.HTTP, r.options.MaxBodyLength)
+	}
+
+	if err := r.HTTP.ParseMultipartForm(maxMemory); err != nil {
+		maxBytesError := &http.MaxBytesError{}
+		if errors.As(err, &maxBytesError) {
+			log.PError("Rejecting multipart request with oversized body", map[string]interface{}{
+				"max_length": r.options.MaxBodyLength,
+			})
+			return CommonErrors.PayloadTooLarge
+		}
+
+		log.PError("Invalid multipart request", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return CommonErrors.BadRequest
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package web_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func multipartBody(t *testing.T, files map[string][]byte) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for name, data := range files {
+		part, err := writer.CreateFormFile(name, name+".txt")
+		if err != nil {
+			t.Fatalf("Error creating form file: %s", err.Error())
+		}
+		part.Write(data)
+	}
+	writer.Close()
+	return body, writer.FormDataContentType()
+}
+
+func TestRequestFile(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	expected := []byte(randomString(32))
+
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		file, err := request.File("upload")
+		if err != nil {
+			return nil, nil, err
+		}
+		if file == nil {
+			t.Errorf("No file returned for upload field")
+			return nil, nil, web.CommonErrors.BadRequest
+		}
+		if file.Filename != "upload.txt" {
+			t.Errorf("Unexpected filename. Expected '%s' got '%s'", "upload.txt", file.Filename)
+		}
+		if file.Size != int64(len(expected)) {
+			t.Errorf("Unexpected file size. Expected %d got %d", len(expected), file.Size)
+		}
+		reader, openErr := file.Open()
+		if openErr != nil {
+			t.Errorf("Error opening uploaded file: %s", openErr.Error())
+			return nil, nil, web.CommonErrors.ServerError
+		}
+		defer reader.Close()
+		data, _ := io.ReadAll(reader)
+		if !bytes.Equal(data, expected) {
+			t.Errorf("Unexpected file contents")
+		}
+
+		missing, _ := request.File("missing")
+		if missing != nil {
+			t.Errorf("File returned for missing field")
+		}
+
+		files, _ := request.Files()
+		if len(files) != 2 {
+			t.Errorf("Unexpected number of files. Expected %d got %d", 2, len(files))
+		} else if files[0].Field != "other" || files[1].Field != "upload" {
+			t.Errorf("Unexpected order of files. Expected 'other', 'upload' got '%s', '%s'", files[0].Field, files[1].Field)
+		}
+		return true, nil, nil
+	}
+
+	path := randomString(5)
+	server.API.POST("/"+path, handle, web.HandleOptions{})
+
+	body, contentType := multipartBody(t, map[string][]byte{
+		"upload": expected,
+		"other":  []byte("other"),
+	})
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path), contentType, body)
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("Unexpected status code. Expected %d got %d", 200, resp.StatusCode)
+	}
+}
+
+func TestRequestFileTooLarge(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		_, err := request.File("upload")
+		return nil, nil, err
+	}
+
+	path := randomString(5)
+	server.API.POST("/"+path, handle, web.HandleOptions{
+		MaxBodyLength: 64,
+	})
+
+	body, contentType := multipartBody(t, map[string][]byte{
+		"upload": []byte(randomString(128)),
+	})
+	// Use a reader without a known length to force a chunked request
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path), contentType, io.MultiReader(body))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 413 {
+		t.Fatalf("Unexpected status code. Expected %d got %d", 413, resp.StatusCode)
+	}
+}
+
+func TestRequestFileNotMultipart(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	handle := func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		_, err := request.Files()
+		return nil, nil, err
+	}
+
+	path := randomString(5)
+	server.API.POST("/"+path, handle, web.HandleOptions{})
+
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path), "application/json", bytes.NewReader([]byte("{}")))
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 400 {
+		t.Fatalf("Unexpected status code. Expected %d got %d", 400, resp.StatusCode)
+	}
+}
@@ -1,14 +1,20 @@
 package web
 
 import (
+	"io"
 	"net"
 	"net/http"
+	"strings"
+	"sync/atomic"
 )
 
 // RealRemoteAddr will try to get the real IP address of the incoming connection taking proxies into
 // consideration. This function looks for the `X-Real-IP`, `X-Forwarded-For`, and `CF-Connecting-IP`
 // headers, and if those don't exist will return the remote address of the connection.
 //
+// The headers are trusted unconditionally, which allows any client to provide its own address. Prefer
+// [web.Request.RealRemoteAddr], which honors the TrustedProxies option of the server.
+//
 // Will never return nil, if it is unable to get a valid address it will return 0.0.0.0
 func RealRemoteAddr(r *http.Request) net.IP {
 	if ip := net.ParseIP(r.Header.Get("X-Real-IP")); ip != nil {
@@ -21,6 +27,11 @@ func RealRemoteAddr(r *http.Request) net.IP {
 		return ip
 	}
 
+	return peerAddr(r)
+}
+
+// peerAddr returns the address of the directly connected peer of the request
+func peerAddr(r *http.Request) net.IP {
 	ipStr, _, _ := net.SplitHostPort(r.RemoteAddr)
 	if ip := net.ParseIP(ipStr); ip != nil {
 		return ip
@@ -28,3 +39,133 @@ func RealRemoteAddr(r *http.Request) net.IP {
 
 	return net.IPv4(0, 0, 0, 0)
 }
+
+// realRemoteAddr returns the real IP address of the request, only considering forwarding headers if the request came
+// from a trusted proxy. If the server has no trusted proxies configured, this is the same as [web.RealRemoteAddr].
+func (s *Server) realRemoteAddr(r *http.Request) net.IP {
+	if len(s.Options.TrustedProxies) == 0 {
+		return RealRemoteAddr(r)
+	}
+
+	trusted := s.trustedProxies.get(s.Options.TrustedProxies)
+	isTrusted := func(ip net.IP) bool {
+		return networksContain(trusted, ip)
+	}
+
+	peer := peerAddr(r)
+	if !isTrusted(peer) {
+		return peer
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip
+	}
+
+	// X-Forwarded-For may contain a list of addresses with each proxy appending the address of its peer, so the client
+	// is the right-most address that isn't one of our proxies
+	if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+		addrs := strings.Split(forwardedFor, ",")
+		for i := len(addrs) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(addrs[i]))
+			if ip == nil {
+				break
+			}
+			if !isTrusted(ip) || i == 0 {
+				return ip
+			}
+		}
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("CF-Connecting-IP"))); ip != nil {
+		return ip
+	}
+
+	return peer
+}
+
+// networkCache holds the parsed networks of a server option, so that they are only parsed again when the option is
+// replaced with a different list
+type networkCache struct {
+	parsed atomic.Pointer[parsedNetworks]
+}
+
+type parsedNetworks struct {
+	addresses []string
+	networks  []*net.IPNet
+}
+
+func newNetworkCache() *networkCache {
+	return &networkCache{}
+}
+
+// get returns the parsed networks of addresses, parsing them only if addresses is not the same list as last time
+func (c *networkCache) get(addresses []string) []*net.IPNet {
+	if parsed := c.parsed.Load(); parsed != nil && sameList(parsed.addresses, addresses) {
+		return parsed.networks
+	}
+	networks := parseNetworks(addresses)
+	c.parsed.Store(&parsedNetworks{addresses: addresses, networks: networks})
+	return networks
+}
+
+// sameList returns true if a and b are the same slice, rather than slices with equal values
+func sameList(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	return len(a) == 0 || &a[0] == &b[0]
+}
+
+// parseNetworks parses a list of CIDR ranges or IP addresses, ignoring any invalid entry
+func parseNetworks(addresses []string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(addresses))
+	for _, address := range addresses {
+		if !strings.Contains(address, "/") {
+			ip := net.ParseIP(address)
+			if ip == nil {
+				continue
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(address)
+		if err != nil {
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// networksContain returns true if any of the networks contains the IP address
+func networksContain(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// seekLength returns the number of bytes remaining in the seeker from its current position, without changing the
+// position
+func seekLength(seeker io.Seeker) (uint64, error) {
+	current, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	end, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := seeker.Seek(current, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return uint64(end - current), nil
+}
@@ -0,0 +1,288 @@
+package web
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ecnepsnai/web/router"
+)
+
+// routeVariants describes the handles registered for a method and path. The fallback handle serves requests that do
+// not match the headers of any variant.
+type routeVariants struct {
+	method   string
+	path     string
+	variants []routeVariant
+	fallback router.Handle
+	// If the fallback is registered with the router directly, without dispatching to variants
+	direct bool
+	vary   []string
+	// The total weight of all weighted variants
+	weight int
+	// The description and annotations from the options of the handles, and if any handle has an AuthenticateMethod
+	description   string
+	annotations   map[string]string
+	authenticated bool
+}
+
+type routeVariant struct {
+	headers map[string]string
+	handle  router.Handle
+	// Weighted variants serve requests in the range [offset, offset+weight) out of 100
+	weight    int
+	offset    int
+	weightKey func(request *http.Request) string
+}
+
+type variantRegistry struct {
+	routes map[string]*routeVariants
+	lock   *sync.RWMutex
+}
+
+func newVariantRegistry() *variantRegistry {
+	return &variantRegistry{
+		routes: map[string]*routeVariants{},
+		lock:   &sync.RWMutex{},
+	}
+}
+
+// registerRoute registers the handle with the router for the method and path. Handles with MatchHeaders or a Weight are
+// added as variants of the route, which is then dispatched by the headers of each request.
+//
+// Will panic if a handle with the same MatchHeaders is already registered for the method and path, or if the weight of
+// the variant is not valid.
+func (s *Server) registerRoute(method, path string, handle router.Handle, options HandleOptions) {
+	key := routeKey(method, path)
+	s.variants.lock.Lock()
+	defer s.variants.lock.Unlock()
+
+	if options.Weight < 0 || options.Weight > 100 {
+		panic("Variant weight must be between 1 and 100")
+	}
+	if options.Weight > 0 && options.Variant == "" {
+		panic("Weighted variants must have a name")
+	}
+
+	route, exists := s.variants.routes[key]
+	if !exists {
+		route = &routeVariants{method: method, path: path}
+		s.variants.routes[key] = route
+	}
+	if route.description == "" {
+		route.description = options.Description
+	}
+	for name, value := range options.Annotations {
+		if route.annotations == nil {
+			route.annotations = map[string]string{}
+		}
+		route.annotations[name] = value
+	}
+	route.authenticated = route.authenticated || options.AuthenticateMethod != nil
+
+	if len(options.MatchHeaders) == 0 && options.Weight == 0 {
+		if route.fallback != nil {
+			panic("Handle already registered for method and path")
+		}
+		route.fallback = handle
+		if !exists {
+			route.direct = true
+			s.router.Handle(method, path, handle)
+		}
+		return
+	}
+
+	variant := routeVariant{
+		headers: options.MatchHeaders,
+		handle:  handle,
+	}
+	if options.Weight > 0 {
+		if route.weight+options.Weight > 100 {
+			panic("Total weight of variants exceeds 100")
+		}
+		variant.weight = options.Weight
+		variant.offset = route.weight
+		variant.weightKey = options.WeightKey
+		route.weight += options.Weight
+	} else {
+		variantKey := matchHeadersKey(options.MatchHeaders)
+		for _, existing := range route.variants {
+			if existing.weight == 0 && matchHeadersKey(existing.headers) == variantKey {
+				panic("Handle already registered for method, path, and headers")
+			}
+		}
+	}
+	route.variants = append(route.variants, variant)
+	for name := range options.MatchHeaders {
+		name = http.CanonicalHeaderKey(name)
+		if !slices.Contains(route.vary, name) {
+			route.vary = append(route.vary, name)
+		}
+	}
+	log.PDebug("Register route variant", map[string]interface{}{
+		"method":  method,
+		"path":    path,
+		"headers": options.MatchHeaders,
+		"weight":  options.Weight,
+		"variant": options.Variant,
+	})
+
+	if route.direct {
+		// The route was registered before it had any variants
+		s.router.RemoveHandle(method, path)
+		route.direct = false
+		exists = false
+	}
+	if !exists {
+		s.router.Handle(method, path, s.dispatchVariants(route))
+	}
+}
+
+// unregisterRoute removes the handle and all variants registered for the method and path from the router. Returns false
+// if nothing was registered for the method and path.
+func (s *Server) unregisterRoute(method, path string) bool {
+	key := routeKey(method, path)
+	s.variants.lock.Lock()
+	defer s.variants.lock.Unlock()
+
+	if _, exists := s.variants.routes[key]; !exists {
+		return false
+	}
+	delete(s.variants.routes, key)
+	s.router.RemoveHandle(method, path)
+	log.PDebug("Unregister route", map[string]interface{}{
+		"method": method,
+		"path":   path,
+	})
+	return true
+}
+
+// routeKey returns a key identifying the method and path of a route, ignoring the names of any parameters
+func routeKey(method, path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if kind, parameterType := parameterSegment(segment); kind != 0 {
+			segments[i] = string(kind) + parameterType
+		}
+	}
+	return method + " " + strings.Join(segments, "/")
+}
+
+// parameterSegment returns the kind of parameter of a segment of a registered path, either ':' or '*', and the type of
+// the parameter if it has one, or 0 if the segment is not a parameter. Parameters in braces are described by
+// [router.Server.Handle].
+func parameterSegment(segment string) (kind byte, parameterType string) {
+	if len(segment) <= 1 {
+		return 0, ""
+	}
+	if segment[0] == ':' || segment[0] == '*' {
+		return segment[0], ""
+	}
+	if len(segment) <= 2 || segment[0] != '{' || segment[len(segment)-1] != '}' {
+		return 0, ""
+	}
+	inner := segment[1 : len(segment)-1]
+	if strings.HasSuffix(inner, "...") {
+		return '*', ""
+	}
+	_, parameterType, _ = strings.Cut(inner, ":")
+	return ':', parameterType
+}
+
+// dispatchVariants returns a handle that calls the first variant of the route, in order of registration, that matches
+// the request, or the fallback handle
+func (s *Server) dispatchVariants(route *routeVariants) router.Handle {
+	return func(w http.ResponseWriter, r router.Request) {
+		s.variants.lock.RLock()
+		variants := route.variants
+		fallback := route.fallback
+		vary := route.vary
+		weighted := route.weight > 0
+		s.variants.lock.RUnlock()
+
+		for _, name := range vary {
+			w.Header().Add("Vary", name)
+		}
+		// Weighted variants without a key share one random bucket so that their weights are not skewed
+		random := 0
+		if weighted {
+			random = rand.IntN(100)
+		}
+		for _, variant := range variants {
+			if variant.matches(r.HTTP, random) {
+				variant.handle(w, r)
+				return
+			}
+		}
+		if fallback != nil {
+			fallback(w, r)
+			return
+		}
+		s.notFoundHandle(w, r.HTTP)
+	}
+}
+
+// matches returns true if every header of the variant matches the request, and the request is selected for the
+// weight of the variant
+func (v routeVariant) matches(r *http.Request, random int) bool {
+	for name, value := range v.headers {
+		if !headerMatches(r, name, value) {
+			return false
+		}
+	}
+	if v.weight == 0 {
+		return true
+	}
+	bucket := weightBucket(r, v.weightKey, random)
+	return bucket >= v.offset && bucket < v.offset+v.weight
+}
+
+// weightBucket returns the bucket of the request, from 0 to 99, used to select weighted variants. Requests with the
+// same key are always in the same bucket, requests without a key use the random bucket.
+func weightBucket(r *http.Request, weightKey func(request *http.Request) string, random int) int {
+	key := ""
+	if weightKey != nil {
+		key = weightKey(r)
+	}
+	if key == "" {
+		return random
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	return int(hash.Sum32() % 100)
+}
+
+// headerMatches returns true if the request has the header and any of its comma-separated values, without parameters,
+// is equal to value, ignoring case. A value of "*" matches any value.
+func headerMatches(r *http.Request, name, value string) bool {
+	values := r.Header.Values(name)
+	if len(values) == 0 {
+		return false
+	}
+	if value == "*" {
+		return true
+	}
+	for _, header := range values {
+		for _, element := range strings.Split(header, ",") {
+			element, _, _ = strings.Cut(element, ";")
+			if strings.EqualFold(strings.TrimSpace(element), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchHeadersKey returns a string uniquely identifying the set of header matchers
+func matchHeadersKey(headers map[string]string) string {
+	pairs := make([]string, 0, len(headers))
+	for name, value := range headers {
+		pairs = append(pairs, http.CanonicalHeaderKey(name)+"="+strings.ToLower(value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
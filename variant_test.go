@@ -0,0 +1,144 @@
+package web_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestRouteVariants(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	handle := func(name string) web.APIHandle {
+		return func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+			return name, nil, nil
+		}
+	}
+
+	// Stable registered before its variants
+	server.API.GET("/users", handle("stable"), web.HandleOptions{})
+	server.API.GET("/users", handle("beta"), web.HandleOptions{
+		MatchHeaders: map[string]string{"X-Api-Channel": "beta"},
+	})
+	server.API.GET("/users", handle("v2"), web.HandleOptions{
+		MatchHeaders: map[string]string{"Accept": "application/vnd.example.v2+json"},
+	})
+	// Variant registered without any stable handle
+	server.API.GET("/canary", handle("canary"), web.HandleOptions{
+		MatchHeaders: map[string]string{"X-Canary": "*"},
+	})
+
+	check := func(path string, headers map[string]string, expectedStatus int, expectedData string) {
+		req := httptest.NewRequest("GET", path, nil)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		response := server.Do(req)
+		if response.Status != expectedStatus {
+			t.Errorf("Unexpected status code for %s %v. Expected %d got %d", path, headers, expectedStatus, response.Status)
+			return
+		}
+		if expectedStatus != 200 {
+			return
+		}
+		result, err := response.JSON()
+		if err != nil {
+			t.Fatalf("Error decoding response: %s", err.Error())
+		}
+		if result.Data != expectedData {
+			t.Errorf("Unexpected handle for %s %v. Expected '%s' got '%v'", path, headers, expectedData, result.Data)
+		}
+		if path == "/users" && response.Header.Values("Vary") == nil {
+			t.Errorf("No Vary header for route with variants")
+		}
+	}
+
+	check("/users", nil, 200, "stable")
+	check("/users", map[string]string{"X-Api-Channel": "stable"}, 200, "stable")
+	check("/users", map[string]string{"X-Api-Channel": "BETA"}, 200, "beta")
+	check("/users", map[string]string{"Accept": "text/html, application/vnd.example.v2+json;q=0.9"}, 200, "v2")
+	check("/canary", map[string]string{"X-Canary": "1"}, 200, "canary")
+	check("/canary", nil, 404, "")
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("No panic registering duplicate variant")
+		}
+	}()
+	server.API.GET("/users", handle("beta"), web.HandleOptions{
+		MatchHeaders: map[string]string{"x-api-channel": "Beta"},
+	})
+}
+
+func TestRouteVariantsWeighted(t *testing.T) {
+	t.Parallel()
+	server := web.NewMockServer()
+
+	handle := func(name string) web.APIHandle {
+		return func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+			return name, nil, nil
+		}
+	}
+	userKey := func(request *http.Request) string {
+		return request.Header.Get("X-User")
+	}
+
+	server.API.GET("/search", handle("stable"), web.HandleOptions{})
+	server.API.GET("/search", handle("canary"), web.HandleOptions{
+		Weight:    20,
+		WeightKey: userKey,
+		Variant:   "canary",
+	})
+
+	served := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		req := httptest.NewRequest("GET", "/search", nil)
+		user := fmt.Sprintf("user-%d", i)
+		req.Header.Set("X-User", user)
+		result, err := server.Do(req).JSON()
+		if err != nil {
+			t.Fatalf("Error decoding response: %s", err.Error())
+		}
+		name := result.Data.(string)
+		served[name]++
+
+		// The same user is always served by the same variant
+		for j := 0; j < 3; j++ {
+			req := httptest.NewRequest("GET", "/search", nil)
+			req.Header.Set("X-User", user)
+			if again, _ := server.Do(req).JSON(); again.Data != name {
+				t.Fatalf("Request for %s served by '%v', previously '%s'", user, again.Data, name)
+			}
+		}
+	}
+	if served["canary"] < 100 || served["canary"] > 300 {
+		t.Errorf("Unexpected number of requests for canary variant. Expected about %d got %d", 200, served["canary"])
+	}
+
+	for _, route := range server.RouteMetrics() {
+		if route.Path != "/search" {
+			continue
+		}
+		expected := uint64(served["stable"] * 4)
+		if route.Variant == "canary" {
+			expected = uint64(served["canary"] * 4)
+		}
+		if route.Requests != expected {
+			t.Errorf("Unexpected number of requests for variant '%s'. Expected %d got %d", route.Variant, expected, route.Requests)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("No panic registering variants with a total weight over 100")
+		}
+	}()
+	server.API.GET("/search", handle("other"), web.HandleOptions{
+		Weight:  90,
+		Variant: "other",
+	})
+}
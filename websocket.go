@@ -1,10 +1,13 @@
 package web
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
 	"fmt"
 	"net/http"
 	"runtime/debug"
+	"sync"
+	"time"
 
 	"github.com/ecnepsnai/web/router"
 	"github.com/gorilla/websocket"
@@ -13,6 +16,181 @@ import (
 // WSConn describes a websocket connection.
 type WSConn struct {
 	*websocket.Conn
+
+	schema       *SocketSchema
+	userData     interface{}
+	onReauth     func(userData interface{})
+	onReauthLock *sync.Mutex
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+// socketRegistry tracks the open websocket connections of the server, so that they can be closed on shutdown
+type socketRegistry struct {
+	conns   map[*WSConn]struct{}
+	closing bool
+	lock    *sync.Mutex
+	active  *sync.WaitGroup
+}
+
+func newSocketRegistry() *socketRegistry {
+	return &socketRegistry{
+		conns:  map[*WSConn]struct{}{},
+		lock:   &sync.Mutex{},
+		active: &sync.WaitGroup{},
+	}
+}
+
+// add tracks the connection until remove is called. Returns false if the server is shutting down, in which case the
+// connection is not tracked.
+func (r *socketRegistry) add(conn *WSConn) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closing {
+		return false
+	}
+	r.conns[conn] = struct{}{}
+	r.active.Add(1)
+	return true
+}
+
+// remove stops tracking the connection once its handle has returned
+func (r *socketRegistry) remove(conn *WSConn) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.conns[conn]; ok {
+		delete(r.conns, conn)
+		r.active.Done()
+	}
+}
+
+// open returns the tracked connections
+func (r *socketRegistry) open() []*WSConn {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	conns := make([]*WSConn, 0, len(r.conns))
+	for conn := range r.conns {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// shutdownMessage returns the close message sent to websocket connections when the server shuts down
+func (s *Server) shutdownMessage() []byte {
+	code := s.Options.SocketShutdownCode
+	if code == 0 {
+		code = websocket.CloseGoingAway
+	}
+	reason := s.Options.SocketShutdownReason
+	if reason == "" {
+		reason = "server shutting down"
+	}
+	return websocket.FormatCloseMessage(code, reason)
+}
+
+// closeSockets sends a close message to all open websocket connections, waits for their handles to return until the
+// grace period elapses or ctx is done, and then closes any remaining connections. New connections are closed as soon
+// as they are upgraded.
+func (s *Server) closeSockets(ctx context.Context) {
+	s.sockets.lock.Lock()
+	s.sockets.closing = true
+	s.sockets.lock.Unlock()
+
+	conns := s.sockets.open()
+	if len(conns) == 0 {
+		return
+	}
+	log.PInfo("Closing websocket connections", map[string]interface{}{
+		"connections": len(conns),
+	})
+	message := s.shutdownMessage()
+	for _, conn := range conns {
+		conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+	}
+
+	gracePeriod := s.Options.SocketShutdownGracePeriod
+	if gracePeriod <= 0 {
+		gracePeriod = 5 * time.Second
+	}
+	done := make(chan struct{})
+	go func() {
+		s.sockets.active.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(gracePeriod)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	remaining := s.sockets.open()
+	log.PWarn("Closing websocket connections that did not close within the grace period", map[string]interface{}{
+		"connections": len(remaining),
+	})
+	for _, conn := range remaining {
+		conn.Close()
+	}
+}
+
+// OnReauthenticate sets a method to be called with the refreshed user data each time the connection is successfully
+// re-authenticated, as configured by the WebSocketReauthInterval handle option.
+func (c *WSConn) OnReauthenticate(fn func(userData interface{})) {
+	c.onReauthLock.Lock()
+	defer c.onReauthLock.Unlock()
+	c.onReauth = fn
+}
+
+// currentUserData returns the user data of the connection, as refreshed by the last re-authentication
+func (c *WSConn) currentUserData() interface{} {
+	c.onReauthLock.Lock()
+	defer c.onReauthLock.Unlock()
+	return c.userData
+}
+
+// reauthenticate periodically calls the authenticate method of the handle until done is closed, closing the connection
+// if the session is no longer valid
+func (s *Server) reauthenticate(conn *WSConn, r *http.Request, options HandleOptions, done chan struct{}) {
+	ticker := time.NewTicker(options.WebSocketReauthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		userData := options.AuthenticateMethod(r)
+		if isUserdataNil(userData) {
+			log.PWarn("Closing websocket connection after failed reauthentication", map[string]interface{}{
+				"url":         r.URL,
+				"remote_addr": s.realRemoteAddr(r),
+			})
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session expired"), time.Now().Add(time.Second))
+			conn.Close()
+			return
+		}
+		if err := authorize(userData, r, options); err != nil {
+			log.PWarn("Closing websocket connection after failed reauthorization", map[string]interface{}{
+				"url":         r.URL,
+				"remote_addr": s.realRemoteAddr(r),
+				"message":     err.Message,
+			})
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "forbidden"), time.Now().Add(time.Second))
+			conn.Close()
+			return
+		}
+
+		conn.onReauthLock.Lock()
+		conn.userData = userData
+		onReauth := conn.onReauth
+		conn.onReauthLock.Unlock()
+		if onReauth != nil {
+			onReauth(userData)
+		}
+	}
 }
 
 // Socket register a new websocket server at the given path
@@ -20,29 +198,94 @@ func (s *Server) Socket(path string, handle SocketHandle, options HandleOptions)
 	s.registerSocketEndpoint("GET", path, handle, options)
 }
 
+// SocketT register a new websocket server at the given path for request/response style sockets. Each JSON message
+// from the client is decoded into In and passed to handle, and the result is sent back to the client as a
+// [web.JSONResponse]. Messages that cannot be decoded are answered with a validation error. The connection is closed
+// once the client disconnects.
+//
+// Go does not permit type parameters on methods, so unlike other handles SocketT is a function that takes the server.
+func SocketT[In, Out any](s *Server, path string, handle SocketMessageHandle[In, Out], options HandleOptions) {
+	s.registerSocketEndpoint("GET", path, func(request Request, conn *WSConn) {
+		defer conn.Close()
+		for {
+			_, data, err := conn.readValidMessage()
+			if err != nil {
+				return
+			}
+
+			var message In
+			if err := s.jsonDecoder().NewDecoder(bytes.NewReader(data)).Decode(&message); err != nil {
+				if err := s.writeSocketJSON(conn, JSONResponse{Error: ValidationError("%s", err.Error())}); err != nil {
+					return
+				}
+				continue
+			}
+
+			out, handleErr := handle(request, message)
+			response := JSONResponse{Data: out}
+			if handleErr != nil {
+				response = JSONResponse{Error: handleErr}
+			}
+			if err := s.writeSocketJSON(conn, response); err != nil {
+				return
+			}
+		}
+	}, options)
+}
+
+// writeSocketJSON writes v as a text message to the connection using the JSON encoder of the server
+func (s *Server) writeSocketJSON(conn *WSConn, v interface{}) error {
+	w, err := conn.NextWriter(websocket.TextMessage)
+	if err != nil {
+		return err
+	}
+	if err := s.jsonEncoder().NewEncoder(w).Encode(v); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
 func (s *Server) registerSocketEndpoint(method string, path string, handle SocketHandle, options HandleOptions) {
 	log.PDebug("Register websocket", map[string]interface{}{
 		"method": method,
 		"path":   path,
 	})
-	s.router.Handle(method, path, s.socketHandler(handle, options))
+	s.registerRoute(method, path, s.measure(method, path, options, s.socketHandler(handle, options)), options)
 }
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
+// socketUpgrader returns the websocket upgrader for the options of the handle
+func socketUpgrader(options HandleOptions) websocket.Upgrader {
+	return websocket.Upgrader{
+		ReadBufferSize:    1024,
+		WriteBufferSize:   1024,
+		Subprotocols:      options.WebSocketSubprotocols,
+		EnableCompression: options.WebSocketCompression,
+	}
 }
 
 func (s *Server) socketHandler(endpointHandle SocketHandle, options HandleOptions) router.Handle {
+	upgrader := socketUpgrader(options)
 	return func(w http.ResponseWriter, r router.Request) {
+		var userData interface{}
 		defer func() {
 			if err := recover(); err != nil {
+				stack := debug.Stack()
 				log.PError("Recovered from panic during websocket handle", map[string]interface{}{
 					"error":  fmt.Sprintf("%v", err),
 					"route":  r.HTTP.URL.Path,
 					"method": r.HTTP.Method,
-					"stack":  string(debug.Stack()),
+					"stack":  string(stack),
 				})
+				s.reportPanic(err, stack, Request{
+					HTTP:       r.HTTP,
+					Parameters: r.Parameters,
+					UserData:   userData,
+					server:     s,
+					options:    options,
+					decoder:    s.jsonDecoder(),
+				}, options)
+				setResponseError(w, fmt.Sprintf("%v", err))
 				w.WriteHeader(500)
 			}
 		}()
@@ -53,24 +296,34 @@ func (s *Server) socketHandler(endpointHandle SocketHandle, options HandleOption
 			}
 		}
 
-		var userData interface{}
+		if s.isUnavailable(w, r.HTTP, options) {
+			return
+		}
 
-		if s.isRateLimited(w, r.HTTP) {
+		if s.isBanned(w, r.HTTP) {
+			return
+		}
+
+		if s.isRateLimited(w, r.HTTP, options) {
 			return
 		}
 
 		if options.AuthenticateMethod != nil {
-			userData = options.AuthenticateMethod(r.HTTP)
+			userData = s.authenticate(w, r.HTTP, options)
+			if s.isUserRateLimited(w, r.HTTP, userData, options) {
+				return
+			}
 			if isUserdataNil(userData) {
+				s.reportRejected(r.HTTP, RejectedUnauthenticated, 401)
 				if options.UnauthorizedMethod == nil {
 					log.PWarn("Rejected request to authenticated websocket endpoint", map[string]interface{}{
 						"url":         r.HTTP.URL,
 						"method":      r.HTTP.Method,
-						"remote_addr": RealRemoteAddr(r.HTTP),
+						"remote_addr": s.realRemoteAddr(r.HTTP),
 					})
 					w.Header().Set("Content-Type", "application/json")
 					w.WriteHeader(http.StatusUnauthorized)
-					json.NewEncoder(w).Encode(Error{401, "Unauthorized"})
+					s.jsonEncoder().NewEncoder(w).Encode(s.serializeError(&Error{Code: 401, Message: "Unauthorized"}, Error{Code: 401, Message: "Unauthorized"}))
 					return
 				}
 
@@ -78,26 +331,61 @@ func (s *Server) socketHandler(endpointHandle SocketHandle, options HandleOption
 				return
 			}
 		}
+		spanUser(r.HTTP, userData)
+		if s.isForbidden(w, r.HTTP, userData, options) || selfTestHandled(w, r.HTTP) {
+			return
+		}
 
 		conn, err := upgrader.Upgrade(w, r.HTTP, nil)
 		if err != nil {
 			log.PError("Error upgrading client for websocket connection", map[string]interface{}{
 				"error":       err.Error(),
-				"remote_addr": RealRemoteAddr(r.HTTP),
+				"remote_addr": s.realRemoteAddr(r.HTTP),
 			})
 			return
 		}
+		wsConn := &WSConn{
+			Conn:         conn,
+			schema:       options.SocketSchema,
+			userData:     userData,
+			onReauthLock: &sync.Mutex{},
+		}
+		if !s.sockets.add(wsConn) {
+			conn.WriteControl(websocket.CloseMessage, s.shutdownMessage(), time.Now().Add(time.Second))
+			conn.Close()
+			return
+		}
+		defer s.sockets.remove(wsConn)
+		wsConn.applyLimits(options)
+		if options.WebSocketCompression && options.WebSocketCompressionLevel != 0 {
+			if err := conn.SetCompressionLevel(options.WebSocketCompressionLevel); err != nil {
+				log.PError("Invalid websocket compression level", map[string]interface{}{
+					"level": options.WebSocketCompressionLevel,
+					"error": err.Error(),
+				})
+			}
+		}
+		done := make(chan struct{})
+		defer close(done)
+		if options.AuthenticateMethod != nil && options.WebSocketReauthInterval > 0 {
+			go s.reauthenticate(wsConn, r.HTTP, options, done)
+		}
+		if options.WebSocketPingInterval > 0 {
+			go wsConn.keepalive(options.WebSocketPingInterval, done)
+		}
 		endpointHandle(Request{
 			Parameters: r.Parameters,
 			UserData:   userData,
-		}, &WSConn{
-			conn,
-		})
+			server:     s,
+			options:    options,
+			decoder:    s.jsonDecoder(),
+			traced:     isTraced(w),
+		}, wsConn)
 		if !options.DontLogRequests {
 			log.PWrite(s.Options.RequestLogLevel, "Websocket request", map[string]interface{}{
 				"method":      r.HTTP.Method,
 				"url":         r.HTTP.RequestURI,
-				"remote_addr": RealRemoteAddr(r.HTTP),
+				"remote_addr": s.realRemoteAddr(r.HTTP),
 			})
 		}
 	}
@@ -1,10 +1,14 @@
 package web_test
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/ecnepsnai/web"
 	"github.com/gorilla/websocket"
@@ -190,3 +194,496 @@ func TestWebsocketPreHandle(t *testing.T) {
 		t.Fatalf("Unexpected HTTP status code. Expected %d got %d", 400, resp.StatusCode)
 	}
 }
+
+func TestWebsocketSocketSchema(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	type chatMessage struct {
+		Room    string `json:"room"`
+		Message string `json:"message"`
+	}
+	type leaveMessage struct {
+		Room string `json:"room"`
+	}
+
+	path := "/" + randomString(5)
+	server.Socket(path, func(request web.Request, conn *web.WSConn) {
+		defer conn.Close()
+
+		for {
+			messageType, message, err := conn.ReadMessageJSON()
+			if err != nil {
+				return
+			}
+			switch messageType {
+			case "chat":
+				conn.WriteJSON(map[string]string{"echo": message.(*chatMessage).Message})
+			case "leave":
+				conn.WriteJSON(map[string]string{"left": message.(*leaveMessage).Room})
+				return
+			}
+		}
+	}, web.HandleOptions{
+		SocketSchema: &web.SocketSchema{
+			Messages: map[string]interface{}{
+				"chat":  chatMessage{},
+				"leave": leaveMessage{},
+			},
+		},
+	})
+
+	conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://localhost:%d%s", server.ListenPort, path), nil)
+	if err != nil {
+		t.Fatalf("Error connecting to websocket: %s", err.Error())
+	}
+	defer conn.Close()
+
+	invalidMessages := []string{
+		`"hello"`,
+		`{"room":"general","message":"hi"}`,
+		`{"type":"shout","room":"general"}`,
+		`{"type":"chat","room":"general"}`,
+		`{"type":"chat","room":"general","message":1}`,
+		`{"type":"chat","room":"general","message":"hi","extra":true}`,
+	}
+	for _, message := range invalidMessages {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+			t.Fatalf("Error sending message to server: %s", err.Error())
+		}
+		response := web.JSONResponse{}
+		if err := conn.ReadJSON(&response); err != nil {
+			t.Fatalf("Error reading response: %s", err.Error())
+		}
+		if response.Error == nil || response.Error.Code != 400 {
+			t.Errorf("No error returned for invalid message %s", message)
+		}
+	}
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"chat","room":"general","message":"hi"}`))
+	echo := map[string]string{}
+	if err := conn.ReadJSON(&echo); err != nil {
+		t.Fatalf("Error reading response: %s", err.Error())
+	}
+	if echo["echo"] != "hi" {
+		t.Errorf("Unexpected response. Expected '%s' got '%s'", "hi", echo["echo"])
+	}
+}
+
+func TestWebsocketSocketSchemaClose(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	type chatMessage struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	}
+
+	path := "/" + randomString(5)
+	received := make(chan error, 1)
+	server.Socket(path, func(request web.Request, conn *web.WSConn) {
+		defer conn.Close()
+		message := chatMessage{}
+		received <- conn.ReadJSON(&message)
+	}, web.HandleOptions{
+		SocketSchema: &web.SocketSchema{
+			Messages: map[string]interface{}{
+				"chat": chatMessage{},
+			},
+			CloseOnInvalid: true,
+		},
+	})
+
+	conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://localhost:%d%s", server.ListenPort, path), nil)
+	if err != nil {
+		t.Fatalf("Error connecting to websocket: %s", err.Error())
+	}
+	defer conn.Close()
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"chat"}`))
+	if err := <-received; err == nil {
+		t.Errorf("No error returned to handle for invalid message")
+	}
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseInvalidFramePayloadData) {
+		t.Errorf("Connection not closed for invalid message: %v", err)
+	}
+}
+
+func TestWebsocketSocketSchemaAuthorize(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	type chatMessage struct {
+		Message string `json:"message"`
+	}
+	type kickMessage struct {
+		User string `json:"user"`
+	}
+
+	path := "/" + randomString(5)
+	server.Socket(path, func(request web.Request, conn *web.WSConn) {
+		defer conn.Close()
+
+		for {
+			messageType, _, err := conn.ReadMessageJSON()
+			if err != nil {
+				return
+			}
+			conn.WriteJSON(map[string]string{"accepted": messageType})
+		}
+	}, web.HandleOptions{
+		AuthenticateMethod: func(request *http.Request) interface{} {
+			return request.Header.Get("X-User")
+		},
+		SocketSchema: &web.SocketSchema{
+			Messages: map[string]interface{}{
+				"chat": chatMessage{},
+				"kick": kickMessage{},
+			},
+			AuthorizeMessage: func(userData interface{}, messageType string) *web.Error {
+				if messageType == "kick" && userData.(string) != "admin" {
+					return &web.Error{Message: "Moderators only"}
+				}
+				return nil
+			},
+		},
+	})
+
+	check := func(user string, message string, expectAccepted bool) {
+		header := http.Header{}
+		header.Set("X-User", user)
+		conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://localhost:%d%s", server.ListenPort, path), header)
+		if err != nil {
+			t.Fatalf("Error connecting to websocket: %s", err.Error())
+		}
+		defer conn.Close()
+
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+			t.Fatalf("Error sending message to server: %s", err.Error())
+		}
+		response := struct {
+			Accepted string     `json:"accepted"`
+			Error    *web.Error `json:"error"`
+		}{}
+		if err := conn.ReadJSON(&response); err != nil {
+			t.Fatalf("Error reading response: %s", err.Error())
+		}
+		if expectAccepted && response.Accepted == "" {
+			t.Errorf("Message %s from %s was not accepted", message, user)
+		}
+		if !expectAccepted && (response.Error == nil || response.Error.Code != 403 || response.Error.Message != "Moderators only") {
+			t.Errorf("Message %s from %s was not rejected", message, user)
+		}
+	}
+
+	check("user", `{"type":"chat","message":"hi"}`, true)
+	check("user", `{"type":"kick","user":"admin"}`, false)
+	check("admin", `{"type":"kick","user":"user"}`, true)
+}
+
+func TestWebsocketSocketT(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	type questionType struct {
+		Name string `json:"name"`
+	}
+
+	type answerType struct {
+		Greeting string `json:"greeting"`
+	}
+
+	web.SocketT(server, "/socket_typed", func(request web.Request, question questionType) (answerType, *web.Error) {
+		if question.Name == "" {
+			return answerType{}, web.ValidationError("name is required")
+		}
+		return answerType{Greeting: "Hello " + question.Name}, nil
+	}, web.HandleOptions{})
+
+	conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://localhost:%d/socket_typed", server.ListenPort), nil)
+	if err != nil {
+		t.Fatalf("Error connecting to websocket: %s", err.Error())
+	}
+	defer conn.Close()
+
+	type responseType struct {
+		Data  *answerType `json:"data"`
+		Error *web.Error  `json:"error"`
+	}
+
+	for i := 0; i < 2; i++ {
+		name := randomString(6)
+		if err := conn.WriteJSON(questionType{Name: name}); err != nil {
+			t.Fatalf("Error sending JSON message to server: %s", err.Error())
+		}
+		response := responseType{}
+		if err := conn.ReadJSON(&response); err != nil {
+			t.Fatalf("Error reading answer JSON: %s", err.Error())
+		}
+		if response.Data == nil || response.Data.Greeting != "Hello "+name {
+			t.Errorf("Unexpected response. Expected '%s' got '%+v'", "Hello "+name, response.Data)
+		}
+	}
+
+	if err := conn.WriteJSON(questionType{}); err != nil {
+		t.Fatalf("Error sending JSON message to server: %s", err.Error())
+	}
+	response := responseType{}
+	if err := conn.ReadJSON(&response); err != nil {
+		t.Fatalf("Error reading answer JSON: %s", err.Error())
+	}
+	if response.Error == nil || response.Error.Code != 400 {
+		t.Errorf("Expected validation error for handle error, got %+v", response.Error)
+	}
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"name": 1}`)); err != nil {
+		t.Fatalf("Error sending message to server: %s", err.Error())
+	}
+	response = responseType{}
+	if err := conn.ReadJSON(&response); err != nil {
+		t.Fatalf("Error reading answer JSON: %s", err.Error())
+	}
+	if response.Error == nil || response.Error.Code != 400 {
+		t.Errorf("Expected validation error for invalid message, got %+v", response.Error)
+	}
+}
+
+func TestWebsocketReauthenticate(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	var calls int32
+	authenticate := func(request *http.Request) interface{} {
+		if atomic.AddInt32(&calls, 1) > 2 {
+			return nil
+		}
+		return 1
+	}
+	var reauthenticated int32
+	server.Socket("/socket_reauth", func(request web.Request, conn *web.WSConn) {
+		conn.OnReauthenticate(func(userData interface{}) {
+			atomic.AddInt32(&reauthenticated, 1)
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}, web.HandleOptions{
+		AuthenticateMethod:      authenticate,
+		WebSocketReauthInterval: 10 * time.Millisecond,
+	})
+
+	conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://localhost:%d/socket_reauth", server.ListenPort), nil)
+	if err != nil {
+		t.Fatalf("Error connecting to websocket: %s", err.Error())
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Errorf("Unexpected error. Expected policy violation close got %v", err)
+	}
+	if reauths := atomic.LoadInt32(&reauthenticated); reauths != 1 {
+		t.Errorf("Unexpected number of reauthentications. Expected %d got %d", 1, reauths)
+	}
+}
+
+func TestWebsocketLimits(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	handle := func(request web.Request, conn *web.WSConn) {
+		defer conn.Close()
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		}
+	}
+	server.Socket("/socket_max_size", handle, web.HandleOptions{
+		WebSocketMaxMessageSize: 8,
+	})
+	server.Socket("/socket_read_timeout", handle, web.HandleOptions{
+		WebSocketReadTimeout: 50 * time.Millisecond,
+	})
+	server.Socket("/socket_ping", handle, web.HandleOptions{
+		WebSocketReadTimeout:  50 * time.Millisecond,
+		WebSocketPingInterval: 10 * time.Millisecond,
+	})
+
+	dial := func(path string) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://localhost:%d/%s", server.ListenPort, path), nil)
+		if err != nil {
+			t.Fatalf("Error connecting to websocket: %s", err.Error())
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		return conn
+	}
+
+	// Message too big
+	conn := dial("socket_max_size")
+	conn.WriteMessage(websocket.TextMessage, []byte("small"))
+	if _, message, err := conn.ReadMessage(); err != nil || string(message) != "small" {
+		t.Errorf("Unexpected response. Expected 'small' got '%s' (%v)", message, err)
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte("much too large"))
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Errorf("Unexpected error. Expected message too big close got %v", err)
+	}
+	conn.Close()
+
+	// Idle client
+	conn = dial("socket_read_timeout")
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Errorf("Expected connection to be closed after read timeout")
+	}
+	conn.Close()
+
+	// Idle client that responds to pings
+	conn = dial("socket_ping")
+	var pings int32
+	conn.SetPingHandler(func(data string) error {
+		atomic.AddInt32(&pings, 1)
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		conn.WriteMessage(websocket.TextMessage, []byte("alive"))
+	}()
+	if _, message, err := conn.ReadMessage(); err != nil || string(message) != "alive" {
+		t.Errorf("Unexpected response. Expected 'alive' got '%s' (%v)", message, err)
+	}
+	if atomic.LoadInt32(&pings) == 0 {
+		t.Errorf("No pings received from server")
+	}
+	conn.Close()
+}
+
+func TestWebsocketSubprotocolCompression(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+
+	path := "/" + randomString(5)
+	server.Socket(path, func(request web.Request, conn *web.WSConn) {
+		defer conn.Close()
+		conn.WriteMessage(websocket.TextMessage, []byte(conn.Subprotocol()+" "+strings.Repeat("a", 1024)))
+	}, web.HandleOptions{
+		WebSocketSubprotocols:     []string{"chat.v2", "chat.v1"},
+		WebSocketCompression:      true,
+		WebSocketCompressionLevel: 9,
+	})
+
+	dialer := websocket.Dialer{
+		Subprotocols:      []string{"chat.v1", "chat.v2"},
+		EnableCompression: true,
+	}
+	conn, resp, err := dialer.Dial(fmt.Sprintf("ws://localhost:%d%s", server.ListenPort, path), nil)
+	if err != nil {
+		t.Fatalf("Error connecting to websocket: %s", err.Error())
+	}
+	defer conn.Close()
+
+	if conn.Subprotocol() != "chat.v2" {
+		t.Errorf("Unexpected subprotocol. Expected '%s' got '%s'", "chat.v2", conn.Subprotocol())
+	}
+	if !strings.Contains(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate") {
+		t.Errorf("Compression was not negotiated")
+	}
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Error reading message: %s", err.Error())
+	}
+	if !strings.HasPrefix(string(message), "chat.v2 ") {
+		t.Errorf("Unexpected message '%s'", message[:10])
+	}
+
+	// Clients without a supported subprotocol are accepted without one
+	conn, _, err = websocket.DefaultDialer.Dial(fmt.Sprintf("ws://localhost:%d%s", server.ListenPort, path), nil)
+	if err != nil {
+		t.Fatalf("Error connecting to websocket: %s", err.Error())
+	}
+	defer conn.Close()
+	if conn.Subprotocol() != "" {
+		t.Errorf("Unexpected subprotocol '%s'", conn.Subprotocol())
+	}
+}
+
+func TestWebsocketShutdown(t *testing.T) {
+	t.Parallel()
+
+	server := web.New("127.0.0.1:0")
+	server.Options.SocketShutdownCode = websocket.CloseServiceRestart
+	server.Options.SocketShutdownReason = "deploy"
+	server.Options.SocketShutdownGracePeriod = 100 * time.Millisecond
+	listening := make(chan bool, 1)
+	server.Options.OnListen = func(address net.Addr) {
+		listening <- true
+	}
+
+	politeReturned := make(chan bool, 1)
+	server.Socket("/polite", func(request web.Request, conn *web.WSConn) {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				politeReturned <- true
+				return
+			}
+		}
+	}, web.HandleOptions{})
+	release := make(chan bool)
+	stubbornErr := make(chan error, 1)
+	server.Socket("/stubborn", func(request web.Request, conn *web.WSConn) {
+		// Ignores the close message until after the connection has been closed
+		<-release
+		_, _, err := conn.ReadMessage()
+		stubbornErr <- err
+	}, web.HandleOptions{})
+	go server.Start()
+	<-listening
+
+	polite, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://127.0.0.1:%d/polite", server.ListenPort), nil)
+	if err != nil {
+		t.Fatalf("Error connecting to websocket: %s", err.Error())
+	}
+	stubborn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://127.0.0.1:%d/stubborn", server.ListenPort), nil)
+	if err != nil {
+		t.Fatalf("Error connecting to websocket: %s", err.Error())
+	}
+	defer stubborn.Close()
+
+	politeClosed := make(chan error, 1)
+	go func() {
+		_, _, err := polite.ReadMessage()
+		politeClosed <- err
+	}()
+
+	start := time.Now()
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Unexpected error shutting down: %s", err.Error())
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("Unexpected shutdown duration %s", elapsed)
+	}
+
+	closeErr, ok := (<-politeClosed).(*websocket.CloseError)
+	if !ok || closeErr.Code != websocket.CloseServiceRestart || closeErr.Text != "deploy" {
+		t.Errorf("Unexpected close message %v", closeErr)
+	}
+	select {
+	case <-politeReturned:
+	case <-time.After(time.Second):
+		t.Errorf("Handle did not return after close message")
+	}
+
+	// Connections still open after the grace period are closed
+	close(release)
+	if err := <-stubbornErr; err == nil {
+		t.Errorf("Connection was not closed after the grace period")
+	}
+}
@@ -0,0 +1,249 @@
+package web
+
+import (
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/ecnepsnai/web/router"
+)
+
+// WorkerPoolOptions describes a bounded pool of goroutines that run the handles of the server, see the WorkerPool
+// server option
+type WorkerPoolOptions struct {
+	// The number of goroutines that run handles, which is the maximum number of requests handled at once. Required.
+	Workers int
+	// The maximum number of requests waiting for a worker once every worker is busy. Requests that arrive when the queue
+	// is full receive a "503 Service Unavailable" response. Defaults to 0, which rejects requests as soon as every
+	// worker is busy.
+	QueueLength int
+	// The maximum amount of time a request waits in the queue for a worker before it receives a "503 Service
+	// Unavailable" response. Defaults to 0, which waits until the client goes away.
+	QueueTimeout time.Duration
+}
+
+// WorkerPoolStats describes the current state of the worker pool of the server
+type WorkerPoolStats struct {
+	// The number of goroutines that run handles
+	Workers int `json:"workers"`
+	// The number of workers currently running a handle
+	Busy int64 `json:"busy"`
+	// The number of requests currently waiting for a worker
+	Queued int64 `json:"queued"`
+	// The largest number of requests that have waited for a worker at once
+	MaxQueued int64 `json:"max_queued"`
+	// The total number of requests run by a worker
+	Dispatched uint64 `json:"dispatched"`
+	// The number of requests rejected because the queue was full
+	Rejected uint64 `json:"rejected"`
+	// The number of requests rejected because they waited longer than the QueueTimeout
+	TimedOut uint64 `json:"timed_out"`
+	// The total amount of time requests run by a worker spent waiting in the queue
+	QueueWait time.Duration `json:"queue_wait"`
+	// The longest amount of time a request run by a worker spent waiting in the queue
+	MaxQueueWait time.Duration `json:"max_queue_wait"`
+}
+
+type poolJob struct {
+	run     func()
+	queued  time.Time
+	started chan struct{}
+	done    chan timeoutResult
+}
+
+type workerPool struct {
+	options WorkerPoolOptions
+	// Jobs waiting for a worker, including jobs given to an idle worker that has not yet woken up
+	queue []*poolJob
+	// The number of workers waiting for a job
+	idle  int
+	stats WorkerPoolStats
+	lock  *sync.Mutex
+	ready *sync.Cond
+}
+
+func newWorkerPool(options WorkerPoolOptions) *workerPool {
+	lock := &sync.Mutex{}
+	pool := &workerPool{
+		options: options,
+		stats:   WorkerPoolStats{Workers: options.Workers},
+		lock:    lock,
+		ready:   sync.NewCond(lock),
+	}
+	for i := 0; i < options.Workers; i++ {
+		go pool.work()
+	}
+	log.PDebug("Started worker pool", map[string]interface{}{
+		"workers":      options.Workers,
+		"queue_length": options.QueueLength,
+	})
+	return pool
+}
+
+// work runs queued jobs for the lifetime of the server
+func (p *workerPool) work() {
+	for {
+		p.lock.Lock()
+		for len(p.queue) == 0 {
+			p.idle++
+			p.ready.Wait()
+			p.idle--
+		}
+		job := p.queue[0]
+		p.queue[0] = nil
+		p.queue = p.queue[1:]
+		wait := time.Since(job.queued)
+		p.stats.Queued--
+		p.stats.Busy++
+		p.stats.Dispatched++
+		p.stats.QueueWait += wait
+		if wait > p.stats.MaxQueueWait {
+			p.stats.MaxQueueWait = wait
+		}
+		p.lock.Unlock()
+		close(job.started)
+
+		job.done <- p.runJob(job)
+
+		p.lock.Lock()
+		p.stats.Busy--
+		p.lock.Unlock()
+	}
+}
+
+// runJob runs the job, recovering any panic so that it can be repanicked in the goroutine of the request
+func (p *workerPool) runJob(job *poolJob) (result timeoutResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = timeoutResult{panic: r, stack: debug.Stack()}
+		}
+	}()
+	job.run()
+	return timeoutResult{}
+}
+
+// enqueue adds the job to the queue, returning false if the queue is full. Idle workers take jobs from the queue
+// straight away, so they don't count towards its length.
+func (p *workerPool) enqueue(job *poolJob) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if len(p.queue) >= p.options.QueueLength+p.idle {
+		p.stats.Rejected++
+		return false
+	}
+
+	p.queue = append(p.queue, job)
+	p.stats.Queued++
+	if p.stats.Queued > p.stats.MaxQueued {
+		p.stats.MaxQueued = p.stats.Queued
+	}
+	p.ready.Signal()
+	return true
+}
+
+// cancel removes a queued job from the pool, returning false if a worker has already started it
+func (p *workerPool) cancel(job *poolJob) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for i, queued := range p.queue {
+		if queued == job {
+			p.queue = append(p.queue[:i], p.queue[i+1:]...)
+			p.stats.Queued--
+			return true
+		}
+	}
+	return false
+}
+
+func (p *workerPool) currentStats() WorkerPoolStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.stats
+}
+
+// workerPool returns the worker pool of the server, starting it on first use, or nil if the server has no pool
+func (s *Server) workerPool() *workerPool {
+	if s.Options.WorkerPool == nil || s.Options.WorkerPool.Workers <= 0 {
+		return nil
+	}
+	s.poolOnce.Do(func() {
+		s.pool = newWorkerPool(*s.Options.WorkerPool)
+	})
+	return s.pool
+}
+
+// WorkerPoolStats returns the current state of the worker pool of the server, such as to monitor how many requests are
+// queued. Returns an empty value if the server does not have the WorkerPool option.
+func (s *Server) WorkerPoolStats() WorkerPoolStats {
+	pool := s.workerPool()
+	if pool == nil {
+		return WorkerPoolStats{}
+	}
+	return pool.currentStats()
+}
+
+// dispatch wraps the handle for a route to run it with the worker pool of the server, if it has one. The goroutine of
+// the request waits for the worker to finish, so the handle may use the response writer as normal.
+func (s *Server) dispatch(handle router.Handle) router.Handle {
+	return func(w http.ResponseWriter, r router.Request) {
+		pool := s.workerPool()
+		if pool == nil || isSynthetic(r.HTTP) {
+			handle(w, r)
+			return
+		}
+
+		job := &poolJob{
+			run: func() {
+				handle(w, r)
+			},
+			queued:  time.Now(),
+			started: make(chan struct{}),
+			done:    make(chan timeoutResult, 1),
+		}
+		if !pool.enqueue(job) {
+			s.rejectOverloaded(w, r.HTTP, "queue full")
+			return
+		}
+
+		var timeout <-chan time.Time
+		if pool.options.QueueTimeout > 0 {
+			timer := time.NewTimer(pool.options.QueueTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case <-job.started:
+		case <-timeout:
+			if pool.cancel(job) {
+				pool.lock.Lock()
+				pool.stats.TimedOut++
+				pool.lock.Unlock()
+				s.rejectOverloaded(w, r.HTTP, "queue timeout")
+				return
+			}
+		case <-r.HTTP.Context().Done():
+			if pool.cancel(job) {
+				return
+			}
+		}
+
+		result := <-job.done
+		if result.panic != nil {
+			panic(result.panic)
+		}
+	}
+}
+
+// rejectOverloaded writes the response for a request that could not be given to a worker of the pool
+func (s *Server) rejectOverloaded(w http.ResponseWriter, r *http.Request, reason string) {
+	log.PWarn("Rejected request because worker pool is overloaded", map[string]interface{}{
+		"remote_addr": s.realRemoteAddr(r),
+		"method":      r.Method,
+		"url":         r.URL,
+		"reason":      reason,
+	})
+	s.reportRejected(r, RejectedOverloaded, http.StatusServiceUnavailable)
+	w.Header().Set("Retry-After", "1")
+	s.writeError(w, CommonErrors.ServiceUnavailable, "Service unavailable")
+}
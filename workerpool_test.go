@@ -0,0 +1,190 @@
+package web_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/web"
+)
+
+func TestWorkerPool(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+	server.Options.WorkerPool = &web.WorkerPoolOptions{
+		Workers:     1,
+		QueueLength: 1,
+	}
+
+	release := make(chan bool)
+	path := randomString(5)
+	server.API.GET("/"+path, func(request web.Request) (interface{}, *web.APIResponse, *web.Error) {
+		<-release
+		return true, nil, nil
+	}, web.HandleOptions{})
+
+	url := fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path)
+	statuses := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			resp, err := http.Get(url)
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			statuses <- resp.StatusCode
+		}()
+	}
+
+	// Wait for one request to be running and the other to be queued
+	for i := 0; i < 100; i++ {
+		if stats := server.WorkerPoolStats(); stats.Busy == 1 && stats.Queued == 1 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 503 {
+		t.Errorf("Unexpected HTTP status code. Expected %d got %d", 503, resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Errorf("No Retry-After header for rejected request")
+	}
+
+	release <- true
+	release <- true
+	for i := 0; i < 2; i++ {
+		if status := <-statuses; status != 200 {
+			t.Errorf("Unexpected HTTP status code. Expected %d got %d", 200, status)
+		}
+	}
+
+	stats := server.WorkerPoolStats()
+	if stats.Workers != 1 {
+		t.Errorf("Unexpected number of workers. Expected %d got %d", 1, stats.Workers)
+	}
+	if stats.Dispatched != 2 {
+		t.Errorf("Unexpected number of dispatched requests. Expected %d got %d", 2, stats.Dispatched)
+	}
+	if stats.Rejected != 1 {
+		t.Errorf("Unexpected number of rejected requests. Expected %d got %d", 1, stats.Rejected)
+	}
+	if stats.MaxQueued < 1 {
+		t.Errorf("Unexpected max queued requests. Expected at least %d got %d", 1, stats.MaxQueued)
+	}
+	if server.RejectedRequests().ByReason[web.RejectedOverloaded] != 1 {
+		t.Errorf("Rejected request not recorded")
+	}
+}
+
+func TestWorkerPoolQueueTimeout(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+	server.Options.WorkerPool = &web.WorkerPoolOptions{
+		Workers:      1,
+		QueueLength:  1,
+		QueueTimeout: 20 * time.Millisecond,
+	}
+
+	release := make(chan bool)
+	path := randomString(5)
+	server.HTTP.GET("/"+path, func(w http.ResponseWriter, request web.Request) {
+		<-release
+	}, web.HandleOptions{})
+
+	url := fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path)
+	done := make(chan bool)
+	go func() {
+		http.Get(url)
+		done <- true
+	}()
+	for i := 0; i < 100; i++ {
+		if server.WorkerPoolStats().Busy == 1 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Network error: %s", err.Error())
+	}
+	if resp.StatusCode != 503 {
+		t.Errorf("Unexpected HTTP status code. Expected %d got %d", 503, resp.StatusCode)
+	}
+	if stats := server.WorkerPoolStats(); stats.TimedOut != 1 || stats.Queued != 0 {
+		t.Errorf("Unexpected worker pool stats: %+v", stats)
+	}
+
+	release <- true
+	<-done
+}
+
+func TestWorkerPoolCancelledRequest(t *testing.T) {
+	t.Parallel()
+	server := newServer()
+	server.Options.WorkerPool = &web.WorkerPoolOptions{
+		Workers:     1,
+		QueueLength: 1,
+	}
+
+	release := make(chan bool)
+	path := randomString(5)
+	server.HTTP.GET("/"+path, func(w http.ResponseWriter, request web.Request) {
+		<-release
+	}, web.HandleOptions{})
+
+	url := fmt.Sprintf("http://localhost:%d/%s", server.ListenPort, path)
+	waitFor := func(condition func(stats web.WorkerPoolStats) bool) {
+		for i := 0; i < 200; i++ {
+			if condition(server.WorkerPoolStats()) {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("Timed out waiting for worker pool: %+v", server.WorkerPoolStats())
+	}
+
+	statuses := make(chan int, 2)
+	get := func(ctx context.Context) {
+		req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			statuses <- 0
+			return
+		}
+		resp.Body.Close()
+		statuses <- resp.StatusCode
+	}
+
+	go get(context.Background())
+	waitFor(func(stats web.WorkerPoolStats) bool { return stats.Busy == 1 })
+
+	// Queue a request and abandon it
+	ctx, cancel := context.WithCancel(context.Background())
+	go get(ctx)
+	waitFor(func(stats web.WorkerPoolStats) bool { return stats.Queued == 1 })
+	cancel()
+	<-statuses
+	waitFor(func(stats web.WorkerPoolStats) bool { return stats.Queued == 0 })
+
+	// The abandoned request must not take up the queue
+	go get(context.Background())
+	waitFor(func(stats web.WorkerPoolStats) bool { return stats.Queued == 1 || stats.Rejected > 0 })
+	if stats := server.WorkerPoolStats(); stats.Rejected != 0 {
+		t.Errorf("Request rejected while queue was empty: %+v", stats)
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if status := <-statuses; status != 200 {
+			t.Errorf("Unexpected HTTP status code. Expected %d got %d", 200, status)
+		}
+	}
+}